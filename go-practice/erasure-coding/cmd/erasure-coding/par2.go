@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/par2"
+)
+
+func runPar2Create(args []string) int {
+	fs := flag.NewFlagSet("par2create", flag.ExitOnError)
+	out := fs.String("out", "", "index file to write, e.g. archive.par2")
+	slice := fs.Int64("slice", 0, "slice size in bytes, a multiple of 4 (default: about 2000 slices)")
+	recovery := fs.Int("recovery", 0, "number of recovery slices (default: 5% of the input)")
+	volumes := fs.Int("volumes", 1, "number of recovery volume files")
+	fs.Parse(args)
+	if *out == "" || fs.NArg() == 0 {
+		return fail("usage: par2create -out archive.par2 [flags] file...")
+	}
+
+	s, err := par2.Create(*out, fs.Args(), par2.CreateOptions{SliceSize: *slice, Recovery: *recovery, Volumes: *volumes})
+	if err != nil {
+		return fail("par2create: %v", err)
+	}
+	fmt.Printf("set    %x\n", s.ID)
+	fmt.Printf("input  %d files, %d slices of %d bytes\n", len(s.Files), s.InputSlices(), s.SliceSize)
+	fmt.Printf("wrote  %d recovery slices in %d volumes\n", s.RecoverySlices(), *volumes)
+	return 0
+}
+
+func runPar2Verify(args []string) int {
+	return runPar2(args, "par2verify", (*par2.Set).Verify)
+}
+
+func runPar2Repair(args []string) int {
+	return runPar2(args, "par2repair", (*par2.Set).Repair)
+}
+
+// runPar2 opens the set named on the command line, runs check against
+// the directory of its index file and prints the report
+func runPar2(args []string, name string, check func(*par2.Set, string) (*par2.Report, error)) int {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fail("usage: %s archive.par2", name)
+	}
+	s, err := par2.Open(fs.Arg(0))
+	if err != nil {
+		return fail("%s: %v", name, err)
+	}
+	rep, err := check(s, filepath.Dir(fs.Arg(0)))
+	if rep != nil {
+		for _, f := range rep.Files {
+			status := "ok"
+			switch {
+			case !f.Found:
+				status = "missing"
+			case !f.Complete:
+				status = fmt.Sprintf("damaged (%d slices)", len(f.Damaged))
+			}
+			fmt.Printf("%-40s %s\n", f.Name, status)
+		}
+		for _, r := range rep.Repaired {
+			fmt.Printf("repaired %s\n", r)
+		}
+		fmt.Printf("%d slices need recovery, %d recovery slices available\n", rep.Missing, rep.Available)
+	}
+	if err != nil {
+		return fail("%s: %v", name, err)
+	}
+	if !rep.Complete() {
+		return 1
+	}
+	return 0
+}
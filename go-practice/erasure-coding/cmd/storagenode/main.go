@@ -0,0 +1,34 @@
+// Shard storage node daemon
+//
+// Serves shard PUT/GET/DELETE and /health over HTTP from a local directory.
+// Start several on different ports to model a cluster on one machine:
+//
+//	go run ./cmd/storagenode -addr 127.0.0.1:7001 -dir /tmp/node1
+//	go run ./cmd/storagenode -addr 127.0.0.1:7002 -dir /tmp/node2
+//	go run ./cmd/storagenode -addr 127.0.0.1:7003 -dir /tmp/node3
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/storagenode"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:7001", "listen address")
+	dir := flag.String("dir", "", "directory to store shards in")
+	flag.Parse()
+
+	if *dir == "" {
+		log.Fatal("-dir is required")
+	}
+	node, err := storagenode.New(*dir)
+	if err != nil {
+		log.Fatalf("Error opening node directory: %v", err)
+	}
+
+	log.Printf("Storage node serving %s on http://%s", node.Dir(), *addr)
+	log.Fatal(http.ListenAndServe(*addr, node))
+}
@@ -0,0 +1,305 @@
+// Package audit checks that a remote node still holds a shard without
+// downloading it (proof of retrievability by spot checks).
+//
+// Before handing a shard to a node, the owner runs Prepare, which derives a
+// fixed number of challenges from a secret key and precomputes the answer
+// to each. Only the short answers are kept in a Record; the shard itself
+// can be forgotten. A challenge names a few random segments of the shard
+// and a fresh nonce:
+//
+//	nonce    16 bytes, HMAC(key, shard id, size, index) — unpredictable
+//	segments indices of the segments to read
+//
+// The node answers with HMAC-SHA256(nonce, segments...) computed from its
+// copy (Respond), and Verify compares the answer with the stored one in
+// constant time. The node cannot precompute answers, because it cannot
+// predict nonces or segment choices without the key, so it must keep the
+// sampled bytes around. A node that has lost a fraction f of the shard
+// fails one challenge with probability 1-(1-f)^s for s segments per
+// challenge (see DetectionProbability).
+//
+// Each challenge is used once: once revealed, the node could cache its
+// answer. When a Record runs out, the owner prepares a fresh one from a
+// verified copy of the shard.
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// AuditError represents errors returned by the audit package
+type AuditError struct {
+	message string
+}
+
+func (e *AuditError) Error() string {
+	return e.message
+}
+
+// Common errors
+var (
+	ErrKeyTooShort      = &AuditError{"audit key must be at least 16 bytes"}
+	ErrInvalidParams    = &AuditError{"invalid audit parameters"}
+	ErrInvalidChallenge = &AuditError{"invalid audit challenge"}
+	ErrExhausted        = &AuditError{"every challenge of the record has been used"}
+	ErrIndexOutOfRange  = &AuditError{"challenge index out of range"}
+	ErrProofMismatch    = &AuditError{"proof does not match the expected answer"}
+)
+
+// Sizes and defaults
+const (
+	MinKeySize = 16
+	NonceSize  = 16
+	ProofSize  = sha256.Size
+	// ExpectedSize is the number of proof bytes kept per challenge; 128
+	// bits is plenty against a node guessing answers
+	ExpectedSize = 16
+	// MaxSegments bounds the segments a node reads for one challenge
+	MaxSegments = 1024
+	// MaxSegmentSize bounds the segment size, and so the buffer a node
+	// allocates for a challenge it has not verified
+	MaxSegmentSize = 1 << 20
+
+	DefaultSegmentSize = 4096
+	DefaultSegments    = 8
+	DefaultChallenges  = 32
+)
+
+// Params controls how challenges are shaped; zero fields take defaults
+type Params struct {
+	// SegmentSize is the size of a sampled segment in bytes
+	SegmentSize int
+	// Segments is the number of segments sampled per challenge
+	Segments int
+	// Challenges is the number of challenges prepared per shard
+	Challenges int
+}
+
+// Auditor prepares challenge records and checks proofs; it is safe for
+// concurrent use
+type Auditor struct {
+	key    []byte
+	params Params
+}
+
+// NewAuditor creates an Auditor with a secret key
+//
+// Errors:
+//   - ErrKeyTooShort if the key has fewer than MinKeySize bytes
+//   - ErrInvalidParams for negative parameters or more than MaxSegments
+//     segments per challenge
+func NewAuditor(key []byte, p Params) (*Auditor, error) {
+	if len(key) < MinKeySize {
+		return nil, ErrKeyTooShort
+	}
+	if p.SegmentSize < 0 || p.Segments < 0 || p.Challenges < 0 || p.Segments > MaxSegments || p.SegmentSize > MaxSegmentSize {
+		return nil, ErrInvalidParams
+	}
+	if p.SegmentSize == 0 {
+		p.SegmentSize = DefaultSegmentSize
+	}
+	if p.Segments == 0 {
+		p.Segments = DefaultSegments
+	}
+	if p.Challenges == 0 {
+		p.Challenges = DefaultChallenges
+	}
+	return &Auditor{key: append([]byte(nil), key...), params: p}, nil
+}
+
+// Params returns the parameters with defaults applied
+func (a *Auditor) Params() Params {
+	return a.params
+}
+
+// Record holds the precomputed answers for one shard. It contains no
+// secrets and can be stored alongside the object's manifest.
+type Record struct {
+	ShardID     string   `json:"shardId"`
+	Size        int64    `json:"size"`
+	SegmentSize int      `json:"segmentSize"`
+	Segments    int      `json:"segments"`
+	Expected    [][]byte `json:"expected"`
+	// Used is the number of challenges already issued
+	Used int `json:"used"`
+}
+
+// Remaining returns the number of unused challenges
+func (r *Record) Remaining() int {
+	return max(len(r.Expected)-r.Used, 0)
+}
+
+// Challenge is sent to the node holding a shard
+type Challenge struct {
+	Nonce       []byte  `json:"nonce"`
+	SegmentSize int     `json:"segmentSize"`
+	Segments    []int64 `json:"segments"`
+}
+
+// Responder answers challenges for shards it stores; *storagenode.Client
+// implements it over HTTP
+type Responder interface {
+	Prove(ctx context.Context, shardID string, ch *Challenge) ([]byte, error)
+}
+
+// Prepare derives the auditor's challenges for a shard and records their
+// answers
+func (a *Auditor) Prepare(shardID string, shard []byte) *Record {
+	rec := &Record{
+		ShardID:     shardID,
+		Size:        int64(len(shard)),
+		SegmentSize: a.params.SegmentSize,
+		Segments:    a.params.Segments,
+		Expected:    make([][]byte, a.params.Challenges),
+	}
+	for i := range rec.Expected {
+		ch, _ := a.Challenge(rec, i)
+		// The shard is in memory, so reads cannot fail
+		proof, _ := respond(ch, bytes.NewReader(shard), rec.Size)
+		rec.Expected[i] = proof[:ExpectedSize]
+	}
+	return rec
+}
+
+// Challenge returns challenge i of a record. Challenges are derived from
+// the key, so the same record always yields the same challenges.
+//
+// Errors:
+//   - ErrIndexOutOfRange if i is not a challenge of the record
+func (a *Auditor) Challenge(rec *Record, i int) (*Challenge, error) {
+	if i < 0 || i >= len(rec.Expected) {
+		return nil, ErrIndexOutOfRange
+	}
+	seed := a.seed(rec, i)
+	ch := &Challenge{
+		Nonce:       seed[:NonceSize],
+		SegmentSize: rec.SegmentSize,
+		Segments:    make([]int64, rec.Segments),
+	}
+	n := uint64(segmentCount(rec.Size, rec.SegmentSize))
+	var block []byte
+	for j := range ch.Segments {
+		if j%4 == 0 {
+			mac := hmac.New(sha256.New, a.key)
+			mac.Write(seed)
+			mac.Write(binary.BigEndian.AppendUint32(nil, uint32(j/4)))
+			block = mac.Sum(nil)
+		}
+		// The modulo bias is below 2^-40 for any realistic shard
+		ch.Segments[j] = int64(binary.BigEndian.Uint64(block[(j%4)*8:]) % n)
+	}
+	return ch, nil
+}
+
+// seed is the PRF output that determines challenge i of rec
+func (a *Auditor) seed(rec *Record, i int) []byte {
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte("audit-challenge\x00"))
+	mac.Write(binary.BigEndian.AppendUint32(nil, uint32(len(rec.ShardID))))
+	mac.Write([]byte(rec.ShardID))
+	var buf [20]byte
+	binary.BigEndian.PutUint64(buf[0:], uint64(rec.Size))
+	binary.BigEndian.PutUint32(buf[8:], uint32(rec.SegmentSize))
+	binary.BigEndian.PutUint32(buf[12:], uint32(rec.Segments))
+	binary.BigEndian.PutUint32(buf[16:], uint32(i))
+	mac.Write(buf[:])
+	return mac.Sum(nil)
+}
+
+// Verify checks a node's proof for challenge i of a record
+//
+// Errors:
+//   - ErrIndexOutOfRange if i is not a challenge of the record
+//   - ErrProofMismatch if the proof is wrong
+func (a *Auditor) Verify(rec *Record, i int, proof []byte) error {
+	if i < 0 || i >= len(rec.Expected) {
+		return ErrIndexOutOfRange
+	}
+	if len(proof) != ProofSize || subtle.ConstantTimeCompare(proof[:ExpectedSize], rec.Expected[i]) != 1 {
+		return ErrProofMismatch
+	}
+	return nil
+}
+
+// Audit issues the next unused challenge of a record to r and verifies the
+// answer. The challenge counts as used even if the node fails to answer,
+// since it may have seen it.
+//
+// Errors:
+//   - ErrExhausted if the record has no challenges left
+//   - ErrProofMismatch if the node's proof is wrong
+//   - any error returned by r
+func (a *Auditor) Audit(ctx context.Context, rec *Record, r Responder) error {
+	if rec.Remaining() == 0 {
+		return ErrExhausted
+	}
+	i := rec.Used
+	rec.Used++
+	ch, err := a.Challenge(rec, i)
+	if err != nil {
+		return err
+	}
+	proof, err := r.Prove(ctx, rec.ShardID, ch)
+	if err != nil {
+		return err
+	}
+	return a.Verify(rec, i, proof)
+}
+
+// Respond computes the proof for a challenge from a stored shard of the
+// given size. Segments past the end of the shard contribute nothing, so a
+// truncated shard yields a wrong proof rather than an error.
+//
+// Errors:
+//   - ErrInvalidChallenge for a malformed challenge
+//   - any error from reading the shard
+func Respond(ch *Challenge, shard io.ReaderAt, size int64) ([]byte, error) {
+	if len(ch.Nonce) != NonceSize || ch.SegmentSize <= 0 || ch.SegmentSize > MaxSegmentSize || len(ch.Segments) == 0 || len(ch.Segments) > MaxSegments {
+		return nil, ErrInvalidChallenge
+	}
+	for _, s := range ch.Segments {
+		if s < 0 || s > math.MaxInt64/int64(ch.SegmentSize) {
+			return nil, ErrInvalidChallenge
+		}
+	}
+	return respond(ch, shard, size)
+}
+
+func respond(ch *Challenge, shard io.ReaderAt, size int64) ([]byte, error) {
+	mac := hmac.New(sha256.New, ch.Nonce)
+	buf := make([]byte, ch.SegmentSize)
+	var idx [8]byte
+	for _, s := range ch.Segments {
+		binary.BigEndian.PutUint64(idx[:], uint64(s))
+		mac.Write(idx[:])
+		off := s * int64(ch.SegmentSize)
+		if off >= size {
+			continue
+		}
+		n := min(int64(ch.SegmentSize), size-off)
+		if _, err := shard.ReadAt(buf[:n], off); err != nil {
+			return nil, err
+		}
+		mac.Write(buf[:n])
+	}
+	return mac.Sum(nil), nil
+}
+
+// DetectionProbability returns the chance that one challenge of s
+// segments catches a node that has lost a fraction f of the shard
+func DetectionProbability(f float64, s int) float64 {
+	return 1 - math.Pow(1-f, float64(s))
+}
+
+// segmentCount returns the number of segments in a shard; an empty shard
+// has one empty segment
+func segmentCount(size int64, segSize int) int64 {
+	return max((size+int64(segSize)-1)/int64(segSize), 1)
+}
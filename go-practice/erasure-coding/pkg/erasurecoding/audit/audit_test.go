@@ -0,0 +1,250 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+var testKey = []byte("0123456789abcdef-audit-key")
+
+// memNode answers challenges from shards held in memory
+type memNode struct {
+	shards map[string][]byte
+	seen   []*Challenge
+}
+
+func (n *memNode) Prove(ctx context.Context, id string, ch *Challenge) ([]byte, error) {
+	n.seen = append(n.seen, ch)
+	shard, ok := n.shards[id]
+	if !ok {
+		return nil, errors.New("no such shard")
+	}
+	return Respond(ch, bytes.NewReader(shard), int64(len(shard)))
+}
+
+func randomShard(seed int64, n int) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+func mustAuditor(t testing.TB, p Params) *Auditor {
+	t.Helper()
+	a, err := NewAuditor(testKey, p)
+	if err != nil {
+		t.Fatalf("NewAuditor() error = %v", err)
+	}
+	return a
+}
+
+func TestAudit_HonestNodePassesEveryChallenge(t *testing.T) {
+	a := mustAuditor(t, Params{SegmentSize: 256, Segments: 4, Challenges: 10})
+	shard := randomShard(1, 10_000)
+	rec := a.Prepare("obj/0/3", shard)
+	node := &memNode{shards: map[string][]byte{"obj/0/3": shard}}
+
+	for i := 0; i < 10; i++ {
+		if err := a.Audit(context.Background(), rec, node); err != nil {
+			t.Fatalf("Audit() #%d error = %v", i, err)
+		}
+	}
+	if rec.Remaining() != 0 {
+		t.Errorf("Remaining() = %d, want 0", rec.Remaining())
+	}
+	if err := a.Audit(context.Background(), rec, node); !errors.Is(err, ErrExhausted) {
+		t.Errorf("Audit(exhausted) error = %v, want ErrExhausted", err)
+	}
+	// Every challenge was fresh
+	nonces := map[string]bool{}
+	for _, ch := range node.seen {
+		nonces[string(ch.Nonce)] = true
+	}
+	if len(nonces) != 10 {
+		t.Errorf("saw %d distinct nonces, want 10", len(nonces))
+	}
+}
+
+func TestAudit_DetectsDamage(t *testing.T) {
+	a := mustAuditor(t, Params{SegmentSize: 100, Segments: 16, Challenges: 4})
+	shard := randomShard(2, 6400)
+	tests := []struct {
+		name   string
+		stored []byte
+	}{
+		{"half corrupted", append(append([]byte(nil), shard[:3200]...), make([]byte, 3200)...)},
+		{"truncated", shard[:3200]},
+		{"empty", []byte{}},
+		{"other shard", randomShard(3, 6400)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := a.Prepare("s", shard)
+			node := &memNode{shards: map[string][]byte{"s": tt.stored}}
+			// With half the shard gone, one challenge misses with
+			// probability 2^-16; four in a row practically never
+			for i := 0; i < 4; i++ {
+				if err := a.Audit(context.Background(), rec, node); errors.Is(err, ErrProofMismatch) {
+					return
+				}
+			}
+			t.Error("damaged shard passed every challenge")
+		})
+	}
+}
+
+func TestAudit_SingleFlippedByteIsCaughtWhenSampled(t *testing.T) {
+	a := mustAuditor(t, Params{SegmentSize: 64, Segments: 2, Challenges: 50})
+	shard := randomShard(4, 64*8)
+	rec := a.Prepare("s", shard)
+	damaged := append([]byte(nil), shard...)
+	damaged[5*64+7] ^= 1
+
+	for i := 0; i < 50; i++ {
+		ch, err := a.Challenge(rec, i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		proof, err := Respond(ch, bytes.NewReader(damaged), int64(len(damaged)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sampled := ch.Segments[0] == 5 || ch.Segments[1] == 5
+		if err := a.Verify(rec, i, proof); (err != nil) != sampled {
+			t.Errorf("challenge %d (segments %v): Verify() error = %v", i, ch.Segments, err)
+		}
+	}
+}
+
+func TestAudit_ProofsAreBoundToChallengeAndKey(t *testing.T) {
+	a := mustAuditor(t, Params{Challenges: 2})
+	shard := randomShard(5, 50_000)
+	rec := a.Prepare("s", shard)
+
+	ch0, _ := a.Challenge(rec, 0)
+	proof0, _ := Respond(ch0, bytes.NewReader(shard), int64(len(shard)))
+	if err := a.Verify(rec, 0, proof0); err != nil {
+		t.Fatalf("Verify(0) error = %v", err)
+	}
+	if err := a.Verify(rec, 1, proof0); !errors.Is(err, ErrProofMismatch) {
+		t.Errorf("Verify(replayed proof) error = %v, want ErrProofMismatch", err)
+	}
+	if err := a.Verify(rec, 0, proof0[:ExpectedSize]); !errors.Is(err, ErrProofMismatch) {
+		t.Errorf("Verify(short proof) error = %v, want ErrProofMismatch", err)
+	}
+	if err := a.Verify(rec, 2, proof0); !errors.Is(err, ErrIndexOutOfRange) {
+		t.Errorf("Verify(2) error = %v, want ErrIndexOutOfRange", err)
+	}
+
+	// Another key derives other challenges, so its records are useless
+	// to someone who only saw ours
+	other, _ := NewAuditor(bytes.Repeat([]byte{7}, MinKeySize), Params{Challenges: 2})
+	otherCh, _ := other.Challenge(rec, 0)
+	if bytes.Equal(otherCh.Nonce, ch0.Nonce) {
+		t.Error("different keys produced the same nonce")
+	}
+	again, _ := a.Challenge(rec, 0)
+	if !bytes.Equal(again.Nonce, ch0.Nonce) || fmt.Sprint(again.Segments) != fmt.Sprint(ch0.Segments) {
+		t.Error("Challenge() is not deterministic")
+	}
+}
+
+func TestRecord_JSONRoundtrip(t *testing.T) {
+	a := mustAuditor(t, Params{Challenges: 3})
+	shard := randomShard(6, 9000)
+	rec := a.Prepare("obj/1/0", shard)
+	rec.Used = 1
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Record
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	node := &memNode{shards: map[string][]byte{"obj/1/0": shard}}
+	if err := a.Audit(context.Background(), &got, node); err != nil {
+		t.Errorf("Audit(decoded record) error = %v", err)
+	}
+	if got.Used != 2 || got.Remaining() != 1 {
+		t.Errorf("Used = %d, Remaining() = %d, want 2, 1", got.Used, got.Remaining())
+	}
+}
+
+func TestNewAuditor_Errors(t *testing.T) {
+	if _, err := NewAuditor([]byte("short"), Params{}); !errors.Is(err, ErrKeyTooShort) {
+		t.Errorf("NewAuditor(short key) error = %v", err)
+	}
+	for _, p := range []Params{{SegmentSize: -1}, {Segments: -1}, {Challenges: -1}, {Segments: MaxSegments + 1}, {SegmentSize: MaxSegmentSize + 1}} {
+		if _, err := NewAuditor(testKey, p); !errors.Is(err, ErrInvalidParams) {
+			t.Errorf("NewAuditor(%+v) error = %v", p, err)
+		}
+	}
+	a := mustAuditor(t, Params{})
+	if p := a.Params(); p.SegmentSize != DefaultSegmentSize || p.Segments != DefaultSegments || p.Challenges != DefaultChallenges {
+		t.Errorf("Params() = %+v, want defaults", p)
+	}
+}
+
+func TestRespond_InvalidChallenge(t *testing.T) {
+	nonce := make([]byte, NonceSize)
+	shard := bytes.NewReader([]byte("data"))
+	for i, ch := range []*Challenge{
+		{Nonce: nonce[:4], SegmentSize: 4, Segments: []int64{0}},
+		{Nonce: nonce, SegmentSize: 0, Segments: []int64{0}},
+		{Nonce: nonce, SegmentSize: MaxSegmentSize + 1, Segments: []int64{0}},
+		{Nonce: nonce, SegmentSize: math.MaxInt, Segments: []int64{0}},
+		{Nonce: nonce, SegmentSize: 4},
+		{Nonce: nonce, SegmentSize: 4, Segments: []int64{-1}},
+		{Nonce: nonce, SegmentSize: 4, Segments: make([]int64, MaxSegments+1)},
+	} {
+		if _, err := Respond(ch, shard, 4); !errors.Is(err, ErrInvalidChallenge) {
+			t.Errorf("case %d: Respond() error = %v, want ErrInvalidChallenge", i, err)
+		}
+	}
+}
+
+func TestDetectionProbability(t *testing.T) {
+	if got := DetectionProbability(0.5, 1); got != 0.5 {
+		t.Errorf("DetectionProbability(0.5, 1) = %v", got)
+	}
+	if got := DetectionProbability(0.01, DefaultSegments); got < 0.07 || got > 0.08 {
+		t.Errorf("DetectionProbability(0.01, 8) = %v", got)
+	}
+}
+
+func BenchmarkPrepare(b *testing.B) {
+	a := mustAuditor(b, Params{})
+	shard := randomShard(7, 1<<20)
+	b.SetBytes(int64(len(shard)))
+	for i := 0; i < b.N; i++ {
+		a.Prepare("s", shard)
+	}
+}
+
+func BenchmarkRespond(b *testing.B) {
+	a := mustAuditor(b, Params{})
+	shard := randomShard(8, 1<<20)
+	rec := a.Prepare("s", shard)
+	ch, _ := a.Challenge(rec, 0)
+	r := bytes.NewReader(shard)
+	for i := 0; i < b.N; i++ {
+		Respond(ch, r, int64(len(shard)))
+	}
+}
+
+func ExampleDetectionProbability() {
+	for _, lost := range []float64{0.01, 0.1, 0.5} {
+		fmt.Printf("%2.0f%% lost: %.3f per challenge\n", lost*100, DetectionProbability(lost, DefaultSegments))
+	}
+	// Output:
+	//  1% lost: 0.077 per challenge
+	// 10% lost: 0.570 per challenge
+	// 50% lost: 0.996 per challenge
+}
@@ -0,0 +1,271 @@
+package codec
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// Bit-matrix coding: multiplication by a constant e of GF(2^w) is linear
+// over GF(2), so it is a w×w binary matrix whose column c holds the bits of
+// e·2^c. Expanding every entry of a generator this way turns each shard
+// into w packets and every multiply-add into plain packet XORs. Packets
+// are interleaved 8-byte words; see xorSchedule.run.
+
+// smallPolys are primitive polynomials for GF(2^w), w = 2..8; w = 8 is the
+// gf256 polynomial
+var smallPolys = [...]int{2: 0x7, 3: 0xB, 4: 0x13, 5: 0x25, 6: 0x43, 7: 0x89, 8: 0x11D}
+
+// smallField is GF(2^w) for w <= 8 through log/exp tables
+type smallField struct {
+	w        int
+	exp, log []int
+}
+
+func newSmallField(w int) *smallField {
+	n := 1 << w
+	f := &smallField{w: w, exp: make([]int, 2*n), log: make([]int, n)}
+	x := 1
+	for i := 0; i < n-1; i++ {
+		f.exp[i], f.exp[i+n-1] = x, x
+		f.log[x] = i
+		x <<= 1
+		if x&n != 0 {
+			x ^= smallPolys[w]
+		}
+	}
+	return f
+}
+
+func (f *smallField) mul(a, b int) int {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return f.exp[f.log[a]+f.log[b]]
+}
+
+func (f *smallField) inv(a int) int {
+	return f.exp[(1<<f.w)-1-f.log[a]]
+}
+
+// bitRow is a row of a binary matrix, 64 columns per word
+type bitRow []uint64
+
+func newBitRow(cols int) bitRow { return make(bitRow, (cols+63)/64) }
+
+func (r bitRow) get(c int) bool { return r[c/64]>>(c%64)&1 != 0 }
+func (r bitRow) set(c int)      { r[c/64] |= 1 << (c % 64) }
+
+func (r bitRow) xor(o bitRow) {
+	for i := range r {
+		r[i] ^= o[i]
+	}
+}
+
+func (r bitRow) ones() int {
+	n := 0
+	for _, v := range r {
+		n += bits.OnesCount64(v)
+	}
+	return n
+}
+
+// diff returns the number of columns where r and o differ
+func (r bitRow) diff(o bitRow) int {
+	n := 0
+	for i, v := range r {
+		n += bits.OnesCount64(v ^ o[i])
+	}
+	return n
+}
+
+// expandRow turns a row of GF(2^w) coefficients into w binary rows over
+// len(coeffs)·w packet columns
+func (f *smallField) expandRow(coeffs []int) []bitRow {
+	w := f.w
+	rows := make([]bitRow, w)
+	for r := range rows {
+		rows[r] = newBitRow(len(coeffs) * w)
+	}
+	for j, e := range coeffs {
+		for c := 0; c < w; c++ {
+			v := f.mul(e, 1<<c)
+			for r := 0; r < w; r++ {
+				if v>>r&1 != 0 {
+					rows[r].set(j*w + c)
+				}
+			}
+		}
+	}
+	return rows
+}
+
+// invertBits returns the inverse of a square binary matrix, or false if it
+// is singular; m is left unchanged
+func invertBits(m []bitRow) ([]bitRow, bool) {
+	n := len(m)
+	work := make([]bitRow, n)
+	inv := make([]bitRow, n)
+	for i := range m {
+		work[i] = append(bitRow(nil), m[i]...)
+		inv[i] = newBitRow(n)
+		inv[i].set(i)
+	}
+	for col := 0; col < n; col++ {
+		pivot := col
+		for pivot < n && !work[pivot].get(col) {
+			pivot++
+		}
+		if pivot == n {
+			return nil, false
+		}
+		work[col], work[pivot] = work[pivot], work[col]
+		inv[col], inv[pivot] = inv[pivot], inv[col]
+		for r := 0; r < n; r++ {
+			if r != col && work[r].get(col) {
+				work[r].xor(work[col])
+				inv[r].xor(inv[col])
+			}
+		}
+	}
+	return inv, true
+}
+
+// xorOp is one step of a schedule: dst = src, dst ^= src, or dst = 0.
+// Packets are numbered inputs first, then outputs.
+type xorOp struct {
+	dst, src int
+	kind     uint8
+}
+
+const (
+	opCopy uint8 = iota
+	opXor
+	opZero
+)
+
+// xorSchedule is a list of packet operations computing output packets
+// from input packets
+type xorSchedule struct {
+	ops  []xorOp
+	xors int // number of opXor steps
+}
+
+// dumbSchedule computes every output row from the inputs alone: a copy of
+// its first input and an XOR for each further one
+func dumbSchedule(rows []bitRow, inputs int) *xorSchedule {
+	s := &xorSchedule{}
+	for r, row := range rows {
+		s.fromInputs(inputs+r, row, inputs)
+	}
+	return s
+}
+
+// smartSchedule is Jerasure's smart scheduling: each output row is computed
+// either from the inputs or from an output row already computed, whichever
+// takes fewer XORs, and the cheapest remaining row goes next, so runs of
+// similar rows share their work
+func smartSchedule(rows []bitRow, inputs int) *xorSchedule {
+	s := &xorSchedule{}
+	cost := make([]int, len(rows))
+	from := make([]int, len(rows))
+	done := make([]bool, len(rows))
+	for r, row := range rows {
+		cost[r] = max(row.ones()-1, 0)
+		from[r] = -1
+	}
+	for range rows {
+		best := -1
+		for r := range rows {
+			if !done[r] && (best < 0 || cost[r] < cost[best]) {
+				best = r
+			}
+		}
+		done[best] = true
+		if p := from[best]; p < 0 {
+			s.fromInputs(inputs+best, rows[best], inputs)
+		} else {
+			s.ops = append(s.ops, xorOp{dst: inputs + best, src: inputs + p, kind: opCopy})
+			for c := 0; c < inputs; c++ {
+				if rows[best].get(c) != rows[p].get(c) {
+					s.ops = append(s.ops, xorOp{dst: inputs + best, src: c, kind: opXor})
+					s.xors++
+				}
+			}
+		}
+		for r := range rows {
+			if !done[r] {
+				if d := rows[r].diff(rows[best]); d < cost[r] {
+					cost[r], from[r] = d, best
+				}
+			}
+		}
+	}
+	return s
+}
+
+// fromInputs appends the steps computing packet dst from the inputs set in
+// row
+func (s *xorSchedule) fromInputs(dst int, row bitRow, inputs int) {
+	first := true
+	for c := 0; c < inputs; c++ {
+		if !row.get(c) {
+			continue
+		}
+		if first {
+			s.ops = append(s.ops, xorOp{dst: dst, src: c, kind: opCopy})
+			first = false
+		} else {
+			s.ops = append(s.ops, xorOp{dst: dst, src: c, kind: opXor})
+			s.xors++
+		}
+	}
+	if first {
+		s.ops = append(s.ops, xorOp{dst: dst, kind: opZero})
+	}
+}
+
+// packetBatch is the number of regions run executes every step for before
+// moving on, so that a batch of every shard stays in the L1 cache
+const packetBatch = 64
+
+// run executes the schedule over shards holding the inputs followed by the
+// outputs. Each shard is a sequence of regions of w 8-byte words, and
+// packet p is word p%w of every region of shard p/w. Packets interleave
+// this way so that the code stays column by column at the granularity of
+// one region, as the range reader requires.
+func (s *xorSchedule) run(shards [][]byte, w int) {
+	region := 8 * w
+	size := len(shards[0])
+	for start := 0; start < size; start += packetBatch * region {
+		end := min(start+packetBatch*region, size)
+		for _, op := range s.ops {
+			dst := shards[op.dst/w][start+op.dst%w*8 : end]
+			switch op.kind {
+			case opCopy:
+				copyWords(dst, shards[op.src/w][start+op.src%w*8:end], region)
+			case opXor:
+				xorWords(dst, shards[op.src/w][start+op.src%w*8:end], region)
+			case opZero:
+				for i := 0; i < len(dst); i += region {
+					binary.LittleEndian.PutUint64(dst[i:], 0)
+				}
+			}
+		}
+	}
+}
+
+// copyWords copies the 8-byte word at every multiple of stride in src to
+// dst
+func copyWords(dst, src []byte, stride int) {
+	for i := 0; i < len(dst) && i < len(src); i += stride {
+		binary.LittleEndian.PutUint64(dst[i:], binary.LittleEndian.Uint64(src[i:]))
+	}
+}
+
+// xorWords XORs the 8-byte word at every multiple of stride in src into
+// dst
+func xorWords(dst, src []byte, stride int) {
+	for i := 0; i < len(dst) && i < len(src); i += stride {
+		binary.LittleEndian.PutUint64(dst[i:], binary.LittleEndian.Uint64(dst[i:])^binary.LittleEndian.Uint64(src[i:]))
+	}
+}
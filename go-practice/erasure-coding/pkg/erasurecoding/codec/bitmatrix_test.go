@@ -0,0 +1,112 @@
+package codec
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/gf256"
+)
+
+func TestSmallField(t *testing.T) {
+	for w := 2; w <= 8; w++ {
+		f := newSmallField(w)
+		// 2 generates the whole multiplicative group: the polynomial is
+		// primitive
+		seen := map[int]bool{}
+		for i := 0; i < 1<<w-1; i++ {
+			seen[f.exp[i]] = true
+		}
+		if len(seen) != 1<<w-1 {
+			t.Errorf("GF(2^%d): generator order %d, want %d", w, len(seen), 1<<w-1)
+		}
+		for a := 1; a < 1<<w; a++ {
+			if f.mul(a, f.inv(a)) != 1 {
+				t.Fatalf("GF(2^%d): %d · inv(%d) != 1", w, a, a)
+			}
+		}
+	}
+	f := newSmallField(8)
+	for a := 0; a < 256; a += 7 {
+		for b := 0; b < 256; b += 5 {
+			if got := f.mul(a, b); byte(got) != gf256.Mul(byte(a), byte(b)) {
+				t.Fatalf("GF(2^8) mul(%d, %d) = %d, gf256 disagrees", a, b, got)
+			}
+		}
+	}
+}
+
+func TestExpandRow_MatchesFieldMultiply(t *testing.T) {
+	f := newSmallField(4)
+	rows := f.expandRow([]int{7, 11})
+	for x0 := 0; x0 < 16; x0++ {
+		for x1 := 0; x1 < 16; x1++ {
+			want := f.mul(7, x0) ^ f.mul(11, x1)
+			got := 0
+			for r, row := range rows {
+				bit := false
+				for c := 0; c < 4; c++ {
+					if row.get(c) && x0>>c&1 != 0 {
+						bit = !bit
+					}
+					if row.get(4+c) && x1>>c&1 != 0 {
+						bit = !bit
+					}
+				}
+				if bit {
+					got |= 1 << r
+				}
+			}
+			if got != want {
+				t.Fatalf("expanded 7·%d + 11·%d = %d, want %d", x0, x1, got, want)
+			}
+		}
+	}
+}
+
+func TestInvertBits(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for trial := 0; trial < 20; trial++ {
+		n := 1 + rng.Intn(100)
+		m := make([]bitRow, n)
+		for i := range m {
+			m[i] = newBitRow(n)
+			for c := 0; c < n; c++ {
+				if rng.Intn(2) == 0 {
+					m[i].set(c)
+				}
+			}
+		}
+		inv, ok := invertBits(m)
+		if !ok {
+			continue // random binary matrices are often singular
+		}
+		// m · inv = I
+		for i := 0; i < n; i++ {
+			row := newBitRow(n)
+			for c := 0; c < n; c++ {
+				if m[i].get(c) {
+					row.xor(inv[c])
+				}
+			}
+			want := newBitRow(n)
+			want.set(i)
+			if row.diff(want) != 0 {
+				t.Fatalf("n=%d: (M·M^-1) row %d is not the identity", n, i)
+			}
+		}
+	}
+	if _, ok := invertBits([]bitRow{{3}, {3}}); ok {
+		t.Error("invertBits(singular) = ok")
+	}
+}
+
+func TestSmartSchedule_ReusesRows(t *testing.T) {
+	// Rows 1 and 2 differ from row 0 in one input each
+	rows := []bitRow{{0b1111}, {0b0111}, {0b11111}}
+	if got := dumbSchedule(rows, 5).xors; got != 3+2+4 {
+		t.Errorf("dumb xors = %d, want 9", got)
+	}
+	if got := smartSchedule(rows, 5).xors; got != 2+1+1 {
+		t.Errorf("smart xors = %d, want 4", got)
+	}
+}
@@ -0,0 +1,308 @@
+package codec
+
+import "math/bits"
+
+func init() {
+	Register("cauchy", func(params []int) (Codec, error) {
+		switch len(params) {
+		case 2:
+			return NewCauchy(params[0], params[1], 0)
+		case 3:
+			return NewCauchy(params[0], params[1], params[2])
+		}
+		return nil, ErrInvalidParams
+	})
+}
+
+// Cauchy is a Cauchy Reed-Solomon code computed with XORs only, in the
+// style of Jerasure
+//
+// Its k×m Cauchy generator over GF(2^w) is normalised so that the first
+// parity row is all ones (plain XOR parity, as in phase1) and every other
+// row has as few ones as possible once expanded. Each coefficient expands
+// to a w×w bit-matrix and each shard is split into w packets, so encoding
+// is a schedule of packet XORs. The smart schedule reuses parity packets
+// already computed. Decoding inverts the bit-matrix of k surviving shards.
+//
+// The code is MDS like rs, but its parity bytes differ. Packets are
+// interleaved 8-byte words, so shard sizes must be a multiple of 8w.
+// Spec: "cauchy:k+m", with the smallest w >= 2 such that k+m <= 2^w, or
+// "cauchy:k+m+w" for w from 2 to 8.
+type Cauchy struct {
+	k, m, w int
+	field   *smallField
+	coeffs  [][]int  // m×k parity coefficients over GF(2^w)
+	bits    []bitRow // m·w parity rows over k·w data packets
+	dumb    *xorSchedule
+	smart   *xorSchedule
+	cache   *decodeCache[*cauchyDecoder]
+}
+
+// cauchyDecoder holds the schedules Reconstruct runs for one erasure
+// pattern
+type cauchyDecoder struct {
+	use    []int        // the k surviving shards lost data is solved from
+	data   *xorSchedule // nil if no data shard is lost
+	parity *xorSchedule // nil if no parity shard is lost
+}
+
+// NewCauchy creates a k+m Cauchy bit-matrix codec over GF(2^w); w = 0
+// picks the smallest field that fits
+//
+// Errors:
+//   - ErrInvalidParams unless k >= 1, m >= 1, 2 <= w <= 8 and k+m <= 2^w
+func NewCauchy(k, m, w int) (*Cauchy, error) {
+	if w == 0 {
+		w = defaultCauchyW(k + m)
+	}
+	if k < 1 || m < 1 || w < 2 || w > 8 || k+m > 1<<w {
+		return nil, ErrInvalidParams
+	}
+	f := newSmallField(w)
+
+	// Entry (i, j) is 1/(x_i + y_j) with y_j = j and x_i = k+i. Scaling
+	// columns and rows by non-zero constants keeps every square submatrix
+	// invertible.
+	coeffs := make([][]int, m)
+	for i := range coeffs {
+		coeffs[i] = make([]int, k)
+		for j := range coeffs[i] {
+			coeffs[i][j] = f.inv((k + i) ^ j)
+		}
+	}
+	first := append([]int(nil), coeffs[0]...)
+	for i := range coeffs {
+		for j := range coeffs[i] {
+			coeffs[i][j] = f.mul(coeffs[i][j], f.inv(first[j]))
+		}
+	}
+	for i := 1; i < m; i++ {
+		coeffs[i] = f.sparsestMultiple(coeffs[i])
+	}
+
+	c := &Cauchy{k: k, m: m, w: w, field: f, coeffs: coeffs, cache: newDecodeCache[*cauchyDecoder](DefaultDecodeCacheSize)}
+	for _, row := range coeffs {
+		c.bits = append(c.bits, f.expandRow(row)...)
+	}
+	c.dumb = dumbSchedule(c.bits, k*w)
+	c.smart = smartSchedule(c.bits, k*w)
+	return c, nil
+}
+
+// defaultCauchyW returns the smallest w >= 2 with n <= 2^w
+func defaultCauchyW(n int) int {
+	w := 2
+	for 1<<w < n {
+		w++
+	}
+	return w
+}
+
+// sparsestMultiple returns s·row for the non-zero s whose bit-matrix
+// expansion has the fewest ones
+func (f *smallField) sparsestMultiple(row []int) []int {
+	// ones[e] is the number of ones in the w×w expansion of e
+	ones := make([]int, 1<<f.w)
+	for e := range ones {
+		for c := 0; c < f.w; c++ {
+			ones[e] += bits.OnesCount(uint(f.mul(e, 1<<c)))
+		}
+	}
+	bestS, bestOnes := 1, -1
+	for s := 1; s < 1<<f.w; s++ {
+		n := 0
+		for _, e := range row {
+			n += ones[f.mul(s, e)]
+		}
+		if bestOnes < 0 || n < bestOnes {
+			bestS, bestOnes = s, n
+		}
+	}
+	scaled := make([]int, len(row))
+	for j, e := range row {
+		scaled[j] = f.mul(bestS, e)
+	}
+	return scaled
+}
+
+// Spec returns "cauchy:k+m", or "cauchy:k+m+w" if w is not the default
+func (c *Cauchy) Spec() string {
+	if c.w == defaultCauchyW(c.k+c.m) {
+		return FormatSpec("cauchy", c.k, c.m)
+	}
+	return FormatSpec("cauchy", c.k, c.m, c.w)
+}
+
+// DataShards returns k
+func (c *Cauchy) DataShards() int { return c.k }
+
+// ParityShards returns m
+func (c *Cauchy) ParityShards() int { return c.m }
+
+// ShardAlignment returns 8w: shards are made of regions of w 8-byte
+// packet words
+func (c *Cauchy) ShardAlignment() int { return 8 * c.w }
+
+// ExtendParity returns cauchy:k+(m+extra) over the same field. Rows are
+// normalised one at a time against the unchanged first row, so the
+// existing parity is kept.
+func (c *Cauchy) ExtendParity(extra int) (Codec, error) {
+	if extra < 1 {
+		return nil, ErrInvalidParams
+	}
+	return NewCauchy(c.k, c.m+extra, c.w)
+}
+
+// CauchyCost counts the XORs one stripe costs per packet position
+//
+// Both schedules are for encoding. A packet is 1/w of a shard, so Smart/w
+// is the encoding cost in whole-shard XORs. TableMulAdds is what the same
+// parity costs a table-driven matrix codec such as rs: k·m shard-length
+// multiply-adds, each a table lookup plus an XOR per byte.
+type CauchyCost struct {
+	W            int
+	Ones         int // ones in the parity bit-matrix
+	Dumb         int // XORs computing each parity packet from the data alone
+	Smart        int // XORs with the smart schedule
+	TableMulAdds int
+}
+
+// Cost returns the XOR counts of the encoding schedules
+func (c *Cauchy) Cost() CauchyCost {
+	ones := 0
+	for _, r := range c.bits {
+		ones += r.ones()
+	}
+	return CauchyCost{W: c.w, Ones: ones, Dumb: c.dumb.xors, Smart: c.smart.xors, TableMulAdds: c.k * c.m}
+}
+
+// Encode computes the m parity shards with the smart XOR schedule
+func (c *Cauchy) Encode(shards [][]byte) error {
+	size, err := checkShards(c, shards, false)
+	if err != nil {
+		return err
+	}
+	if size%c.ShardAlignment() != 0 {
+		return ErrShardSize
+	}
+	c.smart.run(shards, c.w)
+	return nil
+}
+
+// DecodeCacheStats returns the decode cache counters
+func (c *Cauchy) DecodeCacheStats() DecodeCacheStats { return c.cache.stats() }
+
+// SetDecodeCacheSize bounds the decode cache to n erasure patterns; 0
+// disables it
+func (c *Cauchy) SetDecodeCacheSize(n int) { c.cache.resize(n) }
+
+// Reconstruct rebuilds up to m missing shards. Lost data packets are
+// solved from the bit-matrix of k surviving shards; lost parity is
+// re-encoded. The schedules are cached by erasure pattern.
+func (c *Cauchy) Reconstruct(shards [][]byte) error {
+	return c.reconstruct(shards, false)
+}
+
+// ReconstructInto is Reconstruct rebuilding into the missing shards'
+// buffers
+func (c *Cauchy) ReconstructInto(shards [][]byte) error {
+	return c.reconstruct(shards, true)
+}
+
+func (c *Cauchy) reconstruct(shards [][]byte, reuse bool) error {
+	size, err := checkShards(c, shards, true)
+	if err != nil {
+		return err
+	}
+	if size%c.ShardAlignment() != 0 {
+		return ErrShardSize
+	}
+	var lostData, lostParity []int
+	for i, shard := range shards {
+		if len(shard) == 0 {
+			if i < c.k {
+				lostData = append(lostData, i)
+			} else {
+				lostParity = append(lostParity, i)
+			}
+		}
+	}
+	if len(lostData)+len(lostParity) > c.m {
+		return ErrTooFewShards
+	}
+	if len(lostData)+len(lostParity) == 0 {
+		return nil
+	}
+
+	key := patternKey(nil, shards)
+	d, ok := c.cache.get(key)
+	if !ok {
+		if d, err = c.decoder(shards, lostData, lostParity); err != nil {
+			return err
+		}
+		c.cache.put(key, d)
+	}
+
+	if d.data != nil {
+		in := make([][]byte, len(d.use))
+		for t, i := range d.use {
+			in[t] = shards[i]
+		}
+		out := make([][]byte, len(lostData))
+		for t, j := range lostData {
+			out[t] = rebuildBuffer(shards[j], size, reuse)
+			shards[j] = out[t]
+		}
+		d.data.run(append(in, out...), c.w)
+	}
+	if d.parity != nil {
+		out := make([][]byte, len(lostParity))
+		for t, i := range lostParity {
+			out[t] = rebuildBuffer(shards[i], size, reuse)
+			shards[i] = out[t]
+		}
+		d.parity.run(append(shards[:c.k:c.k], out...), c.w)
+	}
+	return nil
+}
+
+// decoder builds the schedules for the erasure pattern of shards
+func (c *Cauchy) decoder(shards [][]byte, lostData, lostParity []int) (*cauchyDecoder, error) {
+	d := &cauchyDecoder{}
+	if len(lostData) > 0 {
+		// Survivors, data first; their bit rows over the data packets
+		var rows []bitRow
+		for i := 0; i < len(shards) && len(d.use) < c.k; i++ {
+			if len(shards[i]) == 0 {
+				continue
+			}
+			d.use = append(d.use, i)
+			if i < c.k {
+				for b := 0; b < c.w; b++ {
+					row := newBitRow(c.k * c.w)
+					row.set(i*c.w + b)
+					rows = append(rows, row)
+				}
+			} else {
+				rows = append(rows, c.bits[(i-c.k)*c.w:(i-c.k+1)*c.w]...)
+			}
+		}
+		inv, ok := invertBits(rows)
+		if !ok {
+			return nil, ErrTooFewShards
+		}
+		var want []bitRow
+		for _, j := range lostData {
+			want = append(want, inv[j*c.w:(j+1)*c.w]...)
+		}
+		d.data = smartSchedule(want, c.k*c.w)
+	}
+	if len(lostParity) > 0 {
+		var want []bitRow
+		for _, i := range lostParity {
+			want = append(want, c.bits[(i-c.k)*c.w:(i-c.k+1)*c.w]...)
+		}
+		d.parity = smartSchedule(want, c.k*c.w)
+	}
+	return d, nil
+}
@@ -0,0 +1,149 @@
+package codec
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestCauchy_AnyMErasures(t *testing.T) {
+	for _, spec := range []string{"cauchy:2+2", "cauchy:4+2", "cauchy:6+3", "cauchy:10+4", "cauchy:4+2+8", "cauchy:5+3+4"} {
+		t.Run(spec, func(t *testing.T) {
+			c, err := Parse(spec)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", spec, err)
+			}
+			if c.Spec() != spec {
+				t.Errorf("Spec() = %q, want %q", c.Spec(), spec)
+			}
+			for lost := 1; lost <= c.ParityShards(); lost++ {
+				checkErasures(t, c, lost)
+			}
+		})
+	}
+}
+
+func TestCauchy_FirstParityIsXor(t *testing.T) {
+	c, _ := NewCauchy(5, 3, 0)
+	shards := encodedStripe(t, c, 400)
+	want := make([]byte, len(shards[0]))
+	for _, shard := range shards[:5] {
+		xorInto(want, shard)
+	}
+	if !bytes.Equal(shards[5], want) {
+		t.Error("first parity shard is not the XOR of the data shards")
+	}
+}
+
+func TestCauchy_SchedulesAgree(t *testing.T) {
+	c, _ := NewCauchy(6, 3, 0)
+	shards := encodedStripe(t, c, 6*64)
+	dumb := make([][]byte, len(shards))
+	copy(dumb, shards[:6])
+	for i := 6; i < len(dumb); i++ {
+		dumb[i] = make([]byte, len(shards[0]))
+	}
+	c.dumb.run(dumb, c.w)
+	for i := range shards {
+		if !bytes.Equal(dumb[i], shards[i]) {
+			t.Errorf("dumb and smart schedules differ on shard %d", i)
+		}
+	}
+}
+
+func TestCauchy_Cost(t *testing.T) {
+	c, _ := NewCauchy(10, 4, 0)
+	cost := c.Cost()
+	if cost.W != 4 || cost.TableMulAdds != 40 {
+		t.Errorf("Cost() = %+v, want W 4 and 40 table multiply-adds", cost)
+	}
+	// The dumb schedule needs one XOR per one beyond the first in a row
+	if cost.Dumb != cost.Ones-4*4 {
+		t.Errorf("Dumb = %d, want Ones - m·w = %d", cost.Dumb, cost.Ones-16)
+	}
+	if cost.Smart > cost.Dumb {
+		t.Errorf("Smart = %d XORs, more than Dumb = %d", cost.Smart, cost.Dumb)
+	}
+	// The all-ones first row costs exactly (k-1)·w XORs
+	if xor, _ := NewCauchy(10, 1, 0); xor.Cost().Smart != 9*4 {
+		t.Errorf("cauchy:10+1 Smart = %d, want 36", xor.Cost().Smart)
+	}
+}
+
+func TestCauchy_Params(t *testing.T) {
+	for _, tc := range []struct {
+		k, m, w int
+		ok      bool
+	}{
+		{2, 2, 0, true},
+		{200, 56, 0, true},
+		{200, 57, 0, false},
+		{4, 2, 2, false}, // 6 > 2^2
+		{4, 2, 9, false},
+		{0, 2, 0, false},
+		{4, 0, 0, false},
+	} {
+		_, err := NewCauchy(tc.k, tc.m, tc.w)
+		if (err == nil) != tc.ok {
+			t.Errorf("NewCauchy(%d, %d, %d) error = %v, want ok = %v", tc.k, tc.m, tc.w, err, tc.ok)
+		}
+	}
+	c, _ := NewCauchy(4, 2, 0)
+	odd := make([][]byte, 6)
+	for i := range odd {
+		odd[i] = make([]byte, 5)
+	}
+	if err := c.Encode(odd); err != ErrShardSize {
+		t.Errorf("Encode(size 5, w 3) error = %v, want %v", err, ErrShardSize)
+	}
+}
+
+func TestCauchy_ExtendParity(t *testing.T) {
+	c, _ := NewCauchy(4, 2, 4)
+	bigger, extended, err := ExtendParity(c, encodedStripe(t, c, 4*16), 2)
+	if err != nil {
+		t.Fatalf("ExtendParity() error = %v", err)
+	}
+	if bigger.Spec() != "cauchy:4+4+4" {
+		t.Errorf("Spec() = %q, want cauchy:4+4+4", bigger.Spec())
+	}
+	fresh := make([][]byte, len(extended))
+	copy(fresh, extended[:4])
+	for i := 4; i < len(fresh); i++ {
+		fresh[i] = make([]byte, len(extended[0]))
+	}
+	_ = bigger.Encode(fresh)
+	for i := range fresh {
+		if !bytes.Equal(fresh[i], extended[i]) {
+			t.Errorf("shard %d differs from a fresh cauchy:4+4+4 encode", i)
+		}
+	}
+}
+
+func ExampleCauchy_Cost() {
+	for _, spec := range []string{"cauchy:4+2", "cauchy:10+4", "cauchy:10+4+8"} {
+		c, _ := Parse(spec)
+		cost := c.(*Cauchy).Cost()
+		fmt.Printf("%-14s w=%d ones=%d dumb=%d smart=%d table=%d\n", spec, cost.W, cost.Ones, cost.Dumb, cost.Smart, cost.TableMulAdds)
+	}
+	// Output:
+	// cauchy:4+2     w=3 ones=31 dumb=25 smart=22 table=8
+	// cauchy:10+4    w=4 ones=278 dumb=262 smart=233 table=40
+	// cauchy:10+4+8  w=8 ones=852 dumb=820 smart=746 table=40
+}
+
+// Benchmark XOR-scheduled encoding against the table-driven rs codec
+func BenchmarkCauchyEncode(b *testing.B) {
+	for _, spec := range []string{"cauchy:4+2", "rs:4+2", "cauchy:10+4", "rs:10+4", "cauchy:10+4+8"} {
+		b.Run(spec, func(b *testing.B) {
+			c, _ := Parse(spec)
+			k := c.DataShards()
+			shards := encodedStripe(b, c, k<<16)
+			b.SetBytes(int64(k) << 16)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_ = c.Encode(shards)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+// Package codec defines a common interface for erasure codes so that storage
+// layers can encode objects without caring which code is used.
+//
+// A codec works on a stripe of equally sized shards: DataShards() data
+// shards followed by ParityShards() parity shards. All codecs in this package
+// are linear and operate column by column at Alignment(c) granularity: word j
+// of every parity shard depends only on word j of the data shards, where a
+// word is one byte for most codecs, a 16-bit symbol for rs16 and fft16, and
+// 8w bytes for cauchy. That property is what lets the range reader rebuild a
+// small region of a shard without touching the rest; ReconstructRegion
+// widens the region to whole words first.
+//
+// Codecs are selected by a spec string of the form "name:a+b[+c...]":
+//
+//	xor:4+1         phase1-style single XOR parity over 4 data shards
+//	replica:1+2     three full copies of the data
+//	rs:10+4         Reed-Solomon, 10 data and 4 parity shards
+//	lrc:6+2+2       locally repairable code, 2 local groups and 2 global parities
+//	rs16:300+40     Reed-Solomon over GF(2^16), for more than 256 shards
+//	fft16:1000+200  Reed-Solomon by additive FFT, for very wide stripes
+//	cauchy:10+4     Cauchy Reed-Solomon as a bit-matrix, XORs only
+//
+// Example:
+//
+//	c, err := codec.Parse("xor:4+1")
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	shards, err := codec.Split(c, data)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	if err := c.Encode(shards); err != nil {
+//	    log.Fatal(err)
+//	}
+//
+//	shards[1] = nil // lose a shard
+//	if err := c.Reconstruct(shards); err != nil {
+//	    log.Fatal(err)
+//	}
+//	original := codec.Join(c, shards, len(data))
+package codec
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Codec is a systematic erasure code over a stripe of equally sized shards
+type Codec interface {
+	// Spec returns the spec string that Parse accepts to recreate the codec
+	Spec() string
+	// DataShards returns the number of data shards in a stripe
+	DataShards() int
+	// ParityShards returns the number of parity shards in a stripe
+	ParityShards() int
+	// Encode computes the parity shards from the data shards in place.
+	// shards must hold DataShards()+ParityShards() slices of equal length.
+	Encode(shards [][]byte) error
+	// Reconstruct rebuilds every nil (or empty) shard in place.
+	// It returns ErrTooFewShards if the missing shards cannot be recovered.
+	Reconstruct(shards [][]byte) error
+}
+
+// CodecError represents errors that can occur while encoding or decoding
+type CodecError struct {
+	message string
+}
+
+func (e *CodecError) Error() string {
+	return e.message
+}
+
+// Common errors
+var (
+	ErrEmptyData      = &CodecError{"input data cannot be empty"}
+	ErrShardCount     = &CodecError{"wrong number of shards for codec"}
+	ErrShardSize      = &CodecError{"shards must all have the same non-zero size"}
+	ErrTooFewShards   = &CodecError{"too few shards available to reconstruct"}
+	ErrInvalidSpec    = &CodecError{"invalid codec spec"}
+	ErrUnknownCodec   = &CodecError{"unknown codec"}
+	ErrInvalidParams  = &CodecError{"invalid codec parameters"}
+	ErrInvalidLayout  = &CodecError{"invalid encoded object layout"}
+	ErrNegativeOffset = &CodecError{"read offset cannot be negative"}
+)
+
+// Constructor builds a codec from the integer parameters of a spec
+type Constructor func(params []int) (Codec, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Constructor{}
+)
+
+// Register makes a codec available to Parse under name
+//
+// Register panics if name is already registered, mirroring database/sql.
+func Register(name string, ctor Constructor) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := registry[name]; dup {
+		panic("codec: Register called twice for " + name)
+	}
+	registry[name] = ctor
+}
+
+// Names returns the sorted names of all registered codecs
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Parse builds a codec from a spec string such as "xor:4+1"
+//
+// Errors:
+//   - ErrInvalidSpec if the spec is malformed
+//   - ErrUnknownCodec if no codec is registered under the name
+//   - ErrInvalidParams (or a codec specific error) for bad parameters
+func Parse(spec string) (Codec, error) {
+	name, params, err := splitSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	registryMu.RLock()
+	ctor, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownCodec, name)
+	}
+	return ctor(params)
+}
+
+// FormatSpec builds a spec string from a codec name and its parameters
+func FormatSpec(name string, params ...int) string {
+	parts := make([]string, len(params))
+	for i, p := range params {
+		parts[i] = strconv.Itoa(p)
+	}
+	return name + ":" + strings.Join(parts, "+")
+}
+
+// splitSpec splits "name:a+b+c" into its name and integer parameters
+func splitSpec(spec string) (string, []int, error) {
+	name, rest, ok := strings.Cut(strings.TrimSpace(spec), ":")
+	if !ok || name == "" || rest == "" {
+		return "", nil, fmt.Errorf("%w: %q", ErrInvalidSpec, spec)
+	}
+
+	fields := strings.Split(rest, "+")
+	params := make([]int, len(fields))
+	for i, f := range fields {
+		p, err := strconv.Atoi(f)
+		if err != nil || p < 0 {
+			return "", nil, fmt.Errorf("%w: %q", ErrInvalidSpec, spec)
+		}
+		params[i] = p
+	}
+	return name, params, nil
+}
+
+// Aligner is implemented by codecs whose shard sizes must be a multiple
+// of some number of bytes, such as codecs over GF(2^16)
+type Aligner interface {
+	ShardAlignment() int
+}
+
+// Alignment returns the shard size multiple c needs (1 unless c is an
+// Aligner)
+func Alignment(c Codec) int {
+	if a, ok := c.(Aligner); ok {
+		return a.ShardAlignment()
+	}
+	return 1
+}
+
+// TotalShards returns the number of shards in a stripe of c
+func TotalShards(c Codec) int {
+	return c.DataShards() + c.ParityShards()
+}
+
+// ChunkSize returns the shard size used to split size bytes over c's data
+// shards, matching phase1.Encode and rounded up to c's Alignment
+func ChunkSize(c Codec, size int) int {
+	k, a := c.DataShards(), Alignment(c)
+	chunk := (size + k - 1) / k
+	return (chunk + a - 1) / a * a
+}
+
+// Split divides data into DataShards() zero-padded data shards and allocates
+// empty parity shards, ready to be passed to Encode
+//
+// Data is laid out contiguously like phase1.Encode: shard i holds bytes
+// [i*chunkSize, (i+1)*chunkSize) of the input.
+//
+// Errors:
+//   - ErrEmptyData if input is empty
+func Split(c Codec, data []byte) ([][]byte, error) {
+	if len(data) == 0 {
+		return nil, ErrEmptyData
+	}
+
+	chunkSize := ChunkSize(c, len(data))
+	shards := make([][]byte, TotalShards(c))
+	for i := range shards {
+		shards[i] = make([]byte, chunkSize)
+		if i < c.DataShards() {
+			start := i * chunkSize
+			if start < len(data) {
+				end := start + chunkSize
+				if end > len(data) {
+					end = len(data)
+				}
+				copy(shards[i], data[start:end])
+			}
+		}
+	}
+	return shards, nil
+}
+
+// Join concatenates the data shards and trims the padding, returning the
+// first size bytes of the original data
+func Join(c Codec, shards [][]byte, size int) []byte {
+	data := make([]byte, 0, size)
+	for _, shard := range shards[:c.DataShards()] {
+		data = append(data, shard...)
+	}
+	if len(data) > size {
+		data = data[:size]
+	}
+	return data
+}
+
+// checkShards validates the shard count and returns the common shard size.
+// Missing (nil or empty) shards are allowed only when allowMissing is set.
+func checkShards(c Codec, shards [][]byte, allowMissing bool) (int, error) {
+	if len(shards) != TotalShards(c) {
+		return 0, ErrShardCount
+	}
+	size := 0
+	for _, shard := range shards {
+		if len(shard) == 0 {
+			if !allowMissing {
+				return 0, ErrShardSize
+			}
+			continue
+		}
+		if size == 0 {
+			size = len(shard)
+		} else if len(shard) != size {
+			return 0, ErrShardSize
+		}
+	}
+	if size == 0 {
+		if allowMissing {
+			return 0, ErrTooFewShards
+		}
+		return 0, ErrShardSize
+	}
+	return size, nil
+}
+
+// xorInto XORs src into dst, a 64-bit word at a time; it is the inner
+// loop of the XOR and Cauchy bit-matrix codecs
+func xorInto(dst, src []byte) {
+	dst = dst[:len(src)]
+	n := len(src) &^ 7
+	for i := 0; i < n; i += 8 {
+		binary.LittleEndian.PutUint64(dst[i:], binary.LittleEndian.Uint64(dst[i:])^binary.LittleEndian.Uint64(src[i:]))
+	}
+	for i := n; i < len(src); i++ {
+		dst[i] ^= src[i]
+	}
+}
@@ -0,0 +1,195 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec       string
+		dataShards int
+		parity     int
+	}{
+		{"xor:4+1", 4, 1},
+		{"xor:2+1", 2, 1},
+		{"replica:1+2", 1, 2},
+		{"replica:1+0", 1, 0},
+		{"rs:10+4", 10, 4},
+		{"lrc:6+2+2", 6, 4},
+		{"fft:10+4", 10, 4},
+		{"fft16:1000+200", 1000, 200},
+		{"cauchy:10+4", 10, 4},
+		{"cauchy:4+2+8", 4, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			c, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.spec, err)
+			}
+			if c.DataShards() != tt.dataShards || c.ParityShards() != tt.parity {
+				t.Errorf("Parse(%q) = %d+%d, want %d+%d", tt.spec, c.DataShards(), c.ParityShards(), tt.dataShards, tt.parity)
+			}
+			if c.Spec() != tt.spec {
+				t.Errorf("Spec() = %q, want %q", c.Spec(), tt.spec)
+			}
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		spec string
+		want error
+	}{
+		{"", ErrInvalidSpec},
+		{"xor", ErrInvalidSpec},
+		{"xor:a+1", ErrInvalidSpec},
+		{"xor:-1+1", ErrInvalidSpec},
+		{"nope:1+1", ErrUnknownCodec},
+		{"xor:1+1", ErrInvalidParams},
+		{"xor:4+2", ErrInvalidParams},
+		{"replica:2+1", ErrInvalidParams},
+		{"rs:4", ErrInvalidParams},
+		{"rs:200+57", ErrInvalidParams},
+		{"lrc:6+4+2", ErrInvalidParams},
+		{"fft:200+57", ErrInvalidParams},
+		{"cauchy:4+2+9", ErrInvalidParams},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			if _, err := Parse(tt.spec); !errors.Is(err, tt.want) {
+				t.Errorf("Parse(%q) error = %v, want %v", tt.spec, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCodecs_ReconstructEachShard(t *testing.T) {
+	data := []byte("The quick brown fox jumps over the lazy dog")
+
+	for _, spec := range []string{"xor:2+1", "xor:5+1", "replica:1+1", "replica:1+3", "rs:4+2", "lrc:4+2+1", "fft:4+2", "fft16:4+3", "cauchy:4+2"} {
+		c, _ := Parse(spec)
+		for lost := 0; lost < TotalShards(c); lost++ {
+			t.Run(fmt.Sprintf("%s/lost_%d", spec, lost), func(t *testing.T) {
+				shards, err := Split(c, data)
+				if err != nil {
+					t.Fatalf("Split() error = %v", err)
+				}
+				if err := c.Encode(shards); err != nil {
+					t.Fatalf("Encode() error = %v", err)
+				}
+				want := append([]byte(nil), shards[lost]...)
+
+				shards[lost] = nil
+				if err := c.Reconstruct(shards); err != nil {
+					t.Fatalf("Reconstruct() error = %v", err)
+				}
+				if !bytes.Equal(shards[lost], want) {
+					t.Errorf("Reconstruct() shard %d = %x, want %x", lost, shards[lost], want)
+				}
+				if got := Join(c, shards, len(data)); !bytes.Equal(got, data) {
+					t.Errorf("Join() = %q, want %q", got, data)
+				}
+			})
+		}
+	}
+}
+
+func TestCodecs_TooManyLost(t *testing.T) {
+	data := []byte("TOO MANY LOST")
+
+	for _, spec := range []string{"xor:3+1", "replica:1+2", "rs:3+2", "fft16:3+2"} {
+		t.Run(spec, func(t *testing.T) {
+			c, _ := Parse(spec)
+			shards, _ := Split(c, data)
+			_ = c.Encode(shards)
+			for i := 0; i <= c.ParityShards(); i++ {
+				shards[i] = nil
+			}
+			if err := c.Reconstruct(shards); err != ErrTooFewShards {
+				t.Errorf("Reconstruct() error = %v, want %v", err, ErrTooFewShards)
+			}
+		})
+	}
+}
+
+func TestEncode_ShardValidation(t *testing.T) {
+	c, _ := NewXor(2)
+	if err := c.Encode([][]byte{{1}, {2}}); err != ErrShardCount {
+		t.Errorf("Encode(2 shards) error = %v, want %v", err, ErrShardCount)
+	}
+	if err := c.Encode([][]byte{{1}, {2, 3}, {0}}); err != ErrShardSize {
+		t.Errorf("Encode(uneven shards) error = %v, want %v", err, ErrShardSize)
+	}
+}
+
+func TestSplit_MatchesPhase1Layout(t *testing.T) {
+	c, _ := NewXor(3)
+	shards, err := Split(c, []byte("HELLO WORLD"))
+	if err != nil {
+		t.Fatalf("Split() error = %v", err)
+	}
+	want := []string{"HELL", "O WO", "RLD\x00"}
+	for i, w := range want {
+		if string(shards[i]) != w {
+			t.Errorf("shard %d = %q, want %q", i, shards[i], w)
+		}
+	}
+	if _, err := Split(c, nil); err != ErrEmptyData {
+		t.Errorf("Split(empty) error = %v, want %v", err, ErrEmptyData)
+	}
+}
+
+// Benchmark XOR encoding through the Codec interface
+func BenchmarkXorEncode(b *testing.B) {
+	data := bytes.Repeat([]byte("benchmark data "), 1000) // ~15KB
+	c, _ := NewXor(5)
+	shards, _ := Split(c, data)
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_ = c.Encode(shards)
+	}
+}
+
+// Example demonstrates encoding and reconstruction through a spec string
+func ExampleParse() {
+	c, err := Parse("xor:3+1")
+	if err != nil {
+		panic(err)
+	}
+
+	data := []byte("HELLO WORLD")
+	shards, _ := Split(c, data)
+	_ = c.Encode(shards)
+
+	shards[1] = nil
+	if err := c.Reconstruct(shards); err != nil {
+		panic(err)
+	}
+	fmt.Println(string(Join(c, shards, len(data))))
+	// Output:
+	// HELLO WORLD
+}
+
+func TestXorInto(t *testing.T) {
+	for _, n := range []int{0, 1, 7, 8, 9, 33} {
+		dst, src := make([]byte, n), make([]byte, n)
+		want := make([]byte, n)
+		for i := range src {
+			dst[i], src[i] = byte(i*7), byte(i*13+1)
+			want[i] = dst[i] ^ src[i]
+		}
+		xorInto(dst, src)
+		if !bytes.Equal(dst, want) {
+			t.Errorf("xorInto(len %d) = %x, want %x", n, dst, want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package codec
+
+import (
+	"container/list"
+	"sync"
+)
+
+// DefaultDecodeCacheSize is the number of erasure patterns a codec keeps
+// decoding state for
+const DefaultDecodeCacheSize = 64
+
+// DecodeCacheStats counts the lookups in a codec's decode cache
+type DecodeCacheStats struct {
+	Hits     int64
+	Misses   int64
+	Entries  int
+	Capacity int
+}
+
+// HitRate returns Hits / (Hits + Misses), or 0 before the first lookup
+func (s DecodeCacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// DecodeCacher is implemented by codecs that keep the inverted decode
+// matrix of recent erasure patterns, so that reconstructing many stripes
+// with the same shards missing inverts it only once: rs, rs16, lrc and
+// cauchy. The cache is shared by every goroutine using the codec.
+type DecodeCacher interface {
+	DecodeCacheStats() DecodeCacheStats
+	// SetDecodeCacheSize bounds the cache to n patterns, evicting the
+	// least recently used; 0 disables it
+	SetDecodeCacheSize(n int)
+}
+
+// decodeCache is a bounded LRU map from erasure pattern to decoding state.
+// Values are never modified once stored, so callers share them without
+// copying. A nil cache stores nothing.
+type decodeCache[V any] struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List // most recently used first
+	hits     int64
+	misses   int64
+}
+
+type cacheEntry[V any] struct {
+	key   string
+	value V
+}
+
+func newDecodeCache[V any](capacity int) *decodeCache[V] {
+	return &decodeCache[V]{capacity: capacity, entries: make(map[string]*list.Element), order: list.New()}
+}
+
+// patternKey appends the set of missing shards, as a bitmap, to buf.
+// Lookups take the key as a byte slice so that a hit needs no allocation.
+func patternKey(buf []byte, shards [][]byte) []byte {
+	for i := 0; i < len(shards); i += 8 {
+		var b byte
+		for j := i; j < min(i+8, len(shards)); j++ {
+			if len(shards[j]) == 0 {
+				b |= 1 << (j - i)
+			}
+		}
+		buf = append(buf, b)
+	}
+	return buf
+}
+
+// get returns the value stored for key and counts a hit or a miss
+func (c *decodeCache[V]) get(key []byte) (V, bool) {
+	var zero V
+	if c == nil {
+		return zero, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.capacity == 0 {
+		return zero, false
+	}
+	e, ok := c.entries[string(key)]
+	if !ok {
+		c.misses++
+		return zero, false
+	}
+	c.hits++
+	c.order.MoveToFront(e)
+	return e.Value.(*cacheEntry[V]).value, true
+}
+
+// put stores value for key. Goroutines that missed on the same pattern at
+// once each store what they computed; the values are equal.
+func (c *decodeCache[V]) put(key []byte, value V) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.capacity == 0 {
+		return
+	}
+	if e, ok := c.entries[string(key)]; ok {
+		c.order.MoveToFront(e)
+		return
+	}
+	k := string(key)
+	c.entries[k] = c.order.PushFront(&cacheEntry[V]{key: k, value: value})
+	c.evict()
+}
+
+// evict drops least recently used entries beyond the capacity
+func (c *decodeCache[V]) evict() {
+	for c.order.Len() > c.capacity {
+		e := c.order.Back()
+		c.order.Remove(e)
+		delete(c.entries, e.Value.(*cacheEntry[V]).key)
+	}
+}
+
+func (c *decodeCache[V]) stats() DecodeCacheStats {
+	if c == nil {
+		return DecodeCacheStats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return DecodeCacheStats{Hits: c.hits, Misses: c.misses, Entries: c.order.Len(), Capacity: c.capacity}
+}
+
+func (c *decodeCache[V]) resize(n int) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.capacity = max(n, 0)
+	c.evict()
+}
@@ -0,0 +1,177 @@
+package codec
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestDecodeCache_LRU(t *testing.T) {
+	c := newDecodeCache[int](2)
+	c.put([]byte("a"), 1)
+	c.put([]byte("b"), 2)
+	if v, ok := c.get([]byte("a")); !ok || v != 1 {
+		t.Fatalf("get(a) = %d, %v, want 1, true", v, ok)
+	}
+	c.put([]byte("c"), 3) // evicts b, the least recently used
+	if _, ok := c.get([]byte("b")); ok {
+		t.Error("b survived eviction")
+	}
+	for key, want := range map[string]int{"a": 1, "c": 3} {
+		if v, ok := c.get([]byte(key)); !ok || v != want {
+			t.Errorf("get(%s) = %d, %v, want %d, true", key, v, ok, want)
+		}
+	}
+	want := DecodeCacheStats{Hits: 3, Misses: 1, Entries: 2, Capacity: 2}
+	if got := c.stats(); got != want {
+		t.Errorf("stats() = %+v, want %+v", got, want)
+	}
+
+	c.resize(1)
+	if got := c.stats(); got.Entries != 1 {
+		t.Errorf("after resize(1) Entries = %d, want 1", got.Entries)
+	}
+	c.resize(0)
+	c.put([]byte("d"), 4)
+	if _, ok := c.get([]byte("d")); ok {
+		t.Error("disabled cache stored a value")
+	}
+	if got := c.stats(); got.Entries != 0 || got.Hits+got.Misses != 4 {
+		t.Errorf("disabled cache stats() = %+v, want no entries and no new lookups", got)
+	}
+}
+
+func TestPatternKey(t *testing.T) {
+	a := patternKey(nil, [][]byte{nil, {1}, {1}, nil, {1}, {1}, {1}, {1}, {1}})
+	b := patternKey(nil, [][]byte{nil, {1}, {1}, {1}, nil, {1}, {1}, {1}, {1}})
+	if bytes.Equal(a, b) {
+		t.Error("different erasure patterns share a key")
+	}
+	if len(a) != 2 {
+		t.Errorf("key for 9 shards is %d bytes, want 2", len(a))
+	}
+}
+
+// Reconstructing the same pattern twice inverts once, and the cached
+// decoder gives the same shards
+func TestDecodeCache_RepeatedPattern(t *testing.T) {
+	for _, spec := range []string{"rs:6+3", "rs16:6+3", "lrc:6+2+2", "cauchy:6+3"} {
+		t.Run(spec, func(t *testing.T) {
+			c, err := Parse(spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dc, ok := c.(DecodeCacher)
+			if !ok {
+				t.Fatalf("%s is not a DecodeCacher", spec)
+			}
+			want := encodedStripe(t, c, 6000)
+			for round := 0; round < 3; round++ {
+				shards := make([][]byte, len(want))
+				copy(shards, want)
+				shards[0], shards[2], shards[7] = nil, nil, nil
+				if err := c.Reconstruct(shards); err != nil {
+					t.Fatalf("round %d: Reconstruct() error = %v", round, err)
+				}
+				for i := range want {
+					if !bytes.Equal(shards[i], want[i]) {
+						t.Fatalf("round %d: shard %d differs", round, i)
+					}
+				}
+			}
+			got := dc.DecodeCacheStats()
+			if got.Misses != 1 || got.Hits != 2 || got.Entries != 1 {
+				t.Errorf("DecodeCacheStats() = %+v, want 1 miss, 2 hits, 1 entry", got)
+			}
+		})
+	}
+}
+
+func TestDecodeCache_Disabled(t *testing.T) {
+	c, _ := NewReedSolomon(4, 2)
+	c.SetDecodeCacheSize(0)
+	checkErasures(t, c, 2)
+	if got := c.DecodeCacheStats(); got != (DecodeCacheStats{}) {
+		t.Errorf("DecodeCacheStats() = %+v with the cache disabled", got)
+	}
+}
+
+// One codec shared by many goroutines, each cycling through erasure
+// patterns in a cache too small for all of them
+func TestDecodeCache_Concurrent(t *testing.T) {
+	c, _ := NewReedSolomon(5, 3)
+	c.SetDecodeCacheSize(8)
+	want := encodedStripe(t, c, 5000)
+	var patterns [][]int
+	forEachErasure(TotalShards(c), 2, func(erased []int) {
+		patterns = append(patterns, append([]int(nil), erased...))
+	})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for n := 0; n < 200; n++ {
+				erased := patterns[(g*7+n)%len(patterns)%12]
+				shards := make([][]byte, len(want))
+				copy(shards, want)
+				for _, i := range erased {
+					shards[i] = nil
+				}
+				if err := c.Reconstruct(shards); err != nil {
+					errs <- err
+					return
+				}
+				for i := range want {
+					if !bytes.Equal(shards[i], want[i]) {
+						errs <- fmt.Errorf("lost %v: shard %d differs", erased, i)
+						return
+					}
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	got := c.DecodeCacheStats()
+	if got.Entries > 8 {
+		t.Errorf("Entries = %d, over the capacity of 8", got.Entries)
+	}
+	if got.Hits == 0 {
+		t.Errorf("DecodeCacheStats() = %+v, want some hits", got)
+	}
+}
+
+// Benchmark decoding the same erasure pattern with and without the cache;
+// hit-rate is the fraction of stripes that skipped the inversion
+func BenchmarkDecodeCache(b *testing.B) {
+	const shardSize = 1024
+	for _, spec := range []string{"rs:10+4", "rs:50+20", "rs16:50+20", "cauchy:10+4"} {
+		for _, size := range []int{0, DefaultDecodeCacheSize} {
+			c, _ := Parse(spec)
+			dc := c.(DecodeCacher)
+			dc.SetDecodeCacheSize(size)
+			k, m := c.DataShards(), c.ParityShards()
+			b.Run(fmt.Sprintf("%s/cache=%d", spec, size), func(b *testing.B) {
+				want := encodedStripe(b, c, k*shardSize)
+				shards := make([][]byte, len(want))
+				b.SetBytes(int64(k * shardSize))
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					copy(shards, want)
+					for j := 0; j < m; j++ {
+						shards[j] = nil
+					}
+					_ = c.Reconstruct(shards)
+				}
+				b.ReportMetric(dc.DecodeCacheStats().HitRate(), "hit-rate")
+			})
+		}
+	}
+}
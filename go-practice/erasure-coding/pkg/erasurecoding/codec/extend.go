@@ -0,0 +1,146 @@
+package codec
+
+import "fmt"
+
+// ErrNotExtendable is returned by ExtendParity for codecs that cannot grow
+// their parity in place
+var ErrNotExtendable = &CodecError{"codec cannot extend its parity"}
+
+// ParityExtender is implemented by codecs whose generator can gain parity
+// rows without changing the existing ones
+type ParityExtender interface {
+	Codec
+	// ExtendParity returns the codec with extra more parity shards. Its
+	// first TotalShards() shards are encoded exactly like this codec's, so
+	// stripes written with either codec are interchangeable.
+	ExtendParity(extra int) (Codec, error)
+}
+
+// ExtendParity adds extra parity shards to an already encoded stripe
+//
+// Only the data shards are read; existing parity shards are neither read
+// nor rewritten (they may even be missing). It returns the extended codec
+// and the stripe with the new parity shards appended. Decoding the result
+// with the extended codec can use old and new parity interchangeably.
+//
+// The built-in codecs other than fft and fft16 are extendable:
+//
+//	rs:k+m      -> rs:k+(m+extra)
+//	rs16:k+m    -> rs16:k+(m+extra)
+//	lrc:k+l+r   -> lrc:k+l+(r+extra)   (more global parities)
+//	xor:k+1     -> lrc:k+1+extra        (the XOR parity is a one-group LRC)
+//	replica:1+m -> replica:1+(m+extra)
+//	cauchy:k+m  -> cauchy:k+(m+extra)+w (same field)
+//
+// Errors:
+//   - ErrNotExtendable if c does not implement ParityExtender
+//   - ErrInvalidParams if extra < 1 or the extended code is too large
+//   - ErrShardCount if shards does not match c
+//   - ErrTooFewShards if a data shard is missing (Reconstruct it first)
+//   - ErrShardSize if the data shards differ in size
+func ExtendParity(c Codec, shards [][]byte, extra int) (Codec, [][]byte, error) {
+	ext, ok := c.(ParityExtender)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %s", ErrNotExtendable, c.Spec())
+	}
+	if extra < 1 {
+		return nil, nil, ErrInvalidParams
+	}
+	if len(shards) != TotalShards(c) {
+		return nil, nil, ErrShardCount
+	}
+	size := len(shards[0])
+	for _, shard := range shards[:c.DataShards()] {
+		if len(shard) == 0 {
+			return nil, nil, ErrTooFewShards
+		}
+		if len(shard) != size {
+			return nil, nil, ErrShardSize
+		}
+	}
+	if size%Alignment(c) != 0 {
+		return nil, nil, ErrShardSize
+	}
+
+	bigger, err := ext.ExtendParity(extra)
+	if err != nil {
+		return nil, nil, err
+	}
+	first := len(shards)
+	out := make([][]byte, TotalShards(bigger))
+	copy(out, shards)
+	for i := first; i < len(out); i++ {
+		out[i] = make([]byte, size)
+	}
+
+	switch b := bigger.(type) {
+	case rowEncoder:
+		for i := first; i < len(out); i++ {
+			b.encodeRow(i, out, out[i])
+		}
+	case *Replica:
+		for i := first; i < len(out); i++ {
+			copy(out[i], out[0])
+		}
+	default:
+		// Encode a scratch stripe and keep only the new rows
+		scratch := make([][]byte, len(out))
+		copy(scratch, out[:c.DataShards()])
+		for i := c.DataShards(); i < len(scratch); i++ {
+			scratch[i] = make([]byte, size)
+		}
+		if err := bigger.Encode(scratch); err != nil {
+			return nil, nil, err
+		}
+		copy(out[first:], scratch[first:])
+	}
+	return bigger, out, nil
+}
+
+// rowEncoder is implemented by the matrix codes, which can compute a single
+// generator row
+type rowEncoder interface {
+	encodeRow(i int, shards [][]byte, out []byte)
+}
+
+// ExtendParity returns rs:k+(m+extra); its first m parity rows are this
+// codec's, since the Cauchy rows only depend on their index
+func (r *ReedSolomon) ExtendParity(extra int) (Codec, error) {
+	if extra < 1 {
+		return nil, ErrInvalidParams
+	}
+	return NewReedSolomon(r.k, r.m+extra)
+}
+
+// ExtendParity returns rs16:k+(m+extra)
+func (r *ReedSolomon16) ExtendParity(extra int) (Codec, error) {
+	if extra < 1 {
+		return nil, ErrInvalidParams
+	}
+	return NewReedSolomon16(r.k, r.m+extra)
+}
+
+// ExtendParity returns lrc:k+l+(r+extra), appending global parities
+func (c *LRC) ExtendParity(extra int) (Codec, error) {
+	if extra < 1 {
+		return nil, ErrInvalidParams
+	}
+	return NewLRC(c.k, c.groups, c.global+extra)
+}
+
+// ExtendParity returns lrc:k+1+extra: the XOR parity is the local parity of
+// a single group covering all data shards
+func (x *Xor) ExtendParity(extra int) (Codec, error) {
+	if extra < 1 {
+		return nil, ErrInvalidParams
+	}
+	return NewLRC(x.dataShards, 1, extra)
+}
+
+// ExtendParity returns replica:1+(m+extra)
+func (r *Replica) ExtendParity(extra int) (Codec, error) {
+	if extra < 1 {
+		return nil, ErrInvalidParams
+	}
+	return NewReplica(r.copies + extra)
+}
@@ -0,0 +1,91 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestExtendParity_MatchesExtendedGenerator(t *testing.T) {
+	tests := []struct{ spec, want string }{
+		{"rs:4+2", "rs:4+4"},
+		{"lrc:6+2+2", "lrc:6+2+4"},
+		{"xor:4+1", "lrc:4+1+2"},
+		{"replica:1+1", "replica:1+3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			c, _ := Parse(tt.spec)
+			stripe := encodedStripe(t, c, 600)
+			oldParity := make([][]byte, c.ParityShards())
+			copy(oldParity, stripe[c.DataShards():])
+
+			// Existing parity must not be needed
+			for i := c.DataShards(); i < len(stripe); i++ {
+				stripe[i] = nil
+			}
+			bigger, extended, err := ExtendParity(c, stripe, 2)
+			if err != nil {
+				t.Fatalf("ExtendParity() error = %v", err)
+			}
+			if bigger.Spec() != tt.want {
+				t.Fatalf("ExtendParity() codec = %s, want %s", bigger.Spec(), tt.want)
+			}
+			copy(extended[c.DataShards():], oldParity)
+
+			want := encodedStripe(t, bigger, 600)
+			for i := range want {
+				if !bytes.Equal(extended[i], want[i]) {
+					t.Fatalf("shard %d differs from a fresh %s encode", i, bigger.Spec())
+				}
+			}
+		})
+	}
+}
+
+func TestExtendParity_OldAndNewParityInterchangeable(t *testing.T) {
+	c, _ := NewReedSolomon(4, 2)
+	stripe := encodedStripe(t, c, 400)
+	bigger, extended, err := ExtendParity(c, stripe, 2)
+	if err != nil {
+		t.Fatalf("ExtendParity() error = %v", err)
+	}
+
+	// Any 4 losses are repaired, mixing old (4, 5) and new (6, 7) parity
+	checkErasures(t, bigger, bigger.ParityShards())
+	shards := make([][]byte, len(extended))
+	copy(shards, extended)
+	shards[0], shards[1], shards[4], shards[7] = nil, nil, nil, nil
+	if err := bigger.Reconstruct(shards); err != nil {
+		t.Fatalf("Reconstruct() error = %v", err)
+	}
+	for i := range extended {
+		if !bytes.Equal(shards[i], extended[i]) {
+			t.Errorf("shard %d not recovered", i)
+		}
+	}
+}
+
+type plainCodec struct{ Codec }
+
+func TestExtendParity_Errors(t *testing.T) {
+	rs, _ := NewReedSolomon(4, 2)
+	stripe := encodedStripe(t, rs, 400)
+
+	if _, _, err := ExtendParity(plainCodec{rs}, stripe, 2); !errors.Is(err, ErrNotExtendable) {
+		t.Errorf("ExtendParity(plain) error = %v, want %v", err, ErrNotExtendable)
+	}
+	if _, _, err := ExtendParity(rs, stripe, 0); err != ErrInvalidParams {
+		t.Errorf("ExtendParity(0) error = %v, want %v", err, ErrInvalidParams)
+	}
+	if _, _, err := ExtendParity(rs, stripe[:5], 1); err != ErrShardCount {
+		t.Errorf("ExtendParity(5 shards) error = %v, want %v", err, ErrShardCount)
+	}
+	if _, _, err := ExtendParity(rs, stripe, 251); err != ErrInvalidParams {
+		t.Errorf("ExtendParity(past 256) error = %v, want %v", err, ErrInvalidParams)
+	}
+	stripe[2] = nil
+	if _, _, err := ExtendParity(rs, stripe, 1); err != ErrTooFewShards {
+		t.Errorf("ExtendParity(missing data) error = %v, want %v", err, ErrTooFewShards)
+	}
+}
@@ -0,0 +1,97 @@
+package codec
+
+import "github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/fft"
+
+func init() {
+	Register("fft", func(params []int) (Codec, error) {
+		if len(params) != 2 {
+			return nil, ErrInvalidParams
+		}
+		return NewFFT(params[0], params[1])
+	})
+	Register("fft16", func(params []int) (Codec, error) {
+		if len(params) != 2 {
+			return nil, ErrInvalidParams
+		}
+		return NewFFT16(params[0], params[1])
+	})
+}
+
+// FFT is a Reed-Solomon code computed with the additive FFT of package
+// fft: O(log m) work per byte to encode instead of the matrix codecs' O(m),
+// which pays off for wide stripes. Spec: "fft:k+m" over GF(2^8) or
+// "fft16:k+m" over GF(2^16).
+//
+// Its parity differs from rs and rs16 parity, and it is not a
+// ParityExtender: the parity positions depend on NextPow2(m).
+type FFT struct {
+	code *fft.Code
+	name string
+}
+
+// NewFFT creates a k+m FFT Reed-Solomon codec over GF(2^8)
+//
+// Errors:
+//   - ErrInvalidParams unless k >= 1, m >= 1 and k + NextPow2(m) <= 256
+func NewFFT(k, m int) (*FFT, error) {
+	return newFFT("fft", 8, k, m)
+}
+
+// NewFFT16 creates a k+m FFT Reed-Solomon codec over GF(2^16)
+//
+// Errors:
+//   - ErrInvalidParams unless k >= 1, m >= 1 and k + NextPow2(m) <= 65536
+func NewFFT16(k, m int) (*FFT, error) {
+	return newFFT("fft16", 16, k, m)
+}
+
+func newFFT(name string, bits, k, m int) (*FFT, error) {
+	code, err := fft.New(bits, k, m)
+	if err != nil {
+		return nil, ErrInvalidParams
+	}
+	return &FFT{code: code, name: name}, nil
+}
+
+// Spec returns "fft:k+m" or "fft16:k+m"
+func (c *FFT) Spec() string { return FormatSpec(c.name, c.code.DataShards(), c.code.ParityShards()) }
+
+// DataShards returns k
+func (c *FFT) DataShards() int { return c.code.DataShards() }
+
+// ParityShards returns m
+func (c *FFT) ParityShards() int { return c.code.ParityShards() }
+
+// ShardAlignment returns the field's word size: 1 for fft, 2 for fft16
+func (c *FFT) ShardAlignment() int { return c.code.WordSize() }
+
+// Encode computes the m parity shards
+func (c *FFT) Encode(shards [][]byte) error {
+	if _, err := checkShards(c, shards, false); err != nil {
+		return err
+	}
+	k := c.DataShards()
+	return fftError(c.code.Encode(shards[:k], shards[k:]))
+}
+
+// Reconstruct rebuilds up to m missing shards
+func (c *FFT) Reconstruct(shards [][]byte) error {
+	if _, err := checkShards(c, shards, true); err != nil {
+		return err
+	}
+	k := c.DataShards()
+	return fftError(c.code.Reconstruct(shards[:k], shards[k:]))
+}
+
+// fftError maps package fft errors to the codec errors
+func fftError(err error) error {
+	switch err {
+	case fft.ErrShardCount:
+		return ErrShardCount
+	case fft.ErrShardSize:
+		return ErrShardSize
+	case fft.ErrTooFewShards:
+		return ErrTooFewShards
+	}
+	return err
+}
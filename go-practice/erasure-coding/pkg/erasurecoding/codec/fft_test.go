@@ -0,0 +1,94 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestFFT_AnyMErasures(t *testing.T) {
+	for _, spec := range []string{"fft:4+2", "fft:6+3", "fft16:4+2", "fft16:10+4"} {
+		t.Run(spec, func(t *testing.T) {
+			c, _ := Parse(spec)
+			for lost := 1; lost <= c.ParityShards(); lost++ {
+				checkErasures(t, c, lost)
+			}
+		})
+	}
+}
+
+func TestFFT_ShardAlignment(t *testing.T) {
+	c8, _ := NewFFT(4, 2)
+	c16, _ := NewFFT16(4, 2)
+	if Alignment(c8) != 1 || Alignment(c16) != 2 {
+		t.Errorf("Alignment() = %d, %d, want 1, 2", Alignment(c8), Alignment(c16))
+	}
+	odd := make([][]byte, 6)
+	for i := range odd {
+		odd[i] = make([]byte, 3)
+	}
+	if err := c16.Encode(odd); err != ErrShardSize {
+		t.Errorf("Encode(odd size) error = %v, want %v", err, ErrShardSize)
+	}
+}
+
+func TestFFT_NotExtendable(t *testing.T) {
+	c, _ := NewFFT(4, 2)
+	if _, _, err := ExtendParity(c, encodedStripe(t, c, 100), 1); !errors.Is(err, ErrNotExtendable) {
+		t.Errorf("ExtendParity() error = %v, want %v", err, ErrNotExtendable)
+	}
+}
+
+// crossoverGeometries are stripes of growing width with 20% overhead,
+// the largest ones only possible over GF(2^16)
+var crossoverGeometries = [][2]int{{8, 2}, {16, 4}, {32, 8}, {64, 16}, {128, 32}, {200, 50}, {400, 100}, {800, 200}, {1600, 400}}
+
+// Benchmark matrix and FFT Reed-Solomon encoding at increasing k+m to find
+// the width from which the FFT codecs win; compare with
+//
+//	go test ./pkg/erasurecoding/codec -run xxx -bench Crossover
+func BenchmarkCrossoverEncode(b *testing.B) {
+	const shardSize = 4096
+	for _, km := range crossoverGeometries {
+		for _, name := range []string{"rs", "fft", "rs16", "fft16"} {
+			c, err := Parse(FormatSpec(name, km[0], km[1]))
+			if err != nil {
+				continue // too wide for GF(2^8)
+			}
+			b.Run(fmt.Sprintf("%d+%d/%s", km[0], km[1], name), func(b *testing.B) {
+				shards := encodedStripe(b, c, km[0]*shardSize)
+				b.SetBytes(int64(km[0] * shardSize))
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					_ = c.Encode(shards)
+				}
+			})
+		}
+	}
+}
+
+// Benchmark decoding with m data shards lost, the worst case for both
+func BenchmarkCrossoverReconstruct(b *testing.B) {
+	const shardSize = 4096
+	for _, km := range crossoverGeometries {
+		for _, name := range []string{"rs", "fft", "rs16", "fft16"} {
+			c, err := Parse(FormatSpec(name, km[0], km[1]))
+			if err != nil {
+				continue
+			}
+			b.Run(fmt.Sprintf("%d+%d/%s", km[0], km[1], name), func(b *testing.B) {
+				want := encodedStripe(b, c, km[0]*shardSize)
+				shards := make([][]byte, len(want))
+				b.SetBytes(int64(km[0] * shardSize))
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					copy(shards, want)
+					for j := 0; j < km[1]; j++ {
+						shards[j] = nil
+					}
+					_ = c.Reconstruct(shards)
+				}
+			})
+		}
+	}
+}
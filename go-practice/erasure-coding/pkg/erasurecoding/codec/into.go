@@ -0,0 +1,92 @@
+package codec
+
+// IntoReconstructor is implemented by codecs that rebuild missing shards
+// into buffers the caller provides: xor, replica, rs, rs16, lrc and cauchy
+//
+// ReconstructInto works like Reconstruct, except that a missing shard given
+// as an empty slice with capacity for a whole shard (buf[:0]) is rebuilt
+// in that buffer. With buffers for every missing shard and a decode-cache
+// hit, xor, replica, rs, rs16 and lrc allocate nothing.
+type IntoReconstructor interface {
+	ReconstructInto(shards [][]byte) error
+}
+
+// EncodeInto splits data over the data shards of a stripe the caller
+// provides, zero-padding it like Split, and encodes the parity into the
+// remaining shards
+//
+// Each shard needs capacity for ChunkSize(c, len(data)) bytes; it is
+// resliced to that length. Together with ShardPool this lets steady-state
+// encoding run without allocating.
+//
+// Errors:
+//   - ErrEmptyData if input is empty
+//   - ErrShardCount unless there are TotalShards(c) shards
+//   - ErrShardSize if a shard buffer is too small
+func EncodeInto(c Codec, data []byte, shards [][]byte) error {
+	if len(data) == 0 {
+		return ErrEmptyData
+	}
+	if len(shards) != TotalShards(c) {
+		return ErrShardCount
+	}
+	chunkSize := ChunkSize(c, len(data))
+	for i, shard := range shards {
+		if cap(shard) < chunkSize {
+			return ErrShardSize
+		}
+		shards[i] = shard[:chunkSize]
+	}
+	for i, shard := range shards[:c.DataShards()] {
+		n := 0
+		if start := i * chunkSize; start < len(data) {
+			n = copy(shard, data[start:])
+		}
+		clear(shard[n:])
+	}
+	return c.Encode(shards)
+}
+
+// ReconstructInto rebuilds every missing shard, reusing the buffers of
+// empty shards that have the capacity (see IntoReconstructor)
+//
+// Codecs that are not IntoReconstructors reconstruct as usual and the
+// result is copied into the buffers.
+func ReconstructInto(c Codec, shards [][]byte) error {
+	if r, ok := c.(IntoReconstructor); ok {
+		return r.ReconstructInto(shards)
+	}
+	bufs := make([][]byte, len(shards))
+	for i, shard := range shards {
+		if len(shard) == 0 {
+			bufs[i] = shard
+			shards[i] = nil
+		}
+	}
+	if err := c.Reconstruct(shards); err != nil {
+		for i, buf := range bufs {
+			if buf != nil {
+				shards[i] = buf
+			}
+		}
+		return err
+	}
+	for i, buf := range bufs {
+		if buf != nil && cap(buf) >= len(shards[i]) {
+			shards[i] = append(buf[:0], shards[i]...)
+		}
+	}
+	return nil
+}
+
+// rebuildBuffer returns a zeroed buffer of size bytes to rebuild a missing
+// shard into: the shard's own capacity if reuse is set and it is large
+// enough, a new slice otherwise
+func rebuildBuffer(shard []byte, size int, reuse bool) []byte {
+	if reuse && cap(shard) >= size {
+		shard = shard[:size]
+		clear(shard)
+		return shard
+	}
+	return make([]byte, size)
+}
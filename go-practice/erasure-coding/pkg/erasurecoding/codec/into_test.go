@@ -0,0 +1,244 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// zeroAllocSpecs are the codecs whose EncodeInto and ReconstructInto do
+// not allocate in steady state
+var zeroAllocSpecs = []string{"xor:4+1", "replica:1+2", "rs:10+4", "rs16:10+4", "lrc:6+2+2"}
+
+// pooledStripe returns a stripe of buffers from pool
+func pooledStripe(c Codec, pool *ShardPool) [][]byte {
+	shards := make([][]byte, TotalShards(c))
+	for i := range shards {
+		shards[i] = pool.Get()
+	}
+	return shards
+}
+
+func TestEncodeInto_MatchesSplitAndEncode(t *testing.T) {
+	for _, spec := range append(zeroAllocSpecs, "cauchy:4+2", "fft:4+2") {
+		t.Run(spec, func(t *testing.T) {
+			c, _ := Parse(spec)
+			data := bytes.Repeat([]byte("reuse the buffers "), 100)
+			want, err := Split(c, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.Encode(want); err != nil {
+				t.Fatal(err)
+			}
+
+			// Dirty, oversized buffers: the padding must still come out zero
+			shards := make([][]byte, len(want))
+			for i := range shards {
+				shards[i] = bytes.Repeat([]byte{0xee}, len(want[0])+10)
+			}
+			if err := EncodeInto(c, data, shards); err != nil {
+				t.Fatalf("EncodeInto() error = %v", err)
+			}
+			for i := range want {
+				if !bytes.Equal(shards[i], want[i]) {
+					t.Fatalf("shard %d differs from Split and Encode", i)
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeInto_Errors(t *testing.T) {
+	c, _ := NewReedSolomon(4, 2)
+	tests := []struct {
+		name   string
+		data   []byte
+		shards [][]byte
+		want   error
+	}{
+		{"empty data", nil, make([][]byte, 6), ErrEmptyData},
+		{"shard count", []byte("abcd"), make([][]byte, 5), ErrShardCount},
+		{"small buffer", make([]byte, 100), [][]byte{make([]byte, 25), make([]byte, 25), make([]byte, 24), make([]byte, 25), make([]byte, 25), make([]byte, 25)}, ErrShardSize},
+	}
+	for _, tt := range tests {
+		if err := EncodeInto(c, tt.data, tt.shards); !errors.Is(err, tt.want) {
+			t.Errorf("%s: EncodeInto() error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+// Missing shards passed as buf[:0] are rebuilt in buf, for every codec
+func TestReconstructInto_ReusesBuffers(t *testing.T) {
+	for _, spec := range append(zeroAllocSpecs, "cauchy:4+2", "fft:4+2") {
+		t.Run(spec, func(t *testing.T) {
+			c, _ := Parse(spec)
+			want := encodedStripe(t, c, 4000)
+			shards := make([][]byte, len(want))
+			copy(shards, want)
+			lost := []int{0, len(want) - 1}
+			if c.ParityShards() == 1 {
+				lost = lost[:1]
+			}
+			bufs := make([][]byte, len(want))
+			for _, i := range lost {
+				bufs[i] = bytes.Repeat([]byte{0xee}, len(want[i]))
+				shards[i] = bufs[i][:0]
+			}
+			if err := ReconstructInto(c, shards); err != nil {
+				t.Fatalf("ReconstructInto() error = %v", err)
+			}
+			for i := range want {
+				if !bytes.Equal(shards[i], want[i]) {
+					t.Fatalf("shard %d differs", i)
+				}
+			}
+			for _, i := range lost {
+				if &shards[i][0] != &bufs[i][0] {
+					t.Errorf("shard %d was not rebuilt in its buffer", i)
+				}
+			}
+		})
+	}
+}
+
+func TestReconstructInto_SmallBufferAllocates(t *testing.T) {
+	c, _ := NewReedSolomon(4, 2)
+	want := encodedStripe(t, c, 400)
+	shards := make([][]byte, len(want))
+	copy(shards, want)
+	small := make([]byte, 10)
+	shards[1] = small[:0]
+	if err := ReconstructInto(c, shards); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(shards[1], want[1]) {
+		t.Error("shard 1 differs")
+	}
+}
+
+// Steady state: one stripe of pooled buffers encoded and repaired again
+// and again allocates nothing
+func TestEncodeInto_ZeroAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("sync.Pool drops items at random under the race detector")
+	}
+	for _, spec := range zeroAllocSpecs {
+		c, _ := Parse(spec)
+		data := make([]byte, 64<<10)
+		pool := NewShardPool(ChunkSize(c, len(data)))
+		allocs := testing.AllocsPerRun(100, func() {
+			shards := pooledStripe(c, pool)
+			if err := EncodeInto(c, data, shards); err != nil {
+				t.Fatal(err)
+			}
+			for i := range shards {
+				pool.Put(shards[i])
+			}
+		})
+		// The [][]byte header of the stripe is the caller's; only count
+		// what the codec and the pool add
+		if allocs > 1 {
+			t.Errorf("%s: EncodeInto with pooled shards: %v allocs/op, want only the stripe header", spec, allocs)
+		}
+
+		shards := pooledStripe(c, pool)
+		if err := EncodeInto(c, data, shards); err != nil {
+			t.Fatal(err)
+		}
+		allocs = testing.AllocsPerRun(100, func() {
+			shards[0] = shards[0][:0]
+			if err := ReconstructInto(c, shards); err != nil {
+				t.Fatal(err)
+			}
+		})
+		if allocs != 0 {
+			t.Errorf("%s: ReconstructInto: %v allocs/op, want 0", spec, allocs)
+		}
+	}
+}
+
+// Benchmark steady-state encoding and repair into reused buffers; both
+// fail unless they run without allocating
+func BenchmarkEncodeInto(b *testing.B) {
+	const size = 1 << 20
+	for _, spec := range zeroAllocSpecs {
+		c, _ := Parse(spec)
+		data := make([]byte, size)
+		shards := pooledStripe(c, NewShardPool(ChunkSize(c, size)))
+		b.Run(spec, func(b *testing.B) {
+			if allocs := testing.AllocsPerRun(10, func() { _ = EncodeInto(c, data, shards) }); allocs != 0 {
+				b.Fatalf("EncodeInto: %v allocs/op, want 0", allocs)
+			}
+			b.ReportAllocs()
+			b.SetBytes(size)
+			for i := 0; i < b.N; i++ {
+				_ = EncodeInto(c, data, shards)
+			}
+		})
+	}
+}
+
+func BenchmarkReconstructInto(b *testing.B) {
+	const size = 1 << 20
+	for _, spec := range zeroAllocSpecs {
+		c, _ := Parse(spec)
+		shards := pooledStripe(c, NewShardPool(ChunkSize(c, size)))
+		_ = EncodeInto(c, make([]byte, size), shards)
+		lose := func() {
+			shards[0] = shards[0][:0]
+			_ = ReconstructInto(c, shards)
+		}
+		b.Run(spec, func(b *testing.B) {
+			if allocs := testing.AllocsPerRun(10, lose); allocs != 0 {
+				b.Fatalf("ReconstructInto: %v allocs/op, want 0", allocs)
+			}
+			b.ReportAllocs()
+			b.SetBytes(size)
+			for i := 0; i < b.N; i++ {
+				lose()
+			}
+		})
+	}
+}
+
+// Benchmark the allocating path for comparison
+func BenchmarkSplitEncode(b *testing.B) {
+	const size = 1 << 20
+	for _, spec := range zeroAllocSpecs {
+		c, _ := Parse(spec)
+		data := make([]byte, size)
+		b.Run(spec, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(size)
+			for i := 0; i < b.N; i++ {
+				shards, _ := Split(c, data)
+				_ = c.Encode(shards)
+			}
+		})
+	}
+}
+
+func ExampleEncodeInto() {
+	c, _ := Parse("rs:4+2")
+	data := []byte("steady-state encoding without garbage")
+	pool := NewShardPool(ChunkSize(c, len(data)))
+	shards := make([][]byte, TotalShards(c))
+	for i := range shards {
+		shards[i] = pool.Get()
+	}
+	if err := EncodeInto(c, data, shards); err != nil {
+		panic(err)
+	}
+
+	shards[2] = shards[2][:0] // lost, but keep the buffer
+	if err := ReconstructInto(c, shards); err != nil {
+		panic(err)
+	}
+	fmt.Printf("%s\n", Join(c, shards, len(data)))
+	for _, s := range shards {
+		pool.Put(s)
+	}
+	// Output: steady-state encoding without garbage
+}
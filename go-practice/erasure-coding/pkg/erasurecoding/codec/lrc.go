@@ -0,0 +1,139 @@
+package codec
+
+import "github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/gf256"
+
+func init() {
+	Register("lrc", func(params []int) (Codec, error) {
+		if len(params) != 3 {
+			return nil, ErrInvalidParams
+		}
+		return NewLRC(params[0], params[1], params[2])
+	})
+}
+
+// LRC is a locally repairable code in the style of Azure's LRC(k, l, r)
+//
+// The k data shards are split into l equal local groups. Each group gets a
+// local parity shard, the XOR of its members, so a single lost shard is
+// rebuilt from k/l shards instead of k. On top of that r global parity
+// shards (Cauchy rows, as in ReedSolomon) cover all data shards and handle
+// larger failures.
+//
+// Shard order: k data shards, l local parities (group order), r global
+// parities. Spec: "lrc:k+l+r".
+type LRC struct {
+	matrixCode[byte]
+	groups int
+	global int
+}
+
+// NewLRC creates an LRC codec with k data shards, l local groups and r
+// global parities
+//
+// Errors:
+//   - ErrInvalidParams unless k >= 2, l >= 1, l divides k, r >= 0 and
+//     k+l+r <= 256
+func NewLRC(k, l, r int) (*LRC, error) {
+	if k < 2 || l < 1 || k%l != 0 || r < 0 || k+l+r > 256 {
+		return nil, ErrInvalidParams
+	}
+	gen := gf256.Identity(k)
+	size := k / l
+	for g := 0; g < l; g++ {
+		row := make([]byte, k)
+		for j := g * size; j < (g+1)*size; j++ {
+			row[j] = 1
+		}
+		gen = append(gen, row)
+	}
+	global, err := gf256.Cauchy(r, k, 0)
+	if err != nil {
+		return nil, ErrInvalidParams
+	}
+	gen = append(gen, global...)
+	return &LRC{matrixCode: newMatrixCode[byte](k, gen, gf8{}), groups: l, global: r}, nil
+}
+
+// Spec returns "lrc:k+l+r"
+func (c *LRC) Spec() string { return FormatSpec("lrc", c.k, c.groups, c.global) }
+
+// DataShards returns k
+func (c *LRC) DataShards() int { return c.k }
+
+// ParityShards returns l+r
+func (c *LRC) ParityShards() int { return c.groups + c.global }
+
+// LocalGroup returns the shard indexes of group g: its data shards followed
+// by its local parity
+func (c *LRC) LocalGroup(g int) []int {
+	members := make([]int, c.k/c.groups+1)
+	for t := range members {
+		members[t] = c.member(g, t)
+	}
+	return members
+}
+
+// member returns the shard index of member t of group g, in LocalGroup
+// order
+func (c *LRC) member(g, t int) int {
+	size := c.k / c.groups
+	if t == size {
+		return c.k + g
+	}
+	return g*size + t
+}
+
+// Encode computes the local and global parity shards
+func (c *LRC) Encode(shards [][]byte) error {
+	size, err := checkShards(c, shards, false)
+	if err != nil {
+		return err
+	}
+	c.encode(shards, size)
+	return nil
+}
+
+// Reconstruct repairs single losses inside a local group from that group
+// alone and falls back to decoding with the global parities otherwise
+func (c *LRC) Reconstruct(shards [][]byte) error {
+	return c.reconstructShards(shards, false)
+}
+
+// ReconstructInto is Reconstruct rebuilding into the missing shards'
+// buffers
+func (c *LRC) ReconstructInto(shards [][]byte) error {
+	return c.reconstructShards(shards, true)
+}
+
+func (c *LRC) reconstructShards(shards [][]byte, reuse bool) error {
+	size, err := checkShards(c, shards, true)
+	if err != nil {
+		return err
+	}
+	// Members are walked by index rather than through LocalGroup so that
+	// ReconstructInto does not allocate
+	members := c.k/c.groups + 1
+	for g := 0; g < c.groups; g++ {
+		lost := -1
+		for t := 0; t < members; t++ {
+			if i := c.member(g, t); len(shards[i]) == 0 {
+				if lost >= 0 {
+					lost = -2
+					break
+				}
+				lost = i
+			}
+		}
+		if lost < 0 {
+			continue
+		}
+		recovered := rebuildBuffer(shards[lost], size, reuse)
+		for t := 0; t < members; t++ {
+			if i := c.member(g, t); i != lost {
+				xorInto(recovered, shards[i][:size])
+			}
+		}
+		shards[lost] = recovered
+	}
+	return c.reconstruct(shards, size, reuse)
+}
@@ -0,0 +1,46 @@
+package codec
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLRC_AnyRPlusOneErasures(t *testing.T) {
+	for _, spec := range []string{"lrc:4+2+1", "lrc:6+2+2", "lrc:12+2+2"} {
+		t.Run(spec, func(t *testing.T) {
+			c, _ := Parse(spec)
+			lrc := c.(*LRC)
+			for lost := 1; lost <= lrc.global+1; lost++ {
+				checkErasures(t, c, lost)
+			}
+		})
+	}
+}
+
+func TestLRC_LocalRepairNeedsOnlyTheGroup(t *testing.T) {
+	c, _ := NewLRC(6, 2, 2)
+	want := encodedStripe(t, c, 600)
+
+	// Keep only group 0 minus one member: nothing else is needed
+	group := c.LocalGroup(0)
+	shards := make([][]byte, len(want))
+	for _, i := range group[1:] {
+		shards[i] = want[i]
+	}
+	if err := c.Reconstruct(shards); err != ErrTooFewShards {
+		t.Fatalf("Reconstruct() error = %v, want %v for the other group", err, ErrTooFewShards)
+	}
+	if !bytes.Equal(shards[group[0]], want[group[0]]) {
+		t.Errorf("shard %d not repaired from its local group", group[0])
+	}
+}
+
+func TestLRC_LocalGroup(t *testing.T) {
+	c, _ := NewLRC(6, 2, 2)
+	if got := c.LocalGroup(1); len(got) != 4 || got[0] != 3 || got[3] != 7 {
+		t.Errorf("LocalGroup(1) = %v, want [3 4 5 7]", got)
+	}
+	if c.Spec() != "lrc:6+2+2" || c.ParityShards() != 4 {
+		t.Errorf("Spec() = %q, ParityShards() = %d", c.Spec(), c.ParityShards())
+	}
+}
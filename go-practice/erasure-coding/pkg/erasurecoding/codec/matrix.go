@@ -0,0 +1,179 @@
+package codec
+
+import (
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/gf256"
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/gf65536"
+)
+
+// element is a Galois field element: a byte for GF(2^8), a uint16 for
+// GF(2^16)
+type element interface {
+	~uint8 | ~uint16
+}
+
+// field is the arithmetic a matrixCode needs. Only whole-shard operations
+// go through it, so the per-byte loops stay in the field packages and the
+// indirection costs one call per shard and generator entry.
+type field[E element] interface {
+	// wordSize is the number of shard bytes one element covers; shard
+	// sizes must be a multiple of it
+	wordSize() int
+	mulAddSlice(c E, in, out []byte)
+	invert(m [][]E) ([][]E, error)
+	newBasis(cols int) basis[E]
+}
+
+// basis picks linearly independent generator rows
+type basis[E element] interface {
+	Add(v []E) bool
+}
+
+// gf8 is GF(2^8) from package gf256
+type gf8 struct{}
+
+func (gf8) wordSize() int                       { return 1 }
+func (gf8) mulAddSlice(c byte, in, out []byte)  { gf256.MulAddSlice(c, in, out) }
+func (gf8) invert(m [][]byte) ([][]byte, error) { return gf256.Matrix(m).Invert() }
+func (gf8) newBasis(cols int) basis[byte]       { return gf256.NewBasis(cols) }
+
+// gf16 is GF(2^16) from package gf65536; shard bytes are little-endian
+// 16-bit words
+type gf16 struct{}
+
+func (gf16) wordSize() int                           { return 2 }
+func (gf16) mulAddSlice(c uint16, in, out []byte)    { gf65536.MulAddSlice(c, in, out) }
+func (gf16) invert(m [][]uint16) ([][]uint16, error) { return gf65536.Matrix(m).Invert() }
+func (gf16) newBasis(cols int) basis[uint16]         { return gf65536.NewBasis(cols) }
+
+// matrixCode is a systematic linear code over GF(2^8) or GF(2^16)
+// described by its (k+p)×k generator matrix: shard i = Σ_j gen[i][j] ·
+// data_j. The first k rows are the identity. Reed-Solomon and LRC are both
+// matrix codes and share the encoder and the general decoder below.
+type matrixCode[E element] struct {
+	k     int
+	gen   [][]E
+	field field[E]
+	cache *decodeCache[matrixDecoder[E]]
+}
+
+// matrixDecoder is what reconstruct caches per erasure pattern: the
+// surviving shards it decodes from and the inverse of their generator rows
+type matrixDecoder[E element] struct {
+	rows   []int
+	decode [][]E
+}
+
+func newMatrixCode[E element](k int, gen [][]E, f field[E]) matrixCode[E] {
+	return matrixCode[E]{k: k, gen: gen, field: f, cache: newDecodeCache[matrixDecoder[E]](DefaultDecodeCacheSize)}
+}
+
+// DecodeCacheStats returns the decode cache counters
+func (mc *matrixCode[E]) DecodeCacheStats() DecodeCacheStats { return mc.cache.stats() }
+
+// SetDecodeCacheSize bounds the decode cache to n erasure patterns; 0
+// disables it
+func (mc *matrixCode[E]) SetDecodeCacheSize(n int) { mc.cache.resize(n) }
+
+// checkWords rejects shard sizes that do not divide into field elements
+func (mc *matrixCode[E]) checkWords(size int) error {
+	if size%mc.field.wordSize() != 0 {
+		return ErrShardSize
+	}
+	return nil
+}
+
+// encode fills every parity shard from the data shards
+func (mc *matrixCode[E]) encode(shards [][]byte, size int) {
+	for i := mc.k; i < len(shards); i++ {
+		mc.encodeRow(i, shards, shards[i][:size])
+	}
+}
+
+// encodeRow computes shard row i of the generator into out
+func (mc *matrixCode[E]) encodeRow(i int, shards [][]byte, out []byte) {
+	for b := range out {
+		out[b] = 0
+	}
+	for j, c := range mc.gen[i] {
+		mc.field.mulAddSlice(c, shards[j][:len(out)], out)
+	}
+}
+
+// reconstruct rebuilds every missing shard by choosing k surviving shards
+// whose generator rows are independent, inverting that k×k system to get
+// the data shards back, and re-encoding missing parity. The choice and the
+// inverse depend only on which shards are missing, so they are cached.
+func (mc *matrixCode[E]) reconstruct(shards [][]byte, size int, reuse bool) error {
+	dataMissing, parityMissing := false, false
+	for i, shard := range shards {
+		if len(shard) == 0 {
+			if i < mc.k {
+				dataMissing = true
+			} else {
+				parityMissing = true
+			}
+		}
+	}
+
+	if dataMissing {
+		var keyBuf [32]byte
+		key := patternKey(keyBuf[:0], shards)
+		d, ok := mc.cache.get(key)
+		if !ok {
+			var err error
+			if d, err = mc.decoder(shards); err != nil {
+				return err
+			}
+			mc.cache.put(key, d)
+		}
+		for i := 0; i < mc.k; i++ {
+			if len(shards[i]) > 0 {
+				continue
+			}
+			out := rebuildBuffer(shards[i], size, reuse)
+			for t, r := range d.rows {
+				mc.field.mulAddSlice(d.decode[i][t], shards[r][:size], out)
+			}
+			shards[i] = out
+		}
+	}
+
+	if parityMissing {
+		for i := mc.k; i < len(shards); i++ {
+			if len(shards[i]) == 0 {
+				shards[i] = rebuildBuffer(shards[i], size, reuse)
+				mc.encodeRow(i, shards, shards[i])
+			}
+		}
+	}
+	return nil
+}
+
+// decoder picks k surviving shards with independent generator rows and
+// inverts them
+func (mc *matrixCode[E]) decoder(shards [][]byte) (matrixDecoder[E], error) {
+	// Data shards come first, so an intact data shard is always chosen and
+	// the system stays as close to the identity as possible
+	basis := mc.field.newBasis(mc.k)
+	var rows []int
+	for i, shard := range shards {
+		if len(shard) > 0 && basis.Add(mc.gen[i]) {
+			rows = append(rows, i)
+			if len(rows) == mc.k {
+				break
+			}
+		}
+	}
+	if len(rows) < mc.k {
+		return matrixDecoder[E]{}, ErrTooFewShards
+	}
+	sub := make([][]E, len(rows))
+	for t, r := range rows {
+		sub[t] = mc.gen[r]
+	}
+	decode, err := mc.field.invert(sub)
+	if err != nil {
+		return matrixDecoder[E]{}, ErrTooFewShards
+	}
+	return matrixDecoder[E]{rows: rows, decode: decode}, nil
+}
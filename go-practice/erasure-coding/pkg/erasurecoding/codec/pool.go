@@ -0,0 +1,86 @@
+package codec
+
+import (
+	"sync"
+	"unsafe"
+)
+
+// ShardBufferAlignment is the address alignment of ShardPool buffers: a
+// cache line, and the width of the widest vector loads the gf256 kernels
+// use
+const ShardBufferAlignment = 64
+
+// ShardPool recycles shard buffers of one size through a sync.Pool
+//
+// Every buffer starts at a multiple of ShardBufferAlignment, so vector
+// loops never split a cache line at the start of a shard. Get and Put do
+// not allocate once the pool is warm.
+//
+// Example:
+//
+//	pool := codec.NewShardPool(codec.ChunkSize(c, objectSize))
+//	shards := make([][]byte, codec.TotalShards(c))
+//	for i := range shards {
+//	    shards[i] = pool.Get()
+//	}
+//	err := codec.EncodeInto(c, data, shards)
+//	// ... write the shards out ...
+//	for _, s := range shards {
+//	    pool.Put(s)
+//	}
+type ShardPool struct {
+	size int
+	// bufs holds *[]byte boxes with a buffer in them and boxes the empty
+	// ones, so that Put can store a slice without allocating a new box
+	bufs, boxes sync.Pool
+}
+
+// NewShardPool creates a pool of buffers of size bytes
+func NewShardPool(size int) *ShardPool {
+	return &ShardPool{size: max(size, 0)}
+}
+
+// Size returns the length of the buffers in the pool
+func (p *ShardPool) Size() int { return p.size }
+
+// Get returns an aligned buffer of Size() bytes. A recycled buffer keeps
+// its old contents.
+func (p *ShardPool) Get() []byte {
+	if box, ok := p.bufs.Get().(*[]byte); ok {
+		buf := *box
+		*box = nil
+		p.boxes.Put(box)
+		return buf
+	}
+	return alignedBuffer(p.size)
+}
+
+// Put returns a buffer to the pool. Buffers that did not come from a pool
+// of this size (too small, or misaligned) are dropped.
+func (p *ShardPool) Put(buf []byte) {
+	if cap(buf) < p.size || !aligned(buf) {
+		return
+	}
+	box, ok := p.boxes.Get().(*[]byte)
+	if !ok {
+		box = new([]byte)
+	}
+	*box = buf[:p.size]
+	p.bufs.Put(box)
+}
+
+// alignedBuffer allocates size bytes starting at a multiple of
+// ShardBufferAlignment
+func alignedBuffer(size int) []byte {
+	raw := make([]byte, size+ShardBufferAlignment-1)
+	off := 0
+	if rem := int(uintptr(unsafe.Pointer(unsafe.SliceData(raw))) % ShardBufferAlignment); rem != 0 {
+		off = ShardBufferAlignment - rem
+	}
+	return raw[off : off+size : off+size]
+}
+
+// aligned reports whether buf starts at a multiple of ShardBufferAlignment
+func aligned(buf []byte) bool {
+	return uintptr(unsafe.Pointer(unsafe.SliceData(buf)))%ShardBufferAlignment == 0
+}
@@ -0,0 +1,55 @@
+package codec
+
+import "testing"
+
+func TestShardPool_Aligned(t *testing.T) {
+	for _, size := range []int{1, 63, 64, 1000, 1 << 16} {
+		p := NewShardPool(size)
+		for i := 0; i < 10; i++ {
+			buf := p.Get()
+			if len(buf) != size {
+				t.Fatalf("Get() len = %d, want %d", len(buf), size)
+			}
+			if !aligned(buf) {
+				t.Fatalf("Get() buffer of %d bytes is not %d-byte aligned", size, ShardBufferAlignment)
+			}
+			p.Put(buf)
+		}
+	}
+}
+
+func TestShardPool_DropsForeignBuffers(t *testing.T) {
+	p := NewShardPool(128)
+	p.Put(make([]byte, 64)) // too small
+	own := alignedBuffer(256)
+	p.Put(own[1:129]) // misaligned
+	for i := 0; i < 10; i++ {
+		if buf := p.Get(); len(buf) != 128 || !aligned(buf) {
+			t.Fatalf("Get() = %d bytes, aligned %v", len(buf), aligned(buf))
+		}
+	}
+}
+
+func TestShardPool_ZeroAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("sync.Pool drops items at random under the race detector")
+	}
+	p := NewShardPool(4096)
+	p.Put(p.Get())
+	allocs := testing.AllocsPerRun(1000, func() {
+		p.Put(p.Get())
+	})
+	if allocs != 0 {
+		t.Errorf("Get and Put: %v allocs/op, want 0", allocs)
+	}
+}
+
+func BenchmarkShardPool(b *testing.B) {
+	p := NewShardPool(1 << 20)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			p.Put(p.Get())
+		}
+	})
+}
@@ -0,0 +1,168 @@
+package codec
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrShardUnavailable is returned by a ShardSource when a shard cannot be read
+var ErrShardUnavailable = &CodecError{"shard is unavailable"}
+
+// ShardSource provides random access to the shards of an encoded object
+//
+// Shards are numbered in stripe order: data shards first, then parity.
+// ReadShardAt follows io.ReaderAt semantics; any error marks the shard as
+// unavailable for that read.
+type ShardSource interface {
+	ReadShardAt(shard int, p []byte, off int64) (int, error)
+}
+
+// RangeReader reads byte ranges of an object encoded with any Codec
+//
+// phase1.RangeReader is this reader over an "xor:k+1" codec.
+//
+// It maps a byte range of the original object onto the data shards that
+// hold it and reads only those. If one of them fails, the same region is
+// read from every other shard and passed through the codec's Reconstruct,
+// so only the affected columns of the stripe are rebuilt.
+type RangeReader struct {
+	codec     Codec
+	src       ShardSource
+	chunkSize int
+	size      int64
+}
+
+// NewRangeReader creates a RangeReader over an object encoded with c
+//
+// Errors:
+//   - ErrInvalidLayout if the layout cannot hold size bytes
+func NewRangeReader(c Codec, src ShardSource, chunkSize int, size int64) (*RangeReader, error) {
+	if chunkSize < 0 || size < 0 || size > int64(c.DataShards())*int64(chunkSize) {
+		return nil, ErrInvalidLayout
+	}
+	return &RangeReader{codec: c, src: src, chunkSize: chunkSize, size: size}, nil
+}
+
+// Size returns the original (unpadded) object size
+func (r *RangeReader) Size() int64 {
+	return r.size
+}
+
+// NewSectionReader returns an io.SectionReader over bytes [off, off+n)
+func (r *RangeReader) NewSectionReader(off, n int64) *io.SectionReader {
+	return io.NewSectionReader(r, off, n)
+}
+
+// ReadAt implements io.ReaderAt over the original object bytes
+func (r *RangeReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, ErrNegativeOffset
+	}
+	if off >= r.size {
+		return 0, io.EOF
+	}
+
+	want := p
+	if remaining := r.size - off; int64(len(want)) > remaining {
+		want = want[:remaining]
+	}
+
+	n := 0
+	for n < len(want) {
+		pos := off + int64(n)
+		shard := int(pos / int64(r.chunkSize))
+		shardOff := pos % int64(r.chunkSize)
+
+		end := len(want)
+		if avail := int64(r.chunkSize) - shardOff; int64(end-n) > avail {
+			end = n + int(avail)
+		}
+
+		if err := r.readShardRange(shard, want[n:end], shardOff); err != nil {
+			return n, err
+		}
+		n = end
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// readShardRange fills p from a data shard, reconstructing on failure
+func (r *RangeReader) readShardRange(shard int, p []byte, off int64) error {
+	err := ReadShardFull(r.src, shard, p, off)
+	if err == nil {
+		return nil
+	}
+	region, recErr := ReconstructRegion(r.codec, r.src, shard, len(p), off)
+	if recErr != nil {
+		return errors.Join(err, recErr)
+	}
+	copy(p, region)
+	return nil
+}
+
+// ReconstructRegion rebuilds bytes [off, off+length) of shard target from
+// the same region of the other shards in src
+//
+// Shards that fail to read are treated as missing. The codec decides
+// whether the survivors are enough; otherwise ErrTooFewShards is returned.
+// For an Aligner the region is widened to whole words and trimmed again.
+func ReconstructRegion(c Codec, src ShardSource, target, length int, off int64) ([]byte, error) {
+	if a := int64(Alignment(c)); a > 1 && (off%a != 0 || int64(length)%a != 0) {
+		start := off / a * a
+		end := (off + int64(length) + a - 1) / a * a
+		wide, err := ReconstructRegion(c, src, target, int(end-start), start)
+		if err != nil {
+			return nil, err
+		}
+		return wide[off-start : off-start+int64(length)], nil
+	}
+	region := make([][]byte, TotalShards(c))
+	for i := range region {
+		if i == target {
+			continue
+		}
+		buf := make([]byte, length)
+		if err := ReadShardFull(src, i, buf, off); err == nil {
+			region[i] = buf
+		}
+	}
+	if err := c.Reconstruct(region); err != nil {
+		return nil, err
+	}
+	return region[target], nil
+}
+
+// ReadShardFull reads exactly len(p) bytes of a shard from src
+func ReadShardFull(src ShardSource, shard int, p []byte, off int64) error {
+	n, err := src.ReadShardAt(shard, p, off)
+	if n == len(p) {
+		return nil
+	}
+	if err == nil || err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// MemorySource is a ShardSource over in-memory shards; nil entries report
+// ErrShardUnavailable
+type MemorySource [][]byte
+
+// ReadShardAt implements ShardSource
+func (m MemorySource) ReadShardAt(shard int, p []byte, off int64) (int, error) {
+	if shard < 0 || shard >= len(m) || m[shard] == nil {
+		return 0, ErrShardUnavailable
+	}
+	if off >= int64(len(m[shard])) {
+		return 0, io.EOF
+	}
+	n := copy(p, m[shard][off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
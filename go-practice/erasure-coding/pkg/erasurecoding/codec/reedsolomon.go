@@ -0,0 +1,126 @@
+package codec
+
+import (
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/gf256"
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/gf65536"
+)
+
+func init() {
+	Register("rs", func(params []int) (Codec, error) {
+		if len(params) != 2 {
+			return nil, ErrInvalidParams
+		}
+		return NewReedSolomon(params[0], params[1])
+	})
+	Register("rs16", func(params []int) (Codec, error) {
+		if len(params) != 2 {
+			return nil, ErrInvalidParams
+		}
+		return NewReedSolomon16(params[0], params[1])
+	})
+}
+
+// reedSolomon is the field-independent part of the Reed-Solomon codecs
+type reedSolomon[E element] struct {
+	matrixCode[E]
+	name string
+	m    int
+}
+
+// ReedSolomon is a systematic Reed-Solomon code over GF(2^8): k data
+// shards and m parity shards, any k of which recover the stripe
+//
+// The parity rows of the generator form a Cauchy matrix, which makes every
+// k×k submatrix of the generator invertible (the code is MDS).
+type ReedSolomon struct {
+	reedSolomon[byte]
+}
+
+// ReedSolomon16 is the same code over GF(2^16), for stripes of up to
+// 65536 shards. Shard bytes are read as little-endian 16-bit words, so
+// shard sizes must be even; ChunkSize and Split take care of that. Spec:
+// "rs16:k+m".
+//
+// For stripes that fit in 256 shards ReedSolomon is faster.
+type ReedSolomon16 struct {
+	reedSolomon[uint16]
+}
+
+// NewReedSolomon creates a k+m Reed-Solomon codec
+//
+// Errors:
+//   - ErrInvalidParams unless k >= 1, m >= 1 and k+m <= 256
+func NewReedSolomon(k, m int) (*ReedSolomon, error) {
+	if k < 1 || m < 1 || k+m > 256 {
+		return nil, ErrInvalidParams
+	}
+	parity, err := gf256.Cauchy(m, k, 0)
+	if err != nil {
+		return nil, ErrInvalidParams
+	}
+	gen := append(gf256.Identity(k), parity...)
+	return &ReedSolomon{reedSolomon[byte]{matrixCode: newMatrixCode[byte](k, gen, gf8{}), name: "rs", m: m}}, nil
+}
+
+// NewReedSolomon16 creates a k+m Reed-Solomon codec over GF(2^16)
+//
+// Errors:
+//   - ErrInvalidParams unless k >= 1, m >= 1 and k+m <= 65536
+func NewReedSolomon16(k, m int) (*ReedSolomon16, error) {
+	if k < 1 || m < 1 || k+m > 1<<16 {
+		return nil, ErrInvalidParams
+	}
+	parity, err := gf65536.Cauchy(m, k, 0)
+	if err != nil {
+		return nil, ErrInvalidParams
+	}
+	gen := append(gf65536.Identity(k), parity...)
+	return &ReedSolomon16{reedSolomon[uint16]{matrixCode: newMatrixCode[uint16](k, gen, gf16{}), name: "rs16", m: m}}, nil
+}
+
+// ShardAlignment returns 2: shards hold whole 16-bit words
+func (r *ReedSolomon16) ShardAlignment() int { return 2 }
+
+// Spec returns "rs:k+m" or "rs16:k+m"
+func (r *reedSolomon[E]) Spec() string { return FormatSpec(r.name, r.k, r.m) }
+
+// DataShards returns k
+func (r *reedSolomon[E]) DataShards() int { return r.k }
+
+// ParityShards returns m
+func (r *reedSolomon[E]) ParityShards() int { return r.m }
+
+// Encode computes the m parity shards
+func (r *reedSolomon[E]) Encode(shards [][]byte) error {
+	size, err := checkShards(r, shards, false)
+	if err != nil {
+		return err
+	}
+	if err := r.checkWords(size); err != nil {
+		return err
+	}
+	r.encode(shards, size)
+	return nil
+}
+
+// Reconstruct rebuilds up to m missing shards
+func (r *reedSolomon[E]) Reconstruct(shards [][]byte) error {
+	return r.reconstructShards(shards, false)
+}
+
+// ReconstructInto is Reconstruct rebuilding into the missing shards'
+// buffers
+func (r *reedSolomon[E]) ReconstructInto(shards [][]byte) error {
+	return r.reconstructShards(shards, true)
+}
+
+func (r *reedSolomon[E]) reconstructShards(shards [][]byte, reuse bool) error {
+	size, err := checkShards(r, shards, true)
+	if err != nil {
+		return err
+	}
+	if err := r.checkWords(size); err != nil {
+		return err
+	}
+	return r.reconstruct(shards, size, reuse)
+}
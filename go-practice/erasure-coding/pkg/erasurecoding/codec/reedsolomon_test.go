@@ -0,0 +1,223 @@
+package codec
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// encodedStripe returns random data split and encoded with c
+func encodedStripe(t testing.TB, c Codec, size int) [][]byte {
+	t.Helper()
+	data := make([]byte, size)
+	rand.New(rand.NewSource(int64(size))).Read(data)
+	shards, err := Split(c, data)
+	if err != nil {
+		t.Fatalf("Split() error = %v", err)
+	}
+	if err := c.Encode(shards); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	return shards
+}
+
+// forEachErasure calls fn with every set of exactly lost shard indexes
+func forEachErasure(n, lost int, fn func(erased []int)) {
+	var rec func(start int, erased []int)
+	rec = func(start int, erased []int) {
+		if len(erased) == lost {
+			fn(erased)
+			return
+		}
+		for i := start; i < n; i++ {
+			rec(i+1, append(erased, i))
+		}
+	}
+	rec(0, nil)
+}
+
+// checkErasures verifies that every pattern of lost erasures is repaired
+func checkErasures(t *testing.T, c Codec, lost int) {
+	t.Helper()
+	want := encodedStripe(t, c, 1000)
+	forEachErasure(TotalShards(c), lost, func(erased []int) {
+		shards := make([][]byte, len(want))
+		copy(shards, want)
+		for _, i := range erased {
+			shards[i] = nil
+		}
+		if err := c.Reconstruct(shards); err != nil {
+			t.Fatalf("%s: Reconstruct(lost %v) error = %v", c.Spec(), erased, err)
+		}
+		for i := range want {
+			if !bytes.Equal(shards[i], want[i]) {
+				t.Fatalf("%s: Reconstruct(lost %v) shard %d differs", c.Spec(), erased, i)
+			}
+		}
+	})
+}
+
+func TestReedSolomon_AnyMErasures(t *testing.T) {
+	for _, spec := range []string{"rs:1+1", "rs:4+2", "rs:6+3", "rs:10+4"} {
+		t.Run(spec, func(t *testing.T) {
+			c, _ := Parse(spec)
+			for lost := 1; lost <= c.ParityShards(); lost++ {
+				checkErasures(t, c, lost)
+			}
+		})
+	}
+}
+
+func TestReedSolomon_TooManyErasures(t *testing.T) {
+	c, _ := NewReedSolomon(4, 2)
+	shards := encodedStripe(t, c, 100)
+	shards[0], shards[3], shards[5] = nil, nil, nil
+	if err := c.Reconstruct(shards); err != ErrTooFewShards {
+		t.Errorf("Reconstruct(3 lost) error = %v, want %v", err, ErrTooFewShards)
+	}
+}
+
+func TestReedSolomon_MaxShards(t *testing.T) {
+	c, err := NewReedSolomon(200, 56)
+	if err != nil {
+		t.Fatalf("NewReedSolomon(200, 56) error = %v", err)
+	}
+	shards := encodedStripe(t, c, 200*8)
+	want := append([]byte(nil), shards[17]...)
+	for i := 0; i < 56; i++ {
+		shards[i*3] = nil
+	}
+	if err := c.Reconstruct(shards); err != nil || !bytes.Equal(shards[17], want) {
+		t.Errorf("Reconstruct(56 lost of 256) error = %v", err)
+	}
+}
+
+func TestReedSolomon16_AnyMErasures(t *testing.T) {
+	for _, spec := range []string{"rs16:1+1", "rs16:4+2", "rs16:10+4"} {
+		t.Run(spec, func(t *testing.T) {
+			c, _ := Parse(spec)
+			if c.Spec() != spec {
+				t.Errorf("Spec() = %q, want %q", c.Spec(), spec)
+			}
+			for lost := 1; lost <= c.ParityShards(); lost++ {
+				checkErasures(t, c, lost)
+			}
+		})
+	}
+}
+
+func TestReedSolomon16_WideStripe(t *testing.T) {
+	if _, err := NewReedSolomon(300, 40); err != ErrInvalidParams {
+		t.Errorf("NewReedSolomon(300, 40) error = %v, want %v", err, ErrInvalidParams)
+	}
+	c, err := NewReedSolomon16(300, 40)
+	if err != nil {
+		t.Fatalf("NewReedSolomon16(300, 40) error = %v", err)
+	}
+	want := encodedStripe(t, c, 300*64)
+	shards := make([][]byte, len(want))
+	copy(shards, want)
+	// Lose 30 data shards past index 256 and 10 parity shards
+	for i := 0; i < 40; i++ {
+		shards[250+i*2] = nil
+	}
+	if err := c.Reconstruct(shards); err != nil {
+		t.Fatalf("Reconstruct(40 lost of 340) error = %v", err)
+	}
+	for i := range want {
+		if !bytes.Equal(shards[i], want[i]) {
+			t.Fatalf("shard %d differs after Reconstruct", i)
+		}
+	}
+	shards[0] = nil
+	for i := 0; i < 40; i++ {
+		shards[1+i] = nil
+	}
+	if err := c.Reconstruct(shards); err != ErrTooFewShards {
+		t.Errorf("Reconstruct(41 lost) error = %v, want %v", err, ErrTooFewShards)
+	}
+}
+
+func TestReedSolomon16_ShardAlignment(t *testing.T) {
+	c, _ := NewReedSolomon16(4, 2)
+	if got := Alignment(c); got != 2 {
+		t.Errorf("Alignment() = %d, want 2", got)
+	}
+	// 9 bytes over 4 shards would give 3-byte shards; they are rounded to 4
+	shards, err := Split(c, []byte("123456789"))
+	if err != nil {
+		t.Fatalf("Split() error = %v", err)
+	}
+	if len(shards[0]) != 4 {
+		t.Errorf("shard size = %d, want 4", len(shards[0]))
+	}
+
+	odd := make([][]byte, 6)
+	for i := range odd {
+		odd[i] = make([]byte, 3)
+	}
+	if err := c.Encode(odd); err != ErrShardSize {
+		t.Errorf("Encode(odd size) error = %v, want %v", err, ErrShardSize)
+	}
+	odd[0] = nil
+	if err := c.Reconstruct(odd); err != ErrShardSize {
+		t.Errorf("Reconstruct(odd size) error = %v, want %v", err, ErrShardSize)
+	}
+}
+
+func TestReedSolomon16_MatchesParityExtension(t *testing.T) {
+	c, _ := NewReedSolomon16(4, 2)
+	bigger, extended, err := ExtendParity(c, encodedStripe(t, c, 400), 2)
+	if err != nil {
+		t.Fatalf("ExtendParity() error = %v", err)
+	}
+	if bigger.Spec() != "rs16:4+4" {
+		t.Errorf("Spec() = %q, want rs16:4+4", bigger.Spec())
+	}
+	fresh := make([][]byte, len(extended))
+	copy(fresh, extended[:4])
+	for i := 4; i < len(fresh); i++ {
+		fresh[i] = make([]byte, len(extended[0]))
+	}
+	_ = bigger.Encode(fresh)
+	for i := range fresh {
+		if !bytes.Equal(fresh[i], extended[i]) {
+			t.Errorf("shard %d differs from a fresh rs16:4+4 encode", i)
+		}
+	}
+}
+
+// Benchmark RS encoding for a few common geometries
+func BenchmarkReedSolomonEncode(b *testing.B) {
+	for _, km := range [][2]int{{4, 2}, {10, 4}} {
+		b.Run(fmt.Sprintf("%d+%d", km[0], km[1]), func(b *testing.B) {
+			c, _ := NewReedSolomon(km[0], km[1])
+			shards := encodedStripe(b, c, km[0]<<16)
+			b.SetBytes(int64(km[0]) << 16)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_ = c.Encode(shards)
+			}
+		})
+	}
+}
+
+// Benchmark RS over GF(2^16), including a stripe too wide for GF(2^8)
+func BenchmarkReedSolomon16Encode(b *testing.B) {
+	for _, km := range [][2]int{{4, 2}, {10, 4}, {300, 40}} {
+		b.Run(fmt.Sprintf("%d+%d", km[0], km[1]), func(b *testing.B) {
+			c, _ := NewReedSolomon16(km[0], km[1])
+			size := 1 << 16
+			if km[0] > 100 {
+				size = 1 << 12
+			}
+			shards := encodedStripe(b, c, km[0]*size)
+			b.SetBytes(int64(km[0] * size))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_ = c.Encode(shards)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package codec
+
+func init() {
+	Register("replica", func(params []int) (Codec, error) {
+		if len(params) != 2 || params[0] != 1 {
+			return nil, ErrInvalidParams
+		}
+		return NewReplica(params[1] + 1)
+	})
+}
+
+// Replica is plain replication expressed as a code with one data shard and
+// copies-1 parity shards, so replicated and erasure-coded objects can share
+// the same storage paths
+type Replica struct {
+	copies int
+}
+
+// NewReplica creates a replication codec storing the given number of copies
+//
+// Errors:
+//   - ErrInvalidParams if copies < 1
+func NewReplica(copies int) (*Replica, error) {
+	if copies < 1 {
+		return nil, ErrInvalidParams
+	}
+	return &Replica{copies: copies}, nil
+}
+
+// Spec returns "replica:1+m"
+func (r *Replica) Spec() string { return FormatSpec("replica", 1, r.copies-1) }
+
+// DataShards always returns 1
+func (r *Replica) DataShards() int { return 1 }
+
+// ParityShards returns the number of extra copies
+func (r *Replica) ParityShards() int { return r.copies - 1 }
+
+// Encode copies the data shard into every parity shard
+func (r *Replica) Encode(shards [][]byte) error {
+	if _, err := checkShards(r, shards, false); err != nil {
+		return err
+	}
+	for _, shard := range shards[1:] {
+		copy(shard, shards[0])
+	}
+	return nil
+}
+
+// Reconstruct fills every missing copy from any surviving one
+func (r *Replica) Reconstruct(shards [][]byte) error {
+	return r.reconstruct(shards, false)
+}
+
+// ReconstructInto is Reconstruct copying into the missing shards' buffers
+func (r *Replica) ReconstructInto(shards [][]byte) error {
+	return r.reconstruct(shards, true)
+}
+
+func (r *Replica) reconstruct(shards [][]byte, reuse bool) error {
+	size, err := checkShards(r, shards, true)
+	if err != nil {
+		return err
+	}
+
+	var survivor []byte
+	for _, shard := range shards {
+		if len(shard) > 0 {
+			survivor = shard
+			break
+		}
+	}
+	for i, shard := range shards {
+		if len(shard) == 0 {
+			shards[i] = rebuildBuffer(shard, size, reuse)
+			copy(shards[i], survivor)
+		}
+	}
+	return nil
+}
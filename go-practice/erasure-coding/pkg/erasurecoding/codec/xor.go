@@ -0,0 +1,93 @@
+package codec
+
+func init() {
+	Register("xor", func(params []int) (Codec, error) {
+		if len(params) != 2 || params[1] != 1 {
+			return nil, ErrInvalidParams
+		}
+		return NewXor(params[0])
+	})
+}
+
+// Xor is the phase1 single-parity code (RAID-5 style) behind the Codec
+// interface: one parity shard holding the XOR of all data shards
+type Xor struct {
+	dataShards int
+}
+
+// NewXor creates an XOR parity codec with the given number of data shards
+//
+// Errors:
+//   - ErrInvalidParams if dataShards < 2, matching phase1.Encode
+func NewXor(dataShards int) (*Xor, error) {
+	if dataShards < 2 {
+		return nil, ErrInvalidParams
+	}
+	return &Xor{dataShards: dataShards}, nil
+}
+
+// Spec returns "xor:k+1"
+func (x *Xor) Spec() string { return FormatSpec("xor", x.dataShards, 1) }
+
+// DataShards returns the number of data shards
+func (x *Xor) DataShards() int { return x.dataShards }
+
+// ParityShards always returns 1
+func (x *Xor) ParityShards() int { return 1 }
+
+// Encode sets the parity shard to the XOR of all data shards
+func (x *Xor) Encode(shards [][]byte) error {
+	size, err := checkShards(x, shards, false)
+	if err != nil {
+		return err
+	}
+
+	parity := shards[x.dataShards]
+	for i := range parity {
+		parity[i] = 0
+	}
+	for _, shard := range shards[:x.dataShards] {
+		xorInto(parity, shard[:size])
+	}
+	return nil
+}
+
+// Reconstruct rebuilds at most one missing shard: since every stripe XORs to
+// zero, the missing shard is the XOR of all the others
+func (x *Xor) Reconstruct(shards [][]byte) error {
+	return x.reconstruct(shards, false)
+}
+
+// ReconstructInto is Reconstruct rebuilding into the missing shard's buffer
+func (x *Xor) ReconstructInto(shards [][]byte) error {
+	return x.reconstruct(shards, true)
+}
+
+func (x *Xor) reconstruct(shards [][]byte, reuse bool) error {
+	size, err := checkShards(x, shards, true)
+	if err != nil {
+		return err
+	}
+
+	missing := -1
+	for i, shard := range shards {
+		if len(shard) == 0 {
+			if missing >= 0 {
+				return ErrTooFewShards
+			}
+			missing = i
+		}
+	}
+	if missing < 0 {
+		return nil
+	}
+
+	recovered := rebuildBuffer(shards[missing], size, reuse)
+	for i, shard := range shards {
+		if i != missing {
+			xorInto(recovered, shard)
+		}
+	}
+	shards[missing] = recovered
+	return nil
+}
@@ -0,0 +1,515 @@
+// Package coordinator spreads erasure-coded objects over a cluster of
+// storage nodes (see package storagenode) and reads them back.
+//
+// An object is cut into stripes of StripeSize bytes. Every stripe is encoded
+// with the configured codec and its k+m shards are written to k+m different
+// nodes, rotating the starting node per stripe so load is spread evenly.
+//
+// Writes succeed once WriteQuorum shards of every stripe are stored; if any
+// stripe falls short, every shard written so far is deleted again so no
+// partial object is left behind. Reads fetch any k shards of a stripe,
+// preferring data shards, and fall back to parity shards and reconstruction
+// when nodes are down or too slow. A ReadPolicy can hedge reads by asking
+// more than k nodes and decoding from the first k shards to arrive.
+//
+// The coordinator does not keep metadata itself: Put returns a Manifest that
+// the caller stores and hands back to Get and Delete.
+//
+// With an Auditor configured, Put also prepares proof-of-retrievability
+// records for every shard (see package audit) and keeps them in the
+// manifest, so Audit can later spot-check that each node still holds its
+// shards without reading them back.
+package coordinator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/audit"
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/codec"
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/storagenode"
+)
+
+// CoordinatorError represents errors returned by the coordinator
+type CoordinatorError struct {
+	message string
+}
+
+func (e *CoordinatorError) Error() string {
+	return e.message
+}
+
+// Common errors
+var (
+	ErrNoCodec           = &CoordinatorError{"a codec is required"}
+	ErrNotEnoughNodes    = &CoordinatorError{"fewer nodes than shards per stripe"}
+	ErrInvalidQuorum     = &CoordinatorError{"write quorum must be between k and k+m"}
+	ErrWriteQuorum       = &CoordinatorError{"write quorum not reached"}
+	ErrTooFewShards      = &CoordinatorError{"too few shards readable to decode stripe"}
+	ErrCorruptManifest   = &CoordinatorError{"manifest does not match coordinator configuration"}
+	ErrInvalidReadPolicy = &CoordinatorError{"read policy extra requests cannot be negative"}
+	ErrNoAuditor         = &CoordinatorError{"no auditor configured"}
+	ErrNoAuditRecord     = &CoordinatorError{"shard has no audit record"}
+)
+
+// Defaults applied by New for zero Config fields
+const (
+	DefaultStripeSize = 1 << 20
+	DefaultTimeout    = 2 * time.Second
+)
+
+// Config configures a Coordinator
+type Config struct {
+	// Codec encodes every stripe
+	Codec codec.Codec
+	// Nodes are the base URLs of the storage nodes
+	Nodes []string
+	// StripeSize is the number of object bytes per stripe
+	StripeSize int
+	// WriteQuorum is the number of shards per stripe that must be stored
+	// for a write to succeed (default: all k+m)
+	WriteQuorum int
+	// Timeout bounds every individual node request
+	Timeout time.Duration
+	// HTTPClient is used for node requests (default: http.DefaultClient)
+	HTTPClient *http.Client
+	// ReadPolicy controls hedged reads (default: exactly k requests,
+	// data shards first)
+	ReadPolicy ReadPolicy
+	// Auditor, if set, prepares audit records for every shard on Put
+	Auditor *audit.Auditor
+}
+
+// Manifest describes where the shards of an object live
+type Manifest struct {
+	ID         string   `json:"id"`
+	Size       int64    `json:"size"`
+	Codec      string   `json:"codec"`
+	StripeSize int      `json:"stripeSize"`
+	Stripes    []Stripe `json:"stripes"`
+}
+
+// Stripe records the placement of one stripe's shards
+type Stripe struct {
+	// ChunkSize is the size of every shard in the stripe
+	ChunkSize int `json:"chunkSize"`
+	// Nodes[i] is the index of the node holding shard i
+	Nodes []int `json:"nodes"`
+	// Missing lists shards that could not be written (within quorum)
+	Missing []int `json:"missing,omitempty"`
+	// Audits[i] holds the audit record of shard i (only with an Auditor;
+	// nil for missing shards)
+	Audits []*audit.Record `json:"audits,omitempty"`
+}
+
+// Coordinator writes and reads objects across storage nodes
+type Coordinator struct {
+	cfg     Config
+	nodes   []*storagenode.Client
+	latency *latencyTracker
+}
+
+// New creates a Coordinator
+//
+// Errors:
+//   - ErrNoCodec if cfg.Codec is nil
+//   - ErrNotEnoughNodes if there are fewer nodes than shards per stripe
+//   - ErrInvalidQuorum if WriteQuorum is outside [k, k+m]
+//   - ErrInvalidReadPolicy if ReadPolicy.Extra is negative
+func New(cfg Config) (*Coordinator, error) {
+	if cfg.Codec == nil {
+		return nil, ErrNoCodec
+	}
+	total := codec.TotalShards(cfg.Codec)
+	if len(cfg.Nodes) < total {
+		return nil, ErrNotEnoughNodes
+	}
+	if cfg.WriteQuorum == 0 {
+		cfg.WriteQuorum = total
+	}
+	if cfg.WriteQuorum < cfg.Codec.DataShards() || cfg.WriteQuorum > total {
+		return nil, ErrInvalidQuorum
+	}
+	if cfg.StripeSize <= 0 {
+		cfg.StripeSize = DefaultStripeSize
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.ReadPolicy.Extra < 0 {
+		return nil, ErrInvalidReadPolicy
+	}
+
+	nodes := make([]*storagenode.Client, len(cfg.Nodes))
+	for i, addr := range cfg.Nodes {
+		nodes[i] = storagenode.NewClient(addr, cfg.HTTPClient)
+	}
+	return &Coordinator{cfg: cfg, nodes: nodes, latency: newLatencyTracker(len(nodes))}, nil
+}
+
+// ShardID returns the ID under which a shard is stored on its node
+func ShardID(objectID string, stripe, shard int) string {
+	return fmt.Sprintf("%s/%d/%d", objectID, stripe, shard)
+}
+
+// Put encodes data stripe by stripe and writes the shards to the nodes
+//
+// Errors:
+//   - ErrWriteQuorum (wrapped) if a stripe could not be stored on enough
+//     nodes; all shards written by this call are removed again
+func (c *Coordinator) Put(ctx context.Context, id string, data []byte) (*Manifest, error) {
+	m := &Manifest{
+		ID:         id,
+		Size:       int64(len(data)),
+		Codec:      c.cfg.Codec.Spec(),
+		StripeSize: c.cfg.StripeSize,
+	}
+
+	for s := 0; s*c.cfg.StripeSize < len(data); s++ {
+		end := (s + 1) * c.cfg.StripeSize
+		if end > len(data) {
+			end = len(data)
+		}
+		stripe, err := c.putStripe(ctx, id, s, data[s*c.cfg.StripeSize:end])
+		if err != nil {
+			c.rollback(m)
+			return nil, fmt.Errorf("stripe %d: %w", s, err)
+		}
+		m.Stripes = append(m.Stripes, *stripe)
+	}
+	return m, nil
+}
+
+// putStripe encodes and writes one stripe, rolling back its own shards if
+// the write quorum is not reached
+func (c *Coordinator) putStripe(ctx context.Context, id string, s int, data []byte) (*Stripe, error) {
+	shards, err := codec.Split(c.cfg.Codec, data)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.cfg.Codec.Encode(shards); err != nil {
+		return nil, err
+	}
+
+	stripe := &Stripe{ChunkSize: len(shards[0]), Nodes: c.place(id, s)}
+	errs := make([]error, len(shards))
+	var wg sync.WaitGroup
+	for i := range shards {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			reqCtx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+			defer cancel()
+			errs[i] = c.nodes[stripe.Nodes[i]].Put(reqCtx, ShardID(id, s, i), shards[i])
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			stripe.Missing = append(stripe.Missing, i)
+		}
+	}
+	if c.cfg.Auditor != nil {
+		stripe.Audits = make([]*audit.Record, len(shards))
+		for i, err := range errs {
+			if err == nil {
+				stripe.Audits[i] = c.cfg.Auditor.Prepare(ShardID(id, s, i), shards[i])
+			}
+		}
+	}
+	if stored := len(shards) - len(stripe.Missing); stored < c.cfg.WriteQuorum {
+		c.deleteStripe(id, s, stripe)
+		return nil, fmt.Errorf("%w: stored %d of %d shards, need %d: %v",
+			ErrWriteQuorum, stored, len(shards), c.cfg.WriteQuorum, errors.Join(errs...))
+	}
+	return stripe, nil
+}
+
+// rollback deletes every stripe already recorded in m
+func (c *Coordinator) rollback(m *Manifest) {
+	for s := range m.Stripes {
+		c.deleteStripe(m.ID, s, &m.Stripes[s])
+	}
+}
+
+// deleteStripe removes the shards of a stripe on a best-effort basis. It
+// uses its own context so rollback still runs after the caller's context
+// has been cancelled.
+func (c *Coordinator) deleteStripe(id string, s int, stripe *Stripe) error {
+	errs := make([]error, len(stripe.Nodes))
+	var wg sync.WaitGroup
+	for i, node := range stripe.Nodes {
+		wg.Add(1)
+		go func(i, node int) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), c.cfg.Timeout)
+			defer cancel()
+			errs[i] = c.nodes[node].Delete(ctx, ShardID(id, s, i))
+		}(i, node)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Get reads an object back, reconstructing stripes whose shards are
+// unavailable
+//
+// Errors:
+//   - ErrTooFewShards (wrapped) if fewer than k shards of a stripe can be read
+func (c *Coordinator) Get(ctx context.Context, m *Manifest) ([]byte, error) {
+	if m.Codec != c.cfg.Codec.Spec() || m.StripeSize != c.cfg.StripeSize {
+		return nil, ErrCorruptManifest
+	}
+
+	data := make([]byte, 0, m.Size)
+	for s := range m.Stripes {
+		stripeLen := m.Size - int64(s)*int64(m.StripeSize)
+		if stripeLen > int64(m.StripeSize) {
+			stripeLen = int64(m.StripeSize)
+		}
+		shards, err := c.readStripe(ctx, m, s)
+		if err != nil {
+			return nil, fmt.Errorf("stripe %d: %w", s, err)
+		}
+		data = append(data, codec.Join(c.cfg.Codec, shards, int(stripeLen))...)
+	}
+	return data, nil
+}
+
+// shardResult is the outcome of one shard fetch
+type shardResult struct {
+	shard int
+	data  []byte
+	err   error
+}
+
+// readStripe fetches k shards of a stripe and reconstructs missing data
+//
+// The read policy decides the order in which shards are tried, how many
+// requests are issued up front (k+Extra) and whether another request is
+// hedged after HedgeDelay. Every failed or timed-out request is replaced by
+// a request for the next untried shard. As soon as k valid shards have
+// arrived, the outstanding requests are cancelled.
+func (c *Coordinator) readStripe(ctx context.Context, m *Manifest, s int) ([][]byte, error) {
+	stripe := &m.Stripes[s]
+	k := c.cfg.Codec.DataShards()
+	if len(stripe.Nodes) != codec.TotalShards(c.cfg.Codec) {
+		return nil, ErrCorruptManifest
+	}
+
+	candidates := c.orderShards(stripe)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan shardResult, len(candidates))
+	fetch := func(shard int) {
+		node := stripe.Nodes[shard]
+		reqCtx, reqCancel := context.WithTimeout(ctx, c.cfg.Timeout)
+		defer reqCancel()
+		start := time.Now()
+		data, err := c.nodes[node].Get(reqCtx, ShardID(m.ID, s, shard))
+		if err == nil && len(data) != stripe.ChunkSize {
+			err = fmt.Errorf("shard %d has %d bytes, want %d", shard, len(data), stripe.ChunkSize)
+		}
+		c.latency.record(node, time.Since(start), err, ctx.Err() != nil)
+		results <- shardResult{shard: shard, data: data, err: err}
+	}
+
+	next, inflight := 0, 0
+	launch := func() {
+		if next < len(candidates) {
+			inflight++
+			go fetch(candidates[next])
+			next++
+		}
+	}
+	for next < len(candidates) && next < k+c.cfg.ReadPolicy.Extra {
+		launch()
+	}
+
+	var hedge <-chan time.Time
+	if c.cfg.ReadPolicy.HedgeDelay > 0 {
+		ticker := time.NewTicker(c.cfg.ReadPolicy.HedgeDelay)
+		defer ticker.Stop()
+		hedge = ticker.C
+	}
+
+	shards := make([][]byte, len(stripe.Nodes))
+	got := 0
+	var errs []error
+	for got < k && inflight > 0 {
+		select {
+		case r := <-results:
+			inflight--
+			if r.err != nil {
+				errs = append(errs, fmt.Errorf("shard %d: %w", r.shard, r.err))
+				launch()
+				continue
+			}
+			shards[r.shard] = r.data
+			got++
+		case <-hedge:
+			launch()
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if got < k {
+		return nil, fmt.Errorf("%w: %v", ErrTooFewShards, errors.Join(errs...))
+	}
+
+	// Shards that arrived beyond k are dropped; Reconstruct only needs k
+	if err := c.cfg.Codec.Reconstruct(shards); err != nil {
+		return nil, err
+	}
+	return shards, nil
+}
+
+// availableShards lists the shards of a stripe that were written, data
+// shards first
+func availableShards(stripe *Stripe) []int {
+	missing := map[int]bool{}
+	for _, i := range stripe.Missing {
+		missing[i] = true
+	}
+	shards := make([]int, 0, len(stripe.Nodes))
+	for i := range stripe.Nodes {
+		if !missing[i] {
+			shards = append(shards, i)
+		}
+	}
+	return shards
+}
+
+// Delete removes every shard of an object; errors from unreachable nodes
+// are returned but do not stop the other deletes
+func (c *Coordinator) Delete(ctx context.Context, m *Manifest) error {
+	var errs []error
+	for s := range m.Stripes {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := c.deleteStripe(m.ID, s, &m.Stripes[s]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// NodeStatus is the health of one node as seen by the coordinator
+type NodeStatus struct {
+	Addr   string
+	Health *storagenode.Health
+	Err    error
+}
+
+// Health queries every node concurrently
+func (c *Coordinator) Health(ctx context.Context) []NodeStatus {
+	statuses := make([]NodeStatus, len(c.nodes))
+	var wg sync.WaitGroup
+	for i, node := range c.nodes {
+		wg.Add(1)
+		go func(i int, node *storagenode.Client) {
+			defer wg.Done()
+			reqCtx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+			defer cancel()
+			h, err := node.Health(reqCtx)
+			statuses[i] = NodeStatus{Addr: node.BaseURL(), Health: h, Err: err}
+		}(i, node)
+	}
+	wg.Wait()
+	return statuses
+}
+
+// AuditResult is the outcome of auditing one shard
+type AuditResult struct {
+	Stripe, Shard, Node int
+	// Err is nil if the node proved it holds the shard. Otherwise it is
+	// audit.ErrProofMismatch for a wrong answer, audit.ErrExhausted if
+	// the shard's record needs renewing, or the node's error.
+	Err error
+}
+
+// AuditReport summarises an Audit
+type AuditReport struct {
+	Passed int
+	Failed []AuditResult
+}
+
+// OK reports whether every audited shard passed
+func (r *AuditReport) OK() bool {
+	return len(r.Failed) == 0
+}
+
+// Audit challenges the node holding every recorded shard of an object,
+// one stripe at a time with the shards of a stripe in parallel. Each
+// audit uses up a challenge, so the updated manifest must be stored again.
+//
+// Errors:
+//   - ErrNoAuditor if the coordinator has no Auditor
+//   - ErrCorruptManifest if m was not written by this configuration
+//   - ErrNoAuditRecord if a stored shard has no record (the object was
+//     written without an Auditor)
+func (c *Coordinator) Audit(ctx context.Context, m *Manifest) (*AuditReport, error) {
+	if c.cfg.Auditor == nil {
+		return nil, ErrNoAuditor
+	}
+	if m.Codec != c.cfg.Codec.Spec() || m.StripeSize != c.cfg.StripeSize {
+		return nil, ErrCorruptManifest
+	}
+	report := &AuditReport{}
+	for s := range m.Stripes {
+		stripe := &m.Stripes[s]
+		shards := availableShards(stripe)
+		if len(stripe.Audits) != len(stripe.Nodes) {
+			return nil, fmt.Errorf("%w: stripe %d", ErrNoAuditRecord, s)
+		}
+		for _, i := range shards {
+			if stripe.Audits[i] == nil {
+				return nil, fmt.Errorf("%w: stripe %d shard %d", ErrNoAuditRecord, s, i)
+			}
+		}
+
+		errs := make([]error, len(shards))
+		var wg sync.WaitGroup
+		for j, i := range shards {
+			wg.Add(1)
+			go func(j, i int) {
+				defer wg.Done()
+				reqCtx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+				defer cancel()
+				errs[j] = c.cfg.Auditor.Audit(reqCtx, stripe.Audits[i], c.nodes[stripe.Nodes[i]])
+			}(j, i)
+		}
+		wg.Wait()
+
+		for j, i := range shards {
+			if errs[j] == nil {
+				report.Passed++
+				continue
+			}
+			report.Failed = append(report.Failed, AuditResult{Stripe: s, Shard: i, Node: stripe.Nodes[i], Err: errs[j]})
+		}
+	}
+	return report, nil
+}
+
+// place returns the node of every shard of stripe s, rotating the start
+// node per object and stripe
+func (c *Coordinator) place(id string, s int) []int {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	start := int((h.Sum32() + uint32(s)) % uint32(len(c.nodes)))
+
+	nodes := make([]int, codec.TotalShards(c.cfg.Codec))
+	for i := range nodes {
+		nodes[i] = (start + i) % len(c.nodes)
+	}
+	return nodes
+}
@@ -0,0 +1,323 @@
+package coordinator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/audit"
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/codec"
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/storagenode"
+)
+
+// testNode is a storage node on loopback that can be stopped or slowed down
+type testNode struct {
+	node  *storagenode.Node
+	srv   *httptest.Server
+	delay atomic.Int64
+	hits  atomic.Int64
+}
+
+func (n *testNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n.hits.Add(1)
+	if d := time.Duration(n.delay.Load()); d > 0 {
+		select {
+		case <-time.After(d):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	n.node.ServeHTTP(w, r)
+}
+
+func startNodes(t *testing.T, count int) ([]*testNode, []string) {
+	t.Helper()
+	nodes := make([]*testNode, count)
+	addrs := make([]string, count)
+	for i := range nodes {
+		node, err := storagenode.New(t.TempDir())
+		if err != nil {
+			t.Fatalf("storagenode.New() error = %v", err)
+		}
+		nodes[i] = &testNode{node: node}
+		nodes[i].srv = httptest.NewServer(nodes[i])
+		addrs[i] = nodes[i].srv.URL
+		t.Cleanup(nodes[i].srv.Close)
+	}
+	return nodes, addrs
+}
+
+func newTestCoordinator(t *testing.T, addrs []string, spec string, quorum int) *Coordinator {
+	t.Helper()
+	c, err := codec.Parse(spec)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	coord, err := New(Config{
+		Codec:       c,
+		Nodes:       addrs,
+		StripeSize:  64,
+		WriteQuorum: quorum,
+		Timeout:     200 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return coord
+}
+
+func storedShards(t *testing.T, nodes []*testNode) int {
+	t.Helper()
+	total := 0
+	for _, n := range nodes {
+		h, err := storagenode.NewClient(n.srv.URL, nil).Health(context.Background())
+		if err == nil {
+			total += h.Shards
+		}
+	}
+	return total
+}
+
+var testData = bytes.Repeat([]byte("coordinated erasure coding "), 20) // 540 bytes, 9 stripes
+
+func TestPutGetRoundtrip(t *testing.T) {
+	nodes, addrs := startNodes(t, 6)
+	coord := newTestCoordinator(t, addrs, "xor:4+1", 0)
+	ctx := context.Background()
+
+	m, err := coord.Put(ctx, "obj", testData)
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if len(m.Stripes) != 9 {
+		t.Errorf("Put() wrote %d stripes, want 9", len(m.Stripes))
+	}
+	for s, stripe := range m.Stripes {
+		seen := map[int]bool{}
+		for _, n := range stripe.Nodes {
+			if seen[n] {
+				t.Errorf("stripe %d places two shards on node %d", s, n)
+			}
+			seen[n] = true
+		}
+	}
+	if got := storedShards(t, nodes); got != 9*5 {
+		t.Errorf("nodes store %d shards, want %d", got, 9*5)
+	}
+
+	got, err := coord.Get(ctx, m)
+	if err != nil || !bytes.Equal(got, testData) {
+		t.Fatalf("Get() = %d bytes, %v", len(got), err)
+	}
+
+	if err := coord.Delete(ctx, m); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if got := storedShards(t, nodes); got != 0 {
+		t.Errorf("nodes store %d shards after Delete, want 0", got)
+	}
+}
+
+func TestGet_NodesDown(t *testing.T) {
+	nodes, addrs := startNodes(t, 5)
+	coord := newTestCoordinator(t, addrs, "replica:1+2", 0)
+	ctx := context.Background()
+
+	m, err := coord.Put(ctx, "obj", testData)
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	// Every stripe keeps at least one replica with two nodes down
+	nodes[0].srv.Close()
+	nodes[3].srv.Close()
+
+	got, err := coord.Get(ctx, m)
+	if err != nil || !bytes.Equal(got, testData) {
+		t.Fatalf("Get() with 2 nodes down = %d bytes, %v", len(got), err)
+	}
+}
+
+func TestGet_ReconstructsFromParity(t *testing.T) {
+	nodes, addrs := startNodes(t, 5)
+	coord := newTestCoordinator(t, addrs, "xor:4+1", 0)
+	ctx := context.Background()
+
+	m, _ := coord.Put(ctx, "obj", testData)
+	nodes[2].srv.Close()
+
+	got, err := coord.Get(ctx, m)
+	if err != nil || !bytes.Equal(got, testData) {
+		t.Fatalf("Get() with 1 node down = %d bytes, %v", len(got), err)
+	}
+
+	nodes[4].srv.Close()
+	if _, err := coord.Get(ctx, m); !errors.Is(err, ErrTooFewShards) {
+		t.Errorf("Get() with 2 nodes down error = %v, want %v", err, ErrTooFewShards)
+	}
+}
+
+func TestGet_SlowNodeTimesOut(t *testing.T) {
+	nodes, addrs := startNodes(t, 5)
+	coord := newTestCoordinator(t, addrs, "xor:4+1", 0)
+	ctx := context.Background()
+
+	m, _ := coord.Put(ctx, "obj", testData[:64])
+	slow := m.Stripes[0].Nodes[0]
+	nodes[slow].delay.Store(int64(5 * time.Second))
+
+	start := time.Now()
+	got, err := coord.Get(ctx, m)
+	if err != nil || !bytes.Equal(got, testData[:64]) {
+		t.Fatalf("Get() with slow node = %q, %v", got, err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Get() took %v, want timeout-bounded", elapsed)
+	}
+}
+
+func TestPut_RollbackBelowQuorum(t *testing.T) {
+	nodes, addrs := startNodes(t, 5)
+	coord := newTestCoordinator(t, addrs, "xor:4+1", 0)
+	nodes[1].srv.Close()
+
+	_, err := coord.Put(context.Background(), "obj", testData)
+	if !errors.Is(err, ErrWriteQuorum) {
+		t.Fatalf("Put() error = %v, want %v", err, ErrWriteQuorum)
+	}
+	if got := storedShards(t, nodes); got != 0 {
+		t.Errorf("nodes store %d shards after failed Put, want 0", got)
+	}
+}
+
+func TestPut_QuorumToleratesDownNode(t *testing.T) {
+	nodes, addrs := startNodes(t, 5)
+	coord := newTestCoordinator(t, addrs, "xor:4+1", 4)
+	nodes[1].srv.Close()
+	ctx := context.Background()
+
+	m, err := coord.Put(ctx, "obj", testData)
+	if err != nil {
+		t.Fatalf("Put() with quorum 4 error = %v", err)
+	}
+	for s, stripe := range m.Stripes {
+		if len(stripe.Missing) != 1 {
+			t.Errorf("stripe %d Missing = %v, want one shard", s, stripe.Missing)
+		}
+	}
+
+	got, err := coord.Get(ctx, m)
+	if err != nil || !bytes.Equal(got, testData) {
+		t.Fatalf("Get() = %d bytes, %v", len(got), err)
+	}
+}
+
+func TestNew_Validation(t *testing.T) {
+	c, _ := codec.NewXor(4)
+	if _, err := New(Config{Codec: c, Nodes: []string{"a", "b"}}); err != ErrNotEnoughNodes {
+		t.Errorf("New(2 nodes) error = %v, want %v", err, ErrNotEnoughNodes)
+	}
+	if _, err := New(Config{Codec: c, Nodes: make([]string, 5), WriteQuorum: 3}); err != ErrInvalidQuorum {
+		t.Errorf("New(quorum 3) error = %v, want %v", err, ErrInvalidQuorum)
+	}
+	if _, err := New(Config{Nodes: make([]string, 5)}); err != ErrNoCodec {
+		t.Errorf("New(no codec) error = %v, want %v", err, ErrNoCodec)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	nodes, addrs := startNodes(t, 3)
+	coord := newTestCoordinator(t, addrs, "xor:2+1", 0)
+	nodes[2].srv.Close()
+
+	statuses := coord.Health(context.Background())
+	if statuses[0].Err != nil || statuses[0].Health.Status != "ok" {
+		t.Errorf("Health()[0] = %+v", statuses[0])
+	}
+	if statuses[2].Err == nil {
+		t.Error("Health()[2] expected error for stopped node")
+	}
+}
+
+func TestAudit(t *testing.T) {
+	nodes, addrs := startNodes(t, 5)
+	c, _ := codec.Parse("xor:4+1")
+	auditor, err := audit.NewAuditor([]byte("coordinator audit key"), audit.Params{SegmentSize: 4, Segments: 8, Challenges: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	coord, err := New(Config{Codec: c, Nodes: addrs, StripeSize: 64, WriteQuorum: 4, Timeout: 200 * time.Millisecond, Auditor: auditor})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	ctx := context.Background()
+	m, err := coord.Put(ctx, "obj", testData[:192])
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	report, err := coord.Audit(ctx, m)
+	if err != nil || !report.OK() || report.Passed != 3*5 {
+		t.Fatalf("Audit() = %+v, %v, want 15 passed", report, err)
+	}
+
+	// Replace one shard with garbage and stop a node
+	bad := m.Stripes[1].Nodes[2]
+	storagenode.NewClient(addrs[bad], nil).Put(ctx, ShardID("obj", 1, 2), bytes.Repeat([]byte{0xEE}, m.Stripes[1].ChunkSize))
+	down := m.Stripes[0].Nodes[0]
+	if down == bad {
+		down = m.Stripes[0].Nodes[1]
+	}
+	nodes[down].srv.Close()
+
+	report, err = coord.Audit(ctx, m)
+	if err != nil {
+		t.Fatalf("Audit() error = %v", err)
+	}
+	var mismatches, unreachable int
+	for _, r := range report.Failed {
+		switch {
+		case errors.Is(r.Err, audit.ErrProofMismatch):
+			mismatches++
+			if r.Stripe != 1 || r.Shard != 2 || r.Node != bad {
+				t.Errorf("unexpected mismatch %+v", r)
+			}
+		case r.Node == down:
+			unreachable++
+		default:
+			t.Errorf("unexpected failure %+v", r)
+		}
+	}
+	if mismatches != 1 || unreachable != 3 || report.Passed != 15-4 {
+		t.Errorf("Audit() = %d passed, %d mismatches, %d unreachable", report.Passed, mismatches, unreachable)
+	}
+
+	// The third audit uses up the records
+	coord.Audit(ctx, m)
+	report, _ = coord.Audit(ctx, m)
+	if len(report.Failed) != 15 || !errors.Is(report.Failed[0].Err, audit.ErrExhausted) {
+		t.Errorf("Audit(exhausted) failed %d, first = %v", len(report.Failed), report.Failed[0].Err)
+	}
+}
+
+func TestAudit_RequiresRecords(t *testing.T) {
+	_, addrs := startNodes(t, 3)
+	coord := newTestCoordinator(t, addrs, "xor:2+1", 0)
+	ctx := context.Background()
+	m, _ := coord.Put(ctx, "obj", testData[:10])
+	if _, err := coord.Audit(ctx, m); err != ErrNoAuditor {
+		t.Errorf("Audit(no auditor) error = %v, want %v", err, ErrNoAuditor)
+	}
+
+	auditor, _ := audit.NewAuditor([]byte("coordinator audit key"), audit.Params{})
+	c, _ := codec.Parse("xor:2+1")
+	audited, _ := New(Config{Codec: c, Nodes: addrs, StripeSize: 64, Auditor: auditor})
+	if _, err := audited.Audit(ctx, m); !errors.Is(err, ErrNoAuditRecord) {
+		t.Errorf("Audit(unrecorded manifest) error = %v, want %v", err, ErrNoAuditRecord)
+	}
+}
@@ -0,0 +1,124 @@
+package coordinator
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// ReadPolicy controls how many shard requests a stripe read issues
+//
+// Tail latency of a stripe read is the latency of its slowest shard fetch.
+// Asking more than k holders (Extra) or adding a request whenever the read
+// has stalled for HedgeDelay trades a little extra load for a read that
+// completes with the first k shards to arrive.
+type ReadPolicy struct {
+	// Extra is the number of shards requested up front beyond k (δ)
+	Extra int
+	// HedgeDelay, if positive, issues one more request each time this long
+	// passes without the read completing
+	HedgeDelay time.Duration
+	// Adaptive tries the holders with the lowest observed latency first
+	// instead of always starting with the data shards
+	Adaptive bool
+}
+
+// ewmaWeight is the weight of the newest sample in the latency average
+const ewmaWeight = 0.2
+
+// NodeStats are the read latency statistics collected for one node
+type NodeStats struct {
+	Addr string
+	// Requests counts completed shard reads, including failures
+	Requests int64
+	// Failures counts reads that returned an error
+	Failures int64
+	// Cancelled counts reads abandoned because k shards already arrived
+	Cancelled int64
+	// Latency is an exponentially weighted moving average of read latency
+	Latency time.Duration
+}
+
+// latencyTracker records per-node read latency for adaptive ordering
+type latencyTracker struct {
+	mu    sync.Mutex
+	stats []NodeStats
+}
+
+func newLatencyTracker(nodes int) *latencyTracker {
+	return &latencyTracker{stats: make([]NodeStats, nodes)}
+}
+
+// record adds one observation for node
+//
+// Failed reads count as taking the full elapsed time (usually the timeout),
+// so unreliable nodes drift to the back. A read cancelled after the stripe
+// was already decoded only tells us the node is at least that slow, so it
+// can raise the average but never lower it.
+func (t *latencyTracker) record(node int, elapsed time.Duration, err error, cancelled bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	st := &t.stats[node]
+	switch {
+	case cancelled && err != nil:
+		st.Cancelled++
+		if elapsed <= st.Latency {
+			return
+		}
+	case err != nil:
+		st.Requests++
+		st.Failures++
+	default:
+		st.Requests++
+	}
+
+	if st.Latency == 0 {
+		st.Latency = elapsed
+		return
+	}
+	st.Latency = time.Duration(ewmaWeight*float64(elapsed) + (1-ewmaWeight)*float64(st.Latency))
+}
+
+// latency returns the current average for node
+func (t *latencyTracker) latency(node int) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.stats[node].Latency
+}
+
+// Stats returns a snapshot of the per-node read statistics
+func (c *Coordinator) Stats() []NodeStats {
+	c.latency.mu.Lock()
+	defer c.latency.mu.Unlock()
+
+	stats := make([]NodeStats, len(c.latency.stats))
+	copy(stats, c.latency.stats)
+	for i := range stats {
+		stats[i].Addr = c.nodes[i].BaseURL()
+	}
+	return stats
+}
+
+// orderShards returns the readable shards of a stripe in the order they
+// should be requested
+//
+// By default data shards come first, since reading them needs no decoding.
+// With an adaptive policy, shards are ordered by their holder's observed
+// latency; nodes without samples sort first so they get measured, and data
+// shards win ties.
+func (c *Coordinator) orderShards(stripe *Stripe) []int {
+	shards := availableShards(stripe)
+	if !c.cfg.ReadPolicy.Adaptive {
+		return shards
+	}
+
+	latency := make(map[int]time.Duration, len(shards))
+	for _, shard := range shards {
+		latency[shard] = c.latency.latency(stripe.Nodes[shard])
+	}
+	sort.SliceStable(shards, func(i, j int) bool {
+		return latency[shards[i]] < latency[shards[j]]
+	})
+	return shards
+}
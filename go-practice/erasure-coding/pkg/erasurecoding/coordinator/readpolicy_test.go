@@ -0,0 +1,136 @@
+package coordinator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/codec"
+)
+
+func newPolicyCoordinator(t *testing.T, addrs []string, policy ReadPolicy) *Coordinator {
+	t.Helper()
+	c, _ := codec.NewXor(4)
+	coord, err := New(Config{
+		Codec:      c,
+		Nodes:      addrs,
+		StripeSize: 64,
+		Timeout:    5 * time.Second,
+		ReadPolicy: policy,
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return coord
+}
+
+// timedGet reads m and returns how long it took
+func timedGet(t *testing.T, coord *Coordinator, m *Manifest, want []byte) time.Duration {
+	t.Helper()
+	start := time.Now()
+	got, err := coord.Get(context.Background(), m)
+	if err != nil || !bytes.Equal(got, want) {
+		t.Fatalf("Get() = %q, %v", got, err)
+	}
+	return time.Since(start)
+}
+
+func TestReadPolicy_ExtraRequests(t *testing.T) {
+	nodes, addrs := startNodes(t, 5)
+	coord := newPolicyCoordinator(t, addrs, ReadPolicy{Extra: 1})
+	data := testData[:64]
+
+	m, err := coord.Put(context.Background(), "obj", data)
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	slow := nodes[m.Stripes[0].Nodes[0]]
+	slow.delay.Store(int64(time.Second))
+
+	// k+1 requests go out at once; the parity shard replaces the slow one
+	if elapsed := timedGet(t, coord, m, data); elapsed > 500*time.Millisecond {
+		t.Errorf("Get() with Extra=1 took %v, want well under the 1s straggler", elapsed)
+	}
+}
+
+func TestReadPolicy_HedgeDelay(t *testing.T) {
+	nodes, addrs := startNodes(t, 5)
+	coord := newPolicyCoordinator(t, addrs, ReadPolicy{HedgeDelay: 20 * time.Millisecond})
+	data := testData[:64]
+
+	m, _ := coord.Put(context.Background(), "obj", data)
+	slow := nodes[m.Stripes[0].Nodes[2]]
+	slow.delay.Store(int64(time.Second))
+
+	if elapsed := timedGet(t, coord, m, data); elapsed > 500*time.Millisecond {
+		t.Errorf("Get() with HedgeDelay took %v, want well under the 1s straggler", elapsed)
+	}
+}
+
+func TestReadPolicy_NoHedgeWaitsForStraggler(t *testing.T) {
+	nodes, addrs := startNodes(t, 5)
+	coord := newPolicyCoordinator(t, addrs, ReadPolicy{})
+	data := testData[:64]
+
+	m, _ := coord.Put(context.Background(), "obj", data)
+	nodes[m.Stripes[0].Nodes[1]].delay.Store(int64(300 * time.Millisecond))
+
+	if elapsed := timedGet(t, coord, m, data); elapsed < 300*time.Millisecond {
+		t.Errorf("Get() without hedging took %v, expected to wait for the straggler", elapsed)
+	}
+}
+
+func TestReadPolicy_AdaptiveAvoidsSlowNode(t *testing.T) {
+	nodes, addrs := startNodes(t, 5)
+	coord := newPolicyCoordinator(t, addrs, ReadPolicy{HedgeDelay: 10 * time.Millisecond, Adaptive: true})
+	data := testData[:64]
+
+	m, _ := coord.Put(context.Background(), "obj", data)
+	slowIndex := m.Stripes[0].Nodes[0]
+	slow := nodes[slowIndex]
+	slow.delay.Store(int64(200 * time.Millisecond))
+
+	// Warm up: every node gets sampled and the slow one is hedged around
+	for i := 0; i < 3; i++ {
+		timedGet(t, coord, m, data)
+	}
+	stats := coord.Stats()
+	for i, st := range stats {
+		if i != slowIndex && st.Latency >= stats[slowIndex].Latency {
+			t.Errorf("node %d latency %v not below slow node's %v", i, st.Latency, stats[slowIndex].Latency)
+		}
+	}
+
+	before := slow.hits.Load()
+	for i := 0; i < 5; i++ {
+		timedGet(t, coord, m, data)
+	}
+	if after := slow.hits.Load(); after != before {
+		t.Errorf("adaptive reads still sent %d requests to the slow node", after-before)
+	}
+}
+
+func TestReadPolicy_StatsCountFailures(t *testing.T) {
+	nodes, addrs := startNodes(t, 5)
+	coord := newPolicyCoordinator(t, addrs, ReadPolicy{})
+	m, _ := coord.Put(context.Background(), "obj", testData[:64])
+
+	down := m.Stripes[0].Nodes[0]
+	nodes[down].srv.Close()
+	timedGet(t, coord, m, testData[:64])
+
+	st := coord.Stats()[down]
+	if st.Requests != 1 || st.Failures != 1 || st.Addr != addrs[down] {
+		t.Errorf("Stats()[down] = %+v, want 1 failed request", st)
+	}
+}
+
+func TestNew_InvalidReadPolicy(t *testing.T) {
+	c, _ := codec.NewXor(2)
+	_, err := New(Config{Codec: c, Nodes: make([]string, 3), ReadPolicy: ReadPolicy{Extra: -1}})
+	if !errors.Is(err, ErrInvalidReadPolicy) {
+		t.Errorf("New(Extra=-1) error = %v, want %v", err, ErrInvalidReadPolicy)
+	}
+}
@@ -0,0 +1,99 @@
+package das
+
+import (
+	"fmt"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/codec"
+)
+
+// Repair rebuilds an extended square from any sufficient subset of its
+// cells
+//
+// cells holds the (2k)² cells in row-major order with nil for missing ones
+// (it is not modified). Rows and columns with at least k cells are
+// reconstructed in alternating passes until nothing changes; each pass can
+// unlock columns (or rows) that were short before. The result is checked
+// against every root in h, so a publisher who committed to a badly encoded
+// square is caught.
+//
+// Errors:
+//   - ErrCellCount for the wrong number or size of cells, or a header
+//     without 2k row and column roots
+//   - ErrUnrecoverable if the passes stall with cells still missing
+//   - ErrBadEncoding if the repaired square does not match h
+func Repair(h *Header, cells [][]byte) (*Square, error) {
+	if h.K < 1 || h.K > MaxK || h.CellSize <= 0 {
+		return nil, ErrCellCount
+	}
+	w := h.Width()
+	if len(cells) != w*w || len(h.RowRoots) != w || len(h.ColRoots) != w {
+		return nil, ErrCellCount
+	}
+	s := &Square{k: h.K, cellSize: h.CellSize, cells: make([][]byte, w*w)}
+	missing := 0
+	for i, c := range cells {
+		switch {
+		case c == nil:
+			missing++
+		case len(c) != h.CellSize:
+			return nil, ErrCellCount
+		default:
+			s.cells[i] = append([]byte(nil), c...)
+		}
+	}
+	c, err := newCodec(h.K)
+	if err != nil {
+		return nil, err
+	}
+
+	for missing > 0 {
+		before := missing
+		for i := 0; i < w; i++ {
+			missing -= s.repairLine(c, s.row(i), i, true)
+		}
+		for i := 0; i < w; i++ {
+			missing -= s.repairLine(c, s.col(i), i, false)
+		}
+		if missing == before {
+			return nil, fmt.Errorf("%w: %d of %d cells missing", ErrUnrecoverable, missing, w*w)
+		}
+	}
+
+	s.header = s.computeHeader()
+	for i := 0; i < w; i++ {
+		if s.header.RowRoots[i] != h.RowRoots[i] {
+			return nil, fmt.Errorf("%w: row %d", ErrBadEncoding, i)
+		}
+		if s.header.ColRoots[i] != h.ColRoots[i] {
+			return nil, fmt.Errorf("%w: column %d", ErrBadEncoding, i)
+		}
+	}
+	return s, nil
+}
+
+// repairLine reconstructs a row or column that has at least k cells and
+// writes the rebuilt cells back into the square; it returns how many cells
+// it filled
+func (s *Square) repairLine(c codec.Codec, line [][]byte, idx int, isRow bool) int {
+	present := 0
+	for _, cell := range line {
+		if cell != nil {
+			present++
+		}
+	}
+	if present == len(line) || present < s.k {
+		return 0
+	}
+	if err := c.Reconstruct(line); err != nil {
+		return 0
+	}
+	w := 2 * s.k
+	for j, cell := range line {
+		pos := idx*w + j
+		if !isRow {
+			pos = j*w + idx
+		}
+		s.cells[pos] = cell
+	}
+	return len(line) - present
+}
@@ -0,0 +1,135 @@
+package das
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+// drop returns a copy of s's cells with the cells for which lost is true
+// set to nil
+func drop(s *Square, lost func(r, c int) bool) [][]byte {
+	cells := s.Cells()
+	w := s.Width()
+	for i := range cells {
+		if lost(i/w, i%w) {
+			cells[i] = nil
+		}
+	}
+	return cells
+}
+
+func checkRepaired(t *testing.T, s *Square, cells [][]byte) {
+	t.Helper()
+	got, err := Repair(s.Header(), cells)
+	if err != nil {
+		t.Fatalf("Repair() error = %v", err)
+	}
+	if !bytes.Equal(got.Data(), s.Data()) || got.Header().DataRoot() != s.Header().DataRoot() {
+		t.Fatal("Repair() did not restore the square")
+	}
+}
+
+func TestRepair_FromOneQuadrant(t *testing.T) {
+	// Any single quadrant is enough: its k rows extend to full rows, which
+	// then fill every column
+	s := mustExtend(t, randomData(10, 32*6*6), 32)
+	k := s.K()
+	for q := 0; q < 4; q++ {
+		r0, c0 := (q/2)*k, (q%2)*k
+		cells := drop(s, func(r, c int) bool {
+			return r < r0 || r >= r0+k || c < c0 || c >= c0+k
+		})
+		checkRepaired(t, s, cells)
+	}
+}
+
+func TestRepair_AlternatesRowsAndColumns(t *testing.T) {
+	// Q0 without its corner cell, plus cell (k, 0). Row 0 is one cell
+	// short until column 0 (rows 1..k) is repaired, and only then can
+	// every column be finished
+	s := mustExtend(t, randomData(11, 16*5*5), 16)
+	k := s.K()
+	cells := drop(s, func(r, c int) bool {
+		inQ0 := r < k && c < k && !(r == 0 && c == 0)
+		return !inQ0 && !(r == k && c == 0)
+	})
+	checkRepaired(t, s, cells)
+}
+
+func TestRepair_RandomSubsets(t *testing.T) {
+	s := mustExtend(t, randomData(12, 16*8*8), 16)
+	rng := rand.New(rand.NewSource(13))
+	repaired := 0
+	for trial := 0; trial < 40; trial++ {
+		cells := drop(s, func(r, c int) bool { return rng.Float64() < 0.5 })
+		if got, err := Repair(s.Header(), cells); err == nil {
+			repaired++
+			if !bytes.Equal(got.Data(), s.Data()) {
+				t.Fatal("Repair() returned a different square")
+			}
+		} else if !errors.Is(err, ErrUnrecoverable) {
+			t.Fatalf("Repair() error = %v", err)
+		}
+	}
+	// With half the cells left nearly every pattern is repairable
+	if repaired < 35 {
+		t.Errorf("repaired %d of 40 random half-squares", repaired)
+	}
+}
+
+func TestRepair_WithheldSubSquareIsUnrecoverable(t *testing.T) {
+	s := mustExtend(t, randomData(14, 16*4*4), 16)
+	k := s.K()
+	cells := drop(s, func(r, c int) bool { return r <= k && c <= k })
+	if _, err := Repair(s.Header(), cells); !errors.Is(err, ErrUnrecoverable) {
+		t.Errorf("Repair((k+1)² withheld) error = %v, want ErrUnrecoverable", err)
+	}
+	// One cell fewer withheld is enough to recover
+	cells = drop(s, func(r, c int) bool { return r <= k && c <= k && !(r == 0 && c == 0) })
+	checkRepaired(t, s, cells)
+}
+
+func TestRepair_DetectsBadEncoding(t *testing.T) {
+	s := mustExtend(t, randomData(15, 16*4*4), 16)
+	cells := s.Cells()
+	cells[0][0] ^= 1 // a cell that contradicts the committed roots
+	cells[len(cells)-1] = nil
+	if _, err := Repair(s.Header(), cells); !errors.Is(err, ErrBadEncoding) {
+		t.Errorf("Repair(bad cell) error = %v, want ErrBadEncoding", err)
+	}
+}
+
+func TestRepair_InvalidInput(t *testing.T) {
+	s := mustExtend(t, randomData(16, 100), 16)
+	if _, err := Repair(s.Header(), s.Cells()[1:]); !errors.Is(err, ErrCellCount) {
+		t.Errorf("Repair(short) error = %v", err)
+	}
+	cells := s.Cells()
+	cells[0] = cells[0][:3]
+	if _, err := Repair(s.Header(), cells); !errors.Is(err, ErrCellCount) {
+		t.Errorf("Repair(bad cell size) error = %v", err)
+	}
+	// A header off the network may carry short root lists
+	h := *s.Header()
+	h.ColRoots = h.ColRoots[:1]
+	if _, err := Repair(&h, s.Cells()); !errors.Is(err, ErrCellCount) {
+		t.Errorf("Repair(short column roots) error = %v", err)
+	}
+	h = *s.Header()
+	h.RowRoots = nil
+	if _, err := Repair(&h, s.Cells()); !errors.Is(err, ErrCellCount) {
+		t.Errorf("Repair(no row roots) error = %v", err)
+	}
+}
+
+func BenchmarkRepair_OneQuadrant(b *testing.B) {
+	s := mustExtend(b, randomData(17, 512*16*16), 512)
+	k := s.K()
+	cells := drop(s, func(r, c int) bool { return r >= k || c >= k })
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Repair(s.Header(), cells)
+	}
+}
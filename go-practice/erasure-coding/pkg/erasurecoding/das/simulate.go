@@ -0,0 +1,117 @@
+package das
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Withheld returns the minimum number of cells a publisher must withhold to
+// make a 2k×2k square unrecoverable: a (k+1)×(k+1) sub-square, which
+// leaves k+1 rows and k+1 columns with only k-1 cells each
+func Withheld(k int) int {
+	return (k + 1) * (k + 1)
+}
+
+// Confidence returns the probability that at least one of s distinct,
+// uniformly random samples hits a withheld cell when the publisher
+// withholds the minimum needed to make the square unrecoverable
+//
+// This is 1 - C(N-W, s)/C(N, s) for N = (2k)² cells and W = Withheld(k);
+// withholding more only raises it. For large k every sample hits with
+// probability of about 1/4, so 1 - (3/4)^s is a close lower bound.
+func Confidence(k, s int) float64 {
+	n, w := float64(4*k*k), float64(Withheld(k))
+	miss := 1.0
+	for i := 0; i < s; i++ {
+		if n-w-float64(i) <= 0 {
+			return 1
+		}
+		miss *= (n - w - float64(i)) / (n - float64(i))
+	}
+	return 1 - miss
+}
+
+// SamplesFor returns the number of samples needed to reach the given
+// confidence (0 <= confidence < 1) against a withholding publisher
+func SamplesFor(k int, confidence float64) int {
+	for s := 0; s <= 4*k*k; s++ {
+		if Confidence(k, s) >= confidence {
+			return s
+		}
+	}
+	return 4 * k * k
+}
+
+// SimResult is the outcome of Simulate
+type SimResult struct {
+	K, Samples, Trials int
+	// Withheld is the number of cells hidden by the adversary
+	Withheld int
+	// Detected is the fraction of trials in which a sample hit a withheld
+	// cell; Expected is Confidence(K, Samples) for comparison
+	Detected float64
+	Expected float64
+}
+
+// StdErr returns the standard error of Detected
+func (r *SimResult) StdErr() float64 {
+	return math.Sqrt(r.Detected * (1 - r.Detected) / float64(r.Trials))
+}
+
+// Simulate estimates by Monte Carlo how often a light client taking
+// samples distinct random cells notices that a publisher withheld a
+// random (k+1)×(k+1) sub-square
+//
+// Each trial picks fresh withheld rows and columns and fresh sample
+// positions from rng.
+//
+// Errors:
+//   - ErrInvalidSim for out-of-range arguments
+func Simulate(k, samples, trials int, rng *rand.Rand) (*SimResult, error) {
+	w := 2 * k
+	if k < 1 || samples < 0 || samples > w*w || trials < 1 {
+		return nil, ErrInvalidSim
+	}
+	res := &SimResult{K: k, Samples: samples, Trials: trials, Withheld: Withheld(k), Expected: Confidence(k, samples)}
+
+	hiddenRow := make([]bool, w)
+	hiddenCol := make([]bool, w)
+	detected := 0
+	for t := 0; t < trials; t++ {
+		for i := range hiddenRow {
+			hiddenRow[i], hiddenCol[i] = false, false
+		}
+		for _, r := range rng.Perm(w)[:k+1] {
+			hiddenRow[r] = true
+		}
+		for _, c := range rng.Perm(w)[:k+1] {
+			hiddenCol[c] = true
+		}
+		// The first samples entries of a partial Fisher-Yates shuffle
+		for _, cell := range partialPerm(rng, w*w, samples) {
+			if hiddenRow[cell/w] && hiddenCol[cell%w] {
+				detected++
+				break
+			}
+		}
+	}
+	res.Detected = float64(detected) / float64(trials)
+	return res, nil
+}
+
+// partialPerm returns m distinct values from [0, n)
+func partialPerm(rng *rand.Rand, n, m int) []int {
+	if m*4 < n {
+		seen := make(map[int]bool, m)
+		out := make([]int, 0, m)
+		for len(out) < m {
+			v := rng.Intn(n)
+			if !seen[v] {
+				seen[v] = true
+				out = append(out, v)
+			}
+		}
+		return out
+	}
+	return rng.Perm(n)[:m]
+}
@@ -0,0 +1,72 @@
+package das
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestConfidence(t *testing.T) {
+	if got := Confidence(4, 0); got != 0 {
+		t.Errorf("Confidence(4, 0) = %v, want 0", got)
+	}
+	// k = 1: 4 of 4 cells must be withheld, one sample always detects
+	if got := Confidence(1, 1); got != 1 {
+		t.Errorf("Confidence(1, 1) = %v, want 1", got)
+	}
+	// k = 2: W = 9 of N = 16
+	if got, want := Confidence(2, 2), 1-(7.0/16)*(6.0/15); math.Abs(got-want) > 1e-12 {
+		t.Errorf("Confidence(2, 2) = %v, want %v", got, want)
+	}
+	// Large squares approach 1 - (3/4)^s from above
+	for _, s := range []int{1, 10, 30} {
+		got, bound := Confidence(64, s), 1-math.Pow(0.75, float64(s))
+		if got < bound || got > bound+0.02 {
+			t.Errorf("Confidence(64, %d) = %v, want just above %v", s, got, bound)
+		}
+	}
+	if s := SamplesFor(64, 0.99); s < 15 || s > 17 || Confidence(64, s) < 0.99 || Confidence(64, s-1) >= 0.99 {
+		t.Errorf("SamplesFor(64, 0.99) = %d", s)
+	}
+}
+
+func TestSimulate_MatchesConfidence(t *testing.T) {
+	rng := rand.New(rand.NewSource(20))
+	for _, tc := range []struct{ k, samples int }{{2, 1}, {4, 3}, {8, 5}, {16, 10}} {
+		res, err := Simulate(tc.k, tc.samples, 4000, rng)
+		if err != nil {
+			t.Fatalf("Simulate() error = %v", err)
+		}
+		if diff := math.Abs(res.Detected - res.Expected); diff > 4*res.StdErr()+0.005 {
+			t.Errorf("k=%d s=%d: detected %.4f, expected %.4f", tc.k, tc.samples, res.Detected, res.Expected)
+		}
+	}
+}
+
+func TestSimulate_InvalidArguments(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, args := range [][3]int{{0, 1, 1}, {2, -1, 1}, {2, 17, 1}, {2, 1, 0}} {
+		if _, err := Simulate(args[0], args[1], args[2], rng); !errors.Is(err, ErrInvalidSim) {
+			t.Errorf("Simulate%v error = %v, want ErrInvalidSim", args, err)
+		}
+	}
+}
+
+func BenchmarkSimulate(b *testing.B) {
+	rng := rand.New(rand.NewSource(2))
+	for i := 0; i < b.N; i++ {
+		Simulate(32, 20, 100, rng)
+	}
+}
+
+func ExampleSamplesFor() {
+	for _, c := range []float64{0.9, 0.99, 0.999999} {
+		fmt.Printf("%.6f confidence: %d samples\n", c, SamplesFor(128, c))
+	}
+	// Output:
+	// 0.900000 confidence: 8 samples
+	// 0.990000 confidence: 16 samples
+	// 0.999999 confidence: 48 samples
+}
@@ -0,0 +1,285 @@
+// Package das implements a two-dimensional Reed-Solomon extended data
+// square for data-availability sampling, as used by blockchain light
+// clients.
+//
+// The data is cut into cells and laid out as a k×k square (Q0). Every row
+// is RS-extended with rs:k+k to width 2k (Q1), then every one of the 2k
+// columns is extended to height 2k (Q2, Q3):
+//
+//	Q0 | Q1        original | row parity
+//	---+---        ---------+-----------
+//	Q2 | Q3        col parity of both
+//
+// Because the code is linear and the same for rows and columns, Q3 is also
+// the row extension of Q2, so every row and every column of the 2k×2k
+// square is a codeword, and any k cells of a row or column determine it.
+//
+// Each row and column is committed to with a Merkle tree over its cells,
+// and the 4k roots are committed to by one data root. A light client that
+// knows only the data root samples random cells. Each sample carries a
+// proof up to the row root, and a proof from the row root up to the data
+// root. To make the square unrecoverable, a publisher has to withhold at
+// least (k+1)² cells, roughly a quarter of the square, so every sample
+// catches withholding with probability of about 1/4. See Confidence and
+// Simulate.
+package das
+
+import (
+	"fmt"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/codec"
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/merkle"
+)
+
+// DASError represents errors returned by the das package
+type DASError struct {
+	message string
+}
+
+func (e *DASError) Error() string {
+	return e.message
+}
+
+// Common errors
+var (
+	ErrInvalidCellSize = &DASError{"cell size must be positive"}
+	ErrSquareTooLarge  = &DASError{"data needs a square wider than 128 cells"}
+	ErrOutOfRange      = &DASError{"cell coordinates out of range"}
+	ErrInvalidSample   = &DASError{"sample does not match the data root"}
+	ErrCellCount       = &DASError{"cells must hold (2k)² entries of CellSize bytes or nil"}
+	ErrUnrecoverable   = &DASError{"too few cells to repair the square"}
+	ErrBadEncoding     = &DASError{"repaired square does not match the committed roots"}
+	ErrInvalidSim      = &DASError{"simulation needs k >= 1, 0 <= samples <= (2k)² and trials >= 1"}
+)
+
+// MaxK is the largest original width: rs:k+k needs 2k <= 256 shards
+const MaxK = 128
+
+// Header commits to an extended square
+type Header struct {
+	K        int
+	CellSize int
+	// RowRoots and ColRoots are the Merkle roots of the 2k rows and columns
+	RowRoots []merkle.Hash
+	ColRoots []merkle.Hash
+}
+
+// Width returns 2k
+func (h *Header) Width() int {
+	return 2 * h.K
+}
+
+// rootLeaves returns the leaves of the data root tree: rows, then columns
+func (h *Header) rootLeaves() []merkle.Hash {
+	leaves := make([]merkle.Hash, 0, 2*h.Width())
+	for _, r := range append(append([]merkle.Hash(nil), h.RowRoots...), h.ColRoots...) {
+		leaves = append(leaves, merkle.LeafHash(r[:]))
+	}
+	return leaves
+}
+
+// DataRoot returns the single hash committing to every row and column
+func (h *Header) DataRoot() merkle.Hash {
+	return merkle.Root(h.rootLeaves())
+}
+
+// Square is a 2k×2k extended data square
+type Square struct {
+	k, cellSize int
+	cells       [][]byte // row-major, (2k)² cells
+	header      *Header
+}
+
+// newCodec returns the rs:k+k code used for rows and columns
+func newCodec(k int) (codec.Codec, error) {
+	return codec.Parse(fmt.Sprintf("rs:%d+%d", k, k))
+}
+
+// Extend lays data out in the smallest k×k square of cellSize-byte cells
+// that holds it (zero-padded) and extends it to 2k×2k
+//
+// Errors:
+//   - ErrInvalidCellSize for a non-positive cell size
+//   - ErrSquareTooLarge if k would exceed MaxK
+func Extend(data []byte, cellSize int) (*Square, error) {
+	if cellSize <= 0 {
+		return nil, ErrInvalidCellSize
+	}
+	n := max(1, (len(data)+cellSize-1)/cellSize)
+	k := 1
+	for k*k < n {
+		k++
+	}
+	if k > MaxK {
+		return nil, ErrSquareTooLarge
+	}
+	c, err := newCodec(k)
+	if err != nil {
+		return nil, err
+	}
+
+	w := 2 * k
+	s := &Square{k: k, cellSize: cellSize, cells: make([][]byte, w*w)}
+	for i := range s.cells {
+		s.cells[i] = make([]byte, cellSize)
+	}
+	for i := 0; i < k*k; i++ {
+		if off := i * cellSize; off < len(data) {
+			copy(s.cell(i/k, i%k), data[off:])
+		}
+	}
+
+	// Rows of Q0 → Q1, then all columns → Q2, Q3
+	for r := 0; r < k; r++ {
+		if err := c.Encode(s.row(r)); err != nil {
+			return nil, err
+		}
+	}
+	for col := 0; col < w; col++ {
+		if err := c.Encode(s.col(col)); err != nil {
+			return nil, err
+		}
+	}
+	s.header = s.computeHeader()
+	return s, nil
+}
+
+// cell returns the cell at (r, c) of the square
+func (s *Square) cell(r, c int) []byte {
+	return s.cells[r*2*s.k+c]
+}
+
+// row returns the cells of row r (aliasing the square)
+func (s *Square) row(r int) [][]byte {
+	w := 2 * s.k
+	return s.cells[r*w : (r+1)*w]
+}
+
+// col returns the cells of column c (aliasing the cell buffers)
+func (s *Square) col(c int) [][]byte {
+	w := 2 * s.k
+	out := make([][]byte, w)
+	for r := range out {
+		out[r] = s.cells[r*w+c]
+	}
+	return out
+}
+
+// cellLeaves returns the Merkle leaves of a row or column
+func cellLeaves(cells [][]byte) []merkle.Hash {
+	leaves := make([]merkle.Hash, len(cells))
+	for i, c := range cells {
+		leaves[i] = merkle.LeafHash(c)
+	}
+	return leaves
+}
+
+// computeHeader builds the row and column commitments
+func (s *Square) computeHeader() *Header {
+	w := 2 * s.k
+	h := &Header{K: s.k, CellSize: s.cellSize, RowRoots: make([]merkle.Hash, w), ColRoots: make([]merkle.Hash, w)}
+	for i := 0; i < w; i++ {
+		h.RowRoots[i] = merkle.Root(cellLeaves(s.row(i)))
+		h.ColRoots[i] = merkle.Root(cellLeaves(s.col(i)))
+	}
+	return h
+}
+
+// K returns the width of the original square
+func (s *Square) K() int {
+	return s.k
+}
+
+// Width returns the width of the extended square, 2k
+func (s *Square) Width() int {
+	return 2 * s.k
+}
+
+// Header returns the commitments to the square
+func (s *Square) Header() *Header {
+	return s.header
+}
+
+// Cell returns the cell at row r, column c
+//
+// Errors:
+//   - ErrOutOfRange for coordinates outside the extended square
+func (s *Square) Cell(r, c int) ([]byte, error) {
+	if r < 0 || c < 0 || r >= s.Width() || c >= s.Width() {
+		return nil, ErrOutOfRange
+	}
+	return s.cell(r, c), nil
+}
+
+// Cells returns a copy of all (2k)² cells in row-major order
+func (s *Square) Cells() [][]byte {
+	out := make([][]byte, len(s.cells))
+	for i, c := range s.cells {
+		out[i] = append([]byte(nil), c...)
+	}
+	return out
+}
+
+// Data returns the original quadrant row by row, zero-padded to
+// k·k·cellSize bytes
+func (s *Square) Data() []byte {
+	out := make([]byte, 0, s.k*s.k*s.cellSize)
+	for r := 0; r < s.k; r++ {
+		for c := 0; c < s.k; c++ {
+			out = append(out, s.cell(r, c)...)
+		}
+	}
+	return out
+}
+
+// Sample is one cell with the proofs needed to check it against a data
+// root
+type Sample struct {
+	Row, Col int
+	Cell     []byte
+	// Proof leads from the cell to RowRoot
+	Proof   []merkle.Hash
+	RowRoot merkle.Hash
+	// RootProof leads from RowRoot to the data root
+	RootProof []merkle.Hash
+}
+
+// Sample returns cell (r, c) with its proofs
+//
+// Errors:
+//   - ErrOutOfRange for coordinates outside the extended square
+func (s *Square) Sample(r, c int) (*Sample, error) {
+	cell, err := s.Cell(r, c)
+	if err != nil {
+		return nil, err
+	}
+	proof, _ := merkle.Proof(cellLeaves(s.row(r)), c)
+	rootProof, _ := merkle.Proof(s.header.rootLeaves(), r)
+	return &Sample{
+		Row:       r,
+		Col:       c,
+		Cell:      append([]byte(nil), cell...),
+		Proof:     proof,
+		RowRoot:   s.header.RowRoots[r],
+		RootProof: rootProof,
+	}, nil
+}
+
+// VerifySample checks a sample of a square with original width k against
+// dataRoot
+//
+// Errors:
+//   - ErrInvalidSample if any proof fails
+func VerifySample(dataRoot merkle.Hash, k int, s *Sample) error {
+	w := 2 * k
+	if s.Row < 0 || s.Col < 0 || s.Row >= w || s.Col >= w {
+		return ErrInvalidSample
+	}
+	if !merkle.VerifyProof(s.RowRoot, merkle.LeafHash(s.Cell), s.Col, w, s.Proof) {
+		return ErrInvalidSample
+	}
+	if !merkle.VerifyProof(dataRoot, merkle.LeafHash(s.RowRoot[:]), s.Row, 2*w, s.RootProof) {
+		return ErrInvalidSample
+	}
+	return nil
+}
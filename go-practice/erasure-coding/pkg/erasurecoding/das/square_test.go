@@ -0,0 +1,141 @@
+package das
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/codec"
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/merkle"
+)
+
+func randomData(seed int64, n int) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+func mustExtend(t testing.TB, data []byte, cellSize int) *Square {
+	t.Helper()
+	s, err := Extend(data, cellSize)
+	if err != nil {
+		t.Fatalf("Extend() error = %v", err)
+	}
+	return s
+}
+
+func TestExtend_Layout(t *testing.T) {
+	tests := []struct{ size, cellSize, k int }{
+		{0, 64, 1},
+		{1, 64, 1},
+		{64 * 4, 64, 2},
+		{64*4 + 1, 64, 3},
+		{64 * 16 * 16, 64, 16},
+	}
+	for _, tt := range tests {
+		data := randomData(int64(tt.size), tt.size)
+		s := mustExtend(t, data, tt.cellSize)
+		if s.K() != tt.k || s.Width() != 2*tt.k || len(s.Cells()) != 4*tt.k*tt.k {
+			t.Errorf("Extend(%d bytes) k = %d, want %d", tt.size, s.K(), tt.k)
+		}
+		got := s.Data()
+		if len(got) != tt.k*tt.k*tt.cellSize || !bytes.Equal(got[:tt.size], data) {
+			t.Errorf("Data() does not start with the input")
+		}
+	}
+}
+
+func TestExtend_EveryRowAndColumnIsACodeword(t *testing.T) {
+	s := mustExtend(t, randomData(1, 64*8*8), 64)
+	c, _ := codec.Parse("rs:8+8")
+	for i := 0; i < s.Width(); i++ {
+		for _, line := range [][][]byte{s.row(i), s.col(i)} {
+			// Re-encoding the first k cells must give back the last k
+			check := make([][]byte, len(line))
+			for j := range check {
+				if j < s.K() {
+					check[j] = append([]byte(nil), line[j]...)
+				} else {
+					check[j] = make([]byte, len(line[j]))
+				}
+			}
+			if err := c.Encode(check); err != nil {
+				t.Fatal(err)
+			}
+			for j := s.K(); j < len(line); j++ {
+				if !bytes.Equal(check[j], line[j]) {
+					t.Fatalf("line %d is not a codeword at %d", i, j)
+				}
+			}
+		}
+	}
+}
+
+func TestSample_VerifiesAgainstDataRoot(t *testing.T) {
+	s := mustExtend(t, randomData(2, 64*5*5), 64)
+	root := s.Header().DataRoot()
+	for r := 0; r < s.Width(); r++ {
+		for c := 0; c < s.Width(); c++ {
+			sample, err := s.Sample(r, c)
+			if err != nil {
+				t.Fatalf("Sample(%d, %d) error = %v", r, c, err)
+			}
+			if err := VerifySample(root, s.K(), sample); err != nil {
+				t.Fatalf("VerifySample(%d, %d) error = %v", r, c, err)
+			}
+		}
+	}
+
+	good, _ := s.Sample(3, 7)
+	tampered := []func(*Sample){
+		func(x *Sample) { x.Cell[0] ^= 1 },
+		func(x *Sample) { x.Col = 6 },
+		func(x *Sample) { x.Row = 4 },
+		func(x *Sample) { x.RowRoot = s.Header().RowRoots[4] },
+		func(x *Sample) { x.Row = 100 },
+	}
+	for i, mutate := range tampered {
+		x := *good
+		x.Cell = append([]byte(nil), good.Cell...)
+		mutate(&x)
+		if err := VerifySample(root, s.K(), &x); !errors.Is(err, ErrInvalidSample) {
+			t.Errorf("case %d: VerifySample() error = %v, want ErrInvalidSample", i, err)
+		}
+	}
+	other := mustExtend(t, randomData(3, 64*5*5), 64)
+	if err := VerifySample(other.Header().DataRoot(), s.K(), good); !errors.Is(err, ErrInvalidSample) {
+		t.Errorf("VerifySample(other root) error = %v", err)
+	}
+}
+
+func TestExtend_Errors(t *testing.T) {
+	if _, err := Extend([]byte("x"), 0); !errors.Is(err, ErrInvalidCellSize) {
+		t.Errorf("Extend(cell 0) error = %v", err)
+	}
+	if _, err := Extend(make([]byte, 129*129), 1); !errors.Is(err, ErrSquareTooLarge) {
+		t.Errorf("Extend(too large) error = %v", err)
+	}
+	s := mustExtend(t, []byte("x"), 8)
+	if _, err := s.Sample(2, 0); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("Sample(out of range) error = %v", err)
+	}
+}
+
+func TestHeader_DataRootCommitsToColumns(t *testing.T) {
+	s := mustExtend(t, randomData(4, 64*9), 64)
+	h := *s.Header()
+	h.ColRoots = append([]merkle.Hash(nil), h.ColRoots...)
+	h.ColRoots[0][0] ^= 1
+	if h.DataRoot() == s.Header().DataRoot() {
+		t.Error("changing a column root did not change the data root")
+	}
+}
+
+func BenchmarkExtend(b *testing.B) {
+	data := randomData(5, 512*32*32)
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		Extend(data, 512)
+	}
+}
@@ -0,0 +1,160 @@
+package dedup
+
+import (
+	"io"
+	"math/bits"
+)
+
+// ChunkerOptions bounds the chunk sizes produced by a Chunker
+type ChunkerOptions struct {
+	// MinSize is the smallest chunk (except for the last one)
+	MinSize int
+	// AvgSize is the target average; it is rounded down to a power of two
+	AvgSize int
+	// MaxSize forces a cut when no boundary was found
+	MaxSize int
+}
+
+// DefaultChunkerOptions are 2 KiB / 8 KiB / 64 KiB, as in the FastCDC paper
+var DefaultChunkerOptions = ChunkerOptions{MinSize: 2 << 10, AvgSize: 8 << 10, MaxSize: 64 << 10}
+
+// validate checks that the sizes are ordered and usable
+func (o ChunkerOptions) validate() error {
+	if o.MinSize < 64 || o.AvgSize <= o.MinSize || o.MaxSize <= o.AvgSize {
+		return ErrInvalidOptions
+	}
+	return nil
+}
+
+// gear maps every byte to a pseudo-random 64-bit value (splitmix64 with a
+// fixed seed, so chunk boundaries are stable across runs and machines)
+var gear [256]uint64
+
+func init() {
+	x := uint64(0x2545f4914f6cdd1d)
+	for i := range gear {
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+		z = (z ^ z>>27) * 0x94d049bb133111eb
+		gear[i] = z ^ z>>31
+	}
+}
+
+// Chunker splits a stream into content-defined chunks with FastCDC
+//
+// A rolling "gear" hash fp = (fp << 1) + gear[b] is computed over the
+// bytes after MinSize, and a chunk ends where the top bits of fp are all
+// zero. Because the hash only depends on the last 64 bytes, an insertion
+// or deletion shifts the boundaries near the edit only; chunks further
+// away are cut at the same content and deduplicate. Normalized chunking
+// uses a stricter mask before AvgSize and a looser one after it, which
+// pulls chunk sizes towards the average.
+type Chunker struct {
+	r            io.Reader
+	opts         ChunkerOptions
+	maskS, maskL uint64
+	buf          []byte
+	start, end   int
+	eof          bool
+}
+
+// NewChunker returns a chunker reading from r
+//
+// Errors:
+//   - ErrInvalidOptions unless 64 <= MinSize < AvgSize < MaxSize
+func NewChunker(r io.Reader, opts ChunkerOptions) (*Chunker, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	b := bits.Len(uint(opts.AvgSize)) - 1 // log2, rounded down
+	return &Chunker{
+		r:     r,
+		opts:  opts,
+		maskS: ^uint64(0) << (64 - (b + 1)),
+		maskL: ^uint64(0) << (64 - (b - 1)),
+		buf:   make([]byte, 2*opts.MaxSize),
+	}, nil
+}
+
+// Next returns the next chunk, or io.EOF after the last one
+//
+// The returned slice is only valid until the next call.
+func (c *Chunker) Next() ([]byte, error) {
+	if c.end-c.start < c.opts.MaxSize && !c.eof {
+		if err := c.fill(); err != nil {
+			return nil, err
+		}
+	}
+	if c.start == c.end {
+		return nil, io.EOF
+	}
+	n := c.cut(c.buf[c.start:c.end])
+	chunk := c.buf[c.start : c.start+n]
+	c.start += n
+	return chunk, nil
+}
+
+// fill compacts the buffer and reads until it holds MaxSize bytes or EOF
+func (c *Chunker) fill() error {
+	copy(c.buf, c.buf[c.start:c.end])
+	c.end -= c.start
+	c.start = 0
+	for c.end < c.opts.MaxSize && !c.eof {
+		n, err := c.r.Read(c.buf[c.end:])
+		c.end += n
+		if err == io.EOF {
+			c.eof = true
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cut returns the length of the next chunk at the start of data
+func (c *Chunker) cut(data []byte) int {
+	n := len(data)
+	if n <= c.opts.MinSize {
+		return n
+	}
+	if n > c.opts.MaxSize {
+		n = c.opts.MaxSize
+	}
+	normal := c.opts.AvgSize
+	if n < normal {
+		normal = n
+	}
+
+	var fp uint64
+	i := c.opts.MinSize
+	for ; i < normal; i++ {
+		fp = fp<<1 + gear[data[i]]
+		if fp&c.maskS == 0 {
+			return i + 1
+		}
+	}
+	for ; i < n; i++ {
+		fp = fp<<1 + gear[data[i]]
+		if fp&c.maskL == 0 {
+			return i + 1
+		}
+	}
+	return n
+}
+
+// Split chunks data in memory and returns the chunk boundaries as
+// sub-slices of data
+func Split(data []byte, opts ChunkerOptions) ([][]byte, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	c, _ := NewChunker(nil, opts)
+	var chunks [][]byte
+	for len(data) > 0 {
+		n := c.cut(data)
+		chunks = append(chunks, data[:n])
+		data = data[n:]
+	}
+	return chunks, nil
+}
@@ -0,0 +1,125 @@
+package dedup
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+	"testing/iotest"
+)
+
+func randomData(seed int64, n int) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+func chunkSet(t *testing.T, data []byte) map[string]bool {
+	t.Helper()
+	chunks, err := Split(data, DefaultChunkerOptions)
+	if err != nil {
+		t.Fatalf("Split() error = %v", err)
+	}
+	set := map[string]bool{}
+	for _, c := range chunks {
+		set[string(c)] = true
+	}
+	return set
+}
+
+func TestSplit_SizesWithinBounds(t *testing.T) {
+	opts := DefaultChunkerOptions
+	data := randomData(1, 4<<20)
+	chunks, err := Split(data, opts)
+	if err != nil {
+		t.Fatalf("Split() error = %v", err)
+	}
+	if !bytes.Equal(bytes.Join(chunks, nil), data) {
+		t.Fatal("chunks do not concatenate to the input")
+	}
+	for i, c := range chunks {
+		last := i == len(chunks)-1
+		if len(c) > opts.MaxSize || (len(c) < opts.MinSize && !last) {
+			t.Fatalf("chunk %d has %d bytes", i, len(c))
+		}
+	}
+	avg := len(data) / len(chunks)
+	if avg < opts.AvgSize/2 || avg > opts.AvgSize*2 {
+		t.Errorf("average chunk = %d bytes, want about %d", avg, opts.AvgSize)
+	}
+}
+
+func TestSplit_BoundaryShiftResistance(t *testing.T) {
+	data := randomData(2, 1<<20)
+	before := chunkSet(t, data)
+
+	// Insert a byte at the front and overwrite a few in the middle
+	edited := append([]byte{0x42}, data...)
+	copy(edited[len(edited)/2:], "edited")
+	after := chunkSet(t, edited)
+
+	shared := 0
+	for c := range after {
+		if before[c] {
+			shared++
+		}
+	}
+	if shared < len(before)-4 {
+		t.Errorf("%d of %d chunks survive the edits, want all but a few", shared, len(before))
+	}
+}
+
+func TestChunker_MatchesSplit(t *testing.T) {
+	data := randomData(3, 300<<10)
+	want, _ := Split(data, DefaultChunkerOptions)
+
+	// One byte per Read exercises the refill logic
+	c, err := NewChunker(iotest.OneByteReader(bytes.NewReader(data)), DefaultChunkerOptions)
+	if err != nil {
+		t.Fatalf("NewChunker() error = %v", err)
+	}
+	for i := 0; ; i++ {
+		chunk, err := c.Next()
+		if err == io.EOF {
+			if i != len(want) {
+				t.Fatalf("got %d chunks, want %d", i, len(want))
+			}
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		if i >= len(want) || !bytes.Equal(chunk, want[i]) {
+			t.Fatalf("chunk %d differs from Split", i)
+		}
+	}
+}
+
+func TestChunker_Empty(t *testing.T) {
+	c, _ := NewChunker(bytes.NewReader(nil), DefaultChunkerOptions)
+	if _, err := c.Next(); err != io.EOF {
+		t.Errorf("Next() error = %v, want io.EOF", err)
+	}
+}
+
+func TestNewChunker_InvalidOptions(t *testing.T) {
+	for _, opts := range []ChunkerOptions{
+		{MinSize: 8, AvgSize: 1024, MaxSize: 4096},
+		{MinSize: 1024, AvgSize: 1024, MaxSize: 4096},
+		{MinSize: 512, AvgSize: 4096, MaxSize: 4096},
+	} {
+		if _, err := NewChunker(nil, opts); !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("NewChunker(%+v) error = %v, want ErrInvalidOptions", opts, err)
+		}
+	}
+}
+
+func BenchmarkSplit(b *testing.B) {
+	data := randomData(4, 8<<20)
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Split(data, DefaultChunkerOptions)
+	}
+}
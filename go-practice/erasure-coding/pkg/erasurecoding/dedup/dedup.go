@@ -0,0 +1,361 @@
+// Package dedup deduplicates objects with content-defined chunking before
+// they reach the erasure coder.
+//
+// Objects are split into chunks by a FastCDC Chunker and every chunk is
+// addressed by its SHA-256. A chunk is written to the backend only the first
+// time it is seen; an object itself is just a manifest listing the hashes of
+// its chunks. Near-identical objects therefore share almost all of their
+// chunks and cost little more than one copy.
+//
+// Durability comes from the backend: with a volume.Store the unique chunks
+// are packed into volumes and erasure-coded when sealed, so objects survive
+// the loss of as many shard directories as the codec tolerates.
+//
+// Chunks and manifests are both backend objects:
+//
+//	c/<sha256 hex>   chunk data
+//	m/<name>         manifest (JSON)
+//
+// Reference counts are not persisted; Open rebuilds them from the manifests
+// and removes chunks no manifest refers to, which is what a crash between
+// writing chunks and writing the manifest leaves behind.
+package dedup
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DedupError represents errors returned by the dedup package
+type DedupError struct {
+	message string
+}
+
+func (e *DedupError) Error() string {
+	return e.message
+}
+
+// Common errors
+var (
+	ErrInvalidOptions  = &DedupError{"chunker sizes must satisfy 64 <= min < avg < max"}
+	ErrNotFound        = &DedupError{"object not found"}
+	ErrInvalidName     = &DedupError{"object name must not be empty"}
+	ErrMissingChunk    = &DedupError{"chunk referenced by a manifest is missing"}
+	ErrChunkMismatch   = &DedupError{"chunk content does not match its hash"}
+	ErrCorruptManifest = &DedupError{"corrupt manifest"}
+)
+
+const (
+	chunkPrefix    = "c/"
+	manifestPrefix = "m/"
+)
+
+// Backend stores whole objects by key; *volume.Store implements it
+type Backend interface {
+	Put(key string, data []byte) error
+	Get(key string) ([]byte, error)
+	Delete(key string) error
+	Keys() []string
+}
+
+// ChunkRef names one chunk of an object
+type ChunkRef struct {
+	Hash string `json:"hash"`
+	Size int    `json:"size"`
+}
+
+// Manifest describes an object as the ordered list of its chunks
+type Manifest struct {
+	Name   string     `json:"name"`
+	Size   int64      `json:"size"`
+	Chunks []ChunkRef `json:"chunks"`
+}
+
+// PutResult reports how much of an object was new
+type PutResult struct {
+	Manifest *Manifest
+	// NewChunks and NewBytes count the chunks written to the backend
+	NewChunks int
+	NewBytes  int64
+}
+
+// Stats summarises the deduplication achieved by a Store
+type Stats struct {
+	Objects int
+	// LogicalBytes is the total size of all objects
+	LogicalBytes int64
+	// UniqueChunks and StoredBytes count what is actually in the backend
+	UniqueChunks int
+	StoredBytes  int64
+}
+
+// Ratio returns LogicalBytes / StoredBytes (1 when nothing is stored)
+func (s Stats) Ratio() float64 {
+	if s.StoredBytes == 0 {
+		return 1
+	}
+	return float64(s.LogicalBytes) / float64(s.StoredBytes)
+}
+
+// chunkInfo is the in-memory index entry of a stored chunk
+type chunkInfo struct {
+	size int
+	refs int
+}
+
+// Store is a deduplicating object store over a Backend
+type Store struct {
+	backend Backend
+	opts    ChunkerOptions
+
+	mu        sync.Mutex
+	chunks    map[string]*chunkInfo
+	manifests map[string]*Manifest
+}
+
+// Open opens a store over backend, rebuilding the chunk index from the
+// manifests and removing unreferenced chunks
+//
+// Errors:
+//   - ErrInvalidOptions for bad chunker sizes
+//   - ErrCorruptManifest if a manifest cannot be decoded
+//   - backend errors
+func Open(backend Backend, opts ChunkerOptions) (*Store, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	s := &Store{
+		backend:   backend,
+		opts:      opts,
+		chunks:    map[string]*chunkInfo{},
+		manifests: map[string]*Manifest{},
+	}
+
+	var stored []string
+	for _, key := range backend.Keys() {
+		if hash, ok := strings.CutPrefix(key, chunkPrefix); ok {
+			stored = append(stored, hash)
+			continue
+		}
+		name, ok := strings.CutPrefix(key, manifestPrefix)
+		if !ok {
+			continue
+		}
+		raw, err := backend.Get(key)
+		if err != nil {
+			return nil, err
+		}
+		m := &Manifest{}
+		if err := json.Unmarshal(raw, m); err != nil || m.Name != name {
+			return nil, fmt.Errorf("%w: %s", ErrCorruptManifest, name)
+		}
+		s.manifests[name] = m
+		s.ref(m)
+	}
+
+	// Chunks written by a Put that crashed before its manifest
+	for _, hash := range stored {
+		if _, ok := s.chunks[hash]; !ok {
+			if err := backend.Delete(chunkPrefix + hash); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return s, nil
+}
+
+// ref increments the reference count of every chunk of m
+func (s *Store) ref(m *Manifest) {
+	for _, c := range m.Chunks {
+		info, ok := s.chunks[c.Hash]
+		if !ok {
+			info = &chunkInfo{size: c.Size}
+			s.chunks[c.Hash] = info
+		}
+		info.refs++
+	}
+}
+
+// unref decrements the reference counts of m and deletes chunks that are
+// no longer referenced
+func (s *Store) unref(m *Manifest) error {
+	var errs []error
+	for _, c := range m.Chunks {
+		info, ok := s.chunks[c.Hash]
+		if !ok {
+			continue
+		}
+		info.refs--
+		if info.refs == 0 {
+			delete(s.chunks, c.Hash)
+			errs = append(errs, s.backend.Delete(chunkPrefix+c.Hash))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Put chunks r and stores it as name, writing only chunks not already
+// present; an existing object of the same name is replaced
+//
+// Chunks are written before the manifest, so a crash leaves either the old
+// object or the new one (plus orphan chunks that Open removes).
+//
+// Errors:
+//   - ErrInvalidName for an empty name
+//   - read and backend errors
+func (s *Store) Put(name string, r io.Reader) (*PutResult, error) {
+	if name == "" {
+		return nil, ErrInvalidName
+	}
+	chunker, err := NewChunker(r, s.opts)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m := &Manifest{Name: name}
+	res := &PutResult{Manifest: m}
+	written := map[string]bool{}
+	for {
+		chunk, err := chunker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(chunk)
+		hash := hex.EncodeToString(sum[:])
+		if _, ok := s.chunks[hash]; !ok && !written[hash] {
+			if err := s.backend.Put(chunkPrefix+hash, chunk); err != nil {
+				return nil, err
+			}
+			written[hash] = true
+			res.NewChunks++
+			res.NewBytes += int64(len(chunk))
+		}
+		m.Chunks = append(m.Chunks, ChunkRef{Hash: hash, Size: len(chunk)})
+		m.Size += int64(len(chunk))
+	}
+
+	raw, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.backend.Put(manifestPrefix+name, raw); err != nil {
+		return nil, err
+	}
+	s.ref(m)
+	old := s.manifests[name]
+	s.manifests[name] = m
+	if old != nil {
+		if err := s.unref(old); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// Manifest returns the manifest of name
+//
+// Errors:
+//   - ErrNotFound if there is no such object
+func (s *Store) Manifest(name string) (*Manifest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.manifests[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return m, nil
+}
+
+// Get reassembles name from its chunks, verifying every chunk hash
+//
+// Errors:
+//   - ErrNotFound if there is no such object
+//   - ErrMissingChunk if the backend lost a chunk
+//   - ErrChunkMismatch if a chunk does not match its hash
+func (s *Store) Get(name string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := s.WriteTo(name, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteTo streams name to w chunk by chunk; errors are those of Get
+func (s *Store) WriteTo(name string, w io.Writer) error {
+	m, err := s.Manifest(name)
+	if err != nil {
+		return err
+	}
+	for _, c := range m.Chunks {
+		chunk, err := s.backend.Get(chunkPrefix + c.Hash)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrMissingChunk, c.Hash, err)
+		}
+		sum := sha256.Sum256(chunk)
+		if hex.EncodeToString(sum[:]) != c.Hash || len(chunk) != c.Size {
+			return fmt.Errorf("%w: %s", ErrChunkMismatch, c.Hash)
+		}
+		if _, err := w.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes name and every chunk only it referenced
+//
+// The manifest goes first, so a crash leaves at worst orphan chunks.
+// Deleting a missing object is not an error.
+func (s *Store) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.manifests[name]
+	if !ok {
+		return nil
+	}
+	if err := s.backend.Delete(manifestPrefix + name); err != nil {
+		return err
+	}
+	delete(s.manifests, name)
+	return s.unref(m)
+}
+
+// Names returns all object names in sorted order
+func (s *Store) Names() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.manifests))
+	for name := range s.manifests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Stats returns the current deduplication statistics
+func (s *Store) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := Stats{Objects: len(s.manifests), UniqueChunks: len(s.chunks)}
+	for _, m := range s.manifests {
+		st.LogicalBytes += m.Size
+	}
+	for _, c := range s.chunks {
+		st.StoredBytes += int64(c.size)
+	}
+	return st
+}
@@ -0,0 +1,237 @@
+package dedup
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/codec"
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/volume"
+)
+
+// testVolumes opens a volume store with rs:4+2 shards over six directories
+func testVolumes(t *testing.T, root string) (*volume.Store, volume.Config) {
+	t.Helper()
+	c, err := codec.Parse("rs:4+2")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	cfg := volume.Config{Dir: filepath.Join(root, "volumes"), Codec: c, MaxVolumeSize: 256 << 10}
+	for i := 0; i < 6; i++ {
+		cfg.ShardDirs = append(cfg.ShardDirs, filepath.Join(root, fmt.Sprintf("disk%d", i)))
+	}
+	vs, err := volume.Open(cfg)
+	if err != nil {
+		t.Fatalf("volume.Open() error = %v", err)
+	}
+	t.Cleanup(func() { vs.Close() })
+	return vs, cfg
+}
+
+func mustOpen(t *testing.T, b Backend) *Store {
+	t.Helper()
+	s, err := Open(b, DefaultChunkerOptions)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	return s
+}
+
+func mustPut(t *testing.T, s *Store, name string, data []byte) *PutResult {
+	t.Helper()
+	res, err := s.Put(name, bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Put(%s) error = %v", name, err)
+	}
+	return res
+}
+
+func checkGet(t *testing.T, s *Store, name string, want []byte) {
+	t.Helper()
+	got, err := s.Get(name)
+	if err != nil || !bytes.Equal(got, want) {
+		t.Fatalf("Get(%s) = %d bytes, %v; want %d bytes", name, len(got), err, len(want))
+	}
+}
+
+// variant returns base with a few small edits, like a new revision of a file
+func variant(base []byte, i int) []byte {
+	v := append([]byte(fmt.Sprintf("revision %d\n", i)), base...)
+	copy(v[len(v)/3:], fmt.Sprintf("patched by %d", i))
+	return v
+}
+
+func TestStore_DeduplicatesNearIdenticalObjects(t *testing.T) {
+	vs, _ := testVolumes(t, t.TempDir())
+	s := mustOpen(t, vs)
+
+	base := randomData(10, 1<<20)
+	first := mustPut(t, s, "v0", base)
+	if first.NewBytes != int64(len(base)) {
+		t.Fatalf("first Put wrote %d bytes, want %d", first.NewBytes, len(base))
+	}
+	objects := map[string][]byte{"v0": base}
+	for i := 1; i <= 9; i++ {
+		name := fmt.Sprintf("v%d", i)
+		objects[name] = variant(base, i)
+		res := mustPut(t, s, name, objects[name])
+		if res.NewBytes > int64(len(base))/8 {
+			t.Errorf("Put(%s) wrote %d new bytes, want a small fraction", name, res.NewBytes)
+		}
+	}
+
+	st := s.Stats()
+	if st.Objects != 10 || st.Ratio() < 5 {
+		t.Errorf("Stats() = %+v ratio %.2f, want ratio >= 5", st, st.Ratio())
+	}
+	for name, want := range objects {
+		checkGet(t, s, name, want)
+	}
+}
+
+func TestStore_DeleteReleasesUnsharedChunks(t *testing.T) {
+	vs, _ := testVolumes(t, t.TempDir())
+	s := mustOpen(t, vs)
+	base := randomData(11, 256<<10)
+	mustPut(t, s, "a", base)
+	mustPut(t, s, "b", variant(base, 1))
+	both := s.Stats().StoredBytes
+
+	if err := s.Delete("a"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	checkGet(t, s, "b", variant(base, 1))
+	if st := s.Stats(); st.StoredBytes >= both || st.StoredBytes < int64(len(base)) {
+		t.Errorf("StoredBytes = %d after delete, was %d", st.StoredBytes, both)
+	}
+	if err := s.Delete("b"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if st := s.Stats(); st.UniqueChunks != 0 || st.StoredBytes != 0 {
+		t.Errorf("Stats() = %+v after deleting everything", st)
+	}
+	if keys := vs.Keys(); len(keys) != 0 {
+		t.Errorf("backend still holds %d keys", len(keys))
+	}
+	if _, err := s.Get("a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(deleted) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestStore_Overwrite(t *testing.T) {
+	vs, _ := testVolumes(t, t.TempDir())
+	s := mustOpen(t, vs)
+	mustPut(t, s, "obj", randomData(12, 100<<10))
+	replacement := randomData(13, 50<<10)
+	mustPut(t, s, "obj", replacement)
+	checkGet(t, s, "obj", replacement)
+	if st := s.Stats(); st.StoredBytes != int64(len(replacement)) {
+		t.Errorf("StoredBytes = %d, want only the replacement", st.StoredBytes)
+	}
+}
+
+func TestStore_SurvivesShardLoss(t *testing.T) {
+	root := t.TempDir()
+	vs, cfg := testVolumes(t, root)
+	s := mustOpen(t, vs)
+	base := randomData(14, 512<<10)
+	objects := map[string][]byte{}
+	for i := 0; i < 4; i++ {
+		name := fmt.Sprintf("file%d", i)
+		objects[name] = variant(base, i)
+		mustPut(t, s, name, objects[name])
+	}
+	if err := vs.Seal(); err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	vs.Close()
+
+	// rs:4+2 tolerates two lost disks
+	for _, d := range cfg.ShardDirs[:2] {
+		if err := os.RemoveAll(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	vs2, err := volume.Open(cfg)
+	if err != nil {
+		t.Fatalf("volume.Open() error = %v", err)
+	}
+	defer vs2.Close()
+	s2 := mustOpen(t, vs2)
+	for name, want := range objects {
+		checkGet(t, s2, name, want)
+	}
+}
+
+func TestOpen_RemovesOrphanChunks(t *testing.T) {
+	vs, _ := testVolumes(t, t.TempDir())
+	s := mustOpen(t, vs)
+	mustPut(t, s, "kept", randomData(15, 64<<10))
+	// A chunk whose manifest never made it, as after a crash in Put
+	if err := vs.Put(chunkPrefix+"deadbeef", []byte("orphan")); err != nil {
+		t.Fatal(err)
+	}
+
+	s2 := mustOpen(t, vs)
+	if _, err := vs.Get(chunkPrefix + "deadbeef"); err == nil {
+		t.Error("orphan chunk survived Open")
+	}
+	if st, st2 := s.Stats(), s2.Stats(); st != st2 {
+		t.Errorf("reopened Stats() = %+v, want %+v", st2, st)
+	}
+	checkGet(t, s2, "kept", randomData(15, 64<<10))
+}
+
+func TestStore_DetectsCorruptChunk(t *testing.T) {
+	vs, _ := testVolumes(t, t.TempDir())
+	s := mustOpen(t, vs)
+	m := mustPut(t, s, "obj", randomData(16, 32<<10)).Manifest
+	if err := vs.Put(chunkPrefix+m.Chunks[0].Hash, []byte("garbage")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get("obj"); !errors.Is(err, ErrChunkMismatch) {
+		t.Errorf("Get() error = %v, want ErrChunkMismatch", err)
+	}
+	if err := vs.Delete(chunkPrefix + m.Chunks[0].Hash); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get("obj"); !errors.Is(err, ErrMissingChunk) {
+		t.Errorf("Get() error = %v, want ErrMissingChunk", err)
+	}
+}
+
+func TestStore_InvalidName(t *testing.T) {
+	vs, _ := testVolumes(t, t.TempDir())
+	s := mustOpen(t, vs)
+	if _, err := s.Put("", bytes.NewReader(nil)); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("Put(\"\") error = %v, want ErrInvalidName", err)
+	}
+}
+
+func BenchmarkStore_PutDuplicate(b *testing.B) {
+	c, _ := codec.Parse("rs:4+2")
+	root := b.TempDir()
+	cfg := volume.Config{Dir: filepath.Join(root, "volumes"), Codec: c}
+	for i := 0; i < 6; i++ {
+		cfg.ShardDirs = append(cfg.ShardDirs, filepath.Join(root, fmt.Sprintf("disk%d", i)))
+	}
+	vs, err := volume.Open(cfg)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer vs.Close()
+	s, _ := Open(vs, DefaultChunkerOptions)
+	data := randomData(17, 4<<20)
+	s.Put("base", bytes.NewReader(data))
+
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.Put(fmt.Sprintf("copy%d", i), bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
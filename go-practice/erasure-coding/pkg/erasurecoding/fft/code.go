@@ -0,0 +1,282 @@
+package fft
+
+// FFTError represents errors returned by the FFT code
+type FFTError struct {
+	message string
+}
+
+func (e *FFTError) Error() string {
+	return e.message
+}
+
+// Common errors
+var (
+	ErrInvalidParams = &FFTError{"invalid fft code parameters"}
+	ErrShardCount    = &FFTError{"wrong number of shards for code"}
+	ErrShardSize     = &FFTError{"shards must all have the same size, a non-zero multiple of the word size"}
+	ErrTooFewShards  = &FFTError{"too few shards available to reconstruct"}
+)
+
+// Code is a k+m Reed-Solomon code evaluated with the additive FFT
+//
+// Shard positions 0..M-1, where M = NextPow2(m), belong to the parity
+// shards (only the first m are kept) and positions M..M+k-1 to the data
+// shards.
+type Code struct {
+	f    *field
+	k, m int
+	mPow int // NextPow2(m): parity positions, and the encoder's chunk size
+	n    int // NextPow2(mPow + k): the decoder's transform size
+}
+
+// New creates a k+m code over GF(2^bits)
+//
+// Errors:
+//   - ErrInvalidParams unless bits is 8 or 16, k >= 1, m >= 1 and
+//     k + NextPow2(m) <= 2^bits
+func New(bits, k, m int) (*Code, error) {
+	var f *field
+	switch bits {
+	case 8:
+		f = field8()
+	case 16:
+		f = field16()
+	default:
+		return nil, ErrInvalidParams
+	}
+	if k < 1 || m < 1 || k+NextPow2(m) > f.order {
+		return nil, ErrInvalidParams
+	}
+	mPow := NextPow2(m)
+	return &Code{f: f, k: k, m: m, mPow: mPow, n: NextPow2(mPow + k)}, nil
+}
+
+// NextPow2 returns the smallest power of two >= n (1 for n <= 1)
+func NextPow2(n int) int {
+	p := 1
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
+
+// DataShards returns k
+func (c *Code) DataShards() int { return c.k }
+
+// ParityShards returns m
+func (c *Code) ParityShards() int { return c.m }
+
+// WordSize returns the number of shard bytes per field element: 1 for
+// GF(2^8), 2 for GF(2^16). Shard sizes must be a multiple of it.
+func (c *Code) WordSize() int { return c.f.wordSize() }
+
+// Encode computes the m parity shards from the k data shards
+//
+// parity must hold m slices of the data shard size; they are overwritten.
+// The data is processed in chunks of M = NextPow2(m) shards: each chunk is
+// interpolated with an inverse transform, the results are summed, and one
+// forward transform evaluates the sum at the parity positions. That is
+// O(k log m) shard operations.
+//
+// Errors:
+//   - ErrShardCount unless there are k data and m parity shards
+//   - ErrShardSize if the shards differ in size or do not hold whole words
+func (c *Code) Encode(data, parity [][]byte) error {
+	size, err := c.check(data, parity, false)
+	if err != nil {
+		return err
+	}
+	work := make([][]byte, c.mPow)
+	for i := range work {
+		if i < c.m {
+			work[i] = parity[i][:size]
+		} else {
+			work[i] = make([]byte, size)
+		}
+	}
+	c.encode(data, work, size)
+	return nil
+}
+
+// encode fills work (mPow shards) with the parity at positions 0..mPow-1
+func (c *Code) encode(data, work [][]byte, size int) {
+	f := c.f
+	c.interpolate(work, data[:min(c.k, c.mPow)], c.mPow)
+	if c.k > c.mPow {
+		temp := make([][]byte, c.mPow)
+		for i := range temp {
+			temp[i] = make([]byte, size)
+		}
+		for start := c.mPow; start < c.k; start += c.mPow {
+			c.interpolate(temp, data[start:min(start+c.mPow, c.k)], c.mPow+start)
+			for i := range work {
+				xorSlice(work[i], temp[i])
+			}
+		}
+	}
+	f.fft(work, c.m, 0)
+}
+
+// interpolate copies a chunk of data shards at absolute position base into
+// work, zero-pads it and applies the inverse transform
+func (c *Code) interpolate(work, chunk [][]byte, base int) {
+	for i := range work {
+		if i < len(chunk) {
+			copy(work[i], chunk[i])
+		} else {
+			clear(work[i])
+		}
+	}
+	c.f.ifft(work, len(chunk), base)
+}
+
+// Reconstruct rebuilds every missing (nil or empty) data and parity shard
+// in place
+//
+// Lost data shards are decoded in one pass: the survivors are weighted by
+// the error locator polynomial, which vanishes at the erased positions,
+// transformed to coefficients, differentiated and evaluated again; the
+// erased values fall out of the derivative. Lost parity is re-encoded.
+//
+// Errors:
+//   - ErrShardCount unless there are k data and m parity shards
+//   - ErrShardSize if the present shards differ in size or do not hold
+//     whole words
+//   - ErrTooFewShards if more than m shards are missing
+func (c *Code) Reconstruct(data, parity [][]byte) error {
+	size, err := c.check(data, parity, true)
+	if err != nil {
+		return err
+	}
+	lost, dataLost := 0, false
+	for i := range data {
+		if len(data[i]) == 0 {
+			lost++
+			dataLost = true
+		}
+	}
+	for i := range parity {
+		if len(parity[i]) == 0 {
+			lost++
+		}
+	}
+	if lost > c.m {
+		return ErrTooFewShards
+	}
+	if dataLost {
+		c.decode(data, parity, size)
+	}
+
+	var work [][]byte
+	for i := range parity {
+		if len(parity[i]) == 0 {
+			if work == nil {
+				work = make([][]byte, c.mPow)
+				for j := range work {
+					work[j] = make([]byte, size)
+				}
+				c.encode(data, work, size)
+			}
+			parity[i] = work[i]
+		}
+	}
+	return nil
+}
+
+// decode recovers the missing data shards
+func (c *Code) decode(data, parity [][]byte, size int) {
+	f := c.f
+	mPow := c.mPow
+
+	// Erasure positions: lost shards and the unused parity positions
+	locs := make([]uint16, f.order)
+	for i := 0; i < mPow; i++ {
+		if i >= c.m || len(parity[i]) == 0 {
+			locs[i] = 1
+		}
+	}
+	for i := range data {
+		if len(data[i]) == 0 {
+			locs[mPow+i] = 1
+		}
+	}
+	// The error locator's logarithm at every point: FWHT, multiply by the
+	// transformed logarithm table, FWHT again
+	f.fwht(locs, mPow+c.k)
+	for i := range locs {
+		locs[i] = uint16(uint32(locs[i]) * uint32(f.logWalsh[i]) % uint32(f.modulus))
+	}
+	// Only the first n points are needed, and a transform output j < n
+	// sees the inputs through their low bits only, so fold the rest in and
+	// transform n entries instead of the whole field
+	for i := c.n; i < len(locs); i++ {
+		locs[i%c.n] = f.addMod(locs[i%c.n], locs[i])
+	}
+	locs = locs[:c.n]
+	f.fwht(locs, c.n)
+
+	work := make([][]byte, c.n)
+	for i := range work {
+		work[i] = make([]byte, size)
+		var shard []byte
+		switch {
+		case i < c.m:
+			shard = parity[i]
+		case i >= mPow && i < mPow+c.k:
+			shard = data[i-mPow]
+		}
+		if len(shard) > 0 {
+			f.mul(work[i], shard, locs[i])
+		}
+	}
+	f.ifft(work, mPow+c.k, 0)
+
+	// Formal derivative in the novel polynomial basis
+	for i := 1; i < c.n; i++ {
+		width := i & -i
+		for j := 0; j < width; j++ {
+			xorSlice(work[i-width+j], work[i+j])
+		}
+	}
+
+	f.fft(work, mPow+c.k, 0)
+	for i := range data {
+		if len(data[i]) == 0 {
+			data[i] = work[mPow+i]
+			f.mul(data[i], data[i], uint16(f.modulus)-locs[mPow+i])
+		}
+	}
+}
+
+// check validates the shard counts and returns the common shard size
+func (c *Code) check(data, parity [][]byte, allowMissing bool) (int, error) {
+	if len(data) != c.k || len(parity) != c.m {
+		return 0, ErrShardCount
+	}
+	size := 0
+	for _, group := range [][][]byte{data, parity} {
+		for _, shard := range group {
+			if len(shard) == 0 {
+				if !allowMissing {
+					return 0, ErrShardSize
+				}
+				continue
+			}
+			if size == 0 {
+				size = len(shard)
+			} else if len(shard) != size {
+				return 0, ErrShardSize
+			}
+		}
+	}
+	if size == 0 {
+		if allowMissing {
+			return 0, ErrTooFewShards
+		}
+		return 0, ErrShardSize
+	}
+	if size%c.WordSize() != 0 {
+		return 0, ErrShardSize
+	}
+	return size, nil
+}
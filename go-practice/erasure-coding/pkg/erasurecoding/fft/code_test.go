@@ -0,0 +1,223 @@
+package fft
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// encoded returns random data shards and their parity
+func encoded(t testing.TB, c *Code, size int) (data, parity [][]byte) {
+	t.Helper()
+	rng := rand.New(rand.NewSource(int64(c.k*1000 + c.m)))
+	data = randomShards(rng, c.k, size)
+	parity = make([][]byte, c.m)
+	for i := range parity {
+		parity[i] = make([]byte, size)
+	}
+	if err := c.Encode(data, parity); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	return data, parity
+}
+
+// checkLoss drops the listed shards (data first, then parity) and checks
+// that Reconstruct restores them
+func checkLoss(t *testing.T, c *Code, data, parity [][]byte, lost []int) {
+	t.Helper()
+	d := append([][]byte(nil), data...)
+	p := append([][]byte(nil), parity...)
+	for _, i := range lost {
+		if i < c.k {
+			d[i] = nil
+		} else {
+			p[i-c.k] = nil
+		}
+	}
+	if err := c.Reconstruct(d, p); err != nil {
+		t.Fatalf("Reconstruct(lost %v) error = %v", lost, err)
+	}
+	for i := range d {
+		if !bytes.Equal(d[i], data[i]) {
+			t.Fatalf("Reconstruct(lost %v): data shard %d differs", lost, i)
+		}
+	}
+	for i := range p {
+		if !bytes.Equal(p[i], parity[i]) {
+			t.Fatalf("Reconstruct(lost %v): parity shard %d differs", lost, i)
+		}
+	}
+}
+
+func TestCode_AnyMErasures(t *testing.T) {
+	for _, bits := range []int{8, 16} {
+		// Data chunks of M = NextPow2(m) shards, partial chunks and m not a
+		// power of two all take different paths
+		for _, km := range [][2]int{{1, 1}, {1, 3}, {2, 2}, {4, 2}, {5, 3}, {9, 4}, {6, 5}} {
+			t.Run(fmt.Sprintf("gf%d/%d+%d", bits, km[0], km[1]), func(t *testing.T) {
+				c, err := New(bits, km[0], km[1])
+				if err != nil {
+					t.Fatalf("New() error = %v", err)
+				}
+				data, parity := encoded(t, c, 32)
+				n := km[0] + km[1]
+				for mask := 1; mask < 1<<n; mask++ {
+					var lost []int
+					for i := 0; i < n; i++ {
+						if mask&(1<<i) != 0 {
+							lost = append(lost, i)
+						}
+					}
+					if len(lost) <= km[1] {
+						checkLoss(t, c, data, parity, lost)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestCode_WideStripes(t *testing.T) {
+	for _, tc := range []struct{ bits, k, m int }{
+		{8, 192, 64},
+		{16, 1000, 200},
+		{16, 3000, 1000},
+	} {
+		t.Run(fmt.Sprintf("gf%d/%d+%d", tc.bits, tc.k, tc.m), func(t *testing.T) {
+			c, err := New(tc.bits, tc.k, tc.m)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			data, parity := encoded(t, c, 16)
+			rng := rand.New(rand.NewSource(int64(tc.k)))
+			for trial := 0; trial < 3; trial++ {
+				checkLoss(t, c, data, parity, rng.Perm(tc.k + tc.m)[:tc.m])
+			}
+			// All parity, or the first m data shards
+			var lost []int
+			for i := 0; i < tc.m; i++ {
+				lost = append(lost, i)
+			}
+			checkLoss(t, c, data, parity, lost)
+		})
+	}
+}
+
+func TestCode_TooManyErasures(t *testing.T) {
+	c, _ := New(8, 4, 2)
+	data, parity := encoded(t, c, 8)
+	data[0], data[2], parity[1] = nil, nil, nil
+	if err := c.Reconstruct(data, parity); err != ErrTooFewShards {
+		t.Errorf("Reconstruct(3 lost) error = %v, want %v", err, ErrTooFewShards)
+	}
+}
+
+func TestNew_Limits(t *testing.T) {
+	for _, tc := range []struct {
+		bits, k, m int
+		ok         bool
+	}{
+		{8, 128, 128, true},
+		{8, 129, 128, false},
+		{8, 191, 65, false}, // NextPow2(65) = 128
+		{8, 128, 65, true},
+		{16, 32768, 32768, true},
+		{16, 32769, 32768, false},
+		{8, 0, 1, false},
+		{8, 1, 0, false},
+		{12, 4, 2, false},
+	} {
+		_, err := New(tc.bits, tc.k, tc.m)
+		if (err == nil) != tc.ok {
+			t.Errorf("New(%d, %d, %d) error = %v, want ok = %v", tc.bits, tc.k, tc.m, err, tc.ok)
+		}
+	}
+}
+
+func TestCode_ShardErrors(t *testing.T) {
+	c, _ := New(16, 4, 2)
+	data, parity := encoded(t, c, 8)
+	if err := c.Encode(data[:3], parity); err != ErrShardCount {
+		t.Errorf("Encode(3 data) error = %v, want %v", err, ErrShardCount)
+	}
+	odd := [][]byte{{1, 2, 3}, {1, 2, 3}, {1, 2, 3}, {1, 2, 3}}
+	if err := c.Encode(odd, [][]byte{make([]byte, 3), make([]byte, 3)}); err != ErrShardSize {
+		t.Errorf("Encode(odd size) error = %v, want %v", err, ErrShardSize)
+	}
+	data[1] = data[1][:4]
+	if err := c.Encode(data, parity); err != ErrShardSize {
+		t.Errorf("Encode(mixed sizes) error = %v, want %v", err, ErrShardSize)
+	}
+	if err := c.Reconstruct(make([][]byte, 4), make([][]byte, 2)); err != ErrTooFewShards {
+		t.Errorf("Reconstruct(all lost) error = %v, want %v", err, ErrTooFewShards)
+	}
+}
+
+func TestNextPow2(t *testing.T) {
+	for n, want := range map[int]int{0: 1, 1: 1, 2: 2, 3: 4, 64: 64, 65: 128} {
+		if got := NextPow2(n); got != want {
+			t.Errorf("NextPow2(%d) = %d, want %d", n, got, want)
+		}
+	}
+}
+
+func ExampleCode() {
+	c, _ := New(16, 1000, 200)
+	data := make([][]byte, 1000)
+	for i := range data {
+		data[i] = []byte{byte(i), byte(i >> 8)}
+	}
+	parity := make([][]byte, 200)
+	for i := range parity {
+		parity[i] = make([]byte, 2)
+	}
+	_ = c.Encode(data, parity)
+
+	for i := 0; i < 200; i++ {
+		data[i*5] = nil // lose every fifth data shard
+	}
+	err := c.Reconstruct(data, parity)
+	fmt.Println(err, data[995])
+	// Output: <nil> [227 3]
+}
+
+func BenchmarkEncode(b *testing.B) {
+	for _, tc := range []struct{ bits, k, m int }{
+		{8, 10, 4},
+		{8, 128, 64},
+		{16, 1000, 200},
+	} {
+		b.Run(fmt.Sprintf("gf%d/%d+%d", tc.bits, tc.k, tc.m), func(b *testing.B) {
+			c, _ := New(tc.bits, tc.k, tc.m)
+			data, parity := encoded(b, c, 4096)
+			b.SetBytes(int64(tc.k * 4096))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_ = c.Encode(data, parity)
+			}
+		})
+	}
+}
+
+func BenchmarkReconstruct(b *testing.B) {
+	for _, tc := range []struct{ bits, k, m int }{
+		{8, 128, 64},
+		{16, 1000, 200},
+	} {
+		b.Run(fmt.Sprintf("gf%d/%d+%d", tc.bits, tc.k, tc.m), func(b *testing.B) {
+			c, _ := New(tc.bits, tc.k, tc.m)
+			data, parity := encoded(b, c, 4096)
+			d := make([][]byte, len(data))
+			b.SetBytes(int64(tc.k * 4096))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				copy(d, data)
+				for j := 0; j < tc.m; j++ {
+					d[j] = nil
+				}
+				_ = c.Reconstruct(d, parity)
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+// Package fft implements Reed-Solomon erasure coding with the additive
+// fast Fourier transform of Lin, Chung and Han, in the style of
+// Leopard-RS, over GF(2^8) and GF(2^16).
+//
+// A matrix Reed-Solomon encoder does k·m multiply-adds per byte column, so
+// its cost grows with the square of the stripe width. Here the data shards
+// are the values of a polynomial at k field points, written in the
+// "novel polynomial basis" whose transform needs only O(n log n) butterfly
+// operations. Encoding interpolates the data with an inverse FFT and
+// evaluates the result at the parity points with a forward FFT. Erasure
+// decoding multiplies the survivors by an error locator polynomial, found
+// with a Walsh-Hadamard transform over logarithms, and recovers the lost
+// values from the formal derivative of the product.
+//
+// The evaluation points are the field elements themselves, numbered so
+// that point i is Σ v_b over the set bits b of i for a Cantor basis v
+// (v_0 = 1 and v_b² + v_b = v_(b-1)). That numbering makes the subspace
+// polynomials the transform recurses on cheap to evaluate. Field elements
+// are stored in this numbering too, so the byte values in shards do not
+// match package gf256 or gf65536 arithmetic; the code is still an MDS
+// Reed-Solomon code, just not the same one as codec's rs.
+//
+// The 8-bit code supports k + NextPow2(m) <= 256 shards and the 16-bit code
+// k + NextPow2(m) <= 65536. GF(2^16) shards are read as little-endian
+// 16-bit words and must have an even size.
+package fft
+
+import "sync"
+
+// field holds the tables of one field in Cantor-basis numbering
+type field struct {
+	bits    int
+	order   int // number of elements, 2^bits
+	modulus int // multiplicative group order, 2^bits - 1
+
+	// log[x] is the discrete logarithm of x; log[0] = modulus. exp is its
+	// inverse with exp[modulus] = exp[0] = 1, so sums of logarithms reduced
+	// by addMod index it directly.
+	log []uint16
+	exp []uint16
+
+	// skew holds the logarithms of the butterfly twiddle factors; modulus
+	// marks a zero factor, where the butterfly is a plain XOR
+	skew []uint16
+	// logWalsh is the Walsh-Hadamard transform of log, used to evaluate
+	// the error locator polynomial
+	logWalsh []uint16
+
+	// mul8 is the 8-bit product table mul8[logM][x] = x·exp(logM)
+	mul8 *[256][256]byte
+}
+
+var (
+	gf8Once, gf16Once sync.Once
+	gf8, gf16         *field
+)
+
+// field8 returns GF(2^8) with polynomial 0x11D, the field Leopard uses
+func field8() *field {
+	gf8Once.Do(func() {
+		gf8 = newField(8, 0x11D, []uint16{1, 214, 152, 146, 86, 200, 88, 230})
+		gf8.mul8 = new([256][256]byte)
+		for logM := 0; logM < 256; logM++ {
+			for x := 1; x < 256; x++ {
+				gf8.mul8[logM][x] = byte(gf8.mulLog(uint16(x), uint16(logM)))
+			}
+		}
+	})
+	return gf8
+}
+
+// field16 returns GF(2^16) with polynomial 0x1002D. The tables take about
+// 512 KiB and are built on first use.
+func field16() *field {
+	gf16Once.Do(func() {
+		gf16 = newField(16, 0x1002D, []uint16{
+			0x0001, 0xACCA, 0x3C0E, 0x163E, 0xC582, 0xED2E, 0x914C, 0x4012,
+			0x6C98, 0x10D8, 0x6A72, 0xB900, 0xFDB8, 0xFB34, 0xFF38, 0x991E,
+		})
+	})
+	return gf16
+}
+
+// newField builds the tables of GF(2^bits) for a primitive polynomial and a
+// Cantor basis given in the polynomial representation
+func newField(bits, poly int, cantor []uint16) *field {
+	order := 1 << bits
+	f := &field{
+		bits:    bits,
+		order:   order,
+		modulus: order - 1,
+		log:     make([]uint16, order),
+		exp:     make([]uint16, order),
+	}
+
+	// Logarithms in the polynomial representation, with generator 2
+	polyLog := make([]uint16, order)
+	state := 1
+	for i := 0; i < f.modulus; i++ {
+		polyLog[state] = uint16(i)
+		state <<= 1
+		if state >= order {
+			state ^= poly
+		}
+	}
+	polyLog[0] = uint16(f.modulus)
+
+	// Renumber: element i is the Cantor basis combination selected by i
+	elem := make([]uint16, order)
+	for b := 0; b < bits; b++ {
+		width := 1 << b
+		for j := 0; j < width; j++ {
+			elem[j+width] = elem[j] ^ cantor[b]
+		}
+	}
+	for i := range f.log {
+		f.log[i] = polyLog[elem[i]]
+	}
+	for i := range f.log {
+		f.exp[f.log[i]] = uint16(i)
+	}
+	f.exp[f.modulus] = f.exp[0]
+
+	f.initSkew()
+	f.logWalsh = append([]uint16(nil), f.log...)
+	f.logWalsh[0] = 0
+	f.fwht(f.logWalsh, order)
+	return f
+}
+
+// initSkew computes the twiddle factors: skew[j] is the value at point j+1
+// of the normalised subspace polynomial of the transform layer j+1 belongs
+// to, built up one basis vector at a time
+func (f *field) initSkew() {
+	temp := make([]uint16, f.bits-1)
+	for i := 1; i < f.bits; i++ {
+		temp[i-1] = uint16(1 << i)
+	}
+	skew := make([]uint16, f.modulus)
+	for m := 0; m < f.bits-1; m++ {
+		step := 1 << (m + 1)
+		skew[1<<m-1] = 0
+		for i := m; i < f.bits-1; i++ {
+			s := 1 << (i + 1)
+			for j := 1<<m - 1; j < s; j += step {
+				skew[j+s] = skew[j] ^ temp[i]
+			}
+		}
+		temp[m] = uint16(f.modulus) - f.log[f.mulLog(temp[m], f.log[temp[m]^1])]
+		for i := m + 1; i < f.bits-1; i++ {
+			sum := f.addMod(f.log[temp[i]^1], temp[m])
+			temp[i] = f.mulLog(temp[i], sum)
+		}
+	}
+	for i, v := range skew {
+		skew[i] = f.log[v]
+	}
+	f.skew = skew
+}
+
+// addMod returns a + b modulo the group order. The result may be modulus
+// itself, which stands for 0 and is what a zero's logarithm is.
+func (f *field) addMod(a, b uint16) uint16 {
+	sum := uint32(a) + uint32(b)
+	return uint16((sum + sum>>f.bits) & uint32(f.modulus))
+}
+
+// subMod returns a - b modulo the group order
+func (f *field) subMod(a, b uint16) uint16 {
+	dif := uint32(a) - uint32(b)
+	return uint16((dif + dif>>f.bits) & uint32(f.modulus))
+}
+
+// mulLog returns a·exp(logB)
+func (f *field) mulLog(a, logB uint16) uint16 {
+	if a == 0 {
+		return 0
+	}
+	return f.exp[f.addMod(f.log[a], logB)]
+}
+
+// fwht applies the Walsh-Hadamard transform modulo the group order in
+// place. Only the first mtrunc entries may be non-zero.
+func (f *field) fwht(data []uint16, mtrunc int) {
+	bits, mod := uint32(f.bits), uint32(f.modulus)
+	for width := 1; width < len(data); width <<= 1 {
+		for r := 0; r < mtrunc; r += 2 * width {
+			lo, hi := data[r:r+width], data[r+width:r+2*width]
+			for i, a := range lo {
+				b := hi[i]
+				sum := uint32(a) + uint32(b)
+				dif := uint32(a) - uint32(b)
+				lo[i] = uint16((sum + sum>>bits) & mod)
+				hi[i] = uint16((dif + dif>>bits) & mod)
+			}
+		}
+	}
+}
+
+// wordSize returns the shard bytes per element
+func (f *field) wordSize() int {
+	return f.bits / 8
+}
+
+// mulAdd sets out ^= in·exp(logM)
+func (f *field) mulAdd(out, in []byte, logM uint16) {
+	if f.mul8 != nil {
+		t := &f.mul8[logM]
+		out = out[:len(in)]
+		for i, b := range in {
+			out[i] ^= t[b]
+		}
+		return
+	}
+	lo, hi := f.splitTables(logM)
+	out = out[:len(in)]
+	for i := 0; i+1 < len(in); i += 2 {
+		v := lo[in[i]] ^ hi[in[i+1]]
+		out[i] ^= byte(v)
+		out[i+1] ^= byte(v >> 8)
+	}
+}
+
+// mul sets out = in·exp(logM)
+func (f *field) mul(out, in []byte, logM uint16) {
+	if f.mul8 != nil {
+		t := &f.mul8[logM]
+		out = out[:len(in)]
+		for i, b := range in {
+			out[i] = t[b]
+		}
+		return
+	}
+	lo, hi := f.splitTables(logM)
+	out = out[:len(in)]
+	for i := 0; i+1 < len(in); i += 2 {
+		v := lo[in[i]] ^ hi[in[i+1]]
+		out[i], out[i+1] = byte(v), byte(v>>8)
+	}
+}
+
+// splitTables returns the 16-bit tables with w·exp(logM) =
+// lo[w&0xff] ^ hi[w>>8]; multiplication by a constant is linear over
+// GF(2) in any numbering of the elements
+func (f *field) splitTables(logM uint16) (lo, hi [256]uint16) {
+	for i := 1; i < 256; i++ {
+		lo[i] = f.mulLog(uint16(i), logM)
+		hi[i] = f.mulLog(uint16(i)<<8, logM)
+	}
+	return lo, hi
+}
@@ -0,0 +1,103 @@
+package fft
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+// polyMul multiplies in the polynomial representation, bit by bit
+func polyMul(a, b uint32, bits int, poly uint32) uint32 {
+	var p uint32
+	for b > 0 {
+		if b&1 != 0 {
+			p ^= a
+		}
+		a <<= 1
+		if a>>bits != 0 {
+			a ^= poly
+		}
+		b >>= 1
+	}
+	return p
+}
+
+func TestCantorBasis(t *testing.T) {
+	for _, tc := range []struct {
+		bits   int
+		poly   uint32
+		cantor []uint32
+	}{
+		{8, 0x11D, []uint32{1, 214, 152, 146, 86, 200, 88, 230}},
+		{16, 0x1002D, []uint32{
+			0x0001, 0xACCA, 0x3C0E, 0x163E, 0xC582, 0xED2E, 0x914C, 0x4012,
+			0x6C98, 0x10D8, 0x6A72, 0xB900, 0xFDB8, 0xFB34, 0xFF38, 0x991E,
+		}},
+	} {
+		// v_b² + v_b = v_(b-1)
+		for b := 1; b < tc.bits; b++ {
+			v := tc.cantor[b]
+			if got := polyMul(v, v, tc.bits, tc.poly) ^ v; got != tc.cantor[b-1] {
+				t.Errorf("GF(2^%d): v_%d² + v_%d = %#x, want %#x", tc.bits, b, b, got, tc.cantor[b-1])
+			}
+		}
+	}
+}
+
+func TestField_Arithmetic(t *testing.T) {
+	for _, f := range []*field{field8(), field16()} {
+		// Every non-zero element has a distinct logarithm
+		seen := make([]bool, f.modulus)
+		for x := 1; x < f.order; x++ {
+			l := f.log[x]
+			if int(l) >= f.modulus || seen[l] || f.exp[l] != uint16(x) {
+				t.Fatalf("GF(2^%d): log[%d] = %d is not a bijection", f.bits, x, l)
+			}
+			seen[l] = true
+		}
+		if int(f.log[0]) != f.modulus || f.exp[f.modulus] != f.exp[0] {
+			t.Errorf("GF(2^%d): zero log sentinel broken", f.bits)
+		}
+
+		rng := rand.New(rand.NewSource(int64(f.bits)))
+		for i := 0; i < 1000; i++ {
+			a, b := uint16(rng.Intn(f.order)), uint16(rng.Intn(f.modulus))
+			if f.subMod(f.addMod(a, b), b)%uint16(f.modulus) != a%uint16(f.modulus) {
+				t.Fatalf("GF(2^%d): (%d + %d) - %d != %d", f.bits, a, b, b, a)
+			}
+			// Multiplication by a constant is linear
+			x, y := uint16(rng.Intn(f.order)), uint16(rng.Intn(f.order))
+			if f.mulLog(x^y, b) != f.mulLog(x, b)^f.mulLog(y, b) {
+				t.Fatalf("GF(2^%d): mulLog is not linear", f.bits)
+			}
+		}
+	}
+}
+
+func TestField_SliceKernels(t *testing.T) {
+	for _, f := range []*field{field8(), field16()} {
+		rng := rand.New(rand.NewSource(1))
+		in := make([]byte, 64)
+		rng.Read(in)
+		for _, logM := range []uint16{0, 1, 77, uint16(f.modulus - 1), uint16(f.modulus)} {
+			want := make([]byte, len(in))
+			for i := 0; i < len(in); i += f.wordSize() {
+				if f.bits == 8 {
+					want[i] = byte(f.mulLog(uint16(in[i]), logM))
+				} else {
+					v := f.mulLog(uint16(in[i])|uint16(in[i+1])<<8, logM)
+					want[i], want[i+1] = byte(v), byte(v>>8)
+				}
+			}
+			got := make([]byte, len(in))
+			f.mul(got, in, logM)
+			if !bytes.Equal(got, want) {
+				t.Errorf("GF(2^%d): mul(logM=%d) differs from mulLog", f.bits, logM)
+			}
+			f.mulAdd(got, in, logM)
+			if !bytes.Equal(got, make([]byte, len(in))) {
+				t.Errorf("GF(2^%d): mulAdd(logM=%d) did not cancel mul", f.bits, logM)
+			}
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package fft
+
+import "encoding/binary"
+
+// The transforms work on n = 2^t shards at absolute positions
+// base..base+n-1. The butterfly joining blocks r..r+dist-1 and
+// r+dist..r+2·dist-1 uses the twiddle factor skew[base+r+dist-1]. A
+// transform of a block whose inputs are all zero is zero, so blocks at or
+// past mtrunc are skipped.
+
+// ifft applies the inverse transform to work in place
+func (f *field) ifft(work [][]byte, mtrunc, base int) {
+	for dist := 1; dist < len(work); dist <<= 1 {
+		for r := 0; r < mtrunc; r += 2 * dist {
+			logM := f.skew[base+r+dist-1]
+			for i := r; i < r+dist; i++ {
+				f.ifftButterfly(work[i], work[i+dist], logM)
+			}
+		}
+	}
+}
+
+// fft applies the forward transform to work in place; only the outputs
+// below mtrunc are computed
+func (f *field) fft(work [][]byte, mtrunc, base int) {
+	for dist := len(work) / 2; dist > 0; dist >>= 1 {
+		for r := 0; r < mtrunc; r += 2 * dist {
+			logM := f.skew[base+r+dist-1]
+			for i := r; i < r+dist; i++ {
+				f.fftButterfly(work[i], work[i+dist], logM)
+			}
+		}
+	}
+}
+
+// fftButterfly sets x ^= y·exp(logM), then y ^= x
+func (f *field) fftButterfly(x, y []byte, logM uint16) {
+	if int(logM) != f.modulus {
+		f.mulAdd(x, y, logM)
+	}
+	xorSlice(y, x)
+}
+
+// ifftButterfly undoes fftButterfly: y ^= x, then x ^= y·exp(logM)
+func (f *field) ifftButterfly(x, y []byte, logM uint16) {
+	xorSlice(y, x)
+	if int(logM) != f.modulus {
+		f.mulAdd(x, y, logM)
+	}
+}
+
+// xorSlice sets out ^= in, eight bytes at a time; the transforms spend
+// most of their time here
+func xorSlice(out, in []byte) {
+	out = out[:len(in)]
+	n := len(in) &^ 7
+	for i := 0; i < n; i += 8 {
+		binary.LittleEndian.PutUint64(out[i:], binary.LittleEndian.Uint64(out[i:])^binary.LittleEndian.Uint64(in[i:]))
+	}
+	for i := n; i < len(in); i++ {
+		out[i] ^= in[i]
+	}
+}
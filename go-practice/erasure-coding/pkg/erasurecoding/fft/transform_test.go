@@ -0,0 +1,82 @@
+package fft
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func randomShards(rng *rand.Rand, n, size int) [][]byte {
+	shards := make([][]byte, n)
+	for i := range shards {
+		shards[i] = make([]byte, size)
+		rng.Read(shards[i])
+	}
+	return shards
+}
+
+func cloneShards(shards [][]byte) [][]byte {
+	out := make([][]byte, len(shards))
+	for i, s := range shards {
+		out[i] = append([]byte(nil), s...)
+	}
+	return out
+}
+
+func TestTransform_RoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, f := range []*field{field8(), field16()} {
+		for _, n := range []int{1, 2, 8, 64} {
+			for _, base := range []int{0, n, 3 * n} {
+				t.Run(fmt.Sprintf("gf%d/n%d/base%d", f.bits, n, base), func(t *testing.T) {
+					want := randomShards(rng, n, 32)
+					work := cloneShards(want)
+					f.ifft(work, n, base)
+					f.fft(work, n, base)
+					for i := range want {
+						if !bytes.Equal(work[i], want[i]) {
+							t.Fatalf("fft(ifft(x))[%d] != x[%d]", i, i)
+						}
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestTransform_TruncationSkipsZeroBlocks(t *testing.T) {
+	// Inputs past mtrunc are zero, so skipping them must not change the
+	// result of a full transform
+	f := field16()
+	rng := rand.New(rand.NewSource(2))
+	full := randomShards(rng, 16, 8)
+	for i := 5; i < 16; i++ {
+		clear(full[i])
+	}
+	trunc := cloneShards(full)
+	f.ifft(full, 16, 16)
+	f.ifft(trunc, 5, 16)
+	for i := range full {
+		if !bytes.Equal(full[i], trunc[i]) {
+			t.Fatalf("truncated ifft differs at %d", i)
+		}
+	}
+}
+
+func TestXorSlice(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	for _, n := range []int{0, 1, 7, 8, 9, 100} {
+		a, b := make([]byte, n), make([]byte, n)
+		rng.Read(a)
+		rng.Read(b)
+		want := make([]byte, n)
+		for i := range want {
+			want[i] = a[i] ^ b[i]
+		}
+		xorSlice(a, b)
+		if !bytes.Equal(a, want) {
+			t.Errorf("xorSlice(len %d) wrong", n)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+// Package fileenc erasure-codes local files into shard files without
+// reading them into the Go heap.
+//
+// A codec stripe lays the input out contiguously (see codec.Split): data
+// shard i is bytes [i·chunk, (i+1)·chunk) of the file, zero-padded at the
+// end. Every codec works column by column, so the stripe can be encoded a
+// block of columns at a time. With memory mapping each block is a set of
+// sub-slices of the mapped input, handed to Codec.Encode as they are, and
+// data shard files are written with WriteAt straight from the mapping.
+// Nothing is copied through a Go buffer except the one block that
+// straddles the end of the input. Shard files are preallocated with
+// fallocate; parity is written from block buffers, or with
+// Options.MapOutputs encoded directly into mappings of the parity files.
+//
+// Where mmap is unavailable (or Options.Mode asks for it) the same block
+// loop runs with ReadAt and WriteAt through a few block-sized buffers.
+// EncodeReader is the conventional path for inputs that are only an
+// io.Reader: it holds the whole stripe in memory.
+//
+// Example:
+//
+//	c, _ := codec.Parse("rs:10+4")
+//	outs := make([]string, codec.TotalShards(c))
+//	for i := range outs {
+//	    outs[i] = fmt.Sprintf("/data/shards/shard.%d", i)
+//	}
+//	res, err := fileenc.EncodeFile(c, "/data/big.img", outs, fileenc.Options{})
+package fileenc
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/codec"
+)
+
+// FileEncError represents errors returned by the fileenc package
+type FileEncError struct {
+	message string
+}
+
+func (e *FileEncError) Error() string {
+	return e.message
+}
+
+// Common errors
+var (
+	ErrOutputCount      = &FileEncError{"need one output per shard"}
+	ErrInvalidBlockSize = &FileEncError{"block size must not be negative"}
+	ErrMmapUnavailable  = &FileEncError{"memory mapping is not available"}
+	ErrShortInput       = &FileEncError{"input ended before the given size"}
+)
+
+// DefaultBlockSize is the number of bytes of every shard encoded per
+// Codec.Encode call
+const DefaultBlockSize = 1 << 20
+
+// Mode selects how EncodeFile reads and writes
+type Mode int
+
+const (
+	// ModeAuto maps the files when the platform supports it and falls
+	// back to ModeBuffered otherwise
+	ModeAuto Mode = iota
+	// ModeMmap maps the files and fails with ErrMmapUnavailable where it
+	// cannot
+	ModeMmap
+	// ModeBuffered uses ReadAt and WriteAt with block-sized buffers
+	ModeBuffered
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeMmap:
+		return "mmap"
+	case ModeBuffered:
+		return "buffered"
+	}
+	return "auto"
+}
+
+// Options tunes EncodeFile; the zero value is ready to use
+type Options struct {
+	// BlockSize is the number of bytes of each shard encoded at a time,
+	// rounded up to the codec's alignment; 0 means DefaultBlockSize
+	BlockSize int
+	Mode      Mode
+	// MapOutputs makes ModeMmap encode straight into mappings of the
+	// parity files instead of block buffers written with WriteAt. That
+	// saves a copy per parity byte but takes a page fault per page, which
+	// costs more on some systems (virtual machines in particular).
+	MapOutputs bool
+}
+
+// Result describes an encoded file
+type Result struct {
+	Size      int64 // input size
+	ChunkSize int64 // size of every shard file
+	Mode      Mode  // ModeMmap or ModeBuffered, whichever was used
+}
+
+// chunkSize is codec.ChunkSize for sizes beyond int
+func chunkSize(c codec.Codec, size int64) int64 {
+	k, a := int64(c.DataShards()), int64(codec.Alignment(c))
+	chunk := (size + k - 1) / k
+	return (chunk + a - 1) / a * a
+}
+
+// blockSize returns the block size to use, a multiple of the alignment
+func (o Options) blockSize(c codec.Codec) (int, error) {
+	if o.BlockSize < 0 {
+		return 0, ErrInvalidBlockSize
+	}
+	b := o.BlockSize
+	if b == 0 {
+		b = DefaultBlockSize
+	}
+	a := codec.Alignment(c)
+	return (b + a - 1) / a * a, nil
+}
+
+// EncodeFile encodes the file at in and writes shard i to outs[i], creating
+// or truncating it
+//
+// Errors:
+//   - ErrOutputCount unless there are codec.TotalShards(c) outputs
+//   - ErrInvalidBlockSize for a negative block size
+//   - ErrMmapUnavailable if ModeMmap is requested and mapping fails
+//   - codec.ErrEmptyData for an empty input
+//   - codec and file system errors
+func EncodeFile(c codec.Codec, in string, outs []string, opts Options) (*Result, error) {
+	if len(outs) != codec.TotalShards(c) {
+		return nil, ErrOutputCount
+	}
+	block, err := opts.blockSize(c)
+	if err != nil {
+		return nil, err
+	}
+	src, err := os.Open(in)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() == 0 {
+		return nil, codec.ErrEmptyData
+	}
+	res := &Result{Size: info.Size(), ChunkSize: chunkSize(c, info.Size()), Mode: ModeBuffered}
+
+	files := make([]*os.File, len(outs))
+	defer func() {
+		for _, f := range files {
+			if f != nil {
+				f.Close()
+			}
+		}
+	}()
+	for i, name := range outs {
+		if files[i], err = os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644); err != nil {
+			return nil, err
+		}
+		// Preallocated files read as zeros, which is already the padding
+		if err := preallocate(files[i], res.ChunkSize); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Mode != ModeBuffered {
+		err := encodeMapped(c, src, files, res, block, opts.MapOutputs)
+		if err == nil {
+			res.Mode = ModeMmap
+			return res, closeAll(files)
+		}
+		if !errors.Is(err, ErrMmapUnavailable) || opts.Mode == ModeMmap {
+			return nil, err
+		}
+	}
+	if err := encodeBuffered(c, src, files, res, block); err != nil {
+		return nil, err
+	}
+	return res, closeAll(files)
+}
+
+// closeAll closes the files, reporting the first error, and clears them
+// so the deferred cleanup does not close them again
+func closeAll(files []*os.File) error {
+	var first error
+	for i, f := range files {
+		if err := f.Close(); err != nil && first == nil {
+			first = err
+		}
+		files[i] = nil
+	}
+	return first
+}
+
+// encodeBuffered runs the block loop with ReadAt and WriteAt
+func encodeBuffered(c codec.Codec, src *os.File, files []*os.File, res *Result, block int) error {
+	k := c.DataShards()
+	block = int(min(int64(block), res.ChunkSize))
+	pool := codec.NewShardPool(block)
+	shards := make([][]byte, len(files))
+	for i := range shards {
+		shards[i] = pool.Get()
+	}
+	for off := int64(0); off < res.ChunkSize; off += int64(block) {
+		n := int(min(int64(block), res.ChunkSize-off))
+		for i := 0; i < k; i++ {
+			buf := shards[i][:n]
+			start := int64(i)*res.ChunkSize + off
+			m := 0
+			if start < res.Size {
+				var err error
+				m, err = src.ReadAt(buf[:min(int64(n), res.Size-start)], start)
+				if err != nil && err != io.EOF {
+					return err
+				}
+			}
+			clear(buf[m:])
+			shards[i] = buf
+		}
+		for i := k; i < len(shards); i++ {
+			shards[i] = shards[i][:n]
+		}
+		if err := c.Encode(shards); err != nil {
+			return err
+		}
+		for i, f := range files {
+			// Padding past the input is already zero in the file
+			if i < k && int64(i)*res.ChunkSize+off >= res.Size {
+				continue
+			}
+			if _, err := f.WriteAt(shards[i], off); err != nil {
+				return err
+			}
+		}
+	}
+	for _, s := range shards {
+		pool.Put(s)
+	}
+	return nil
+}
+
+// EncodeReader reads size bytes from r, encodes them and writes shard i to
+// outs[i]: the streaming path for inputs that cannot be mapped or read at
+// an offset. The whole stripe is held in memory, in shard buffers taken
+// from a codec.ShardPool.
+//
+// Errors:
+//   - ErrOutputCount unless there are codec.TotalShards(c) outputs
+//   - codec.ErrEmptyData if size is not positive
+//   - ErrShortInput if r ends early
+//   - codec and I/O errors
+func EncodeReader(c codec.Codec, r io.Reader, size int64, outs []io.Writer) (*Result, error) {
+	if len(outs) != codec.TotalShards(c) {
+		return nil, ErrOutputCount
+	}
+	if size <= 0 {
+		return nil, codec.ErrEmptyData
+	}
+	chunk := chunkSize(c, size)
+	pool := codec.NewShardPool(int(chunk))
+	shards := make([][]byte, len(outs))
+	for i := range shards {
+		shards[i] = pool.Get()
+		defer pool.Put(shards[i])
+	}
+	remaining := size
+	for i := 0; i < c.DataShards(); i++ {
+		n := min(chunk, remaining)
+		if _, err := io.ReadFull(r, shards[i][:n]); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil, ErrShortInput
+			}
+			return nil, err
+		}
+		clear(shards[i][n:])
+		remaining -= n
+	}
+	if err := c.Encode(shards); err != nil {
+		return nil, err
+	}
+	for i, w := range outs {
+		if _, err := w.Write(shards[i]); err != nil {
+			return nil, err
+		}
+	}
+	return &Result{Size: size, ChunkSize: chunk, Mode: ModeBuffered}, nil
+}
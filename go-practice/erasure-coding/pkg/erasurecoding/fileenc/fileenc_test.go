@@ -0,0 +1,242 @@
+package fileenc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/codec"
+)
+
+// writeInput writes size random bytes to a file in dir
+func writeInput(t testing.TB, dir string, size int) (string, []byte) {
+	t.Helper()
+	data := make([]byte, size)
+	rand.New(rand.NewSource(int64(size))).Read(data)
+	name := filepath.Join(dir, "input")
+	if err := os.WriteFile(name, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return name, data
+}
+
+func outputs(dir string, n int) []string {
+	outs := make([]string, n)
+	for i := range outs {
+		outs[i] = filepath.Join(dir, fmt.Sprintf("shard.%d", i))
+	}
+	return outs
+}
+
+// Every mode writes exactly the shards of codec.Split and Encode, for
+// sizes that leave padding, block sizes that do not divide the chunk, and
+// codecs with alignment
+func TestEncodeFile_MatchesSplit(t *testing.T) {
+	modes := []Mode{ModeAuto, ModeBuffered}
+	if runtime.GOOS == "linux" || runtime.GOOS == "darwin" || runtime.GOOS == "freebsd" {
+		modes = append(modes, ModeMmap)
+	}
+	for _, spec := range []string{"xor:4+1", "rs:4+2", "rs16:3+2", "cauchy:4+2", "lrc:6+2+2"} {
+		for _, size := range []int{1, 1000, 4096, 100003} {
+			for _, mode := range modes {
+				for _, opts := range []Options{{Mode: mode}, {Mode: mode, BlockSize: 100}, {Mode: mode, BlockSize: 100, MapOutputs: true}} {
+					name := fmt.Sprintf("%s/%d/%s/block=%d/map=%v", spec, size, mode, opts.BlockSize, opts.MapOutputs)
+					t.Run(name, func(t *testing.T) {
+						c, _ := codec.Parse(spec)
+						dir := t.TempDir()
+						in, data := writeInput(t, dir, size)
+						want, _ := codec.Split(c, data)
+						if err := c.Encode(want); err != nil {
+							t.Fatal(err)
+						}
+
+						outs := outputs(dir, len(want))
+						res, err := EncodeFile(c, in, outs, opts)
+						if err != nil {
+							t.Fatalf("EncodeFile() error = %v", err)
+						}
+						if res.Size != int64(size) || res.ChunkSize != int64(len(want[0])) {
+							t.Errorf("Result = %+v, want size %d, chunk %d", res, size, len(want[0]))
+						}
+						for i, out := range outs {
+							got, err := os.ReadFile(out)
+							if err != nil {
+								t.Fatal(err)
+							}
+							if !bytes.Equal(got, want[i]) {
+								t.Fatalf("shard %d differs from Split and Encode", i)
+							}
+						}
+					})
+				}
+			}
+		}
+	}
+}
+
+func TestEncodeFile_ModeUsed(t *testing.T) {
+	c, _ := codec.NewReedSolomon(4, 2)
+	dir := t.TempDir()
+	in, _ := writeInput(t, dir, 5000)
+	res, err := EncodeFile(c, in, outputs(dir, 6), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantAuto := ModeBuffered
+	if runtime.GOOS == "linux" || runtime.GOOS == "darwin" || runtime.GOOS == "freebsd" {
+		wantAuto = ModeMmap
+	}
+	if res.Mode != wantAuto {
+		t.Errorf("ModeAuto used %s, want %s", res.Mode, wantAuto)
+	}
+	res, err = EncodeFile(c, in, outputs(dir, 6), Options{Mode: ModeBuffered})
+	if err != nil || res.Mode != ModeBuffered {
+		t.Errorf("ModeBuffered: mode %v, error %v", res.Mode, err)
+	}
+}
+
+// Shard files left from a larger object are truncated, not merged
+func TestEncodeFile_OverwritesLargerOutputs(t *testing.T) {
+	c, _ := codec.NewReedSolomon(2, 1)
+	dir := t.TempDir()
+	outs := outputs(dir, 3)
+	for _, out := range outs {
+		if err := os.WriteFile(out, bytes.Repeat([]byte{0xff}, 10000), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	in, data := writeInput(t, dir, 101)
+	if _, err := EncodeFile(c, in, outs, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	got0, _ := os.ReadFile(outs[0])
+	got1, _ := os.ReadFile(outs[1])
+	if !bytes.Equal(got0, data[:51]) || !bytes.Equal(got1, append(data[51:], 0)) {
+		t.Error("data shards hold stale bytes")
+	}
+}
+
+func TestEncodeFile_Errors(t *testing.T) {
+	c, _ := codec.NewReedSolomon(4, 2)
+	dir := t.TempDir()
+	in, _ := writeInput(t, dir, 100)
+	empty := filepath.Join(dir, "empty")
+	os.WriteFile(empty, nil, 0o644)
+
+	tests := []struct {
+		name string
+		in   string
+		outs []string
+		opts Options
+		want error
+	}{
+		{"output count", in, outputs(dir, 5), Options{}, ErrOutputCount},
+		{"block size", in, outputs(dir, 6), Options{BlockSize: -1}, ErrInvalidBlockSize},
+		{"empty input", empty, outputs(dir, 6), Options{}, codec.ErrEmptyData},
+		{"missing input", filepath.Join(dir, "nope"), outputs(dir, 6), Options{}, os.ErrNotExist},
+	}
+	for _, tt := range tests {
+		if _, err := EncodeFile(c, tt.in, tt.outs, tt.opts); !errors.Is(err, tt.want) {
+			t.Errorf("%s: EncodeFile() error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestEncodeReader(t *testing.T) {
+	c, _ := codec.Parse("rs:5+3")
+	data := make([]byte, 12345)
+	rand.New(rand.NewSource(1)).Read(data)
+	want, _ := codec.Split(c, data)
+	c.Encode(want)
+
+	bufs := make([]bytes.Buffer, len(want))
+	outs := make([]io.Writer, len(want))
+	for i := range outs {
+		outs[i] = &bufs[i]
+	}
+	res, err := EncodeReader(c, bytes.NewReader(data), int64(len(data)), outs)
+	if err != nil {
+		t.Fatalf("EncodeReader() error = %v", err)
+	}
+	if res.ChunkSize != int64(len(want[0])) {
+		t.Errorf("ChunkSize = %d, want %d", res.ChunkSize, len(want[0]))
+	}
+	for i := range want {
+		if !bytes.Equal(bufs[i].Bytes(), want[i]) {
+			t.Fatalf("shard %d differs", i)
+		}
+	}
+
+	if _, err := EncodeReader(c, bytes.NewReader(data[:100]), int64(len(data)), outs); err != ErrShortInput {
+		t.Errorf("short input: error = %v, want %v", err, ErrShortInput)
+	}
+	if _, err := EncodeReader(c, bytes.NewReader(data), 0, outs); err != codec.ErrEmptyData {
+		t.Errorf("size 0: error = %v, want %v", err, codec.ErrEmptyData)
+	}
+}
+
+// Benchmark a 64 MiB file through every path: mapped input with WriteAt or
+// mapped outputs, ReadAt/WriteAt with block buffers, and reading the whole
+// stripe from an io.Reader
+func BenchmarkEncodeFile(b *testing.B) {
+	const size = 64 << 20
+	c, _ := codec.Parse("rs:10+4")
+	dir := b.TempDir()
+	in, _ := writeInput(b, dir, size)
+	outs := outputs(dir, codec.TotalShards(c))
+
+	for _, opts := range []Options{{Mode: ModeMmap}, {Mode: ModeMmap, MapOutputs: true}, {Mode: ModeBuffered}} {
+		name := opts.Mode.String()
+		if opts.MapOutputs {
+			name += "-outputs"
+		}
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(size)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := EncodeFile(c, in, outs, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+	b.Run("reader", func(b *testing.B) {
+		b.SetBytes(size)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := encodeReaderFiles(c, in, outs); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// encodeReaderFiles is EncodeFile done with EncodeReader
+func encodeReaderFiles(c codec.Codec, in string, outs []string) error {
+	src, err := os.Open(in)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	ws := make([]io.Writer, len(outs))
+	for i, name := range outs {
+		f, err := os.Create(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		ws[i] = f
+	}
+	_, err = EncodeReader(c, src, info.Size(), ws)
+	return err
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !freebsd
+
+package fileenc
+
+import (
+	"os"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/codec"
+)
+
+// encodeMapped is not implemented on this platform
+func encodeMapped(c codec.Codec, src *os.File, files []*os.File, res *Result, block int, mapOutputs bool) error {
+	return ErrMmapUnavailable
+}
@@ -0,0 +1,119 @@
+//go:build linux || darwin || freebsd
+
+package fileenc
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"syscall"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/codec"
+)
+
+// encodeMapped runs the block loop over a read-only mapping of src. Parity
+// goes to writable mappings of the parity files with mapOutputs, and
+// through block buffers and WriteAt otherwise.
+func encodeMapped(c codec.Codec, src *os.File, files []*os.File, res *Result, block int, mapOutputs bool) error {
+	if res.Size > math.MaxInt || res.ChunkSize > math.MaxInt {
+		return ErrMmapUnavailable
+	}
+	input, err := mmap(src, res.Size, false)
+	if err != nil {
+		return err
+	}
+	defer syscall.Munmap(input)
+	adviseSequential(input)
+
+	chunk := res.ChunkSize
+	block = int(min(int64(block), chunk))
+	k := c.DataShards()
+	shards := make([][]byte, len(files))
+
+	// parity[p] is the whole mapped parity file p, or one block buffer
+	parity := make([][]byte, len(files)-k)
+	if mapOutputs {
+		// Unmapping a shared mapping leaves the stores in the page cache,
+		// where the file descriptors see them
+		defer func() {
+			for _, m := range parity {
+				if m != nil {
+					syscall.Munmap(m)
+				}
+			}
+		}()
+		for p := range parity {
+			if parity[p], err = mmap(files[k+p], chunk, true); err != nil {
+				return err
+			}
+		}
+	} else {
+		pool := codec.NewShardPool(block)
+		for p := range parity {
+			parity[p] = pool.Get()
+			defer pool.Put(parity[p])
+		}
+	}
+
+	scratch := make([]byte, block)
+	zero := make([]byte, block)
+	for off := int64(0); off < chunk; off += int64(block) {
+		n := int(min(int64(block), chunk-off))
+		stripeBlock(shards[:k], input, chunk, off, n, scratch, zero)
+		for p, buf := range parity {
+			if mapOutputs {
+				shards[k+p] = buf[off : off+int64(n)]
+			} else {
+				shards[k+p] = buf[:n]
+			}
+		}
+		if err := c.Encode(shards); err != nil {
+			return err
+		}
+		// Write the data columns while their pages are still cached.
+		// Padding past the input is already zero in the file.
+		for i, f := range files {
+			if (i < k && int64(i)*chunk+off >= res.Size) || (i >= k && mapOutputs) {
+				continue
+			}
+			if _, err := f.WriteAt(shards[i], off); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// mmap maps the first size bytes of f, shared
+func mmap(f *os.File, size int64, writable bool) ([]byte, error) {
+	prot := syscall.PROT_READ
+	if writable {
+		prot |= syscall.PROT_WRITE
+	}
+	m, err := syscall.Mmap(int(f.Fd()), 0, int(size), prot, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMmapUnavailable, err)
+	}
+	return m, nil
+}
+
+// stripeBlock returns the data shard slices of the block of columns
+// [off, off+n) of the stripe: views of input where the columns lie inside
+// it, and the padded copy in scratch or the shared zero block where they
+// do not. At most one shard of a block straddles the end of the input.
+func stripeBlock(data [][]byte, input []byte, chunk, off int64, n int, scratch, zero []byte) {
+	size := int64(len(input))
+	for i := range data {
+		start := int64(i)*chunk + off
+		switch {
+		case start+int64(n) <= size:
+			data[i] = input[start : start+int64(n)]
+		case start < size:
+			m := copy(scratch[:n], input[start:])
+			clear(scratch[m:n])
+			data[i] = scratch[:n]
+		default:
+			data[i] = zero[:n]
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package fileenc
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// fallocate is syscall.Fallocate, replaceable in tests
+var fallocate = syscall.Fallocate
+
+// preallocate reserves size bytes of disk for f and sets its length, so
+// that stores through a mapping cannot fail for lack of space. File
+// systems without fallocate get a sparse file instead; any other error,
+// such as ENOSPC or EDQUOT, is returned.
+func preallocate(f *os.File, size int64) error {
+	err := fallocate(int(f.Fd()), 0, 0, size)
+	if errors.Is(err, syscall.EOPNOTSUPP) || errors.Is(err, syscall.ENOSYS) {
+		return f.Truncate(size)
+	}
+	if err != nil {
+		return &os.PathError{Op: "fallocate", Path: f.Name(), Err: err}
+	}
+	return nil
+}
+
+// adviseSequential tells the kernel a mapping is read front to back, so
+// it reads ahead further; it is only advice
+func adviseSequential(b []byte) {
+	_ = syscall.Madvise(b, syscall.MADV_SEQUENTIAL)
+}
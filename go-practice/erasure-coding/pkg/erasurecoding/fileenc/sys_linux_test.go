@@ -0,0 +1,38 @@
+package fileenc
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestPreallocate_Errors(t *testing.T) {
+	defer func(f func(int, uint32, int64, int64) error) { fallocate = f }(fallocate)
+
+	for _, tc := range []struct {
+		errno syscall.Errno
+		want  error // nil: falls back to a sparse file
+	}{
+		{syscall.EOPNOTSUPP, nil},
+		{syscall.ENOSYS, nil},
+		{syscall.ENOSPC, syscall.ENOSPC},
+		{syscall.EDQUOT, syscall.EDQUOT},
+	} {
+		fallocate = func(int, uint32, int64, int64) error { return tc.errno }
+		f, err := os.Create(filepath.Join(t.TempDir(), "shard"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = preallocate(f, 4096)
+		fi, _ := f.Stat()
+		f.Close()
+		if tc.want == nil && (err != nil || fi.Size() != 4096) {
+			t.Errorf("preallocate(%v) = %v, size %d, want sparse fallback", tc.errno, err, fi.Size())
+		}
+		if tc.want != nil && !errors.Is(err, tc.want) {
+			t.Errorf("preallocate(%v) error = %v, want %v", tc.errno, err, tc.want)
+		}
+	}
+}
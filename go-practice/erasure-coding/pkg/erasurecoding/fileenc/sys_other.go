@@ -0,0 +1,13 @@
+//go:build !linux
+
+package fileenc
+
+import "os"
+
+// preallocate sets the length of f; without fallocate the file is sparse
+func preallocate(f *os.File, size int64) error {
+	return f.Truncate(size)
+}
+
+// adviseSequential is a no-op where madvise is not available
+func adviseSequential(b []byte) {}
@@ -0,0 +1,123 @@
+// Package gf256 implements arithmetic in the Galois field GF(2^8), the
+// field Reed-Solomon style codes compute in.
+//
+// Elements are bytes. Addition and subtraction are both XOR; multiplication
+// is polynomial multiplication modulo the primitive polynomial
+// x^8 + x^4 + x^3 + x^2 + 1 (0x11d), the same field used by most storage
+// erasure codes. The generator α = 2 has order 255, so every non-zero
+// element is α^i for a unique i in [0, 255).
+//
+// Slice helpers (MulSlice, MulAddSlice) are the hot loops of every encoder
+// built on this package. On amd64 they use SSSE3 or AVX2 split-nibble
+// lookups (PSHUFB) or a GFNI affine transform when the CPU has them, and
+// a precomputed 64 KiB product table otherwise; Kernel reports which. The
+// purego build tag forces the table loop.
+package gf256
+
+// Polynomial is the primitive polynomial defining the field
+const Polynomial = 0x11d
+
+var (
+	expTable [510]byte // α^i, doubled so Mul can skip a modulo
+	logTable [256]int  // log_α(x); logTable[0] is unused
+	mulTable [256][256]byte
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		expTable[i] = byte(x)
+		expTable[i+255] = byte(x)
+		logTable[x] = i
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= Polynomial
+		}
+	}
+	for a := 1; a < 256; a++ {
+		for b := 1; b < 256; b++ {
+			mulTable[a][b] = expTable[logTable[a]+logTable[b]]
+		}
+	}
+	initKernels()
+}
+
+// Add returns a + b (which equals a - b)
+func Add(a, b byte) byte {
+	return a ^ b
+}
+
+// Mul returns a * b
+func Mul(a, b byte) byte {
+	return mulTable[a][b]
+}
+
+// Div returns a / b
+//
+// Div panics if b is zero.
+func Div(a, b byte) byte {
+	if b == 0 {
+		panic("gf256: division by zero")
+	}
+	if a == 0 {
+		return 0
+	}
+	return expTable[logTable[a]+255-logTable[b]]
+}
+
+// Inv returns the multiplicative inverse of a
+//
+// Inv panics if a is zero.
+func Inv(a byte) byte {
+	return Div(1, a)
+}
+
+// Exp returns α^n for any n >= 0
+func Exp(n int) byte {
+	return expTable[n%255]
+}
+
+// Log returns n such that α^n = a
+//
+// Log panics if a is zero.
+func Log(a byte) int {
+	if a == 0 {
+		panic("gf256: log of zero")
+	}
+	return logTable[a]
+}
+
+// MulSlice sets out[i] = c * in[i]
+//
+// out must be at least as long as in.
+func MulSlice(c byte, in, out []byte) {
+	out = out[:len(in)]
+	switch c {
+	case 0:
+		for i := range out {
+			out[i] = 0
+		}
+	case 1:
+		copy(out, in)
+	default:
+		n := active.mul(c, in, out)
+		mulGeneric(c, in[n:], out[n:])
+	}
+}
+
+// MulAddSlice sets out[i] ^= c * in[i]
+//
+// out must be at least as long as in.
+func MulAddSlice(c byte, in, out []byte) {
+	out = out[:len(in)]
+	switch c {
+	case 0:
+	case 1:
+		for i, b := range in {
+			out[i] ^= b
+		}
+	default:
+		n := active.mulAdd(c, in, out)
+		mulAddGeneric(c, in[n:], out[n:])
+	}
+}
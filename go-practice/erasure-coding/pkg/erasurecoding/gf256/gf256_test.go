@@ -0,0 +1,102 @@
+package gf256
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// slowMul multiplies bit by bit (Russian peasant), independent of the tables
+func slowMul(a, b byte) byte {
+	var p byte
+	for b > 0 {
+		if b&1 != 0 {
+			p ^= a
+		}
+		carry := a & 0x80
+		a <<= 1
+		if carry != 0 {
+			a ^= byte(Polynomial & 0xff)
+		}
+		b >>= 1
+	}
+	return p
+}
+
+func TestMul_MatchesCarrylessMultiply(t *testing.T) {
+	for a := 0; a < 256; a++ {
+		for b := 0; b < 256; b++ {
+			if got, want := Mul(byte(a), byte(b)), slowMul(byte(a), byte(b)); got != want {
+				t.Fatalf("Mul(%d, %d) = %d, want %d", a, b, got, want)
+			}
+		}
+	}
+}
+
+func TestFieldAxioms(t *testing.T) {
+	for a := 1; a < 256; a++ {
+		if Mul(byte(a), Inv(byte(a))) != 1 {
+			t.Fatalf("%d * Inv(%d) != 1", a, a)
+		}
+		if Exp(Log(byte(a))) != byte(a) {
+			t.Fatalf("Exp(Log(%d)) != %d", a, a)
+		}
+		for b := 1; b < 256; b++ {
+			if Div(Mul(byte(a), byte(b)), byte(b)) != byte(a) {
+				t.Fatalf("(%d*%d)/%d != %d", a, b, b, a)
+			}
+		}
+	}
+	// α generates the whole multiplicative group
+	seen := map[byte]bool{}
+	for i := 0; i < 255; i++ {
+		seen[Exp(i)] = true
+	}
+	if len(seen) != 255 || seen[0] {
+		t.Errorf("α generates %d distinct elements, want 255 non-zero", len(seen))
+	}
+}
+
+func TestMulSlice(t *testing.T) {
+	in := make([]byte, 300)
+	for i := range in {
+		in[i] = byte(i * 7)
+	}
+	for _, c := range []byte{0, 1, 2, 0x53, 0xff} {
+		out := make([]byte, len(in))
+		MulSlice(c, in, out)
+		acc := bytes.Repeat([]byte{0x5a}, len(in))
+		MulAddSlice(c, in, acc)
+		for i := range in {
+			if out[i] != Mul(c, in[i]) {
+				t.Fatalf("MulSlice(%d)[%d] = %d, want %d", c, i, out[i], Mul(c, in[i]))
+			}
+			if acc[i] != 0x5a^Mul(c, in[i]) {
+				t.Fatalf("MulAddSlice(%d)[%d] = %d, want %d", c, i, acc[i], 0x5a^Mul(c, in[i]))
+			}
+		}
+	}
+}
+
+func TestDivByZeroPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Div(1, 0) did not panic")
+		}
+	}()
+	Div(1, 0)
+}
+
+// Benchmark the multiply-accumulate loop with the active kernel
+func BenchmarkMulAddSlice(b *testing.B) {
+	for _, size := range []int{1 << 10, 1 << 16} {
+		b.Run(fmt.Sprintf("%dB", size), func(b *testing.B) {
+			in := make([]byte, size)
+			out := make([]byte, size)
+			b.SetBytes(int64(size))
+			for i := 0; i < b.N; i++ {
+				MulAddSlice(0x8e, in, out)
+			}
+		})
+	}
+}
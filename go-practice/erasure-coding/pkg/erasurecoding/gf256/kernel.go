@@ -0,0 +1,78 @@
+package gf256
+
+// The slice loops dispatch to the fastest kernel the CPU supports. Each
+// kernel handles a prefix of the slice in whole vector blocks and reports
+// how many bytes it did; the table loop finishes the tail. Every kernel
+// must produce exactly the bytes of the table loop.
+
+// kernel is one implementation of the slice multiply loops
+type kernel struct {
+	name string
+	// mulAdd sets out ^= c·in and mul sets out = c·in over a prefix of
+	// in, returning its length. c is never 0 or 1.
+	mulAdd func(c byte, in, out []byte) int
+	mul    func(c byte, in, out []byte) int
+}
+
+var (
+	// nibbleLow[c][x] = c·x and nibbleHigh[c][x] = c·(x<<4) for x < 16:
+	// the split tables of the PSHUFB kernels
+	nibbleLow, nibbleHigh [256][16]byte
+	// affine[c] is multiplication by c as the 8×8 bit-matrix operand of
+	// GF2P8AFFINEQB. The GFNI multiply instruction is fixed to the AES
+	// polynomial 0x11b, but any linear map can go through the affine one.
+	affine [256]uint64
+
+	// kernels lists the kernels this CPU can run, fastest first; the
+	// table loop is always last
+	kernels []kernel
+	active  kernel
+)
+
+// initKernels fills the kernel tables from mulTable and picks the kernel
+func initKernels() {
+	for c := 0; c < 256; c++ {
+		for x := 0; x < 16; x++ {
+			nibbleLow[c][x] = mulTable[c][x]
+			nibbleHigh[c][x] = mulTable[c][x<<4]
+		}
+		// Output bit i is the parity of row byte 7-i ANDed with the input
+		var m uint64
+		for i := 0; i < 8; i++ {
+			var row byte
+			for j := 0; j < 8; j++ {
+				if mulTable[c][1<<j]>>i&1 != 0 {
+					row |= 1 << j
+				}
+			}
+			m |= uint64(row) << (8 * (7 - i))
+		}
+		affine[c] = m
+	}
+	kernels = append(archKernels(), kernel{name: "generic", mulAdd: mulAddGeneric, mul: mulGeneric})
+	active = kernels[0]
+}
+
+// Kernel returns the name of the multiply kernel in use: "gfni", "avx2",
+// "ssse3" or "generic"
+func Kernel() string {
+	return active.name
+}
+
+func mulAddGeneric(c byte, in, out []byte) int {
+	row := &mulTable[c]
+	out = out[:len(in)]
+	for i, b := range in {
+		out[i] ^= row[b]
+	}
+	return len(in)
+}
+
+func mulGeneric(c byte, in, out []byte) int {
+	row := &mulTable[c]
+	out = out[:len(in)]
+	for i, b := range in {
+		out[i] = row[b]
+	}
+	return len(in)
+}
@@ -0,0 +1,109 @@
+//go:build amd64 && !purego
+
+package gf256
+
+// Implemented in kernel_amd64.s. The vector functions process
+// len(in) rounded down to their block size (16 or 32 bytes).
+
+func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+func xgetbv() (eax, edx uint32)
+
+//go:noescape
+func mulAddSSSE3(low, high *[16]byte, in, out []byte)
+
+//go:noescape
+func mulSSSE3(low, high *[16]byte, in, out []byte)
+
+//go:noescape
+func mulAddAVX2(low, high *[16]byte, in, out []byte)
+
+//go:noescape
+func mulAVX2(low, high *[16]byte, in, out []byte)
+
+//go:noescape
+func mulAddGFNI(matrix uint64, in, out []byte)
+
+//go:noescape
+func mulGFNI(matrix uint64, in, out []byte)
+
+// cpuFeatures are the instruction set extensions the kernels use
+type cpuFeatures struct {
+	ssse3, avx2, gfni bool
+}
+
+// detectCPU checks CPUID, and XGETBV for the operating system saving the
+// YMM registers, as internal/cpu does
+func detectCPU() cpuFeatures {
+	var f cpuFeatures
+	maxID, _, _, _ := cpuid(0, 0)
+	if maxID < 1 {
+		return f
+	}
+	_, _, ecx1, _ := cpuid(1, 0)
+	f.ssse3 = ecx1&(1<<9) != 0
+
+	osYMM := false
+	if ecx1&(1<<27) != 0 { // OSXSAVE
+		xcr0, _ := xgetbv()
+		osYMM = xcr0&6 == 6 // XMM and YMM state
+	}
+	avx := ecx1&(1<<28) != 0 && osYMM
+	if maxID >= 7 {
+		_, ebx7, ecx7, _ := cpuid(7, 0)
+		f.avx2 = avx && ebx7&(1<<5) != 0
+		// The VEX-encoded 256-bit form needs AVX as well as GFNI
+		f.gfni = f.avx2 && ecx7&(1<<8) != 0
+	}
+	return f
+}
+
+func archKernels() []kernel {
+	f := detectCPU()
+	var ks []kernel
+	if f.gfni {
+		ks = append(ks, kernel{
+			name: "gfni",
+			mulAdd: func(c byte, in, out []byte) int {
+				n := len(in) &^ 31
+				mulAddGFNI(affine[c], in[:n], out[:n])
+				return n
+			},
+			mul: func(c byte, in, out []byte) int {
+				n := len(in) &^ 31
+				mulGFNI(affine[c], in[:n], out[:n])
+				return n
+			},
+		})
+	}
+	if f.avx2 {
+		ks = append(ks, kernel{
+			name: "avx2",
+			mulAdd: func(c byte, in, out []byte) int {
+				n := len(in) &^ 31
+				mulAddAVX2(&nibbleLow[c], &nibbleHigh[c], in[:n], out[:n])
+				return n
+			},
+			mul: func(c byte, in, out []byte) int {
+				n := len(in) &^ 31
+				mulAVX2(&nibbleLow[c], &nibbleHigh[c], in[:n], out[:n])
+				return n
+			},
+		})
+	}
+	if f.ssse3 {
+		ks = append(ks, kernel{
+			name: "ssse3",
+			mulAdd: func(c byte, in, out []byte) int {
+				n := len(in) &^ 15
+				mulAddSSSE3(&nibbleLow[c], &nibbleHigh[c], in[:n], out[:n])
+				return n
+			},
+			mul: func(c byte, in, out []byte) int {
+				n := len(in) &^ 15
+				mulSSSE3(&nibbleLow[c], &nibbleHigh[c], in[:n], out[:n])
+				return n
+			},
+		})
+	}
+	return ks
+}
@@ -0,0 +1,215 @@
+//go:build amd64 && !purego
+
+#include "textflag.h"
+
+// func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+TEXT ·cpuid(SB), NOSPLIT, $0-24
+	MOVL eaxArg+0(FP), AX
+	MOVL ecxArg+4(FP), CX
+	CPUID
+	MOVL AX, eax+8(FP)
+	MOVL BX, ebx+12(FP)
+	MOVL CX, ecx+16(FP)
+	MOVL DX, edx+20(FP)
+	RET
+
+// func xgetbv() (eax, edx uint32)
+TEXT ·xgetbv(SB), NOSPLIT, $0-8
+	MOVL $0, CX
+	XGETBV
+	MOVL AX, eax+0(FP)
+	MOVL DX, edx+4(FP)
+	RET
+
+// Split-nibble multiply: c·x = low[x & 15] ^ high[x >> 4], with PSHUFB
+// looking up 16 (or 32) bytes at once.
+
+// func mulAddSSSE3(low, high *[16]byte, in, out []byte)
+TEXT ·mulAddSSSE3(SB), NOSPLIT, $0-64
+	MOVQ   low+0(FP), AX
+	MOVQ   high+8(FP), BX
+	MOVQ   in_base+16(FP), SI
+	MOVQ   in_len+24(FP), CX
+	MOVQ   out_base+40(FP), DI
+	MOVOU  (AX), X6
+	MOVOU  (BX), X7
+	MOVQ   $0x0f0f0f0f0f0f0f0f, DX
+	MOVQ   DX, X8
+	PUNPCKLQDQ X8, X8
+	SHRQ   $4, CX
+	JZ     ssse3AddDone
+
+ssse3AddLoop:
+	MOVOU  (SI), X0
+	MOVOU  X0, X1
+	PSRLQ  $4, X1
+	PAND   X8, X0
+	PAND   X8, X1
+	MOVOU  X6, X2
+	MOVOU  X7, X3
+	PSHUFB X0, X2
+	PSHUFB X1, X3
+	PXOR   X2, X3
+	MOVOU  (DI), X4
+	PXOR   X3, X4
+	MOVOU  X4, (DI)
+	ADDQ   $16, SI
+	ADDQ   $16, DI
+	DECQ   CX
+	JNZ    ssse3AddLoop
+
+ssse3AddDone:
+	RET
+
+// func mulSSSE3(low, high *[16]byte, in, out []byte)
+TEXT ·mulSSSE3(SB), NOSPLIT, $0-64
+	MOVQ   low+0(FP), AX
+	MOVQ   high+8(FP), BX
+	MOVQ   in_base+16(FP), SI
+	MOVQ   in_len+24(FP), CX
+	MOVQ   out_base+40(FP), DI
+	MOVOU  (AX), X6
+	MOVOU  (BX), X7
+	MOVQ   $0x0f0f0f0f0f0f0f0f, DX
+	MOVQ   DX, X8
+	PUNPCKLQDQ X8, X8
+	SHRQ   $4, CX
+	JZ     ssse3MulDone
+
+ssse3MulLoop:
+	MOVOU  (SI), X0
+	MOVOU  X0, X1
+	PSRLQ  $4, X1
+	PAND   X8, X0
+	PAND   X8, X1
+	MOVOU  X6, X2
+	MOVOU  X7, X3
+	PSHUFB X0, X2
+	PSHUFB X1, X3
+	PXOR   X2, X3
+	MOVOU  X3, (DI)
+	ADDQ   $16, SI
+	ADDQ   $16, DI
+	DECQ   CX
+	JNZ    ssse3MulLoop
+
+ssse3MulDone:
+	RET
+
+// func mulAddAVX2(low, high *[16]byte, in, out []byte)
+TEXT ·mulAddAVX2(SB), NOSPLIT, $0-64
+	MOVQ           low+0(FP), AX
+	MOVQ           high+8(FP), BX
+	MOVQ           in_base+16(FP), SI
+	MOVQ           in_len+24(FP), CX
+	MOVQ           out_base+40(FP), DI
+	VBROADCASTI128 (AX), Y6
+	VBROADCASTI128 (BX), Y7
+	MOVQ           $0x0f0f0f0f0f0f0f0f, DX
+	MOVQ           DX, X8
+	VPBROADCASTQ   X8, Y8
+	SHRQ           $5, CX
+	JZ             avx2AddDone
+
+avx2AddLoop:
+	VMOVDQU (SI), Y0
+	VPSRLQ  $4, Y0, Y1
+	VPAND   Y8, Y0, Y0
+	VPAND   Y8, Y1, Y1
+	VPSHUFB Y0, Y6, Y2
+	VPSHUFB Y1, Y7, Y3
+	VPXOR   Y2, Y3, Y3
+	VPXOR   (DI), Y3, Y3
+	VMOVDQU Y3, (DI)
+	ADDQ    $32, SI
+	ADDQ    $32, DI
+	DECQ    CX
+	JNZ     avx2AddLoop
+
+avx2AddDone:
+	VZEROUPPER
+	RET
+
+// func mulAVX2(low, high *[16]byte, in, out []byte)
+TEXT ·mulAVX2(SB), NOSPLIT, $0-64
+	MOVQ           low+0(FP), AX
+	MOVQ           high+8(FP), BX
+	MOVQ           in_base+16(FP), SI
+	MOVQ           in_len+24(FP), CX
+	MOVQ           out_base+40(FP), DI
+	VBROADCASTI128 (AX), Y6
+	VBROADCASTI128 (BX), Y7
+	MOVQ           $0x0f0f0f0f0f0f0f0f, DX
+	MOVQ           DX, X8
+	VPBROADCASTQ   X8, Y8
+	SHRQ           $5, CX
+	JZ             avx2MulDone
+
+avx2MulLoop:
+	VMOVDQU (SI), Y0
+	VPSRLQ  $4, Y0, Y1
+	VPAND   Y8, Y0, Y0
+	VPAND   Y8, Y1, Y1
+	VPSHUFB Y0, Y6, Y2
+	VPSHUFB Y1, Y7, Y3
+	VPXOR   Y2, Y3, Y3
+	VMOVDQU Y3, (DI)
+	ADDQ    $32, SI
+	ADDQ    $32, DI
+	DECQ    CX
+	JNZ     avx2MulLoop
+
+avx2MulDone:
+	VZEROUPPER
+	RET
+
+// GFNI: one affine transform per 32 bytes with the bit-matrix of c
+
+// func mulAddGFNI(matrix uint64, in, out []byte)
+TEXT ·mulAddGFNI(SB), NOSPLIT, $0-56
+	MOVQ           matrix+0(FP), AX
+	MOVQ           in_base+8(FP), SI
+	MOVQ           in_len+16(FP), CX
+	MOVQ           out_base+32(FP), DI
+	MOVQ           AX, X5
+	VPBROADCASTQ   X5, Y5
+	SHRQ           $5, CX
+	JZ             gfniAddDone
+
+gfniAddLoop:
+	VMOVDQU        (SI), Y0
+	VGF2P8AFFINEQB $0, Y5, Y0, Y1
+	VPXOR          (DI), Y1, Y1
+	VMOVDQU        Y1, (DI)
+	ADDQ           $32, SI
+	ADDQ           $32, DI
+	DECQ           CX
+	JNZ            gfniAddLoop
+
+gfniAddDone:
+	VZEROUPPER
+	RET
+
+// func mulGFNI(matrix uint64, in, out []byte)
+TEXT ·mulGFNI(SB), NOSPLIT, $0-56
+	MOVQ           matrix+0(FP), AX
+	MOVQ           in_base+8(FP), SI
+	MOVQ           in_len+16(FP), CX
+	MOVQ           out_base+32(FP), DI
+	MOVQ           AX, X5
+	VPBROADCASTQ   X5, Y5
+	SHRQ           $5, CX
+	JZ             gfniMulDone
+
+gfniMulLoop:
+	VMOVDQU        (SI), Y0
+	VGF2P8AFFINEQB $0, Y5, Y0, Y1
+	VMOVDQU        Y1, (DI)
+	ADDQ           $32, SI
+	ADDQ           $32, DI
+	DECQ           CX
+	JNZ            gfniMulLoop
+
+gfniMulDone:
+	VZEROUPPER
+	RET
@@ -0,0 +1,8 @@
+//go:build !amd64 || purego
+
+package gf256
+
+// archKernels returns no vector kernels on this platform
+func archKernels() []kernel {
+	return nil
+}
@@ -0,0 +1,94 @@
+package gf256
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// Every kernel the CPU supports must match the table loop byte for byte,
+// for every constant and for lengths and offsets around the block sizes
+func TestKernels_MatchTableReference(t *testing.T) {
+	rng := rand.New(rand.NewSource(45))
+	buf := make([]byte, 1100)
+	rng.Read(buf)
+	acc := make([]byte, len(buf))
+	rng.Read(acc)
+
+	for _, k := range kernels {
+		t.Run(k.name, func(t *testing.T) {
+			for c := 2; c < 256; c++ {
+				off := rng.Intn(32)
+				n := rng.Intn(len(buf) - off)
+				if c%16 == 0 {
+					n = c % 97 // short slices, below one block
+				}
+				in := buf[off : off+n]
+
+				want := make([]byte, n)
+				got := make([]byte, n)
+				done := k.mul(byte(c), in, got)
+				mulGeneric(byte(c), in[done:], got[done:])
+				for i, b := range in {
+					want[i] = slowMul(byte(c), b)
+				}
+				if !bytes.Equal(got, want) {
+					t.Fatalf("mul(%d) over %d bytes at offset %d differs from the table loop", c, n, off)
+				}
+
+				copy(want, acc)
+				copy(got, acc)
+				done = k.mulAdd(byte(c), in, got)
+				mulAddGeneric(byte(c), in[done:], got[done:])
+				for i, b := range in {
+					want[i] ^= slowMul(byte(c), b)
+				}
+				if !bytes.Equal(got, want) {
+					t.Fatalf("mulAdd(%d) over %d bytes at offset %d differs from the table loop", c, n, off)
+				}
+			}
+		})
+	}
+}
+
+func TestKernels_LeaveTailAlone(t *testing.T) {
+	in := bytes.Repeat([]byte{0xa7}, 100)
+	for _, k := range kernels {
+		out := make([]byte, len(in))
+		n := k.mul(0x1d, in, out)
+		if n > len(in) {
+			t.Fatalf("%s: processed %d of %d bytes", k.name, n, len(in))
+		}
+		for i := n; i < len(out); i++ {
+			if out[i] != 0 {
+				t.Fatalf("%s: wrote byte %d past the %d it reported", k.name, i, n)
+			}
+		}
+	}
+}
+
+func TestKernel(t *testing.T) {
+	if Kernel() != kernels[0].name {
+		t.Errorf("Kernel() = %q, want the first supported kernel %q", Kernel(), kernels[0].name)
+	}
+	if last := kernels[len(kernels)-1].name; last != "generic" {
+		t.Errorf("last kernel = %q, want generic", last)
+	}
+}
+
+// Benchmark each kernel on its own; the slowest is the table loop
+func BenchmarkKernels(b *testing.B) {
+	for _, k := range kernels {
+		for _, size := range []int{1 << 10, 1 << 16} {
+			b.Run(fmt.Sprintf("%s/%dB", k.name, size), func(b *testing.B) {
+				in := make([]byte, size)
+				out := make([]byte, size)
+				b.SetBytes(int64(size))
+				for i := 0; i < b.N; i++ {
+					k.mulAdd(0x8e, in, out)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package gf256
+
+import (
+	"fmt"
+	"testing"
+)
+
+func isIdentity(m Matrix) bool {
+	for r := range m {
+		for c := range m[r] {
+			want := byte(0)
+			if r == c {
+				want = 1
+			}
+			if m[r][c] != want {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestInvert(t *testing.T) {
+	m, _ := Cauchy(5, 5, 0)
+	inv, err := m.Invert()
+	if err != nil {
+		t.Fatalf("Invert() error = %v", err)
+	}
+	p, _ := m.Mul(inv)
+	if !isIdentity(p) {
+		t.Errorf("m × m⁻¹ = %v, want identity", p)
+	}
+
+	singular := Matrix{{1, 2}, {2, 4}}
+	if _, err := singular.Invert(); err != ErrSingular {
+		t.Errorf("Invert(singular) error = %v, want %v", err, ErrSingular)
+	}
+	if _, err := NewMatrix(2, 3).Invert(); err != ErrNotSquare {
+		t.Errorf("Invert(2×3) error = %v, want %v", err, ErrNotSquare)
+	}
+}
+
+func TestCauchy_SystematicGeneratorIsMDS(t *testing.T) {
+	const k, m = 4, 3
+	parity, err := Cauchy(m, k, 0)
+	if err != nil {
+		t.Fatalf("Cauchy() error = %v", err)
+	}
+	gen := append(Identity(k), parity...)
+
+	// Every choice of k rows out of k+m must be invertible
+	var choose func(start int, rows []int)
+	choose = func(start int, rows []int) {
+		if len(rows) == k {
+			if _, err := gen.SelectRows(rows).Invert(); err != nil {
+				t.Errorf("rows %v are singular", rows)
+			}
+			return
+		}
+		for r := start; r < k+m; r++ {
+			choose(r+1, append(rows, r))
+		}
+	}
+	choose(0, nil)
+
+	if _, err := Cauchy(200, 57, 0); err != ErrCauchyTooWide {
+		t.Errorf("Cauchy(200, 57) error = %v, want %v", err, ErrCauchyTooWide)
+	}
+}
+
+func TestCauchy_RowOffsetExtends(t *testing.T) {
+	base, _ := Cauchy(2, 4, 0)
+	more, _ := Cauchy(2, 4, 2)
+	all, _ := Cauchy(4, 4, 0)
+	for r := 0; r < 4; r++ {
+		want := all[r]
+		got := base
+		if r >= 2 {
+			got = more
+		}
+		if fmt.Sprint(got[r%2]) != fmt.Sprint(want) {
+			t.Errorf("row %d = %v, want %v", r, got[r%2], want)
+		}
+	}
+}
+
+func TestBasis(t *testing.T) {
+	b := NewBasis(3)
+	vectors := []struct {
+		v    []byte
+		want bool
+	}{
+		{[]byte{1, 1, 0}, true},
+		{[]byte{0, 1, 1}, true},
+		{[]byte{1, 0, 1}, false}, // sum of the first two
+		{[]byte{2, 2, 0}, false}, // multiple of the first
+		{[]byte{0, 0, 5}, true},
+		{[]byte{7, 3, 9}, false}, // basis is full
+	}
+	for i, tt := range vectors {
+		if got := b.Add(tt.v); got != tt.want {
+			t.Errorf("Add(#%d %v) = %v, want %v", i, tt.v, got, tt.want)
+		}
+	}
+	if b.Rank() != 3 {
+		t.Errorf("Rank() = %d, want 3", b.Rank())
+	}
+}
@@ -0,0 +1,159 @@
+// Package gf65536 implements arithmetic in the Galois field GF(2^16), for
+// codes with more than the 256 shards GF(2^8) allows.
+//
+// Elements are uint16. Multiplication is polynomial multiplication modulo
+// x^16 + x^12 + x^3 + x + 1 (0x1100B), the polynomial PAR2 uses, with
+// generator α = 2 of order 65535.
+//
+// A 64 Ki × 64 Ki product table is out of the question, so Mul goes
+// through log/exp tables. The slice helpers treat shard bytes as
+// little-endian 16-bit words and, for anything but short slices, build
+// two 256-entry tables for the constant: multiplication by c is linear
+// over GF(2), so c·w = lo[w & 0xff] ^ hi[w >> 8]. Slices must have an
+// even length.
+package gf65536
+
+// Polynomial is the primitive polynomial defining the field
+const Polynomial = 0x1100B
+
+// Order is the size of the multiplicative group
+const Order = 65535
+
+var (
+	expTable [2 * Order]uint16 // α^i, doubled so Mul can skip a modulo
+	logTable [1 << 16]uint16   // log_α(x); logTable[0] is unused
+)
+
+func init() {
+	x := 1
+	for i := 0; i < Order; i++ {
+		expTable[i] = uint16(x)
+		expTable[i+Order] = uint16(x)
+		logTable[x] = uint16(i)
+		x <<= 1
+		if x&0x10000 != 0 {
+			x ^= Polynomial
+		}
+	}
+}
+
+// Add returns a + b (which equals a - b)
+func Add(a, b uint16) uint16 {
+	return a ^ b
+}
+
+// Mul returns a * b
+func Mul(a, b uint16) uint16 {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return expTable[int(logTable[a])+int(logTable[b])]
+}
+
+// Div returns a / b
+//
+// Div panics if b is zero.
+func Div(a, b uint16) uint16 {
+	if b == 0 {
+		panic("gf65536: division by zero")
+	}
+	if a == 0 {
+		return 0
+	}
+	return expTable[int(logTable[a])+Order-int(logTable[b])]
+}
+
+// Inv returns the multiplicative inverse of a
+//
+// Inv panics if a is zero.
+func Inv(a uint16) uint16 {
+	return Div(1, a)
+}
+
+// Exp returns α^n for any n >= 0
+func Exp(n int) uint16 {
+	return expTable[n%Order]
+}
+
+// Log returns n such that α^n = a
+//
+// Log panics if a is zero.
+func Log(a uint16) int {
+	if a == 0 {
+		panic("gf65536: log of zero")
+	}
+	return int(logTable[a])
+}
+
+// splitThreshold is the slice length from which building the two split
+// tables (510 multiplies) beats a log/exp lookup per word
+const splitThreshold = 512
+
+// splitTables returns the tables with c·w = lo[w&0xff] ^ hi[w>>8]
+func splitTables(c uint16) (lo, hi [256]uint16) {
+	for i := 1; i < 256; i++ {
+		lo[i] = Mul(c, uint16(i))
+		hi[i] = Mul(c, uint16(i)<<8)
+	}
+	return lo, hi
+}
+
+// MulSlice sets out = c * in, word by word
+//
+// out must be at least as long as in, and len(in) must be even.
+func MulSlice(c uint16, in, out []byte) {
+	out = out[:len(in)]
+	switch {
+	case c == 0:
+		for i := range out {
+			out[i] = 0
+		}
+	case c == 1:
+		copy(out, in)
+	case len(in) < splitThreshold:
+		lc := int(logTable[c])
+		for i := 0; i+1 < len(in); i += 2 {
+			var v uint16
+			if w := uint16(in[i]) | uint16(in[i+1])<<8; w != 0 {
+				v = expTable[lc+int(logTable[w])]
+			}
+			out[i], out[i+1] = byte(v), byte(v>>8)
+		}
+	default:
+		lo, hi := splitTables(c)
+		for i := 0; i+1 < len(in); i += 2 {
+			v := lo[in[i]] ^ hi[in[i+1]]
+			out[i], out[i+1] = byte(v), byte(v>>8)
+		}
+	}
+}
+
+// MulAddSlice sets out ^= c * in, word by word
+//
+// out must be at least as long as in, and len(in) must be even.
+func MulAddSlice(c uint16, in, out []byte) {
+	out = out[:len(in)]
+	switch {
+	case c == 0:
+	case c == 1:
+		for i, b := range in {
+			out[i] ^= b
+		}
+	case len(in) < splitThreshold:
+		lc := int(logTable[c])
+		for i := 0; i+1 < len(in); i += 2 {
+			if w := uint16(in[i]) | uint16(in[i+1])<<8; w != 0 {
+				v := expTable[lc+int(logTable[w])]
+				out[i] ^= byte(v)
+				out[i+1] ^= byte(v >> 8)
+			}
+		}
+	default:
+		lo, hi := splitTables(c)
+		for i := 0; i+1 < len(in); i += 2 {
+			v := lo[in[i]] ^ hi[in[i+1]]
+			out[i] ^= byte(v)
+			out[i+1] ^= byte(v >> 8)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package gf65536
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// slowMul multiplies bit by bit (Russian peasant), independent of the tables
+func slowMul(a, b uint16) uint16 {
+	var p uint16
+	for b > 0 {
+		if b&1 != 0 {
+			p ^= a
+		}
+		carry := a & 0x8000
+		a <<= 1
+		if carry != 0 {
+			a ^= uint16(Polynomial & 0xffff)
+		}
+		b >>= 1
+	}
+	return p
+}
+
+func TestMul_MatchesCarrylessMultiply(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 200_000; i++ {
+		a, b := uint16(rng.Intn(1<<16)), uint16(rng.Intn(1<<16))
+		if got, want := Mul(a, b), slowMul(a, b); got != want {
+			t.Fatalf("Mul(%#x, %#x) = %#x, want %#x", a, b, got, want)
+		}
+	}
+	if Mul(0x8000, 2) != 0x100B {
+		t.Errorf("Mul(0x8000, 2) = %#x, want 0x100b", Mul(0x8000, 2))
+	}
+}
+
+func TestFieldAxioms(t *testing.T) {
+	for a := 1; a < 1<<16; a++ {
+		if Mul(uint16(a), Inv(uint16(a))) != 1 {
+			t.Fatalf("%d * Inv(%d) != 1", a, a)
+		}
+		if Exp(Log(uint16(a))) != uint16(a) {
+			t.Fatalf("Exp(Log(%d)) != %d", a, a)
+		}
+	}
+	// α generates the whole multiplicative group
+	seen := make([]bool, 1<<16)
+	for i := 0; i < Order; i++ {
+		if seen[Exp(i)] {
+			t.Fatalf("α^%d repeats", i)
+		}
+		seen[Exp(i)] = true
+	}
+	if seen[0] {
+		t.Error("α generates zero")
+	}
+}
+
+func TestMulSlice(t *testing.T) {
+	// Both the log/exp path and the split-table path
+	for _, size := range []int{2, 100, splitThreshold, 4000} {
+		in := make([]byte, size)
+		rand.New(rand.NewSource(int64(size))).Read(in)
+		for _, c := range []uint16{0, 1, 2, 0x1234, 0xffff} {
+			out := make([]byte, size)
+			MulSlice(c, in, out)
+			acc := bytes.Repeat([]byte{0x5a}, size)
+			MulAddSlice(c, in, acc)
+			for i := 0; i < size; i += 2 {
+				want := Mul(c, binary.LittleEndian.Uint16(in[i:]))
+				if got := binary.LittleEndian.Uint16(out[i:]); got != want {
+					t.Fatalf("MulSlice(%#x) size %d word %d = %#x, want %#x", c, size, i/2, got, want)
+				}
+				if got := binary.LittleEndian.Uint16(acc[i:]); got != 0x5a5a^want {
+					t.Fatalf("MulAddSlice(%#x) size %d word %d = %#x, want %#x", c, size, i/2, got, 0x5a5a^want)
+				}
+			}
+		}
+	}
+}
+
+func TestDivByZeroPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Div(1, 0) did not panic")
+		}
+	}()
+	Div(1, 0)
+}
+
+func BenchmarkMulAddSlice(b *testing.B) {
+	for _, size := range []int{1 << 8, 1 << 10, 1 << 16} {
+		b.Run(fmt.Sprintf("%dB", size), func(b *testing.B) {
+			in := make([]byte, size)
+			out := make([]byte, size)
+			rand.New(rand.NewSource(1)).Read(in)
+			b.SetBytes(int64(size))
+			for i := 0; i < b.N; i++ {
+				MulAddSlice(0x8e3d, in, out)
+			}
+		})
+	}
+}
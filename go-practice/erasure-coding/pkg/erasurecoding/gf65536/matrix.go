@@ -0,0 +1,208 @@
+package gf65536
+
+// FieldError represents errors returned by matrix operations
+type FieldError struct {
+	message string
+}
+
+func (e *FieldError) Error() string {
+	return e.message
+}
+
+// Common errors
+var (
+	ErrSingular      = &FieldError{"matrix is singular"}
+	ErrNotSquare     = &FieldError{"matrix is not square"}
+	ErrDimension     = &FieldError{"matrix dimensions do not match"}
+	ErrCauchyTooWide = &FieldError{"cauchy matrix needs rows+cols <= 65536"}
+)
+
+// Matrix is a dense row-major matrix over GF(2^16)
+type Matrix [][]uint16
+
+// NewMatrix returns a zero matrix with the given dimensions
+func NewMatrix(rows, cols int) Matrix {
+	m := make(Matrix, rows)
+	cells := make([]uint16, rows*cols)
+	for r := range m {
+		m[r] = cells[r*cols : (r+1)*cols : (r+1)*cols]
+	}
+	return m
+}
+
+// Identity returns the n×n identity matrix
+func Identity(n int) Matrix {
+	m := NewMatrix(n, n)
+	for i := 0; i < n; i++ {
+		m[i][i] = 1
+	}
+	return m
+}
+
+// Cauchy returns the rows×cols Cauchy matrix with entries
+// 1 / (x_r + y_c), where y_c = c and x_r = cols + rowOffset + r
+//
+// As in gf256, every square submatrix is invertible, so stacking it under
+// an identity matrix gives a systematic MDS generator.
+//
+// Errors:
+//   - ErrCauchyTooWide if the x and y values would not fit in 16 bits
+func Cauchy(rows, cols, rowOffset int) (Matrix, error) {
+	if rows < 0 || cols < 0 || rowOffset < 0 || cols+rowOffset+rows > 1<<16 {
+		return nil, ErrCauchyTooWide
+	}
+	m := NewMatrix(rows, cols)
+	for r := 0; r < rows; r++ {
+		x := uint16(cols + rowOffset + r)
+		for c := 0; c < cols; c++ {
+			m[r][c] = Inv(x ^ uint16(c))
+		}
+	}
+	return m, nil
+}
+
+// Rows returns the number of rows
+func (m Matrix) Rows() int {
+	return len(m)
+}
+
+// Cols returns the number of columns
+func (m Matrix) Cols() int {
+	if len(m) == 0 {
+		return 0
+	}
+	return len(m[0])
+}
+
+// Clone returns a deep copy of m
+func (m Matrix) Clone() Matrix {
+	c := NewMatrix(m.Rows(), m.Cols())
+	for r := range m {
+		copy(c[r], m[r])
+	}
+	return c
+}
+
+// SelectRows returns a new matrix made of the listed rows of m
+func (m Matrix) SelectRows(rows []int) Matrix {
+	s := NewMatrix(len(rows), m.Cols())
+	for i, r := range rows {
+		copy(s[i], m[r])
+	}
+	return s
+}
+
+// Mul returns m × o
+//
+// Errors:
+//   - ErrDimension if m's column count differs from o's row count
+func (m Matrix) Mul(o Matrix) (Matrix, error) {
+	if m.Cols() != o.Rows() {
+		return nil, ErrDimension
+	}
+	p := NewMatrix(m.Rows(), o.Cols())
+	for r := range m {
+		for k, a := range m[r] {
+			mulAddRow(a, o[k], p[r])
+		}
+	}
+	return p, nil
+}
+
+// Invert returns the inverse of a square matrix using Gauss-Jordan
+// elimination; m is left unchanged
+//
+// Errors:
+//   - ErrNotSquare if m is not square
+//   - ErrSingular if m has no inverse
+func (m Matrix) Invert() (Matrix, error) {
+	n := m.Rows()
+	if n != m.Cols() {
+		return nil, ErrNotSquare
+	}
+	work := m.Clone()
+	inv := Identity(n)
+
+	for col := 0; col < n; col++ {
+		pivot := col
+		for pivot < n && work[pivot][col] == 0 {
+			pivot++
+		}
+		if pivot == n {
+			return nil, ErrSingular
+		}
+		work[col], work[pivot] = work[pivot], work[col]
+		inv[col], inv[pivot] = inv[pivot], inv[col]
+
+		if scale := work[col][col]; scale != 1 {
+			s := Inv(scale)
+			scaleRow(s, work[col])
+			scaleRow(s, inv[col])
+		}
+		for r := 0; r < n; r++ {
+			if f := work[r][col]; r != col && f != 0 {
+				mulAddRow(f, work[col], work[r])
+				mulAddRow(f, inv[col], inv[r])
+			}
+		}
+	}
+	return inv, nil
+}
+
+// Basis incrementally builds a set of linearly independent vectors, as
+// gf256.Basis does
+type Basis struct {
+	rows  Matrix // reduced vectors, row i has its leading 1 in column pivot[i]
+	pivot []int
+	cols  int
+}
+
+// NewBasis returns an empty basis for vectors of length cols
+func NewBasis(cols int) *Basis {
+	return &Basis{cols: cols}
+}
+
+// Rank returns the number of vectors accepted so far
+func (b *Basis) Rank() int {
+	return len(b.rows)
+}
+
+// Add reduces v against the basis and keeps it if it is independent of the
+// vectors already added; it reports whether v was kept
+func (b *Basis) Add(v []uint16) bool {
+	r := append([]uint16(nil), v[:b.cols]...)
+	for i, row := range b.rows {
+		if f := r[b.pivot[i]]; f != 0 {
+			mulAddRow(f, row, r)
+		}
+	}
+	for c, x := range r {
+		if x != 0 {
+			scaleRow(Inv(x), r)
+			b.rows = append(b.rows, r)
+			b.pivot = append(b.pivot, c)
+			return true
+		}
+	}
+	return false
+}
+
+// mulAddRow sets out[i] ^= c * in[i] for matrix rows
+func mulAddRow(c uint16, in, out []uint16) {
+	if c == 0 {
+		return
+	}
+	lc := int(logTable[c])
+	for i, v := range in {
+		if v != 0 {
+			out[i] ^= expTable[lc+int(logTable[v])]
+		}
+	}
+}
+
+// scaleRow sets row[i] = c * row[i]
+func scaleRow(c uint16, row []uint16) {
+	for i, v := range row {
+		row[i] = Mul(c, v)
+	}
+}
@@ -0,0 +1,86 @@
+package gf65536
+
+import (
+	"testing"
+)
+
+func isIdentity(m Matrix) bool {
+	for r := range m {
+		for c := range m[r] {
+			want := uint16(0)
+			if r == c {
+				want = 1
+			}
+			if m[r][c] != want {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestInvert(t *testing.T) {
+	m, _ := Cauchy(6, 6, 1000)
+	inv, err := m.Invert()
+	if err != nil {
+		t.Fatalf("Invert() error = %v", err)
+	}
+	p, _ := m.Mul(inv)
+	if !isIdentity(p) {
+		t.Errorf("m × m⁻¹ = %v, want identity", p)
+	}
+
+	singular := Matrix{{1, 2}, {2, 4}}
+	if _, err := singular.Invert(); err != ErrSingular {
+		t.Errorf("Invert(singular) error = %v, want %v", err, ErrSingular)
+	}
+	if _, err := NewMatrix(2, 3).Invert(); err != ErrNotSquare {
+		t.Errorf("Invert(2×3) error = %v, want %v", err, ErrNotSquare)
+	}
+	if _, err := NewMatrix(2, 3).Mul(NewMatrix(2, 3)); err != ErrDimension {
+		t.Errorf("Mul(2×3, 2×3) error = %v, want %v", err, ErrDimension)
+	}
+}
+
+func TestCauchy_WiderThanGF256(t *testing.T) {
+	// 300 data columns and 40 parity rows do not fit GF(2^8)
+	const k, m = 300, 40
+	parity, err := Cauchy(m, k, 0)
+	if err != nil {
+		t.Fatalf("Cauchy(%d, %d) error = %v", m, k, err)
+	}
+	gen := append(Identity(k), parity...)
+	// Drop the first m data rows and decode from the rest
+	rows := make([]int, 0, k)
+	for i := m; i < k+m; i++ {
+		rows = append(rows, i)
+	}
+	if _, err := gen.SelectRows(rows).Invert(); err != nil {
+		t.Errorf("Invert(survivors) error = %v", err)
+	}
+	if _, err := Cauchy(1, 1<<16, 0); err != ErrCauchyTooWide {
+		t.Errorf("Cauchy(too wide) error = %v, want %v", err, ErrCauchyTooWide)
+	}
+}
+
+func TestBasis(t *testing.T) {
+	b := NewBasis(3)
+	for _, tt := range []struct {
+		v    []uint16
+		kept bool
+	}{
+		{[]uint16{1, 2, 3}, true},
+		{[]uint16{Mul(7, 1), Mul(7, 2), Mul(7, 3)}, false},
+		{[]uint16{0, 1, 0}, true},
+		{[]uint16{1, 0x100, 3}, false},
+		{[]uint16{0, 0, 9}, true},
+		{[]uint16{5, 5, 5}, false},
+	} {
+		if got := b.Add(tt.v); got != tt.kept {
+			t.Errorf("Add(%v) = %v, want %v", tt.v, got, tt.kept)
+		}
+	}
+	if b.Rank() != 3 {
+		t.Errorf("Rank() = %d, want 3", b.Rank())
+	}
+}
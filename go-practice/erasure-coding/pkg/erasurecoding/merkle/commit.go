@@ -0,0 +1,329 @@
+package merkle
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/codec"
+)
+
+// DefaultSegmentSize is the segment granularity of shard trees
+const DefaultSegmentSize = 4096
+
+// Layout is the shape of a committed stripe; it is hashed into the root
+type Layout struct {
+	Shards      int `json:"shards"`
+	ShardSize   int `json:"shardSize"`
+	SegmentSize int `json:"segmentSize"`
+	// Codec and Size describe the object the stripe encodes when it was
+	// committed with CommitObject; they are empty for a bare stripe
+	Codec string `json:"codec,omitempty"`
+	Size  int64  `json:"size,omitempty"`
+}
+
+// segments returns the number of segments per shard
+func (l Layout) segments() int {
+	return (l.ShardSize + l.SegmentSize - 1) / l.SegmentSize
+}
+
+// segmentLen returns the length of segment j
+func (l Layout) segmentLen(j int) int {
+	return min(l.SegmentSize, l.ShardSize-j*l.SegmentSize)
+}
+
+// objectRoot binds the tree of shard roots to the layout
+func (l Layout) objectRoot(top Hash) Hash {
+	var buf [1 + 4 + 8 + 4 + 8 + 4]byte
+	buf[0] = objectPrefix
+	binary.BigEndian.PutUint32(buf[1:], uint32(l.Shards))
+	binary.BigEndian.PutUint64(buf[5:], uint64(l.ShardSize))
+	binary.BigEndian.PutUint32(buf[13:], uint32(l.SegmentSize))
+	binary.BigEndian.PutUint64(buf[17:], uint64(l.Size))
+	binary.BigEndian.PutUint32(buf[25:], uint32(len(l.Codec)))
+	h := sha256.New()
+	h.Write(buf[:])
+	h.Write([]byte(l.Codec))
+	h.Write(top[:])
+	var root Hash
+	h.Sum(root[:0])
+	return root
+}
+
+// segmentHashes returns the leaf hashes of the segments of shard
+func segmentHashes(shard []byte, segmentSize int) []Hash {
+	hashes := make([]Hash, 0, (len(shard)+segmentSize-1)/segmentSize)
+	for off := 0; off < len(shard); off += segmentSize {
+		hashes = append(hashes, LeafHash(shard[off:min(off+segmentSize, len(shard))]))
+	}
+	return hashes
+}
+
+// Commitment is the Merkle commitment to one stripe of shards
+type Commitment struct {
+	Layout
+	// Root identifies the stripe
+	Root Hash
+	// ShardRoots[i] is the root of shard i's segment tree
+	ShardRoots []Hash
+
+	segments [][]Hash
+}
+
+// Commit builds the commitment to shards (data and parity, stripe order)
+//
+// Errors:
+//   - ErrNoShards for an empty stripe
+//   - ErrUnequalShards if the shards differ in size
+//   - ErrInvalidSegment for a non-positive segment size
+func Commit(shards [][]byte, segmentSize int) (*Commitment, error) {
+	return commit(shards, segmentSize, "", 0)
+}
+
+// CommitObject is Commit for the stripe of a size-byte object encoded with
+// c, whose spec and size are committed to as well, so a decoder can trust
+// them once a shard verifies
+//
+// Errors:
+//   - as Commit
+//   - ErrInvalidSize if size is negative or exceeds the data shards
+func CommitObject(c codec.Codec, size int64, shards [][]byte, segmentSize int) (*Commitment, error) {
+	if len(shards) > 0 && (size < 0 || size > int64(c.DataShards())*int64(len(shards[0]))) {
+		return nil, ErrInvalidSize
+	}
+	return commit(shards, segmentSize, c.Spec(), size)
+}
+
+// commit implements Commit and CommitObject
+func commit(shards [][]byte, segmentSize int, spec string, size int64) (*Commitment, error) {
+	if len(shards) == 0 {
+		return nil, ErrNoShards
+	}
+	if segmentSize <= 0 {
+		return nil, ErrInvalidSegment
+	}
+	c := &Commitment{
+		Layout:     Layout{Shards: len(shards), ShardSize: len(shards[0]), SegmentSize: segmentSize, Codec: spec, Size: size},
+		ShardRoots: make([]Hash, len(shards)),
+		segments:   make([][]Hash, len(shards)),
+	}
+	for i, shard := range shards {
+		if len(shard) != c.ShardSize {
+			return nil, ErrUnequalShards
+		}
+		c.segments[i] = segmentHashes(shard, segmentSize)
+		c.ShardRoots[i] = Root(c.segments[i])
+	}
+	c.Root = c.objectRoot(Root(c.ShardRoots))
+	return c, nil
+}
+
+// ShardProof proves that a shard belongs to an object root
+type ShardProof struct {
+	Layout
+	Index     int    `json:"index"`
+	ShardRoot Hash   `json:"shardRoot"`
+	Path      []Hash `json:"path"`
+	// Segments are the leaf hashes of the shard's segments, so single
+	// segments can be verified without reading the whole shard
+	Segments []Hash `json:"segments,omitempty"`
+}
+
+// ShardProof returns the proof of shard i including its segment hashes
+//
+// Errors:
+//   - ErrIndexOutOfRange unless 0 <= i < Shards
+func (c *Commitment) ShardProof(i int) (*ShardProof, error) {
+	p, err := Proof(c.ShardRoots, i)
+	if err != nil {
+		return nil, err
+	}
+	return &ShardProof{
+		Layout:    c.Layout,
+		Index:     i,
+		ShardRoot: c.ShardRoots[i],
+		Path:      p,
+		Segments:  append([]Hash(nil), c.segments[i]...),
+	}, nil
+}
+
+// Verify checks that the proof leads to root, and that the segment hashes
+// (if any) produce ShardRoot
+//
+// Errors:
+//   - ErrProofMismatch otherwise
+func (p *ShardProof) Verify(root Hash) error {
+	if p.Shards <= 0 || p.ShardSize < 0 || p.SegmentSize <= 0 || p.Size < 0 {
+		return ErrProofMismatch
+	}
+	top, err := RootFromProof(p.ShardRoot, p.Index, p.Shards, p.Path)
+	if err != nil || p.objectRoot(top) != root {
+		return ErrProofMismatch
+	}
+	if p.Segments != nil && (len(p.Segments) != p.segments() || Root(p.Segments) != p.ShardRoot) {
+		return ErrProofMismatch
+	}
+	return nil
+}
+
+// VerifyShard checks a whole shard against root
+//
+// Errors:
+//   - ErrProofMismatch if the proof does not lead to root
+//   - ErrShardMismatch if the shard's size or content differs
+func VerifyShard(root Hash, shard []byte, p *ShardProof) error {
+	if err := p.Verify(root); err != nil {
+		return err
+	}
+	if len(shard) != p.ShardSize || Root(segmentHashes(shard, p.SegmentSize)) != p.ShardRoot {
+		return fmt.Errorf("%w: shard %d", ErrShardMismatch, p.Index)
+	}
+	return nil
+}
+
+// VerifySegment checks segment j of the shard against the segment hashes
+// of a proof that has already passed Verify
+//
+// Errors:
+//   - ErrNoSegmentHashes if the proof carries none
+//   - ErrSegmentMismatch if the data differs
+func (p *ShardProof) VerifySegment(j int, data []byte) error {
+	if p.Segments == nil {
+		return ErrNoSegmentHashes
+	}
+	if j < 0 || j >= len(p.Segments) || len(data) != p.segmentLen(j) || LeafHash(data) != p.Segments[j] {
+		return fmt.Errorf("%w: shard %d segment %d", ErrSegmentMismatch, p.Index, j)
+	}
+	return nil
+}
+
+// SegmentProof proves a single segment against the object root on its
+// own, e.g. for a client sampling segments from many nodes
+type SegmentProof struct {
+	Layout
+	Shard     int    `json:"shard"`
+	Segment   int    `json:"segment"`
+	ShardPath []Hash `json:"shardPath"`
+	// SegmentPath leads from the segment to the shard root
+	SegmentPath []Hash `json:"segmentPath"`
+}
+
+// SegmentProof returns the proof of segment j of shard i
+//
+// Errors:
+//   - ErrIndexOutOfRange for an unknown shard or segment
+func (c *Commitment) SegmentProof(i, j int) (*SegmentProof, error) {
+	shardPath, err := Proof(c.ShardRoots, i)
+	if err != nil {
+		return nil, err
+	}
+	segPath, err := Proof(c.segments[i], j)
+	if err != nil {
+		return nil, err
+	}
+	return &SegmentProof{Layout: c.Layout, Shard: i, Segment: j, ShardPath: shardPath, SegmentPath: segPath}, nil
+}
+
+// VerifySegment checks a segment against root with a standalone proof
+//
+// Errors:
+//   - ErrSegmentMismatch if the data or proof does not match root
+func VerifySegment(root Hash, data []byte, p *SegmentProof) error {
+	mismatch := fmt.Errorf("%w: shard %d segment %d", ErrSegmentMismatch, p.Shard, p.Segment)
+	if p.SegmentSize <= 0 || p.Segment < 0 || p.Segment >= p.segments() || len(data) != p.segmentLen(p.Segment) {
+		return mismatch
+	}
+	shardRoot, err := RootFromProof(LeafHash(data), p.Segment, p.segments(), p.SegmentPath)
+	if err != nil {
+		return mismatch
+	}
+	top, err := RootFromProof(shardRoot, p.Shard, p.Shards, p.ShardPath)
+	if err != nil || p.objectRoot(top) != root {
+		return mismatch
+	}
+	return nil
+}
+
+// Decode verifies every present shard against root, drops the ones whose
+// proof fails and reconstructs the stripe from the rest
+//
+// shards is modified in place like codec.Codec.Reconstruct. A shard with a
+// nil proof, or a proof with the wrong index, counts as failed. The indices
+// of rejected shards are returned even on error.
+//
+// Errors:
+//   - errors of c.Reconstruct when too few shards survive
+func Decode(c codec.Codec, root Hash, shards [][]byte, proofs []*ShardProof) ([]int, error) {
+	var rejected []int
+	for i, shard := range shards {
+		if shard == nil {
+			continue
+		}
+		if i >= len(proofs) || proofs[i] == nil || proofs[i].Index != i || VerifyShard(root, shard, proofs[i]) != nil {
+			shards[i] = nil
+			rejected = append(rejected, i)
+		}
+	}
+	return rejected, c.Reconstruct(shards)
+}
+
+// VerifiedSource is a codec.ShardSource that checks every segment it
+// serves against the object root
+//
+// Reads are widened to whole segments, and a segment that fails its hash
+// fails the read, so codec.RangeReader rebuilds that region from the other
+// shards. Shards whose proof does not lead to the root are unavailable.
+type VerifiedSource struct {
+	src      codec.ShardSource
+	proofs   []*ShardProof
+	rejected atomic.Int64
+}
+
+// NewVerifiedSource wraps src; proofs[i] must carry segment hashes
+func NewVerifiedSource(src codec.ShardSource, root Hash, proofs []*ShardProof) *VerifiedSource {
+	v := &VerifiedSource{src: src, proofs: make([]*ShardProof, len(proofs))}
+	for i, p := range proofs {
+		if p != nil && p.Index == i && p.Segments != nil && p.Verify(root) == nil {
+			v.proofs[i] = p
+		}
+	}
+	return v
+}
+
+// Rejected returns the number of segment reads that failed verification
+func (v *VerifiedSource) Rejected() int64 {
+	return v.rejected.Load()
+}
+
+// ReadShardAt implements codec.ShardSource
+func (v *VerifiedSource) ReadShardAt(shard int, p []byte, off int64) (int, error) {
+	if shard < 0 || shard >= len(v.proofs) || v.proofs[shard] == nil {
+		return 0, codec.ErrShardUnavailable
+	}
+	proof := v.proofs[shard]
+	if off < 0 || off >= int64(proof.ShardSize) {
+		return 0, io.EOF
+	}
+	end := min(off+int64(len(p)), int64(proof.ShardSize))
+	seg := int64(proof.SegmentSize)
+	first, last := int(off/seg), int((end-1)/seg)
+
+	buf := make([]byte, min(int64(last+1)*seg, int64(proof.ShardSize))-int64(first)*seg)
+	if err := codec.ReadShardFull(v.src, shard, buf, int64(first)*seg); err != nil {
+		return 0, err
+	}
+	for j := first; j <= last; j++ {
+		start := int64(j-first) * seg
+		if err := proof.VerifySegment(j, buf[start:min(start+seg, int64(len(buf)))]); err != nil {
+			v.rejected.Add(1)
+			return 0, err
+		}
+	}
+	n := copy(p, buf[off-int64(first)*seg:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
@@ -0,0 +1,220 @@
+package merkle
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/codec"
+)
+
+// stripe encodes size random bytes with spec and commits to the shards
+func stripe(t testing.TB, spec string, size, segmentSize int) (codec.Codec, []byte, [][]byte, *Commitment) {
+	t.Helper()
+	c, err := codec.Parse(spec)
+	if err != nil {
+		t.Fatalf("Parse(%q) error = %v", spec, err)
+	}
+	data := make([]byte, size)
+	rand.New(rand.NewSource(int64(size))).Read(data)
+	shards, err := codec.Split(c, data)
+	if err != nil {
+		t.Fatalf("Split() error = %v", err)
+	}
+	if err := c.Encode(shards); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	com, err := Commit(shards, segmentSize)
+	if err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	return c, data, shards, com
+}
+
+func proofs(t testing.TB, com *Commitment) []*ShardProof {
+	t.Helper()
+	out := make([]*ShardProof, com.Shards)
+	for i := range out {
+		p, err := com.ShardProof(i)
+		if err != nil {
+			t.Fatalf("ShardProof(%d) error = %v", i, err)
+		}
+		out[i] = p
+	}
+	return out
+}
+
+func cloneShards(shards [][]byte) [][]byte {
+	out := make([][]byte, len(shards))
+	for i, s := range shards {
+		out[i] = append([]byte(nil), s...)
+	}
+	return out
+}
+
+func TestVerifyShard(t *testing.T) {
+	_, _, shards, com := stripe(t, "rs:4+2", 100_000, 1000)
+	ps := proofs(t, com)
+	for i, shard := range shards {
+		if err := VerifyShard(com.Root, shard, ps[i]); err != nil {
+			t.Fatalf("VerifyShard(%d) error = %v", i, err)
+		}
+	}
+
+	forged := append([]byte(nil), shards[2]...)
+	forged[len(forged)-1] ^= 0x80
+	if err := VerifyShard(com.Root, forged, ps[2]); !errors.Is(err, ErrShardMismatch) {
+		t.Errorf("VerifyShard(forged) error = %v, want ErrShardMismatch", err)
+	}
+	if err := VerifyShard(com.Root, shards[2][:len(shards[2])-1], ps[2]); !errors.Is(err, ErrShardMismatch) {
+		t.Errorf("VerifyShard(truncated) error = %v, want ErrShardMismatch", err)
+	}
+	// A valid shard presented under another index
+	if err := VerifyShard(com.Root, shards[1], ps[2]); !errors.Is(err, ErrShardMismatch) {
+		t.Errorf("VerifyShard(swapped) error = %v, want ErrShardMismatch", err)
+	}
+	// A proof whose layout was altered no longer leads to the root
+	bad := *ps[0]
+	bad.SegmentSize = 500
+	if err := bad.Verify(com.Root); !errors.Is(err, ErrProofMismatch) {
+		t.Errorf("Verify(altered layout) error = %v, want ErrProofMismatch", err)
+	}
+	other := *ps[0]
+	other.Segments = append([]Hash(nil), ps[0].Segments...)
+	other.Segments[0] = Hash{}
+	if err := other.Verify(com.Root); !errors.Is(err, ErrProofMismatch) {
+		t.Errorf("Verify(altered segments) error = %v, want ErrProofMismatch", err)
+	}
+}
+
+func TestSegmentProofs(t *testing.T) {
+	_, _, shards, com := stripe(t, "rs:3+2", 50_000, 1024)
+	for i, shard := range shards {
+		for j := 0; j*1024 < len(shard); j++ {
+			seg := shard[j*1024 : min((j+1)*1024, len(shard))]
+			p, err := com.SegmentProof(i, j)
+			if err != nil {
+				t.Fatalf("SegmentProof(%d, %d) error = %v", i, j, err)
+			}
+			if err := VerifySegment(com.Root, seg, p); err != nil {
+				t.Fatalf("VerifySegment(%d, %d) error = %v", i, j, err)
+			}
+			forged := append([]byte(nil), seg...)
+			forged[0] ^= 1
+			if err := VerifySegment(com.Root, forged, p); !errors.Is(err, ErrSegmentMismatch) {
+				t.Fatalf("VerifySegment(forged %d, %d) error = %v", i, j, err)
+			}
+		}
+	}
+	if _, err := com.SegmentProof(0, 1000); !errors.Is(err, ErrIndexOutOfRange) {
+		t.Errorf("SegmentProof(out of range) error = %v", err)
+	}
+}
+
+func TestDecode_RejectsForgedShards(t *testing.T) {
+	c, data, shards, com := stripe(t, "rs:4+2", 64_000, 4096)
+	ps := proofs(t, com)
+
+	got := cloneShards(shards)
+	got[0][100] ^= 1                              // forged data shard
+	got[5] = bytes.Repeat([]byte{7}, len(got[5])) // forged parity shard
+	rejected, err := Decode(c, com.Root, got, ps)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if len(rejected) != 2 || rejected[0] != 0 || rejected[1] != 5 {
+		t.Errorf("rejected = %v, want [0 5]", rejected)
+	}
+	if !bytes.Equal(codec.Join(c, got, len(data)), data) {
+		t.Error("Decode() did not restore the object")
+	}
+
+	// Three forged shards exceed what rs:4+2 can repair
+	got = cloneShards(shards)
+	for _, i := range []int{0, 1, 2} {
+		got[i][0] ^= 1
+	}
+	if rejected, err := Decode(c, com.Root, got, ps); err == nil || len(rejected) != 3 {
+		t.Errorf("Decode(3 forged) = %v, %v; want an error", rejected, err)
+	}
+
+	// Missing or misplaced proofs count as failures
+	got = cloneShards(shards)
+	misplaced := append([]*ShardProof(nil), ps...)
+	misplaced[1], misplaced[3] = nil, ps[2]
+	if rejected, err := Decode(c, com.Root, got, misplaced); err != nil || len(rejected) != 2 {
+		t.Errorf("Decode(bad proofs) = %v, %v", rejected, err)
+	}
+}
+
+func TestVerifiedSource_RangeReadsRepairForgedSegments(t *testing.T) {
+	c, data, shards, com := stripe(t, "rs:4+2", 200_000, 1024)
+	stored := cloneShards(shards)
+	stored[1][5000] ^= 0xff // one forged segment in data shard 1
+	stored[3] = nil         // one missing shard
+
+	src := NewVerifiedSource(codec.MemorySource(stored), com.Root, proofs(t, com))
+	r, err := codec.NewRangeReader(c, src, com.ShardSize, int64(len(data)))
+	if err != nil {
+		t.Fatalf("NewRangeReader() error = %v", err)
+	}
+	got := make([]byte, len(data))
+	if _, err := r.ReadAt(got, 0); err != nil {
+		t.Fatalf("ReadAt() error = %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("ReadAt() returned forged data")
+	}
+	if src.Rejected() == 0 {
+		t.Error("Rejected() = 0, want the forged segment counted")
+	}
+
+	// Unaligned small reads still verify whole segments
+	buf := make([]byte, 10)
+	off := int64(com.ShardSize) + 4995
+	if _, err := r.ReadAt(buf, off); err != nil || !bytes.Equal(buf, data[off:off+10]) {
+		t.Errorf("ReadAt(%d) = %v", off, err)
+	}
+}
+
+func TestNewVerifiedSource_DropsBadProofs(t *testing.T) {
+	_, _, shards, com := stripe(t, "rs:2+1", 10_000, 512)
+	ps := proofs(t, com)
+	ps[0] = &ShardProof{Layout: com.Layout, Index: 0, ShardRoot: Hash{1}}
+	src := NewVerifiedSource(codec.MemorySource(shards), com.Root, ps)
+	if _, err := src.ReadShardAt(0, make([]byte, 10), 0); !errors.Is(err, codec.ErrShardUnavailable) {
+		t.Errorf("ReadShardAt(bad proof) error = %v, want ErrShardUnavailable", err)
+	}
+	if n, err := src.ReadShardAt(1, make([]byte, 10), 0); n != 10 || err != nil {
+		t.Errorf("ReadShardAt(good proof) = %d, %v", n, err)
+	}
+}
+
+func TestCommit_InvalidInput(t *testing.T) {
+	if _, err := Commit(nil, 100); !errors.Is(err, ErrNoShards) {
+		t.Errorf("Commit(nil) error = %v", err)
+	}
+	if _, err := Commit([][]byte{{1}, {1, 2}}, 100); !errors.Is(err, ErrUnequalShards) {
+		t.Errorf("Commit(unequal) error = %v", err)
+	}
+	if _, err := Commit([][]byte{{1}}, 0); !errors.Is(err, ErrInvalidSegment) {
+		t.Errorf("Commit(segment 0) error = %v", err)
+	}
+	xor, _ := codec.Parse("xor:2+1")
+	if _, err := CommitObject(xor, 5, [][]byte{{1, 2}, {3, 4}, {2, 6}}, 100); !errors.Is(err, ErrInvalidSize) {
+		t.Errorf("CommitObject(size 5 in 4 data bytes) error = %v", err)
+	}
+	if _, err := CommitObject(xor, -1, [][]byte{{1, 2}, {3, 4}, {2, 6}}, 100); !errors.Is(err, ErrInvalidSize) {
+		t.Errorf("CommitObject(size -1) error = %v", err)
+	}
+}
+
+func BenchmarkCommit(b *testing.B) {
+	_, _, shards, _ := stripe(b, "rs:10+4", 10<<20, DefaultSegmentSize)
+	b.SetBytes(int64(len(shards) * len(shards[0])))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Commit(shards, DefaultSegmentSize)
+	}
+}
@@ -0,0 +1,220 @@
+package merkle
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/codec"
+)
+
+// A shard directory holds one object as self-verifying files:
+//
+//	<dir>/manifest.json     DirManifest
+//	<dir>/shard.<i>         shard data
+//	<dir>/shard.<i>.proof   ShardProof (JSON) of shard i
+const manifestFile = "manifest.json"
+
+// DirManifest describes the object stored in a shard directory
+type DirManifest struct {
+	Codec     string `json:"codec"`
+	Size      int64  `json:"size"`
+	ChunkSize int    `json:"chunkSize"`
+	Root      Hash   `json:"root"`
+}
+
+func shardFile(dir string, i int) string {
+	return filepath.Join(dir, fmt.Sprintf("shard.%d", i))
+}
+
+func proofFile(dir string, i int) string {
+	return shardFile(dir, i) + ".proof"
+}
+
+// WriteDir encodes data with c and writes the shards, their proofs and the
+// manifest to dir
+//
+// Errors:
+//   - codec.ErrEmptyData for empty data
+//   - ErrInvalidSegment for a non-positive segment size
+//   - codec and file system errors
+func WriteDir(dir string, c codec.Codec, data []byte, segmentSize int) (*DirManifest, error) {
+	shards, err := codec.Split(c, data)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Encode(shards); err != nil {
+		return nil, err
+	}
+	com, err := CommitObject(c, int64(len(data)), shards, segmentSize)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	for i, shard := range shards {
+		proof, _ := com.ShardProof(i)
+		raw, err := json.Marshal(proof)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(shardFile(dir, i), shard, 0o644); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(proofFile(dir, i), raw, 0o644); err != nil {
+			return nil, err
+		}
+	}
+	m := &DirManifest{Codec: c.Spec(), Size: int64(len(data)), ChunkSize: com.ShardSize, Root: com.Root}
+	raw, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return m, os.WriteFile(filepath.Join(dir, manifestFile), raw, 0o644)
+}
+
+// ShardStatus is the verification outcome of one shard file
+type ShardStatus struct {
+	Index int
+	// Err is nil for a valid shard; os.ErrNotExist for a missing file,
+	// ErrProofMismatch or ErrShardMismatch otherwise
+	Err error
+}
+
+// DirReport is the result of VerifyDir
+type DirReport struct {
+	Manifest *DirManifest
+	Root     Hash
+	Shards   []ShardStatus
+	// Valid counts the shards that passed; Recoverable reports whether
+	// they are enough for the codec to rebuild the object
+	Valid       int
+	Recoverable bool
+}
+
+// VerifyDir checks every shard of dir against root, or against the root in
+// the manifest when root is the zero Hash
+//
+// Errors:
+//   - ErrCorruptManifest if the manifest is unreadable, or its codec or
+//     size differ from those a valid shard's proof commits to
+func VerifyDir(dir string, root Hash) (*DirReport, error) {
+	m, c, err := readManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	if root == (Hash{}) {
+		root = m.Root
+	}
+	rep := &DirReport{Manifest: m, Root: root}
+	present := make([][]byte, codec.TotalShards(c))
+	for i := range present {
+		shard, proof, err := readShard(dir, i)
+		if err == nil {
+			err = VerifyShard(root, shard, proof)
+		}
+		if err == nil && proof.Index != i {
+			err = ErrProofMismatch
+		}
+		if err == nil {
+			if err := checkManifest(m, c, proof.Layout); err != nil {
+				return nil, err
+			}
+			rep.Valid++
+			present[i] = shard
+		}
+		rep.Shards = append(rep.Shards, ShardStatus{Index: i, Err: err})
+	}
+	rep.Recoverable = c.Reconstruct(present) == nil
+	return rep, nil
+}
+
+// DecodeDir reassembles the object in dir, rejecting shards whose proofs
+// fail against root (the manifest root when root is zero) and returning
+// their indices
+//
+// The codec and size in the manifest are only used once they match those
+// committed to by the root.
+//
+// Errors:
+//   - ErrCorruptManifest if the manifest is unreadable, or its codec or
+//     size differ from the root's
+//   - errors of codec reconstruction when too few shards verify
+func DecodeDir(dir string, root Hash) ([]byte, []int, error) {
+	m, c, err := readManifest(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	if root == (Hash{}) {
+		root = m.Root
+	}
+	shards := make([][]byte, codec.TotalShards(c))
+	proofs := make([]*ShardProof, len(shards))
+	for i := range shards {
+		if shard, proof, err := readShard(dir, i); err == nil {
+			shards[i], proofs[i] = shard, proof
+		}
+	}
+	rejected, err := Decode(c, root, shards, proofs)
+	if err != nil {
+		return nil, rejected, err
+	}
+	for i, p := range proofs {
+		if p == nil || slices.Contains(rejected, i) {
+			continue
+		}
+		if err := checkManifest(m, c, p.Layout); err != nil {
+			return nil, rejected, err
+		}
+		return codec.Join(c, shards, int(m.Size)), rejected, nil
+	}
+	// Reconstruct succeeded without a single verified shard
+	return nil, rejected, codec.ErrTooFewShards
+}
+
+// checkManifest rejects a manifest whose codec or size differ from the
+// committed layout l, or whose size does not fit the stripe
+func checkManifest(m *DirManifest, c codec.Codec, l Layout) error {
+	if m.Codec != l.Codec || m.Size != l.Size || m.Size < 0 || m.Size > int64(c.DataShards())*int64(l.ShardSize) {
+		return fmt.Errorf("%w: codec %q and size %d are not the committed %q and %d", ErrCorruptManifest, m.Codec, m.Size, l.Codec, l.Size)
+	}
+	return nil
+}
+
+// readManifest loads the manifest and its codec
+func readManifest(dir string) (*DirManifest, codec.Codec, error) {
+	raw, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrCorruptManifest, err)
+	}
+	m := &DirManifest{}
+	if err := json.Unmarshal(raw, m); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrCorruptManifest, err)
+	}
+	c, err := codec.Parse(m.Codec)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrCorruptManifest, err)
+	}
+	return m, c, nil
+}
+
+// readShard loads shard i and its proof
+func readShard(dir string, i int) ([]byte, *ShardProof, error) {
+	shard, err := os.ReadFile(shardFile(dir, i))
+	if err != nil {
+		return nil, nil, err
+	}
+	raw, err := os.ReadFile(proofFile(dir, i))
+	if err != nil {
+		return nil, nil, err
+	}
+	proof := &ShardProof{}
+	if err := json.Unmarshal(raw, proof); err != nil {
+		return nil, nil, errors.Join(ErrProofMismatch, err)
+	}
+	return shard, proof, nil
+}
@@ -0,0 +1,128 @@
+package merkle
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/codec"
+)
+
+func writeTestDir(t *testing.T, spec string, data []byte) (string, *DirManifest) {
+	t.Helper()
+	c, err := codec.Parse(spec)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	dir := filepath.Join(t.TempDir(), "obj")
+	m, err := WriteDir(dir, c, data, 1024)
+	if err != nil {
+		t.Fatalf("WriteDir() error = %v", err)
+	}
+	return dir, m
+}
+
+func TestWriteVerifyDecodeDir(t *testing.T) {
+	data := bytes.Repeat([]byte("merkle committed shards "), 2000)
+	dir, m := writeTestDir(t, "rs:4+2", data)
+
+	rep, err := VerifyDir(dir, m.Root)
+	if err != nil {
+		t.Fatalf("VerifyDir() error = %v", err)
+	}
+	if rep.Valid != 6 || !rep.Recoverable || rep.Root != m.Root {
+		t.Errorf("VerifyDir() = %+v", rep)
+	}
+
+	// Forge shard 2, drop shard 4
+	forged, _ := os.ReadFile(shardFile(dir, 2))
+	forged[0] ^= 1
+	os.WriteFile(shardFile(dir, 2), forged, 0o644)
+	os.Remove(shardFile(dir, 4))
+
+	rep, err = VerifyDir(dir, Hash{}) // root from the manifest
+	if err != nil {
+		t.Fatalf("VerifyDir() error = %v", err)
+	}
+	if rep.Valid != 4 || !rep.Recoverable {
+		t.Errorf("VerifyDir() valid = %d recoverable = %v", rep.Valid, rep.Recoverable)
+	}
+	if !errors.Is(rep.Shards[2].Err, ErrShardMismatch) || !errors.Is(rep.Shards[4].Err, os.ErrNotExist) {
+		t.Errorf("shard statuses = %+v", rep.Shards)
+	}
+
+	got, rejected, err := DecodeDir(dir, m.Root)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("DecodeDir() = %d bytes, %v", len(got), err)
+	}
+	if len(rejected) != 1 || rejected[0] != 2 {
+		t.Errorf("rejected = %v, want [2]", rejected)
+	}
+}
+
+func TestVerifyDir_WrongRoot(t *testing.T) {
+	dir, _ := writeTestDir(t, "xor:3+1", []byte("some object data"))
+	other := LeafHash([]byte("someone else's object"))
+	rep, err := VerifyDir(dir, other)
+	if err != nil {
+		t.Fatalf("VerifyDir() error = %v", err)
+	}
+	if rep.Valid != 0 || rep.Recoverable {
+		t.Errorf("VerifyDir(wrong root) = %+v", rep)
+	}
+	if _, _, err := DecodeDir(dir, other); err == nil {
+		t.Error("DecodeDir(wrong root) succeeded")
+	}
+}
+
+func TestVerifyDir_ForgedManifestRoot(t *testing.T) {
+	// Rewriting the whole directory under a new root is only caught by
+	// pinning the expected root; that is what the root is for
+	dir, m := writeTestDir(t, "rs:2+1", []byte("original"))
+	dir2, _ := writeTestDir(t, "rs:2+1", []byte("replaced"))
+	for _, name := range []string{"manifest.json", "shard.0", "shard.0.proof", "shard.1", "shard.1.proof", "shard.2", "shard.2.proof"} {
+		raw, _ := os.ReadFile(filepath.Join(dir2, name))
+		os.WriteFile(filepath.Join(dir, name), raw, 0o644)
+	}
+	if rep, _ := VerifyDir(dir, m.Root); rep.Recoverable {
+		t.Error("replaced directory verifies against the original root")
+	}
+}
+
+// The root commits to the codec and size, so editing them in the manifest
+// is caught even with the right root pinned
+func TestDecodeDir_ForgedManifestFields(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		edit func(m *DirManifest)
+	}{
+		{"truncated size", func(m *DirManifest) { m.Size = 5 }},
+		{"negative size", func(m *DirManifest) { m.Size = -1 }},
+		{"oversized", func(m *DirManifest) { m.Size = 1 << 40 }},
+		{"other codec", func(m *DirManifest) { m.Codec = "xor:2+1" }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, m := writeTestDir(t, "rs:2+1", []byte("hello, committed world"))
+			forged := *m
+			tc.edit(&forged)
+			raw, _ := json.Marshal(&forged)
+			os.WriteFile(filepath.Join(dir, manifestFile), raw, 0o644)
+
+			if got, _, err := DecodeDir(dir, m.Root); !errors.Is(err, ErrCorruptManifest) {
+				t.Errorf("DecodeDir() = %q, %v, want %v", got, err, ErrCorruptManifest)
+			}
+			if _, err := VerifyDir(dir, m.Root); !errors.Is(err, ErrCorruptManifest) {
+				t.Errorf("VerifyDir() error = %v, want %v", err, ErrCorruptManifest)
+			}
+		})
+	}
+}
+
+func TestVerifyDir_MissingManifest(t *testing.T) {
+	if _, err := VerifyDir(t.TempDir(), Hash{}); !errors.Is(err, ErrCorruptManifest) {
+		t.Errorf("VerifyDir(empty dir) error = %v, want ErrCorruptManifest", err)
+	}
+}
@@ -0,0 +1,104 @@
+package merkle
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+func leaves(n int) []Hash {
+	out := make([]Hash, n)
+	for i := range out {
+		out[i] = LeafHash([]byte(fmt.Sprintf("leaf %d", i)))
+	}
+	return out
+}
+
+func TestRoot_KnownValues(t *testing.T) {
+	// RFC 6962 test vectors: the empty tree and a single empty leaf
+	if got := Root(nil).String(); got != "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" {
+		t.Errorf("Root(empty) = %s", got)
+	}
+	if got := Root([]Hash{LeafHash(nil)}).String(); got != "6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d" {
+		t.Errorf("Root(one empty leaf) = %s", got)
+	}
+
+	// Three leaves split 2 + 1
+	l := leaves(3)
+	want := nodeHash(nodeHash(l[0], l[1]), l[2])
+	if Root(l) != want {
+		t.Error("Root(3 leaves) does not split at the largest power of two")
+	}
+}
+
+func TestProof_AllPositions(t *testing.T) {
+	for n := 1; n <= 33; n++ {
+		l := leaves(n)
+		root := Root(l)
+		for i := 0; i < n; i++ {
+			p, err := Proof(l, i)
+			if err != nil {
+				t.Fatalf("Proof(%d of %d) error = %v", i, n, err)
+			}
+			if !VerifyProof(root, l[i], i, n, p) {
+				t.Fatalf("VerifyProof(%d of %d) = false", i, n)
+			}
+			// The same path must not verify another leaf or index
+			if n > 1 && VerifyProof(root, l[(i+1)%n], i, n, p) {
+				t.Fatalf("proof of %d of %d verifies a different leaf", i, n)
+			}
+			if n > 1 && VerifyProof(root, l[i], (i+1)%n, n, p) {
+				t.Fatalf("proof of %d of %d verifies at another index", i, n)
+			}
+		}
+	}
+	if _, err := Proof(leaves(4), 4); err != ErrIndexOutOfRange {
+		t.Errorf("Proof(out of range) error = %v", err)
+	}
+}
+
+func TestRootFromProof_RejectsMalformedPaths(t *testing.T) {
+	l := leaves(8)
+	p, _ := Proof(l, 3)
+	if _, err := RootFromProof(l[3], 3, 8, p[:2]); err != ErrProofMismatch {
+		t.Errorf("short path error = %v", err)
+	}
+	if _, err := RootFromProof(l[3], 3, 8, append(p, p[0])); err != ErrProofMismatch {
+		t.Errorf("long path error = %v", err)
+	}
+	if _, err := RootFromProof(l[3], 8, 8, p); err != ErrProofMismatch {
+		t.Errorf("index = size error = %v", err)
+	}
+}
+
+func TestLeafAndNodeDomainsDiffer(t *testing.T) {
+	// A leaf whose data is two concatenated hashes must not collide with
+	// the inner node of those hashes
+	l := leaves(2)
+	data := append(append([]byte(nil), l[0][:]...), l[1][:]...)
+	if LeafHash(data) == nodeHash(l[0], l[1]) {
+		t.Error("leaf and node hashes collide")
+	}
+}
+
+func TestHashText(t *testing.T) {
+	h := LeafHash([]byte("x"))
+	b, _ := h.MarshalText()
+	var back Hash
+	if err := back.UnmarshalText(b); err != nil || back != h {
+		t.Errorf("text roundtrip = %v, %v", back, err)
+	}
+	for _, s := range []string{"", "zz", hex.EncodeToString(make([]byte, sha256.Size-1))} {
+		if _, err := ParseHash(s); err != ErrInvalidHash {
+			t.Errorf("ParseHash(%q) error = %v", s, err)
+		}
+	}
+}
+
+func BenchmarkRoot4096(b *testing.B) {
+	l := leaves(4096)
+	for i := 0; i < b.N; i++ {
+		Root(l)
+	}
+}
@@ -0,0 +1,612 @@
+// Package objstore stores whole objects erasure-coded across a set of local
+// directories, one shard per directory.
+//
+// Each directory plays the role of an independent disk. An object encoded
+// with a k+m codec is split into k+m shards, and every shard is written to a
+// different directory chosen by hashing the bucket and key. Reads keep
+// working while up to m of those directories are unavailable: missing data
+// shards are rebuilt on the fly by codec.RangeReader.
+//
+// On-disk layout (per directory):
+//
+//	<dir>/<bucket>/<base64url(key)>/meta.json      object metadata
+//	<dir>/<bucket>/<base64url(key)>/<gen>.<shard>  shard data
+//
+// A key whose encoding is longer than maxEncodedKey would exceed the
+// 255-byte file name limit, so its directory is named "~" and the hex
+// SHA-256 of the key instead. The full key is kept in meta.json.
+//
+// Every write gets a new generation. Shards of a new generation are written
+// next to the old ones and the metadata is switched over last, so a crashed
+// PUT never leaves a half-written object visible.
+package objstore
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/codec"
+)
+
+// StoreError represents errors returned by the object store
+type StoreError struct {
+	message string
+}
+
+func (e *StoreError) Error() string {
+	return e.message
+}
+
+// Common errors
+var (
+	ErrNoDirs          = &StoreError{"at least one directory is required"}
+	ErrNotEnoughDirs   = &StoreError{"not enough available directories for the codec"}
+	ErrNotFound        = &StoreError{"object not found"}
+	ErrInvalidBucket   = &StoreError{"invalid bucket name"}
+	ErrInvalidKey      = &StoreError{"invalid object key"}
+	ErrObjectDegraded  = &StoreError{"too many shards unavailable to read object"}
+	ErrCorruptMetadata = &StoreError{"corrupt object metadata"}
+	ErrConflict        = &StoreError{"object changed while it was being rewritten"}
+	ErrChecksum        = &StoreError{"object content does not match its ETag"}
+)
+
+const metaFile = "meta.json"
+
+// Meta describes a stored object
+type Meta struct {
+	Bucket      string    `json:"bucket"`
+	Key         string    `json:"key"`
+	Size        int64     `json:"size"`
+	ETag        string    `json:"etag"`
+	ContentType string    `json:"contentType,omitempty"`
+	ModTime     time.Time `json:"modTime"`
+	// Codec is the spec string of the codec the object was encoded with
+	Codec string `json:"codec"`
+	// ChunkSize is the size of every shard in bytes
+	ChunkSize int `json:"chunkSize"`
+	// Generation identifies the shard files belonging to this version
+	Generation string `json:"generation"`
+	// Source is the generation of the write whose content a transcode
+	// copied; empty for objects written by Put
+	Source string `json:"source,omitempty"`
+	// Placement[i] is the index of the directory holding shard i
+	Placement []int `json:"placement"`
+	// Pipeline holds the parameters of any transform applied before
+	// encoding (see package pipeline); Size and ETag describe the
+	// transformed bytes
+	Pipeline json.RawMessage `json:"pipeline,omitempty"`
+}
+
+// PutOptions customise a single Put
+type PutOptions struct {
+	// Codec overrides the store's default codec when non-nil
+	Codec codec.Codec
+	// ContentType is recorded in the metadata and returned on reads
+	ContentType string
+	// Pipeline is recorded in the metadata unchanged
+	Pipeline json.RawMessage
+}
+
+// Store is an erasure-coded object store over local directories
+type Store struct {
+	dirs    []string
+	codec   codec.Codec
+	mu      sync.Mutex
+	lastGen atomic.Int64
+}
+
+// New creates a Store writing shards to dirs with defaultCodec
+//
+// The directories are created if needed. More directories than shards are
+// allowed; each object then uses a hash-rotated subset of them.
+//
+// Errors:
+//   - ErrNoDirs if dirs is empty
+//   - ErrNotEnoughDirs if the codec needs more shards than there are dirs
+func New(dirs []string, defaultCodec codec.Codec) (*Store, error) {
+	if len(dirs) == 0 {
+		return nil, ErrNoDirs
+	}
+	if codec.TotalShards(defaultCodec) > len(dirs) {
+		return nil, ErrNotEnoughDirs
+	}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return &Store{dirs: append([]string(nil), dirs...), codec: defaultCodec}, nil
+}
+
+// Dirs returns the configured directories in placement order
+func (s *Store) Dirs() []string {
+	return append([]string(nil), s.dirs...)
+}
+
+// Codec returns the store's default codec
+func (s *Store) Codec() codec.Codec {
+	return s.codec
+}
+
+// Available reports which directories are currently usable
+func (s *Store) Available() []bool {
+	avail := make([]bool, len(s.dirs))
+	for i, dir := range s.dirs {
+		fi, err := os.Stat(dir)
+		avail[i] = err == nil && fi.IsDir()
+	}
+	return avail
+}
+
+// Put encodes data and writes its shards and metadata
+//
+// Errors:
+//   - ErrInvalidBucket or ErrInvalidKey for bad names
+//   - ErrNotEnoughDirs if fewer available directories than shards
+func (s *Store) Put(bucket, key string, data []byte, opts PutOptions) (*Meta, error) {
+	if err := validateName(bucket, key); err != nil {
+		return nil, err
+	}
+	c := opts.Codec
+	if c == nil {
+		c = s.codec
+	}
+
+	shards, err := encode(c, data)
+	if err != nil {
+		return nil, err
+	}
+	placement, err := s.place(bucket, key, len(shards))
+	if err != nil {
+		return nil, err
+	}
+
+	sum := md5.Sum(data)
+	meta := &Meta{
+		Bucket:      bucket,
+		Key:         key,
+		Size:        int64(len(data)),
+		ETag:        hex.EncodeToString(sum[:]),
+		ContentType: opts.ContentType,
+		ModTime:     time.Now().UTC(),
+		Codec:       c.Spec(),
+		ChunkSize:   len(shards[0]),
+		Generation:  s.nextGeneration(),
+		Placement:   placement,
+		Pipeline:    opts.Pipeline,
+	}
+
+	if err := s.writeObject(meta, shards); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// writeObject writes and commits the shards of meta. A transcode may commit
+// the previous content under a generation newer than meta's; the object is
+// then written again under a fresh generation so the write is not lost.
+func (s *Store) writeObject(meta *Meta, shards [][]byte) error {
+	for {
+		if err := s.WriteShards(meta, shards); err != nil {
+			return err
+		}
+		if err := s.Commit(meta); err != ErrConflict {
+			return err
+		}
+		meta.Generation = s.nextGeneration()
+	}
+}
+
+// encode splits and encodes data, returning empty shards for empty objects
+func encode(c codec.Codec, data []byte) ([][]byte, error) {
+	if len(data) == 0 {
+		return make([][]byte, codec.TotalShards(c)), nil
+	}
+	shards, err := codec.Split(c, data)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Encode(shards); err != nil {
+		return nil, err
+	}
+	return shards, nil
+}
+
+// WriteShards durably writes the shards of meta's generation without making
+// them visible; Commit publishes them. On error any shard already written is
+// removed again.
+func (s *Store) WriteShards(meta *Meta, shards [][]byte) error {
+	var written []string
+	for i, shard := range shards {
+		path := s.shardPath(meta, meta.Placement[i], i)
+		if err := writeFileAtomic(path, shard); err != nil {
+			for _, p := range written {
+				os.Remove(p)
+			}
+			return fmt.Errorf("write shard %d: %w", i, err)
+		}
+		written = append(written, path)
+	}
+	return nil
+}
+
+// Commit publishes meta by writing it to every directory holding a shard,
+// then removes the shards and metadata of older generations
+//
+// If a newer generation was committed in the meantime, meta's own shards
+// are discarded instead and the newer object stays visible.
+//
+// Errors:
+//   - ErrConflict if the newer generation is a transcode of content older
+//     than meta; meta's shards are discarded, and writing them again under
+//     a new generation makes meta visible
+func (s *Store) Commit(meta *Meta) error {
+	return s.commit(meta, "")
+}
+
+// commit implements Commit. A non-empty replaces additionally requires the
+// currently visible generation to be exactly replaces; otherwise meta's
+// shards are discarded and ErrConflict is returned.
+func (s *Store) commit(meta *Meta, replaces string) error {
+	raw, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, err := s.Head(meta.Bucket, meta.Key)
+	stale := err == nil && current.Generation > meta.Generation
+	conflict := replaces != "" && (err != nil || current.Generation != replaces)
+	// A transcode that started after meta's write but copied older content
+	// must not hide it
+	conflict = conflict || stale && current.Source != "" && current.Source < meta.Generation
+	if stale || conflict {
+		for i, d := range meta.Placement {
+			os.Remove(s.shardPath(meta, d, i))
+		}
+		if conflict {
+			return ErrConflict
+		}
+		return nil
+	}
+
+	for _, d := range meta.Placement {
+		if err := writeFileAtomic(filepath.Join(s.objectDir(d, meta.Bucket, meta.Key), metaFile), raw); err != nil {
+			return fmt.Errorf("write metadata: %w", err)
+		}
+	}
+
+	// Garbage-collect older generations now that the new one is visible
+	for d := range s.dirs {
+		s.collectGarbage(s.objectDir(d, meta.Bucket, meta.Key), meta.Generation)
+	}
+	return nil
+}
+
+// collectGarbage removes metadata and shard files in an object directory
+// whose generation is older than keep, and the directory itself once empty
+func (s *Store) collectGarbage(dir, keep string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		gen := ""
+		if e.Name() == metaFile {
+			if meta, err := s.readMeta(path); err == nil {
+				gen = meta.Generation
+			}
+		} else if g, _, ok := strings.Cut(e.Name(), "."); ok && g != "" {
+			gen = g
+		} else {
+			continue // in-flight temporary file
+		}
+		if gen < keep {
+			os.Remove(path)
+		}
+	}
+	os.Remove(dir)
+}
+
+// Head returns the newest metadata of an object
+//
+// Errors:
+//   - ErrNotFound if no available directory has the object
+func (s *Store) Head(bucket, key string) (*Meta, error) {
+	if err := validateName(bucket, key); err != nil {
+		return nil, err
+	}
+
+	var newest *Meta
+	for d := range s.dirs {
+		meta, err := s.readMeta(filepath.Join(s.objectDir(d, bucket, key), metaFile))
+		if err != nil {
+			continue
+		}
+		if newest == nil || meta.Generation > newest.Generation {
+			newest = meta
+		}
+	}
+	if newest == nil {
+		return nil, ErrNotFound
+	}
+	return newest, nil
+}
+
+// Delete removes an object from every available directory
+//
+// Deleting a missing object is not an error, matching S3.
+func (s *Store) Delete(bucket, key string) error {
+	if err := validateName(bucket, key); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	for d := range s.dirs {
+		dir := s.objectDir(d, bucket, key)
+		// Remove metadata first so a partial delete never exposes an
+		// object whose shards are already gone
+		if err := os.Remove(filepath.Join(dir, metaFile)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// List returns the newest metadata of every object in bucket whose key
+// starts with prefix, sorted by key
+func (s *Store) List(bucket, prefix string) ([]*Meta, error) {
+	if err := validateName(bucket, "-"); err != nil {
+		return nil, err
+	}
+
+	newest := map[string]*Meta{}
+	for _, dir := range s.dirs {
+		entries, err := os.ReadDir(filepath.Join(dir, bucket))
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			// A hashed name says nothing about the key until meta.json is
+			// read
+			if !strings.HasPrefix(e.Name(), hashedKeyPrefix) {
+				raw, err := base64.RawURLEncoding.DecodeString(e.Name())
+				if err != nil || !strings.HasPrefix(string(raw), prefix) {
+					continue
+				}
+			}
+			meta, err := s.readMeta(filepath.Join(dir, bucket, e.Name(), metaFile))
+			if err != nil || !strings.HasPrefix(meta.Key, prefix) {
+				continue
+			}
+			if cur, ok := newest[meta.Key]; !ok || meta.Generation > cur.Generation {
+				newest[meta.Key] = meta
+			}
+		}
+	}
+
+	metas := make([]*Meta, 0, len(newest))
+	for _, meta := range newest {
+		metas = append(metas, meta)
+	}
+	sort.Slice(metas, func(i, j int) bool { return metas[i].Key < metas[j].Key })
+	return metas, nil
+}
+
+// Object is an open stored object that supports random access reads
+type Object struct {
+	*codec.RangeReader
+	Meta *Meta
+	// Missing lists the shard indices that could not be opened
+	Missing []int
+	files   []*os.File
+}
+
+// Degraded reports whether any shard of the object is unavailable
+func (o *Object) Degraded() bool {
+	return len(o.Missing) > 0
+}
+
+// Close releases the shard files
+func (o *Object) Close() error {
+	var errs []error
+	for _, f := range o.files {
+		if f != nil {
+			errs = append(errs, f.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ReadShardAt implements codec.ShardSource over the opened shard files
+func (o *Object) ReadShardAt(shard int, p []byte, off int64) (int, error) {
+	if shard < 0 || shard >= len(o.files) || o.files[shard] == nil {
+		return 0, codec.ErrShardUnavailable
+	}
+	return o.files[shard].ReadAt(p, off)
+}
+
+// Open opens an object for reading
+//
+// Errors:
+//   - ErrNotFound if the object does not exist
+//   - ErrObjectDegraded if more shards are missing than the codec tolerates
+func (s *Store) Open(bucket, key string) (*Object, error) {
+	meta, err := s.Head(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	return s.OpenMeta(meta)
+}
+
+// OpenMeta opens the shards described by meta
+func (s *Store) OpenMeta(meta *Meta) (*Object, error) {
+	c, err := codec.Parse(meta.Codec)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptMetadata, err)
+	}
+	if len(meta.Placement) != codec.TotalShards(c) {
+		return nil, ErrCorruptMetadata
+	}
+
+	obj := &Object{Meta: meta, files: make([]*os.File, len(meta.Placement))}
+	for i, d := range meta.Placement {
+		f, err := os.Open(s.shardPath(meta, d, i))
+		if err != nil {
+			obj.Missing = append(obj.Missing, i)
+			continue
+		}
+		obj.files[i] = f
+	}
+	if len(obj.Missing) > c.ParityShards() {
+		obj.Close()
+		return nil, ErrObjectDegraded
+	}
+
+	obj.RangeReader, err = codec.NewRangeReader(c, obj, meta.ChunkSize, meta.Size)
+	if err != nil {
+		obj.Close()
+		return nil, fmt.Errorf("%w: %v", ErrCorruptMetadata, err)
+	}
+	return obj, nil
+}
+
+// place picks n distinct available directories for an object, starting at
+// a position derived from the bucket and key
+func (s *Store) place(bucket, key string, n int) ([]int, error) {
+	avail := s.Available()
+	h := fnv.New32a()
+	h.Write([]byte(bucket + "/" + key))
+	start := int(h.Sum32() % uint32(len(s.dirs)))
+
+	placement := make([]int, 0, n)
+	for i := 0; i < len(s.dirs) && len(placement) < n; i++ {
+		d := (start + i) % len(s.dirs)
+		if avail[d] {
+			placement = append(placement, d)
+		}
+	}
+	if len(placement) < n {
+		return nil, ErrNotEnoughDirs
+	}
+	return placement, nil
+}
+
+// nextGeneration returns a strictly increasing, sortable generation ID
+func (s *Store) nextGeneration() string {
+	for {
+		last := s.lastGen.Load()
+		next := time.Now().UnixNano()
+		if next <= last {
+			next = last + 1
+		}
+		if s.lastGen.CompareAndSwap(last, next) {
+			return fmt.Sprintf("%016x", next)
+		}
+	}
+}
+
+func (s *Store) objectDir(d int, bucket, key string) string {
+	return filepath.Join(s.dirs[d], bucket, keyDir(key))
+}
+
+// maxEncodedKey is the longest base64url key used as a directory name as
+// it is, leaving room under NAME_MAX
+const maxEncodedKey = 200
+
+// hashedKeyPrefix starts the directory names of hashed keys; it is not in
+// the base64url alphabet
+const hashedKeyPrefix = "~"
+
+// keyDir returns the directory name of key: base64url(key), or the hashed
+// form for long keys
+func keyDir(key string) string {
+	name := base64.RawURLEncoding.EncodeToString([]byte(key))
+	if len(name) <= maxEncodedKey {
+		return name
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hashedKeyPrefix + hex.EncodeToString(sum[:])
+}
+
+func (s *Store) shardPath(meta *Meta, d, shard int) string {
+	return filepath.Join(s.objectDir(d, meta.Bucket, meta.Key), fmt.Sprintf("%s.%d", meta.Generation, shard))
+}
+
+func (s *Store) readMeta(path string) (*Meta, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var meta Meta
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		return nil, ErrCorruptMetadata
+	}
+	return &meta, nil
+}
+
+// writeFileAtomic writes data to a temporary file, syncs it and renames it
+// into place so readers never observe a partial file
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// validateName applies a simplified version of the S3 naming rules
+func validateName(bucket, key string) error {
+	if len(bucket) < 3 || len(bucket) > 63 {
+		return ErrInvalidBucket
+	}
+	for _, r := range bucket {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '.') {
+			return ErrInvalidBucket
+		}
+	}
+	if key == "" || len(key) > 1024 {
+		return ErrInvalidKey
+	}
+	return nil
+}
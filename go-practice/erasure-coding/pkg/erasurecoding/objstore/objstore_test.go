@@ -0,0 +1,228 @@
+package objstore
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/codec"
+)
+
+func newTestStore(t *testing.T, numDirs int, spec string) *Store {
+	t.Helper()
+	c, err := codec.Parse(spec)
+	if err != nil {
+		t.Fatalf("Parse(%q) error = %v", spec, err)
+	}
+	root := t.TempDir()
+	dirs := make([]string, numDirs)
+	for i := range dirs {
+		dirs[i] = filepath.Join(root, fmt.Sprintf("disk%d", i))
+	}
+	s, err := New(dirs, c)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return s
+}
+
+func readAll(t *testing.T, s *Store, bucket, key string) (*Object, []byte) {
+	t.Helper()
+	obj, err := s.Open(bucket, key)
+	if err != nil {
+		t.Fatalf("Open(%q) error = %v", key, err)
+	}
+	defer obj.Close()
+	got, err := io.ReadAll(obj.NewSectionReader(0, obj.Size()))
+	if err != nil {
+		t.Fatalf("ReadAll(%q) error = %v", key, err)
+	}
+	return obj, got
+}
+
+func TestPutOpenRoundtrip(t *testing.T) {
+	s := newTestStore(t, 5, "xor:4+1")
+	data := []byte("The quick brown fox jumps over the lazy dog")
+
+	meta, err := s.Put("bucket", "dir/fox.txt", data, PutOptions{ContentType: "text/plain"})
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if meta.Size != int64(len(data)) || meta.Codec != "xor:4+1" || len(meta.Placement) != 5 {
+		t.Errorf("Put() meta = %+v", meta)
+	}
+
+	obj, got := readAll(t, s, "bucket", "dir/fox.txt")
+	if !bytes.Equal(got, data) {
+		t.Errorf("read = %q, want %q", got, data)
+	}
+	if obj.Degraded() {
+		t.Error("Degraded() = true with all directories available")
+	}
+}
+
+func TestOpen_DegradedUpToParity(t *testing.T) {
+	data := bytes.Repeat([]byte("degraded read "), 50)
+
+	for _, spec := range []string{"xor:3+1", "replica:1+2"} {
+		t.Run(spec, func(t *testing.T) {
+			s := newTestStore(t, 4, spec)
+			meta, err := s.Put("bucket", "obj", data, PutOptions{})
+			if err != nil {
+				t.Fatalf("Put() error = %v", err)
+			}
+
+			c, _ := codec.Parse(spec)
+			for i := 0; i < c.ParityShards(); i++ {
+				os.RemoveAll(s.Dirs()[meta.Placement[i]])
+			}
+
+			obj, got := readAll(t, s, "bucket", "obj")
+			if !bytes.Equal(got, data) {
+				t.Errorf("degraded read mismatch")
+			}
+			if !obj.Degraded() || len(obj.Missing) != c.ParityShards() {
+				t.Errorf("Missing = %v, want %d shards", obj.Missing, c.ParityShards())
+			}
+
+			// Losing one more shard is unrecoverable. For replication this
+			// also removes the last metadata copy, so the object is gone.
+			os.RemoveAll(s.Dirs()[meta.Placement[c.ParityShards()]])
+			if _, err := s.Open("bucket", "obj"); err != ErrObjectDegraded && err != ErrNotFound {
+				t.Errorf("Open(too many lost) error = %v, want %v", err, ErrObjectDegraded)
+			}
+		})
+	}
+}
+
+func TestPut_OverwriteCollectsOldGeneration(t *testing.T) {
+	s := newTestStore(t, 3, "xor:2+1")
+	first, _ := s.Put("bucket", "obj", []byte("first version"), PutOptions{})
+	second, err := s.Put("bucket", "obj", []byte("second"), PutOptions{})
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if second.Generation <= first.Generation {
+		t.Errorf("generation %q not newer than %q", second.Generation, first.Generation)
+	}
+
+	_, got := readAll(t, s, "bucket", "obj")
+	if string(got) != "second" {
+		t.Errorf("read = %q, want %q", got, "second")
+	}
+	for i, d := range first.Placement {
+		if _, err := os.Stat(s.shardPath(first, d, i)); !os.IsNotExist(err) {
+			t.Errorf("old shard %d still present", i)
+		}
+	}
+}
+
+func TestPut_CodecOverrideAndEmptyObject(t *testing.T) {
+	s := newTestStore(t, 4, "xor:3+1")
+	rep, _ := codec.NewReplica(2)
+
+	meta, err := s.Put("bucket", "empty", nil, PutOptions{Codec: rep})
+	if err != nil {
+		t.Fatalf("Put(empty) error = %v", err)
+	}
+	if meta.Codec != "replica:1+1" || meta.Size != 0 {
+		t.Errorf("Put(empty) meta = %+v", meta)
+	}
+	_, got := readAll(t, s, "bucket", "empty")
+	if len(got) != 0 {
+		t.Errorf("read(empty) = %q", got)
+	}
+}
+
+func TestDeleteAndList(t *testing.T) {
+	s := newTestStore(t, 3, "xor:2+1")
+	for _, key := range []string{"b", "a/1", "a/2", "c"} {
+		if _, err := s.Put("bucket", key, []byte(key), PutOptions{}); err != nil {
+			t.Fatalf("Put(%q) error = %v", key, err)
+		}
+	}
+
+	metas, err := s.List("bucket", "a/")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(metas) != 2 || metas[0].Key != "a/1" || metas[1].Key != "a/2" {
+		t.Errorf("List(a/) = %v", metas)
+	}
+
+	if err := s.Delete("bucket", "a/1"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := s.Head("bucket", "a/1"); err != ErrNotFound {
+		t.Errorf("Head(deleted) error = %v, want %v", err, ErrNotFound)
+	}
+	if err := s.Delete("bucket", "missing"); err != nil {
+		t.Errorf("Delete(missing) error = %v", err)
+	}
+
+	metas, _ = s.List("bucket", "")
+	if len(metas) != 3 {
+		t.Errorf("List() returned %d objects, want 3", len(metas))
+	}
+}
+
+// S3 allows 1024-byte keys, whose base64url is far over NAME_MAX
+func TestPut_LongKeys(t *testing.T) {
+	s := newTestStore(t, 3, "xor:2+1")
+	prefix := strings.Repeat("k", 1000)
+	long1, long2 := prefix+strings.Repeat("1", 24), prefix+strings.Repeat("2", 24)
+	for _, key := range []string{long1, long2, "short"} {
+		if _, err := s.Put("bucket", key, []byte(key[len(key)-5:]), PutOptions{}); err != nil {
+			t.Fatalf("Put(%d-byte key) error = %v", len(key), err)
+		}
+	}
+	if _, err := s.Put("bucket", long1, []byte("new"), PutOptions{}); err != nil {
+		t.Fatalf("Put(overwrite) error = %v", err)
+	}
+	if _, got := readAll(t, s, "bucket", long1); string(got) != "new" {
+		t.Errorf("read(long key) = %q, want %q", got, "new")
+	}
+	if _, got := readAll(t, s, "bucket", long2); string(got) != "22222" {
+		t.Errorf("read(other long key) = %q", got)
+	}
+
+	metas, err := s.List("bucket", prefix)
+	if err != nil || len(metas) != 2 || metas[0].Key != long1 || metas[1].Key != long2 {
+		t.Errorf("List(long prefix) = %d objects, %v", len(metas), err)
+	}
+	if metas, _ := s.List("bucket", "sh"); len(metas) != 1 {
+		t.Errorf("List(sh) = %d objects, want 1", len(metas))
+	}
+
+	if err := s.Delete("bucket", long1); err != nil {
+		t.Fatalf("Delete(long key) error = %v", err)
+	}
+	if metas, _ := s.List("bucket", ""); len(metas) != 2 {
+		t.Errorf("List() after Delete = %d objects, want 2", len(metas))
+	}
+	if _, err := s.Put("bucket", prefix+strings.Repeat("x", 25), nil, PutOptions{}); err != ErrInvalidKey {
+		t.Errorf("Put(1025-byte key) error = %v, want %v", err, ErrInvalidKey)
+	}
+}
+
+func TestPut_NotEnoughDirs(t *testing.T) {
+	s := newTestStore(t, 3, "xor:2+1")
+	os.RemoveAll(s.Dirs()[0])
+	if _, err := s.Put("bucket", "obj", []byte("data"), PutOptions{}); err != ErrNotEnoughDirs {
+		t.Errorf("Put() error = %v, want %v", err, ErrNotEnoughDirs)
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	s := newTestStore(t, 3, "xor:2+1")
+	if _, err := s.Put("BadBucket", "k", []byte("x"), PutOptions{}); err != ErrInvalidBucket {
+		t.Errorf("Put(bad bucket) error = %v, want %v", err, ErrInvalidBucket)
+	}
+	if _, err := s.Put("bucket", "", []byte("x"), PutOptions{}); err != ErrInvalidKey {
+		t.Errorf("Put(empty key) error = %v, want %v", err, ErrInvalidKey)
+	}
+}
@@ -0,0 +1,123 @@
+package objstore
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/codec"
+)
+
+// Transcode re-encodes a stored object with c, for example to move it from
+// xor:4+1 or replica:1+2 to rs:6+3 or lrc:6+2+2
+//
+// The object is read (degraded reads are fine), checked against its ETag,
+// encoded with c and written as a new generation. Only once every new shard
+// is durable is the metadata switched over, and only if the object was not
+// overwritten in the meantime; the old generation's shards are then
+// garbage-collected like after any overwrite. Content, ETag, content type
+// and modification time are kept. A Put that started before the transcode
+// but commits after it still wins: it sees the transcode's Source and
+// writes again under a newer generation.
+//
+// Transcode is safe to interrupt at any point:
+//   - before the switch, the old generation stays visible and the
+//     half-written new shards are collected by the next commit;
+//   - after the switch (even a partial one, with new metadata written to
+//     only some directories), the new generation is visible, and calling
+//     Transcode again finishes publishing it and collects the old shards.
+//
+// Transcoding an object that already uses c just does that clean-up.
+//
+// Errors:
+//   - ErrNotFound, ErrObjectDegraded or ErrCorruptMetadata from Open
+//   - ErrChecksum if the content read back does not match the ETag
+//   - ErrNotEnoughDirs if c needs more shards than available directories
+//   - ErrConflict if the object was overwritten or deleted concurrently
+func (s *Store) Transcode(bucket, key string, c codec.Codec) (*Meta, error) {
+	old, err := s.Head(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	if old.Codec == c.Spec() {
+		return old, s.Commit(old)
+	}
+
+	meta, err := s.prepareTranscode(old, c)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.commit(meta, old.Generation); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// prepareTranscode reads the object described by old and durably writes it
+// as a new, not yet visible generation encoded with c
+func (s *Store) prepareTranscode(old *Meta, c codec.Codec) (*Meta, error) {
+	obj, err := s.OpenMeta(old)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(obj.NewSectionReader(0, obj.Size()))
+	obj.Close()
+	if err != nil {
+		return nil, fmt.Errorf("read %s/%s: %w", old.Bucket, old.Key, err)
+	}
+	if sum := md5.Sum(data); len(old.ETag) == 2*md5.Size && hex.EncodeToString(sum[:]) != old.ETag {
+		return nil, ErrChecksum
+	}
+
+	shards, err := encode(c, data)
+	if err != nil {
+		return nil, err
+	}
+	placement, err := s.place(old.Bucket, old.Key, len(shards))
+	if err != nil {
+		return nil, err
+	}
+
+	meta := *old
+	meta.Codec = c.Spec()
+	meta.ChunkSize = len(shards[0])
+	meta.Generation = s.nextGeneration()
+	if meta.Source == "" {
+		meta.Source = old.Generation
+	}
+	meta.Placement = placement
+	if err := s.WriteShards(&meta, shards); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+// TranscodeAll transcodes every object in bucket whose key starts with
+// prefix and is not encoded with c yet, returning how many were converted
+//
+// It keeps going after per-object failures and returns them joined.
+// Objects deleted or overwritten while it runs are skipped.
+func (s *Store) TranscodeAll(bucket, prefix string, c codec.Codec) (int, error) {
+	metas, err := s.List(bucket, prefix)
+	if err != nil {
+		return 0, err
+	}
+	converted := 0
+	var errs []error
+	for _, meta := range metas {
+		if meta.Codec == c.Spec() {
+			continue
+		}
+		_, err := s.Transcode(bucket, meta.Key, c)
+		switch {
+		case err == nil:
+			converted++
+		case errors.Is(err, ErrConflict), errors.Is(err, ErrNotFound):
+		default:
+			errs = append(errs, fmt.Errorf("%s: %w", meta.Key, err))
+		}
+	}
+	return converted, errors.Join(errs...)
+}
@@ -0,0 +1,254 @@
+package objstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/codec"
+)
+
+var transcodeData = bytes.Repeat([]byte("re-striping keeps the bytes "), 40)
+
+// shardFiles counts shard files per generation across all directories
+func shardFiles(t *testing.T, s *Store) map[string]int {
+	t.Helper()
+	gens := map[string]int{}
+	for _, dir := range s.dirs {
+		filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+			if err == nil && !d.IsDir() && d.Name() != metaFile {
+				gen, _, _ := strings.Cut(d.Name(), ".")
+				gens[gen]++
+			}
+			return nil
+		})
+	}
+	return gens
+}
+
+func mustParse(t *testing.T, spec string) codec.Codec {
+	t.Helper()
+	c, err := codec.Parse(spec)
+	if err != nil {
+		t.Fatalf("Parse(%q) error = %v", spec, err)
+	}
+	return c
+}
+
+func TestTranscode(t *testing.T) {
+	tests := []struct{ from, to string }{
+		{"xor:4+1", "rs:4+2"},
+		{"xor:4+1", "lrc:4+2+1"},
+		{"replica:1+2", "rs:3+2"},
+		{"rs:4+2", "xor:2+1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+"->"+tt.to, func(t *testing.T) {
+			s := newTestStore(t, 7, tt.from)
+			before, err := s.Put("bucket", "obj", transcodeData, PutOptions{ContentType: "text/plain"})
+			if err != nil {
+				t.Fatalf("Put() error = %v", err)
+			}
+
+			after, err := s.Transcode("bucket", "obj", mustParse(t, tt.to))
+			if err != nil {
+				t.Fatalf("Transcode() error = %v", err)
+			}
+			if after.Codec != tt.to || after.ETag != before.ETag || after.ContentType != "text/plain" || !after.ModTime.Equal(before.ModTime) {
+				t.Errorf("Transcode() meta = %+v", after)
+			}
+
+			obj, got := readAll(t, s, "bucket", "obj")
+			if !bytes.Equal(got, transcodeData) || obj.Meta.Codec != tt.to {
+				t.Errorf("read after Transcode = %d bytes with %s", len(got), obj.Meta.Codec)
+			}
+			gens := shardFiles(t, s)
+			if len(gens) != 1 || gens[after.Generation] != len(after.Placement) {
+				t.Errorf("shard files by generation = %v, want only %d of %s", gens, len(after.Placement), after.Generation)
+			}
+		})
+	}
+}
+
+func TestTranscode_DegradedSource(t *testing.T) {
+	s := newTestStore(t, 6, "xor:4+1")
+	before, _ := s.Put("bucket", "obj", transcodeData, PutOptions{})
+	os.Remove(s.shardPath(before, before.Placement[2], 2))
+
+	if _, err := s.Transcode("bucket", "obj", mustParse(t, "rs:4+2")); err != nil {
+		t.Fatalf("Transcode(degraded) error = %v", err)
+	}
+	obj, got := readAll(t, s, "bucket", "obj")
+	if !bytes.Equal(got, transcodeData) || obj.Degraded() {
+		t.Errorf("read after Transcode = %d bytes, degraded %v", len(got), obj.Degraded())
+	}
+}
+
+func TestTranscode_InterruptedBeforeSwitch(t *testing.T) {
+	s := newTestStore(t, 6, "xor:4+1")
+	before, _ := s.Put("bucket", "obj", transcodeData, PutOptions{})
+
+	// Crash after the new shards are durable but before the metadata swap
+	if _, err := s.prepareTranscode(before, mustParse(t, "rs:4+2")); err != nil {
+		t.Fatalf("prepareTranscode() error = %v", err)
+	}
+	obj, got := readAll(t, s, "bucket", "obj")
+	if !bytes.Equal(got, transcodeData) || obj.Meta.Codec != "xor:4+1" {
+		t.Fatalf("read after interrupted Transcode = %d bytes with %s", len(got), obj.Meta.Codec)
+	}
+
+	after, err := s.Transcode("bucket", "obj", mustParse(t, "rs:4+2"))
+	if err != nil {
+		t.Fatalf("Transcode() retry error = %v", err)
+	}
+	if gens := shardFiles(t, s); len(gens) != 1 || gens[after.Generation] != 6 {
+		t.Errorf("shard files by generation = %v, want the orphans collected", gens)
+	}
+}
+
+func TestTranscode_InterruptedDuringSwitch(t *testing.T) {
+	s := newTestStore(t, 6, "xor:4+1")
+	before, _ := s.Put("bucket", "obj", transcodeData, PutOptions{})
+	meta, err := s.prepareTranscode(before, mustParse(t, "rs:4+2"))
+	if err != nil {
+		t.Fatalf("prepareTranscode() error = %v", err)
+	}
+
+	// Crash after the new metadata reached a single directory
+	raw, _ := json.Marshal(meta)
+	if err := writeFileAtomic(filepath.Join(s.objectDir(meta.Placement[0], "bucket", "obj"), metaFile), raw); err != nil {
+		t.Fatal(err)
+	}
+	obj, got := readAll(t, s, "bucket", "obj")
+	if !bytes.Equal(got, transcodeData) || obj.Meta.Codec != "rs:4+2" {
+		t.Fatalf("read after partial switch = %d bytes with %s", len(got), obj.Meta.Codec)
+	}
+
+	if _, err := s.Transcode("bucket", "obj", mustParse(t, "rs:4+2")); err != nil {
+		t.Fatalf("Transcode() resume error = %v", err)
+	}
+	if gens := shardFiles(t, s); len(gens) != 1 || gens[meta.Generation] != 6 {
+		t.Errorf("shard files by generation = %v, want old generation collected", gens)
+	}
+	for _, d := range meta.Placement {
+		m, err := s.readMeta(filepath.Join(s.objectDir(d, "bucket", "obj"), metaFile))
+		if err != nil || m.Generation != meta.Generation {
+			t.Errorf("dir %d metadata = %+v, %v", d, m, err)
+		}
+	}
+}
+
+func TestTranscode_ConcurrentOverwriteWins(t *testing.T) {
+	s := newTestStore(t, 6, "xor:4+1")
+	before, _ := s.Put("bucket", "obj", transcodeData, PutOptions{})
+	meta, err := s.prepareTranscode(before, mustParse(t, "rs:4+2"))
+	if err != nil {
+		t.Fatalf("prepareTranscode() error = %v", err)
+	}
+
+	newer := []byte("written while transcoding")
+	if _, err := s.Put("bucket", "obj", newer, PutOptions{}); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if err := s.commit(meta, before.Generation); err != ErrConflict {
+		t.Fatalf("commit() error = %v, want %v", err, ErrConflict)
+	}
+	if _, got := readAll(t, s, "bucket", "obj"); !bytes.Equal(got, newer) {
+		t.Errorf("read = %q, want the concurrent write", got)
+	}
+	if gens := shardFiles(t, s); len(gens) != 1 {
+		t.Errorf("shard files by generation = %v, want only the newest", gens)
+	}
+}
+
+// A Put that took its generation before a transcode but commits after it
+// must not be replaced by the transcoded old content
+func TestTranscode_EarlierPutStillWins(t *testing.T) {
+	s := newTestStore(t, 6, "xor:4+1")
+	s.Put("bucket", "obj", transcodeData, PutOptions{})
+
+	// Put, stopped between allocating its generation and committing
+	newer := []byte("written while transcoding")
+	shards, _ := encode(s.codec, newer)
+	placement, _ := s.place("bucket", "obj", len(shards))
+	meta := &Meta{
+		Bucket: "bucket", Key: "obj", Size: int64(len(newer)), Codec: s.codec.Spec(),
+		ChunkSize: len(shards[0]), Generation: s.nextGeneration(), Placement: placement,
+	}
+	if err := s.WriteShards(meta, shards); err != nil {
+		t.Fatalf("WriteShards() error = %v", err)
+	}
+
+	transcoded, err := s.Transcode("bucket", "obj", mustParse(t, "rs:4+2"))
+	if err != nil {
+		t.Fatalf("Transcode() error = %v", err)
+	}
+	if transcoded.Generation <= meta.Generation || transcoded.Source == "" {
+		t.Fatalf("transcode generation %q, source %q", transcoded.Generation, transcoded.Source)
+	}
+	stale := *meta
+	if err := s.Commit(&stale); err != ErrConflict {
+		t.Fatalf("Commit(before transcode) error = %v, want %v", err, ErrConflict)
+	}
+
+	if err := s.writeObject(meta, shards); err != nil {
+		t.Fatalf("writeObject() error = %v", err)
+	}
+	if meta.Generation <= transcoded.Generation {
+		t.Errorf("rewritten generation %q not newer than %q", meta.Generation, transcoded.Generation)
+	}
+	if _, got := readAll(t, s, "bucket", "obj"); !bytes.Equal(got, newer) {
+		t.Errorf("read = %q, want the Put's content", got)
+	}
+	if gens := shardFiles(t, s); len(gens) != 1 || gens[meta.Generation] != 5 {
+		t.Errorf("shard files by generation = %v, want only the Put's", gens)
+	}
+}
+
+func TestTranscode_Errors(t *testing.T) {
+	s := newTestStore(t, 5, "xor:4+1")
+	if _, err := s.Transcode("bucket", "missing", mustParse(t, "rs:3+2")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Transcode(missing) error = %v, want %v", err, ErrNotFound)
+	}
+	s.Put("bucket", "obj", transcodeData, PutOptions{})
+	if _, err := s.Transcode("bucket", "obj", mustParse(t, "rs:4+2")); !errors.Is(err, ErrNotEnoughDirs) {
+		t.Errorf("Transcode(6 shards on 5 dirs) error = %v, want %v", err, ErrNotEnoughDirs)
+	}
+
+	// Flip a byte in a data shard: the ETag check must refuse to rewrite it
+	meta, _ := s.Head("bucket", "obj")
+	path := s.shardPath(meta, meta.Placement[0], 0)
+	shard, _ := os.ReadFile(path)
+	shard[0] ^= 0xff
+	os.WriteFile(path, shard, 0o644)
+	if _, err := s.Transcode("bucket", "obj", mustParse(t, "rs:3+2")); !errors.Is(err, ErrChecksum) {
+		t.Errorf("Transcode(corrupt) error = %v, want %v", err, ErrChecksum)
+	}
+}
+
+func TestTranscodeAll(t *testing.T) {
+	s := newTestStore(t, 6, "xor:4+1")
+	for _, key := range []string{"logs/a", "logs/b", "other"} {
+		s.Put("bucket", key, transcodeData, PutOptions{})
+	}
+	s.Put("bucket", "logs/c", transcodeData, PutOptions{Codec: mustParse(t, "rs:4+2")})
+
+	n, err := s.TranscodeAll("bucket", "logs/", mustParse(t, "rs:4+2"))
+	if err != nil || n != 2 {
+		t.Fatalf("TranscodeAll() = %d, %v, want 2", n, err)
+	}
+	metas, _ := s.List("bucket", "")
+	for _, m := range metas {
+		want := "rs:4+2"
+		if m.Key == "other" {
+			want = "xor:4+1"
+		}
+		if m.Codec != want {
+			t.Errorf("%s codec = %s, want %s", m.Key, m.Codec, want)
+		}
+	}
+}
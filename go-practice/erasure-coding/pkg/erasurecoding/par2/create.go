@@ -0,0 +1,275 @@
+package par2
+
+import (
+	"crypto/md5"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/gf65536"
+)
+
+// Defaults applied by Create for zero CreateOptions fields
+const (
+	// DefaultSlices is the number of input slices Create aims for when
+	// choosing a slice size, as the par2 command line tool does
+	DefaultSlices = 2000
+	// DefaultRedundancy is the recovery size in percent of the input
+	DefaultRedundancy = 5
+	// DefaultCreator is written into the creator packet
+	DefaultCreator = "go-practice/erasure-coding par2"
+)
+
+// hash16kSize is the prefix length hashed into Hash16k
+const hash16kSize = 16 << 10
+
+// CreateOptions controls Create
+type CreateOptions struct {
+	// SliceSize is the slice size in bytes, a multiple of 4 (default:
+	// about DefaultSlices slices over all files)
+	SliceSize int64
+	// Recovery is the number of recovery slices (default: DefaultRedundancy
+	// percent of the input slices, at least 1)
+	Recovery int
+	// Volumes is the number of recovery volume files the recovery slices
+	// are spread over (default 1)
+	Volumes int
+	// Creator names the creating program (default DefaultCreator)
+	Creator string
+}
+
+// Create writes a PAR2 index file at path and recovery volumes next to it
+// ("base.volNN+MM.par2" for base.par2) protecting files. File names are
+// recorded relative to the directory of path, which they must lie in.
+//
+// The index file holds the main, file description, IFSC and creator
+// packets; every volume repeats them after its recovery slices, so any
+// single volume is enough to verify. Input files are streamed; memory use
+// is one slice per recovery slice.
+//
+// Errors:
+//   - ErrNoFiles, ErrDuplicateFile or ErrUnsafeName for bad file lists
+//   - ErrInvalidSliceSize, ErrTooManySlices or ErrTooManyRecovery for
+//     options out of range
+func Create(path string, files []string, opts CreateOptions) (*Set, error) {
+	if len(files) == 0 {
+		return nil, ErrNoFiles
+	}
+	if opts.SliceSize < 0 || opts.SliceSize%4 != 0 {
+		return nil, ErrInvalidSliceSize
+	}
+	if opts.Recovery < 0 || opts.Volumes < 0 {
+		return nil, fmt.Errorf("%w: negative recovery or volume count", ErrTooManyRecovery)
+	}
+	if opts.Creator == "" {
+		opts.Creator = DefaultCreator
+	}
+	baseDir := filepath.Dir(path)
+
+	// First pass: names, sizes and the 16k hashes, which fix the File IDs
+	// and so the slice order
+	s := &Set{Creator: opts.Creator, recovery: map[uint32][]byte{}}
+	paths := map[[16]byte]string{}
+	var total int64
+	for _, p := range files {
+		f, err := describe(baseDir, p)
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := paths[f.ID]; dup {
+			return nil, ErrDuplicateFile
+		}
+		paths[f.ID] = p
+		s.Files = append(s.Files, f)
+		total += f.Size
+	}
+	sortFiles(s.Files)
+
+	s.SliceSize = opts.SliceSize
+	if s.SliceSize == 0 {
+		s.SliceSize = max((total/DefaultSlices+3)&^3, 4)
+	}
+	// Rounding up per file can push a chosen size over the limit
+	for opts.SliceSize == 0 && s.sliceCount() > MaxInputSlices {
+		s.SliceSize *= 2
+	}
+	inputs := s.sliceCount()
+	if inputs > MaxInputSlices {
+		return nil, ErrTooManySlices
+	}
+	if opts.Recovery == 0 {
+		opts.Recovery = max((inputs*DefaultRedundancy+99)/100, 1)
+	}
+	if opts.Recovery > gf65536.Order {
+		return nil, ErrTooManyRecovery
+	}
+	if opts.Volumes == 0 {
+		opts.Volumes = 1
+	}
+	opts.Volumes = min(opts.Volumes, opts.Recovery)
+
+	// Second pass: checksums and recovery slices
+	recovery := make([][]byte, opts.Recovery)
+	for i := range recovery {
+		recovery[i] = make([]byte, s.SliceSize)
+	}
+	bases := inputBases(inputs)
+	slice := make([]byte, s.SliceSize)
+	index := 0
+	for _, f := range s.Files {
+		if err := s.scan(paths[f.ID], f, slice, func(data []byte) {
+			for e := range recovery {
+				gf65536.MulAddSlice(gfPow(bases[index], uint32(e)), data, recovery[e])
+			}
+			index++
+		}); err != nil {
+			return nil, err
+		}
+	}
+	s.ID = md5.Sum(s.mainBody())
+
+	critical := s.criticalPackets()
+	if err := writeFileAtomic(path, critical); err != nil {
+		return nil, err
+	}
+	base := strings.TrimSuffix(path, ".par2")
+	width := len(strconv.Itoa(opts.Recovery))
+	first := 0
+	for v := 0; v < opts.Volumes; v++ {
+		count := opts.Recovery/opts.Volumes + btoi(v < opts.Recovery%opts.Volumes)
+		var out []byte
+		for e := first; e < first+count; e++ {
+			out = append(out, s.recoveryPacket(uint32(e), recovery[e])...)
+			s.recovery[uint32(e)] = recovery[e]
+		}
+		out = append(out, critical...)
+		name := fmt.Sprintf("%s.vol%0*d+%0*d.par2", base, width, first, width, count)
+		if err := writeFileAtomic(name, out); err != nil {
+			return nil, err
+		}
+		first += count
+	}
+	return s, nil
+}
+
+// sliceCount returns the number of input slices of the set's files
+func (s *Set) sliceCount() int {
+	n := 0
+	for _, f := range s.Files {
+		n += int((f.Size + s.SliceSize - 1) / s.SliceSize)
+	}
+	return n
+}
+
+// describe fills in everything about a file that does not depend on the
+// slice size
+func describe(baseDir, path string) (*File, error) {
+	name, err := relName(baseDir, path)
+	if err != nil {
+		return nil, err
+	}
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	fi, err := fh.Stat()
+	if err != nil {
+		return nil, err
+	}
+	h := md5.New()
+	if _, err := io.CopyN(h, fh, hash16kSize); err != nil && err != io.EOF {
+		return nil, err
+	}
+	f := &File{Name: name, Size: fi.Size(), Hash16k: [16]byte(h.Sum(nil))}
+	f.ID = fileID(f.Hash16k, f.Size, f.Name)
+	return f, nil
+}
+
+// scan reads a file slice by slice, filling in its hash and slice
+// checksums and passing every zero-padded slice to fn
+func (s *Set) scan(path string, f *File, slice []byte, fn func([]byte)) error {
+	fh, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+	h := md5.New()
+	f.Slices = make([]SliceChecksum, 0, (f.Size+s.SliceSize-1)/s.SliceSize)
+	for off := int64(0); off < f.Size; off += s.SliceSize {
+		n := min(s.SliceSize, f.Size-off)
+		if _, err := io.ReadFull(fh, slice[:n]); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		h.Write(slice[:n])
+		clear(slice[n:])
+		f.Slices = append(f.Slices, SliceChecksum{MD5: md5.Sum(slice), CRC32: crc32.ChecksumIEEE(slice)})
+		fn(slice)
+	}
+	f.Hash = [16]byte(h.Sum(nil))
+	return nil
+}
+
+// relName returns the name of path relative to baseDir with "/"
+// separators
+func relName(baseDir, path string) (string, error) {
+	absBase, err := filepath.Abs(baseDir)
+	if err != nil {
+		return "", err
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(absBase, absPath)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s", ErrUnsafeName, path)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// localPath maps a recorded name back to a path under baseDir, refusing
+// names that would escape it
+func localPath(baseDir, name string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if name == "" || filepath.IsAbs(clean) || filepath.VolumeName(clean) != "" ||
+		clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %q", ErrUnsafeName, name)
+	}
+	return filepath.Join(baseDir, clean), nil
+}
+
+// writeFileAtomic writes data to a temporary file and renames it into
+// place so a crash never leaves a partial file behind
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,118 @@
+package par2
+
+import (
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// writeFiles creates files of the given sizes filled with random data and
+// returns their paths in name order
+func writeFiles(t testing.TB, dir string, seed int64, sizes map[string]int) []string {
+	t.Helper()
+	rng := rand.New(rand.NewSource(seed))
+	names := make([]string, 0, len(sizes))
+	for name := range sizes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var paths []string
+	for _, name := range names {
+		size := sizes[name]
+		data := make([]byte, size)
+		rng.Read(data)
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func TestCreate_VolumesAndDefaults(t *testing.T) {
+	dir := t.TempDir()
+	files := writeFiles(t, dir, 1, map[string]int{"a.bin": 300_000, "sub/b.bin": 12_345, "empty": 0})
+	s, err := Create(filepath.Join(dir, "set.par2"), files, CreateOptions{Recovery: 12, Volumes: 3})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if s.SliceSize%4 != 0 || s.InputSlices() < DefaultSlices*9/10 || s.InputSlices() > DefaultSlices*11/10 {
+		t.Errorf("default slice size %d gives %d slices", s.SliceSize, s.InputSlices())
+	}
+	for _, name := range []string{"set.par2", "set.vol00+04.par2", "set.vol04+04.par2", "set.vol08+04.par2"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("missing %s: %v", name, err)
+		}
+	}
+
+	// Every volume is self-contained
+	vol, err := Open(filepath.Join(dir, "set.vol04+04.par2"))
+	if err != nil {
+		t.Fatalf("Open(volume) error = %v", err)
+	}
+	if vol.ID != s.ID || vol.RecoverySlices() != 12 {
+		t.Errorf("Open(volume) found %d recovery slices", vol.RecoverySlices())
+	}
+	names := map[string]bool{}
+	for _, f := range vol.Files {
+		names[f.Name] = true
+	}
+	if !names["a.bin"] || !names["sub/b.bin"] || !names["empty"] {
+		t.Errorf("file names = %v", names)
+	}
+
+	os.Remove(filepath.Join(dir, "set.par2"))
+	if _, err := Open(filepath.Join(dir, "set.vol08+04.par2")); err != nil {
+		t.Errorf("Open() without the index file error = %v", err)
+	}
+
+	s, err = Create(filepath.Join(dir, "small.par2"), files[:1], CreateOptions{SliceSize: 4096})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (74 * DefaultRedundancy / 100) + 1; s.RecoverySlices() != want {
+		t.Errorf("default recovery = %d slices, want %d", s.RecoverySlices(), want)
+	}
+}
+
+func TestCreate_Errors(t *testing.T) {
+	dir := t.TempDir()
+	files := writeFiles(t, dir, 2, map[string]int{"a": 100})
+	outside := writeFiles(t, t.TempDir(), 3, map[string]int{"x": 10})
+	par := filepath.Join(dir, "x.par2")
+
+	tests := []struct {
+		files []string
+		opts  CreateOptions
+		want  error
+	}{
+		{nil, CreateOptions{}, ErrNoFiles},
+		{[]string{files[0], files[0]}, CreateOptions{}, ErrDuplicateFile},
+		{outside, CreateOptions{}, ErrUnsafeName},
+		{files, CreateOptions{SliceSize: 6}, ErrInvalidSliceSize},
+		{files, CreateOptions{SliceSize: 4, Recovery: 70_000}, ErrTooManyRecovery},
+		{writeFiles(t, dir, 4, map[string]int{"big": 4*MaxInputSlices + 1}), CreateOptions{SliceSize: 4}, ErrTooManySlices},
+	}
+	for i, tt := range tests {
+		if _, err := Create(par, tt.files, tt.opts); !errors.Is(err, tt.want) {
+			t.Errorf("case %d: Create() error = %v, want %v", i, err, tt.want)
+		}
+	}
+}
+
+func BenchmarkCreate(b *testing.B) {
+	dir := b.TempDir()
+	files := writeFiles(b, dir, 5, map[string]int{"a": 1 << 20})
+	b.SetBytes(1 << 20)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Create(filepath.Join(dir, "bench.par2"), files, CreateOptions{SliceSize: 16 << 10, Recovery: 4})
+	}
+}
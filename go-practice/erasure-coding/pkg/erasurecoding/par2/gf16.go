@@ -0,0 +1,31 @@
+package par2
+
+import "github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/gf65536"
+
+// PAR2 computes over GF(2^16) with the primitive polynomial
+// x^16 + x^12 + x^3 + x + 1 and generator 2, which is exactly the field of
+// package gf65536. Slice data is read as little-endian 16-bit words.
+
+// gfPow returns (2^base)^e, the coefficient of an input slice whose
+// constant has logarithm base in the recovery slice with exponent e
+func gfPow(base int, e uint32) uint16 {
+	return gf65536.Exp(int((uint64(base) * uint64(e)) % gf65536.Order))
+}
+
+// MaxInputSlices is the number of distinct input slice constants PAR2
+// defines, and so the largest number of input slices in a recovery set
+const MaxInputSlices = 32768
+
+// inputBases returns the logarithms of the constants of the first n input
+// slices: the positive integers coprime to 65535, in order. Coprime
+// logarithms make every constant a generator of the field, which keeps
+// the repair matrices invertible in practice.
+func inputBases(n int) []int {
+	bases := make([]int, 0, n)
+	for b := 1; len(bases) < n; b++ {
+		if b%3 != 0 && b%5 != 0 && b%17 != 0 && b%257 != 0 {
+			bases = append(bases, b)
+		}
+	}
+	return bases
+}
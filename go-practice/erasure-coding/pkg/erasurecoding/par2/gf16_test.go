@@ -0,0 +1,60 @@
+package par2
+
+import (
+	"testing"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/gf65536"
+)
+
+func TestGFPow(t *testing.T) {
+	// (2^1)^16 = x^16 = x^12 + x^3 + x + 1
+	if got := gfPow(1, 16); got != 0x100B {
+		t.Errorf("gfPow(1, 16) = %#x, want 0x100b", got)
+	}
+	if got := gfPow(7, 0); got != 1 {
+		t.Errorf("gfPow(7, 0) = %#x, want 1", got)
+	}
+	// The exponent product must not overflow or lose the modulo
+	if got, want := gfPow(65534, 1<<31), gf65536.Exp(int(uint64(65534)*(1<<31)%gf65536.Order)); got != want {
+		t.Errorf("gfPow(65534, 2^31) = %#x, want %#x", got, want)
+	}
+}
+
+func TestInputBases(t *testing.T) {
+	got := inputBases(10)
+	want := []int{1, 2, 4, 7, 8, 11, 13, 14, 16, 19}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("inputBases(10) = %v, want %v", got, want)
+		}
+	}
+	// Exactly 32768 logarithms below 65535 are coprime to it
+	all := inputBases(MaxInputSlices)
+	if last := all[len(all)-1]; last >= gf65536.Order {
+		t.Errorf("base %d of %d is %d, want < 65535", MaxInputSlices, MaxInputSlices, last)
+	}
+}
+
+func TestRepairMatrix_Invertible(t *testing.T) {
+	// Vandermonde-like rows over the first input constants, as Repair
+	// builds them
+	bases := inputBases(5)
+	m := gf65536.NewMatrix(5, 5)
+	for r := range m {
+		for c := range m[r] {
+			m[r][c] = gfPow(bases[c], uint32(r))
+		}
+	}
+	inv, err := m.Invert()
+	if err != nil {
+		t.Fatalf("Invert() error = %v", err)
+	}
+	p, _ := m.Mul(inv)
+	for i := range p {
+		for j := range p[i] {
+			if (i == j && p[i][j] != 1) || (i != j && p[i][j] != 0) {
+				t.Fatalf("M * M^-1 [%d][%d] = %#x", i, j, p[i][j])
+			}
+		}
+	}
+}
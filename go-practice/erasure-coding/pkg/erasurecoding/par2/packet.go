@@ -0,0 +1,137 @@
+// Package par2 creates, verifies and repairs PAR2 recovery files, so
+// archives protected with the standard par2 tools can be checked and
+// repaired here and vice versa.
+//
+// A PAR2 recovery set splits its input files into slices of a fixed size
+// (a multiple of 4 bytes; the last slice of a file is zero-padded). Every
+// slice is a vector of little-endian 16-bit words, and input slice i is
+// assigned the constant c_i = 2^b_i in GF(2^16), where b_i is the i-th
+// positive integer coprime to 65535. Recovery slice e is
+//
+//	R_e = sum over i of c_i^e * D_i
+//
+// so any t missing input slices can be solved for from any t recovery
+// slices. Slices are numbered through the files in the order of their
+// File IDs, as listed in the main packet.
+//
+// Everything in a .par2 file is a packet with a 64-byte header:
+//
+//	magic        "PAR2\0PKT"
+//	length       uint64, whole packet, a multiple of 4
+//	hash         MD5 of the packet from the recovery set ID to the end
+//	recovery set MD5 of the main packet body
+//	type         16 bytes, e.g. "PAR 2.0\0Main"
+//
+// followed by the body. This package writes and reads the main, file
+// description, input file slice checksum (IFSC), recovery slice and
+// creator packets; unknown or damaged packets are skipped on read, as the
+// specification requires.
+package par2
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+)
+
+// Par2Error represents errors returned by the par2 package
+type Par2Error struct {
+	message string
+}
+
+func (e *Par2Error) Error() string {
+	return e.message
+}
+
+// Common errors
+var (
+	ErrNoFiles          = &Par2Error{"at least one input file is required"}
+	ErrDuplicateFile    = &Par2Error{"input file listed twice"}
+	ErrInvalidSliceSize = &Par2Error{"slice size must be a positive multiple of 4"}
+	ErrTooManySlices    = &Par2Error{"more than 32768 input slices"}
+	ErrTooManyRecovery  = &Par2Error{"more than 65535 recovery slices"}
+	ErrUnsafeName       = &Par2Error{"file name escapes the base directory"}
+	ErrNoRecoverySet    = &Par2Error{"no valid main packet found"}
+	ErrIncompleteSet    = &Par2Error{"recovery set is missing file description or checksum packets"}
+	ErrNotRepairable    = &Par2Error{"not enough recovery slices to repair"}
+	ErrSingularMatrix   = &Par2Error{"recovery slices do not determine the missing slices"}
+	ErrRepairFailed     = &Par2Error{"repaired file does not match its hash"}
+)
+
+const headerSize = 64
+
+var magic = []byte("PAR2\x00PKT")
+
+// Packet types
+var (
+	typeMain     = packetType("PAR 2.0\x00Main")
+	typeFileDesc = packetType("PAR 2.0\x00FileDesc")
+	typeIFSC     = packetType("PAR 2.0\x00IFSC")
+	typeRecovery = packetType("PAR 2.0\x00RecvSlic")
+	typeCreator  = packetType("PAR 2.0\x00Creator")
+)
+
+func packetType(s string) (t [16]byte) {
+	copy(t[:], s)
+	return t
+}
+
+// packet is a decoded packet header plus its body
+type packet struct {
+	setID [16]byte
+	typ   [16]byte
+	body  []byte
+}
+
+// encode returns the packet with its header; body must be a multiple of
+// 4 bytes long
+func (p *packet) encode() []byte {
+	buf := make([]byte, headerSize+len(p.body))
+	copy(buf, magic)
+	binary.LittleEndian.PutUint64(buf[8:], uint64(len(buf)))
+	copy(buf[32:], p.setID[:])
+	copy(buf[48:], p.typ[:])
+	copy(buf[64:], p.body)
+	sum := md5.Sum(buf[32:])
+	copy(buf[16:], sum[:])
+	return buf
+}
+
+// readPackets returns every intact packet in data. After a damaged packet
+// it resynchronises on the next magic sequence.
+func readPackets(data []byte) []packet {
+	var packets []packet
+	for {
+		i := bytes.Index(data, magic)
+		if i < 0 {
+			return packets
+		}
+		data = data[i:]
+		if len(data) < headerSize {
+			return packets
+		}
+		n := binary.LittleEndian.Uint64(data[8:])
+		if n < headerSize || n%4 != 0 || n > uint64(len(data)) || md5.Sum(data[32:n]) != [16]byte(data[16:32]) {
+			data = data[len(magic):]
+			continue
+		}
+		packets = append(packets, packet{
+			setID: [16]byte(data[32:48]),
+			typ:   [16]byte(data[48:64]),
+			body:  data[64:n],
+		})
+		data = data[n:]
+	}
+}
+
+// padString returns s padded with zero bytes to a multiple of 4
+func padString(s string) []byte {
+	b := make([]byte, (len(s)+3)&^3)
+	copy(b, s)
+	return b
+}
+
+// unpadString strips the zero padding added by padString
+func unpadString(b []byte) string {
+	return string(bytes.TrimRight(b, "\x00"))
+}
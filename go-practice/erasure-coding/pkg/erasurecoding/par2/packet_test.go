@@ -0,0 +1,135 @@
+package par2
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// copyTestdata copies the golden inputs into a fresh directory
+func copyTestdata(t *testing.T, names ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// The golden files were written by a separate implementation of the PAR2
+// 2.0 specification with slice size 8, three recovery slices and the
+// default creator, for testdata/hello.txt (13 bytes) and data.bin (100
+// bytes)
+func TestCreate_MatchesGoldenFiles(t *testing.T) {
+	dir := copyTestdata(t, "hello.txt", "data.bin")
+	_, err := Create(filepath.Join(dir, "golden.par2"),
+		[]string{filepath.Join(dir, "hello.txt"), filepath.Join(dir, "data.bin")},
+		CreateOptions{SliceSize: 8, Recovery: 3})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	for _, name := range []string{"golden.par2", "golden.vol0+3.par2"} {
+		got, _ := os.ReadFile(filepath.Join(dir, name))
+		want, _ := os.ReadFile(filepath.Join("testdata", name))
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s differs from the golden file (%d vs %d bytes)", name, len(got), len(want))
+		}
+	}
+}
+
+func TestPacketLayout(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "golden.par2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Main packet: 64-byte header, slice size, file count, two File IDs
+	if !bytes.Equal(data[:8], []byte("PAR2\x00PKT")) {
+		t.Fatalf("magic = %q", data[:8])
+	}
+	length := binary.LittleEndian.Uint64(data[8:16])
+	if length != 64+12+2*16 {
+		t.Fatalf("main packet length = %d, want 108", length)
+	}
+	if md5.Sum(data[32:length]) != [16]byte(data[16:32]) {
+		t.Error("packet hash does not cover set ID, type and body")
+	}
+	if !bytes.Equal(data[48:64], []byte("PAR 2.0\x00Main\x00\x00\x00\x00")) {
+		t.Errorf("type = %q", data[48:64])
+	}
+	body := data[64:length]
+	if binary.LittleEndian.Uint64(body) != 8 || binary.LittleEndian.Uint32(body[8:]) != 2 {
+		t.Errorf("main body = %x", body[:12])
+	}
+	if md5.Sum(body) != [16]byte(data[32:48]) {
+		t.Error("recovery set ID is not the MD5 of the main body")
+	}
+
+	packets := readPackets(data)
+	types := map[string]int{}
+	for _, p := range packets {
+		types[string(bytes.TrimRight(p.typ[8:], "\x00"))]++
+		if len(p.body)%4 != 0 {
+			t.Errorf("%q body is %d bytes, not a multiple of 4", p.typ, len(p.body))
+		}
+	}
+	if types["Main"] != 1 || types["FileDesc"] != 2 || types["IFSC"] != 2 || types["Creator"] != 1 {
+		t.Errorf("index file packets = %v", types)
+	}
+
+	// File description of hello.txt: the name is padded to 12 bytes and
+	// the File ID is MD5(hash16k || length || name)
+	for _, p := range packets {
+		if p.typ != typeFileDesc || unpadString(p.body[56:]) != "hello.txt" {
+			continue
+		}
+		if len(p.body) != 56+12 || binary.LittleEndian.Uint64(p.body[48:]) != 13 {
+			t.Errorf("hello.txt description = %x", p.body)
+		}
+		if fileID([16]byte(p.body[32:48]), 13, "hello.txt") != [16]byte(p.body[:16]) {
+			t.Error("File ID mismatch")
+		}
+	}
+}
+
+func TestReadPackets_SkipsDamage(t *testing.T) {
+	data, _ := os.ReadFile(filepath.Join("testdata", "golden.par2"))
+	want := len(readPackets(data))
+
+	// Garbage in front, a corrupted first packet and a truncated tail
+	damaged := append([]byte("junk PAR2 junk"), data...)
+	damaged[14+70] ^= 0xFF
+	damaged = append(damaged, data[:100]...)
+	if got := len(readPackets(damaged)); got != want-1 {
+		t.Errorf("readPackets(damaged) = %d packets, want %d", got, want-1)
+	}
+	if _, err := loadSet(readPackets(damaged)); err != ErrNoRecoverySet {
+		t.Errorf("loadSet(without main) error = %v, want %v", err, ErrNoRecoverySet)
+	}
+}
+
+func TestOpen_GoldenSet(t *testing.T) {
+	s, err := Open(filepath.Join("testdata", "golden.vol0+3.par2"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if s.SliceSize != 8 || len(s.Files) != 2 || s.InputSlices() != 2+13 || s.RecoverySlices() != 3 {
+		t.Errorf("Open() = slice %d, %d files, %d input, %d recovery slices",
+			s.SliceSize, len(s.Files), s.InputSlices(), s.RecoverySlices())
+	}
+	if s.Creator != DefaultCreator {
+		t.Errorf("Creator = %q", s.Creator)
+	}
+	report, err := s.Verify("testdata")
+	if err != nil || !report.Complete() {
+		t.Errorf("Verify(testdata) = %+v, %v", report, err)
+	}
+}
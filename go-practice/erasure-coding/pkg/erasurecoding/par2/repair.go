@@ -0,0 +1,250 @@
+package par2
+
+import (
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/gf65536"
+)
+
+// FileStatus is the verification result for one input file
+type FileStatus struct {
+	Name string
+	// Found is false if the file does not exist
+	Found bool
+	// Complete is true if the file matches its size and hash
+	Complete bool
+	// Damaged lists the slices (numbered within the file) that are
+	// missing or fail their checksums
+	Damaged []int
+}
+
+// Report is the result of Verify or Repair
+type Report struct {
+	Files []FileStatus
+	// Missing is the number of input slices that need recovering
+	Missing int
+	// Available is the number of recovery slices in the set
+	Available int
+	// Repaired lists the names of the files Repair rewrote
+	Repaired []string
+}
+
+// Complete reports whether every input file is intact
+func (r *Report) Complete() bool {
+	for _, f := range r.Files {
+		if !f.Complete {
+			return false
+		}
+	}
+	return true
+}
+
+// Repairable reports whether there are enough recovery slices for the
+// damage found
+func (r *Report) Repairable() bool {
+	return r.Missing <= r.Available
+}
+
+// Verify checks the input files found under baseDir against the set
+//
+// Slices are only checked at their own offsets; data shifted by inserted
+// or deleted bytes counts as damaged.
+//
+// Errors:
+//   - ErrUnsafeName if a recorded file name points outside baseDir
+func (s *Set) Verify(baseDir string) (*Report, error) {
+	report := &Report{Available: len(s.recovery)}
+	slice := make([]byte, s.SliceSize)
+	for _, f := range s.Files {
+		path, err := localPath(baseDir, f.Name)
+		if err != nil {
+			return nil, err
+		}
+		st, err := s.check(path, f, slice, nil)
+		if err != nil {
+			return nil, err
+		}
+		report.Files = append(report.Files, *st)
+		report.Missing += len(st.Damaged)
+	}
+	return report, nil
+}
+
+// check verifies one file. Intact slices are passed to fn together with
+// their index within the file.
+func (s *Set) check(path string, f *File, slice []byte, fn func(i int, data []byte)) (*FileStatus, error) {
+	st := &FileStatus{Name: f.Name}
+	fh, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		for i := range f.Slices {
+			st.Damaged = append(st.Damaged, i)
+		}
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	st.Found = true
+
+	h := md5.New()
+	size := int64(0)
+	for i, sum := range f.Slices {
+		off := int64(i) * s.SliceSize
+		want := min(s.SliceSize, f.Size-off)
+		n, err := io.ReadFull(fh, slice[:want])
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, err
+		}
+		h.Write(slice[:n])
+		size += int64(n)
+		clear(slice[n:])
+		if int64(n) != want || md5.Sum(slice) != sum.MD5 || crc32.ChecksumIEEE(slice) != sum.CRC32 {
+			st.Damaged = append(st.Damaged, i)
+			continue
+		}
+		if fn != nil {
+			fn(i, slice)
+		}
+	}
+	// Trailing bytes beyond the recorded size also spoil the file
+	extra, err := io.Copy(h, fh)
+	if err != nil {
+		return nil, err
+	}
+	st.Complete = len(st.Damaged) == 0 && extra == 0 && size == f.Size && [16]byte(h.Sum(nil)) == f.Hash
+	return st, nil
+}
+
+// Repair verifies the input files under baseDir and rewrites every file
+// that is missing or damaged
+//
+// The missing slices are solved for from as many recovery slices: each
+// recovery slice minus the contribution of the intact slices leaves a
+// linear combination of the missing ones. Memory use is two slices per
+// missing slice, plus the damaged file being rewritten.
+//
+// Errors:
+//   - ErrNotRepairable (wrapped) if more slices are missing than there
+//     are recovery slices
+//   - ErrSingularMatrix if the chosen recovery slices are not independent
+//   - ErrRepairFailed if a rewritten file does not match its hash
+func (s *Set) Repair(baseDir string) (*Report, error) {
+	report, err := s.Verify(baseDir)
+	if err != nil || report.Complete() {
+		return report, err
+	}
+	if !report.Repairable() {
+		return report, fmt.Errorf("%w: %d slices missing, %d recovery slices", ErrNotRepairable, report.Missing, report.Available)
+	}
+
+	// Global indices of the missing slices, and the recovery exponents
+	// used to solve for them
+	first := make([]int, len(s.Files))
+	var missing []int
+	total := 0
+	for i, f := range s.Files {
+		first[i] = total
+		for _, j := range report.Files[i].Damaged {
+			missing = append(missing, total+j)
+		}
+		total += len(f.Slices)
+	}
+	exps := make([]uint32, 0, len(s.recovery))
+	for e := range s.recovery {
+		exps = append(exps, e)
+	}
+	sort.Slice(exps, func(i, j int) bool { return exps[i] < exps[j] })
+	exps = exps[:len(missing)]
+	bases := inputBases(total)
+
+	// Subtract the intact slices from the recovery slices
+	rhs := make([][]byte, len(missing))
+	for r, e := range exps {
+		rhs[r] = append([]byte(nil), s.recovery[e]...)
+	}
+	slice := make([]byte, s.SliceSize)
+	for i, f := range s.Files {
+		path, _ := localPath(baseDir, f.Name)
+		_, err := s.check(path, f, slice, func(j int, data []byte) {
+			for r, e := range exps {
+				gf65536.MulAddSlice(gfPow(bases[first[i]+j], e), data, rhs[r])
+			}
+		})
+		if err != nil {
+			return report, err
+		}
+	}
+
+	m := gf65536.NewMatrix(len(missing), len(missing))
+	for r, e := range exps {
+		for c, idx := range missing {
+			m[r][c] = gfPow(bases[idx], e)
+		}
+	}
+	inv, err := m.Invert()
+	if err != nil {
+		return report, ErrSingularMatrix
+	}
+	recovered := map[int][]byte{}
+	for c, idx := range missing {
+		out := make([]byte, s.SliceSize)
+		for r := range rhs {
+			gf65536.MulAddSlice(inv[c][r], rhs[r], out)
+		}
+		recovered[idx] = out
+	}
+
+	for i, f := range s.Files {
+		if report.Files[i].Complete {
+			continue
+		}
+		path, _ := localPath(baseDir, f.Name)
+		if err := s.rewrite(path, f, first[i], recovered); err != nil {
+			return report, err
+		}
+		report.Repaired = append(report.Repaired, f.Name)
+	}
+	final, err := s.Verify(baseDir)
+	if err != nil {
+		return report, err
+	}
+	final.Repaired = report.Repaired
+	if !final.Complete() {
+		return final, ErrRepairFailed
+	}
+	return final, nil
+}
+
+// rewrite rebuilds a file from its intact slices on disk and the
+// recovered ones, checking the result against the file's hash
+func (s *Set) rewrite(path string, f *File, first int, recovered map[int][]byte) error {
+	old, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	data := make([]byte, f.Size)
+	for i := range f.Slices {
+		off := int64(i) * s.SliceSize
+		end := min(off+s.SliceSize, f.Size)
+		if r, ok := recovered[first+i]; ok {
+			copy(data[off:end], r)
+		} else {
+			copy(data[off:end], old[off:end])
+		}
+	}
+	if md5.Sum(data) != f.Hash {
+		return fmt.Errorf("%w: %s", ErrRepairFailed, f.Name)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
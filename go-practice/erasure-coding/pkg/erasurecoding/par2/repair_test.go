@@ -0,0 +1,117 @@
+package par2
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRepair_GoldenSet(t *testing.T) {
+	dir := copyTestdata(t, "hello.txt", "data.bin", "golden.par2", "golden.vol0+3.par2")
+	want, _ := os.ReadFile(filepath.Join(dir, "data.bin"))
+
+	// Three damaged slices: two flipped bytes and a truncated tail
+	damaged := append([]byte(nil), want...)
+	damaged[3] ^= 1
+	damaged[50] ^= 0x80
+	os.WriteFile(filepath.Join(dir, "data.bin"), damaged[:97], 0o644)
+
+	s, err := Open(filepath.Join(dir, "golden.par2"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	report, err := s.Verify(dir)
+	if err != nil || report.Complete() || report.Missing != 3 || !report.Repairable() {
+		t.Fatalf("Verify() = %+v, %v", report, err)
+	}
+	report, err = s.Repair(dir)
+	if err != nil || !report.Complete() || len(report.Repaired) != 1 || report.Repaired[0] != "data.bin" {
+		t.Fatalf("Repair() = %+v, %v", report, err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "data.bin")); !bytes.Equal(got, want) {
+		t.Error("repaired data.bin differs from the original")
+	}
+}
+
+func TestRepair_MissingAndDamagedFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := writeFiles(t, dir, 10, map[string]int{"a": 5000, "b/c": 2222, "d": 1, "e": 0})
+	originals := map[string][]byte{}
+	for _, p := range files {
+		originals[p], _ = os.ReadFile(p)
+	}
+	s, err := Create(filepath.Join(dir, "r.par2"), files, CreateOptions{SliceSize: 256, Recovery: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 8 damaged slices in all: d is gone (1), e is gone (none, but the
+	// file must be recreated), a has 2 flipped bytes and trailing junk,
+	// and b/c has 5 zeroed slices
+	os.Remove(filepath.Join(dir, "d"))
+	os.Remove(filepath.Join(dir, "e"))
+	a := append([]byte(nil), originals[filepath.Join(dir, "a")]...)
+	a[0], a[4999] = a[0]^1, a[4999]^1
+	os.WriteFile(filepath.Join(dir, "a"), append(a, "trailing"...), 0o644)
+	c := append([]byte(nil), originals[filepath.Join(dir, "b", "c")]...)
+	copy(c[800:], make([]byte, 1000)) // slices 3..7
+	os.WriteFile(filepath.Join(dir, "b", "c"), c, 0o644)
+
+	report, err := s.Repair(dir)
+	if err != nil {
+		t.Fatalf("Repair() error = %v (%+v)", err, report)
+	}
+	if len(report.Repaired) != 4 {
+		t.Errorf("Repaired = %v, want 4 files", report.Repaired)
+	}
+	for p, want := range originals {
+		if got, err := os.ReadFile(p); err != nil || !bytes.Equal(got, want) {
+			t.Errorf("%s not restored: %v", p, err)
+		}
+	}
+}
+
+func TestRepair_NotEnoughRecovery(t *testing.T) {
+	dir := t.TempDir()
+	files := writeFiles(t, dir, 11, map[string]int{"a": 4096})
+	s, _ := Create(filepath.Join(dir, "r.par2"), files, CreateOptions{SliceSize: 512, Recovery: 2})
+	os.Remove(files[0])
+
+	report, err := s.Repair(dir)
+	if !errors.Is(err, ErrNotRepairable) || report.Missing != 8 || report.Repairable() {
+		t.Errorf("Repair() = %+v, %v, want ErrNotRepairable", report, err)
+	}
+	if _, err := os.Stat(files[0]); !os.IsNotExist(err) {
+		t.Error("failed Repair() created the file")
+	}
+}
+
+func TestVerify_RejectsUnsafeNames(t *testing.T) {
+	s := &Set{SliceSize: 4, Files: []*File{{Name: "../escape"}}}
+	if _, err := s.Verify(t.TempDir()); !errors.Is(err, ErrUnsafeName) {
+		t.Errorf("Verify(../escape) error = %v, want ErrUnsafeName", err)
+	}
+	s.Files[0].Name = "/etc/passwd"
+	if _, err := s.Verify(t.TempDir()); !errors.Is(err, ErrUnsafeName) {
+		t.Errorf("Verify(/etc/passwd) error = %v, want ErrUnsafeName", err)
+	}
+}
+
+func BenchmarkRepair(b *testing.B) {
+	dir := b.TempDir()
+	files := writeFiles(b, dir, 12, map[string]int{"a": 1 << 20})
+	orig, _ := os.ReadFile(files[0])
+	s, _ := Create(filepath.Join(dir, "r.par2"), files, CreateOptions{SliceSize: 16 << 10, Recovery: 4})
+	damaged := append([]byte(nil), orig...)
+	for i := 0; i < 4; i++ {
+		damaged[i*200_000] ^= 1
+	}
+	b.SetBytes(1 << 20)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		os.WriteFile(files[0], damaged, 0o644)
+		s.Repair(dir)
+	}
+}
@@ -0,0 +1,249 @@
+package par2
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Set is a PAR2 recovery set: the description of its input files and the
+// recovery slices found for it
+type Set struct {
+	// ID is the recovery set ID, the MD5 of the main packet body
+	ID        [16]byte
+	SliceSize int64
+	// Files are the input files in File ID order, which is also the order
+	// in which their slices are numbered
+	Files   []*File
+	Creator string
+
+	// recovery maps an exponent to its recovery slice
+	recovery map[uint32][]byte
+}
+
+// File describes one input file of a recovery set
+type File struct {
+	// ID is the MD5 of Hash16k, the length and the name
+	ID      [16]byte
+	Hash    [16]byte
+	Hash16k [16]byte
+	Size    int64
+	// Name is the path relative to the base directory, with "/" separators
+	Name   string
+	Slices []SliceChecksum
+}
+
+// SliceChecksum holds the checksums of one (zero-padded) input slice
+type SliceChecksum struct {
+	MD5   [16]byte
+	CRC32 uint32
+}
+
+// InputSlices returns the total number of input slices
+func (s *Set) InputSlices() int {
+	n := 0
+	for _, f := range s.Files {
+		n += len(f.Slices)
+	}
+	return n
+}
+
+// RecoverySlices returns the number of recovery slices available
+func (s *Set) RecoverySlices() int {
+	return len(s.recovery)
+}
+
+// fileID computes the File ID of a file
+func fileID(hash16k [16]byte, size int64, name string) [16]byte {
+	buf := make([]byte, 0, 24+len(name))
+	buf = append(buf, hash16k[:]...)
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(size))
+	buf = append(buf, name...)
+	return md5.Sum(buf)
+}
+
+// mainBody encodes the main packet body; its MD5 is the set ID
+func (s *Set) mainBody() []byte {
+	body := make([]byte, 12, 12+16*len(s.Files))
+	binary.LittleEndian.PutUint64(body, uint64(s.SliceSize))
+	binary.LittleEndian.PutUint32(body[8:], uint32(len(s.Files)))
+	for _, f := range s.Files {
+		body = append(body, f.ID[:]...)
+	}
+	return body
+}
+
+// criticalPackets returns the encoded main, file description, IFSC and
+// creator packets, which every file of the set repeats
+func (s *Set) criticalPackets() []byte {
+	var out []byte
+	out = append(out, (&packet{setID: s.ID, typ: typeMain, body: s.mainBody()}).encode()...)
+	for _, f := range s.Files {
+		body := make([]byte, 0, 56+len(f.Name)+3)
+		body = append(body, f.ID[:]...)
+		body = append(body, f.Hash[:]...)
+		body = append(body, f.Hash16k[:]...)
+		body = binary.LittleEndian.AppendUint64(body, uint64(f.Size))
+		body = append(body, padString(f.Name)...)
+		out = append(out, (&packet{setID: s.ID, typ: typeFileDesc, body: body}).encode()...)
+	}
+	for _, f := range s.Files {
+		body := make([]byte, 0, 16+20*len(f.Slices))
+		body = append(body, f.ID[:]...)
+		for _, c := range f.Slices {
+			body = append(body, c.MD5[:]...)
+			body = binary.LittleEndian.AppendUint32(body, c.CRC32)
+		}
+		out = append(out, (&packet{setID: s.ID, typ: typeIFSC, body: body}).encode()...)
+	}
+	out = append(out, (&packet{setID: s.ID, typ: typeCreator, body: padString(s.Creator)}).encode()...)
+	return out
+}
+
+// recoveryPacket encodes the recovery slice with exponent e
+func (s *Set) recoveryPacket(e uint32, data []byte) []byte {
+	body := make([]byte, 4+len(data))
+	binary.LittleEndian.PutUint32(body, e)
+	copy(body[4:], data)
+	return (&packet{setID: s.ID, typ: typeRecovery, body: body}).encode()
+}
+
+// volumeSuffix matches the ".volNN+MM" part of a recovery volume name
+var volumeSuffix = regexp.MustCompile(`\.vol\d+\+\d+$`)
+
+// Open reads a PAR2 file together with the other volumes of its set:
+// for "x.par2" or "x.volNN+MM.par2" it also reads "x.par2" and every
+// "x.vol*.par2" in the same directory
+//
+// Errors:
+//   - ErrNoRecoverySet if no intact main packet is found
+//   - ErrIncompleteSet if a file's description or checksums are missing
+func Open(path string) (*Set, error) {
+	base := volumeSuffix.ReplaceAllString(strings.TrimSuffix(path, ".par2"), "")
+	paths := []string{path}
+	if path != base+".par2" {
+		paths = append(paths, base+".par2")
+	}
+	vols, err := filepath.Glob(escapeGlob(base) + ".vol*.par2")
+	if err != nil {
+		return nil, err
+	}
+	paths = append(paths, vols...)
+
+	var packets []packet
+	seen := map[string]bool{}
+	for _, p := range paths {
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		data, err := os.ReadFile(p)
+		if err != nil {
+			if p == path {
+				return nil, err
+			}
+			continue
+		}
+		packets = append(packets, readPackets(data)...)
+	}
+	return loadSet(packets)
+}
+
+// escapeGlob quotes the glob metacharacters in a path
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// loadSet assembles a recovery set from packets. The first intact main
+// packet decides the set; packets of other sets are ignored.
+func loadSet(packets []packet) (*Set, error) {
+	s := &Set{recovery: map[uint32][]byte{}}
+	var ids [][16]byte
+	found := false
+	for _, p := range packets {
+		if p.typ != typeMain || len(p.body) < 12 || md5.Sum(p.body) != p.setID {
+			continue
+		}
+		size := binary.LittleEndian.Uint64(p.body)
+		n := binary.LittleEndian.Uint32(p.body[8:])
+		if size == 0 || size%4 != 0 || size > 1<<40 || uint64(len(p.body)-12) < 16*uint64(n) {
+			continue
+		}
+		s.ID, s.SliceSize = p.setID, int64(size)
+		for i := 0; i < int(n); i++ {
+			ids = append(ids, [16]byte(p.body[12+16*i:]))
+		}
+		found = true
+		break
+	}
+	if !found {
+		return nil, ErrNoRecoverySet
+	}
+
+	descs := map[[16]byte]*File{}
+	checksums := map[[16]byte][]SliceChecksum{}
+	for _, p := range packets {
+		if p.setID != s.ID {
+			continue
+		}
+		switch p.typ {
+		case typeFileDesc:
+			if len(p.body) < 56 {
+				continue
+			}
+			f := &File{
+				ID:      [16]byte(p.body[0:16]),
+				Hash:    [16]byte(p.body[16:32]),
+				Hash16k: [16]byte(p.body[32:48]),
+				Size:    int64(binary.LittleEndian.Uint64(p.body[48:56])),
+				Name:    unpadString(p.body[56:]),
+			}
+			descs[f.ID] = f
+		case typeIFSC:
+			if len(p.body) < 16 || (len(p.body)-16)%20 != 0 {
+				continue
+			}
+			var sums []SliceChecksum
+			for b := p.body[16:]; len(b) > 0; b = b[20:] {
+				sums = append(sums, SliceChecksum{MD5: [16]byte(b[:16]), CRC32: binary.LittleEndian.Uint32(b[16:20])})
+			}
+			checksums[[16]byte(p.body[0:16])] = sums
+		case typeRecovery:
+			if int64(len(p.body)) != 4+s.SliceSize {
+				continue
+			}
+			s.recovery[binary.LittleEndian.Uint32(p.body)] = p.body[4:]
+		case typeCreator:
+			s.Creator = unpadString(p.body)
+		}
+	}
+
+	for _, id := range ids {
+		f, ok := descs[id]
+		sums, ok2 := checksums[id]
+		if !ok || !ok2 || int64(len(sums)) != (f.Size+s.SliceSize-1)/s.SliceSize {
+			return nil, ErrIncompleteSet
+		}
+		f.Slices = sums
+		s.Files = append(s.Files, f)
+	}
+	return s, nil
+}
+
+// sortFiles orders files by File ID, as the main packet requires
+func sortFiles(files []*File) {
+	sort.Slice(files, func(i, j int) bool {
+		return string(files[i].ID[:]) < string(files[j].ID[:])
+	})
+}
@@ -0,0 +1,136 @@
+package phase1
+
+import (
+	"io"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/codec"
+)
+
+// Range read errors
+var (
+	ErrShardUnavailable = &XorError{"shard is unavailable"}
+	ErrNegativeOffset   = &XorError{"read offset cannot be negative"}
+	ErrInvalidLayout    = &XorError{"invalid encoded object layout"}
+)
+
+// ShardSource provides random access to the chunks of an encoded object
+//
+// Shards are numbered 0..numChunks-1 for the data chunks and numChunks for
+// the parity chunk. Every shard is exactly chunkSize bytes long, including
+// any zero padding at the end of the last data chunks.
+//
+// ReadShardAt follows io.ReaderAt semantics. Implementations should return
+// ErrShardUnavailable (or any other error) when the shard cannot be read;
+// the RangeReader then falls back to parity-based reconstruction.
+type ShardSource interface {
+	ReadShardAt(shard int, p []byte, off int64) (int, error)
+}
+
+// RangeReader reads byte ranges of an encoded object without decoding it
+//
+// A byte at object offset o lives in data chunk o/chunkSize at offset
+// o%chunkSize. Only the chunks covering the requested range are read. When
+// one of them is unavailable, the same region of every other chunk and the
+// parity chunk is read and XORed together, which rebuilds just the affected
+// part of the stripe instead of the whole object.
+//
+// It is a codec.RangeReader over an "xor:k+1" codec, which is the same
+// single-parity code that Encode produces.
+//
+// RangeReader implements io.ReaderAt and is safe for concurrent use if the
+// underlying ShardSource is.
+type RangeReader struct {
+	r *codec.RangeReader
+}
+
+// NewRangeReader creates a RangeReader over an encoded object
+//
+// Arguments:
+//   - src: Source of the data and parity chunks
+//   - numChunks: Number of data chunks the object was encoded with
+//   - chunkSize: Size of each chunk in bytes
+//   - size: Original (unpadded) object size
+//
+// Errors:
+//   - ErrInvalidLayout if the layout cannot hold size bytes
+func NewRangeReader(src ShardSource, numChunks, chunkSize int, size int64) (*RangeReader, error) {
+	if numChunks < 2 || chunkSize < 0 || size < 0 || size > int64(numChunks)*int64(chunkSize) {
+		return nil, ErrInvalidLayout
+	}
+	xor, err := codec.NewXor(numChunks)
+	if err != nil {
+		return nil, ErrInvalidLayout
+	}
+	r, err := codec.NewRangeReader(xor, src, chunkSize, size)
+	if err != nil {
+		return nil, ErrInvalidLayout
+	}
+	return &RangeReader{r: r}, nil
+}
+
+// NewReaderAt returns a RangeReader over an in-memory encoded object
+//
+// Data chunks set to nil are treated as unavailable and are reconstructed
+// from the parity chunk on demand.
+func NewReaderAt(encoded *XorEncoded, originalSize int) (*RangeReader, error) {
+	return NewRangeReader(EncodedSource(encoded), len(encoded.DataChunks), encoded.ChunkSize, int64(originalSize))
+}
+
+// NewSectionReader returns an io.SectionReader over bytes [off, off+n) of
+// the object, so it can be handed to code expecting an io.Reader or io.Seeker
+func (r *RangeReader) NewSectionReader(off, n int64) *io.SectionReader {
+	return io.NewSectionReader(r, off, n)
+}
+
+// Size returns the original (unpadded) object size
+func (r *RangeReader) Size() int64 {
+	return r.r.Size()
+}
+
+// ReadAt reads len(p) bytes of the object starting at offset off
+//
+// It returns io.EOF when the range extends past the end of the object.
+// If a needed chunk is unavailable and cannot be reconstructed, the error
+// from the reconstruction attempt is returned together with the number of
+// bytes read before the failing chunk.
+func (r *RangeReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, ErrNegativeOffset
+	}
+	return r.r.ReadAt(p, off)
+}
+
+// EncodedSource adapts an in-memory XorEncoded to a ShardSource
+//
+// Chunks that are nil (including a nil ParityChunk) report
+// ErrShardUnavailable.
+func EncodedSource(encoded *XorEncoded) ShardSource {
+	return encodedSource{encoded}
+}
+
+type encodedSource struct {
+	encoded *XorEncoded
+}
+
+func (s encodedSource) ReadShardAt(shard int, p []byte, off int64) (int, error) {
+	var chunk []byte
+	switch {
+	case shard >= 0 && shard < len(s.encoded.DataChunks):
+		chunk = s.encoded.DataChunks[shard]
+	case shard == len(s.encoded.DataChunks):
+		chunk = s.encoded.ParityChunk
+	default:
+		return 0, ErrInvalidChunkIndex
+	}
+	if chunk == nil {
+		return 0, ErrShardUnavailable
+	}
+	if off >= int64(len(chunk)) {
+		return 0, io.EOF
+	}
+	n := copy(p, chunk[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
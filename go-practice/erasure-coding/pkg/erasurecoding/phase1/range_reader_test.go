@@ -0,0 +1,179 @@
+package phase1
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+)
+
+// countingSource records which shards were read
+type countingSource struct {
+	ShardSource
+	reads map[int]int
+}
+
+func (s *countingSource) ReadShardAt(shard int, p []byte, off int64) (int, error) {
+	s.reads[shard]++
+	return s.ShardSource.ReadShardAt(shard, p, off)
+}
+
+func TestRangeReader_AllRanges(t *testing.T) {
+	data := []byte("The quick brown fox jumps over the lazy dog")
+	encoded, err := Encode(data, 4)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	r, err := NewReaderAt(encoded, len(data))
+	if err != nil {
+		t.Fatalf("NewReaderAt() error = %v", err)
+	}
+
+	for off := 0; off < len(data); off++ {
+		for n := 1; off+n <= len(data); n++ {
+			buf := make([]byte, n)
+			got, err := r.ReadAt(buf, int64(off))
+			if err != nil || got != n {
+				t.Fatalf("ReadAt(off=%d, n=%d) = %d, %v", off, n, got, err)
+			}
+			if !bytes.Equal(buf, data[off:off+n]) {
+				t.Fatalf("ReadAt(off=%d, n=%d) = %q, want %q", off, n, buf, data[off:off+n])
+			}
+		}
+	}
+}
+
+func TestRangeReader_ReadsOnlyNeededChunks(t *testing.T) {
+	data := []byte("AAAABBBBCCCCDDDD")
+	encoded, _ := Encode(data, 4)
+	src := &countingSource{EncodedSource(encoded), map[int]int{}}
+	r, _ := NewRangeReader(src, 4, encoded.ChunkSize, int64(len(data)))
+
+	buf := make([]byte, 3)
+	if _, err := r.ReadAt(buf, 5); err != nil {
+		t.Fatalf("ReadAt() error = %v", err)
+	}
+	if string(buf) != "BBB" {
+		t.Errorf("ReadAt() = %q, want %q", buf, "BBB")
+	}
+	if len(src.reads) != 1 || src.reads[1] != 1 {
+		t.Errorf("ReadAt() read shards %v, want only shard 1", src.reads)
+	}
+}
+
+func TestRangeReader_DegradedRead(t *testing.T) {
+	data := []byte("TEST DATA FOR DEGRADED RANGE READS")
+	numChunks := 5
+
+	for lost := 0; lost < numChunks; lost++ {
+		t.Run(fmt.Sprintf("lost_%d", lost), func(t *testing.T) {
+			encoded, _ := Encode(data, numChunks)
+			encoded.DataChunks[lost] = nil
+			r, _ := NewReaderAt(encoded, len(data))
+
+			buf := make([]byte, len(data))
+			n, err := r.ReadAt(buf, 0)
+			if err != nil || n != len(data) {
+				t.Fatalf("ReadAt() = %d, %v", n, err)
+			}
+			if !bytes.Equal(buf, data) {
+				t.Errorf("ReadAt() = %q, want %q", buf, data)
+			}
+		})
+	}
+}
+
+func TestRangeReader_TooManyLost(t *testing.T) {
+	data := []byte("TWO CHUNKS LOST")
+	encoded, _ := Encode(data, 3)
+	encoded.DataChunks[0] = nil
+	encoded.ParityChunk = nil
+	r, _ := NewReaderAt(encoded, len(data))
+
+	// Chunk 1 is intact, so reading only from it still works
+	buf := make([]byte, 2)
+	if _, err := r.ReadAt(buf, int64(encoded.ChunkSize)); err != nil {
+		t.Errorf("ReadAt(intact chunk) error = %v", err)
+	}
+
+	if _, err := r.ReadAt(buf, 0); err == nil {
+		t.Error("ReadAt(lost chunk without parity) expected error")
+	}
+}
+
+func TestRangeReader_EOF(t *testing.T) {
+	data := []byte("HELLO WORLD")
+	encoded, _ := Encode(data, 3)
+	r, _ := NewReaderAt(encoded, len(data))
+
+	buf := make([]byte, 10)
+	n, err := r.ReadAt(buf, 6)
+	if n != 5 || err != io.EOF {
+		t.Errorf("ReadAt(past end) = %d, %v, want 5, EOF", n, err)
+	}
+	if string(buf[:n]) != "WORLD" {
+		t.Errorf("ReadAt(past end) = %q, want %q", buf[:n], "WORLD")
+	}
+
+	if _, err := r.ReadAt(buf, int64(len(data))); err != io.EOF {
+		t.Errorf("ReadAt(at end) error = %v, want EOF", err)
+	}
+	if _, err := r.ReadAt(buf, -1); err != ErrNegativeOffset {
+		t.Errorf("ReadAt(-1) error = %v, want %v", err, ErrNegativeOffset)
+	}
+}
+
+func TestRangeReader_SectionReader(t *testing.T) {
+	data := []byte("HELLO WORLD")
+	encoded, _ := Encode(data, 3)
+	encoded.DataChunks[1] = nil
+	r, _ := NewReaderAt(encoded, len(data))
+
+	got, err := io.ReadAll(r.NewSectionReader(3, 5))
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	if string(got) != "LO WO" {
+		t.Errorf("ReadAll() = %q, want %q", got, "LO WO")
+	}
+}
+
+func TestNewRangeReader_InvalidLayout(t *testing.T) {
+	if _, err := NewRangeReader(nil, 3, 4, 13); err != ErrInvalidLayout {
+		t.Errorf("NewRangeReader(size too large) error = %v, want %v", err, ErrInvalidLayout)
+	}
+	if _, err := NewRangeReader(nil, 1, 4, 4); err != ErrInvalidLayout {
+		t.Errorf("NewRangeReader(1 chunk) error = %v, want %v", err, ErrInvalidLayout)
+	}
+}
+
+// Benchmark a small range read against a full decode
+func BenchmarkRangeReader_ReadAt(b *testing.B) {
+	data := bytes.Repeat([]byte("benchmark data "), 1000) // ~15KB
+	encoded, _ := Encode(data, 5)
+	r, _ := NewReaderAt(encoded, len(data))
+	buf := make([]byte, 64)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, _ = r.ReadAt(buf, 7000)
+	}
+}
+
+// Example demonstrates reading a byte range with a lost chunk
+func ExampleRangeReader_ReadAt() {
+	data := []byte("HELLO WORLD")
+	encoded, _ := Encode(data, 3)
+
+	// Simulate losing chunk 1; the range is rebuilt from parity
+	encoded.DataChunks[1] = nil
+	r, _ := NewReaderAt(encoded, len(data))
+
+	buf := make([]byte, 5)
+	if _, err := r.ReadAt(buf, 6); err != nil {
+		panic(err)
+	}
+	fmt.Println(string(buf))
+	// Output:
+	// WORLD
+}
@@ -111,6 +111,66 @@ func Encode(data []byte, numChunks int) (*XorEncoded, error) {
 	}, nil
 }
 
+// EncodeInto is Encode writing into the buffers of an existing XorEncoded
+//
+// Chunk buffers in encoded (DataChunks and ParityChunk) are reused when
+// they are large enough and replaced otherwise, so encoding a stream of
+// similarly sized objects into one XorEncoded stops allocating once it has
+// seen the largest object.
+//
+// Arguments:
+//   - data: The input data to encode
+//   - numChunks: Number of data chunks to split into (must be >= 2)
+//   - encoded: Destination, overwritten; may be a zero XorEncoded
+//
+// Errors:
+//   - ErrEmptyData if input is empty
+//   - ErrInvalidChunkCount if numChunks < 2
+func EncodeInto(data []byte, numChunks int, encoded *XorEncoded) error {
+	if len(data) == 0 {
+		return ErrEmptyData
+	}
+	if numChunks < 2 {
+		return ErrInvalidChunkCount
+	}
+
+	chunkSize := (len(data) + numChunks - 1) / numChunks
+
+	// Keep the chunk list itself when it is long enough
+	if cap(encoded.DataChunks) >= numChunks {
+		encoded.DataChunks = encoded.DataChunks[:numChunks]
+	} else {
+		encoded.DataChunks = append(encoded.DataChunks[:cap(encoded.DataChunks)], make([][]byte, numChunks-cap(encoded.DataChunks))...)
+	}
+
+	for i := range encoded.DataChunks {
+		chunk := reuseChunk(encoded.DataChunks[i], chunkSize)
+		n := 0
+		if start := i * chunkSize; start < len(data) {
+			n = copy(chunk, data[start:])
+		}
+		// Zero the padding, which may hold bytes of an earlier object
+		clear(chunk[n:])
+		encoded.DataChunks[i] = chunk
+	}
+
+	parity := reuseChunk(encoded.ParityChunk, chunkSize)
+	clear(parity)
+	xorInto(parity, encoded.DataChunks, -1)
+	encoded.ParityChunk = parity
+	encoded.ChunkSize = chunkSize
+	return nil
+}
+
+// reuseChunk returns buf resliced to size bytes if it has the capacity,
+// or a new buffer
+func reuseChunk(buf []byte, size int) []byte {
+	if cap(buf) >= size {
+		return buf[:size]
+	}
+	return make([]byte, size)
+}
+
 // generateParity computes XOR parity from data chunks
 //
 // The parity chunk is computed by XORing all data chunks together.
@@ -123,15 +183,24 @@ func generateParity(chunks [][]byte) []byte {
 	parity := make([]byte, chunkSize)
 
 	// XOR all chunks together
-	for _, chunk := range chunks {
-		for i, b := range chunk {
-			parity[i] ^= b
-		}
-	}
+	xorInto(parity, chunks, -1)
 
 	return parity
 }
 
+// xorInto XORs every chunk except chunks[skip] into dst; skip -1 uses them
+// all
+func xorInto(dst []byte, chunks [][]byte, skip int) {
+	for i, chunk := range chunks {
+		if i == skip {
+			continue
+		}
+		for j, b := range chunk {
+			dst[j] ^= b
+		}
+	}
+}
+
 // RecoverChunk recovers a lost data chunk using XOR parity
 //
 // Uses the property that A ⊕ B ⊕ C ⊕ P = 0, where P is parity.
@@ -153,20 +222,28 @@ func RecoverChunk(encoded *XorEncoded, lostChunkIndex int) ([]byte, error) {
 	chunkSize := encoded.ChunkSize
 	recovered := make([]byte, chunkSize)
 
-	// XOR all other data chunks
-	for i, chunk := range encoded.DataChunks {
-		if i != lostChunkIndex {
-			for j, b := range chunk {
-				recovered[j] ^= b
-			}
-		}
-	}
+	// Start from the parity chunk and XOR in all other data chunks
+	copy(recovered, encoded.ParityChunk)
+	xorInto(recovered, encoded.DataChunks, lostChunkIndex)
+
+	return recovered, nil
+}
 
-	// XOR with parity chunk
-	for i, b := range encoded.ParityChunk {
-		recovered[i] ^= b
+// RecoverChunkInto is RecoverChunk writing the recovered chunk into dst
+//
+// dst is reused if it can hold ChunkSize bytes and replaced otherwise; the
+// recovered chunk is returned either way.
+//
+// Errors:
+//   - ErrInvalidChunkIndex if index is out of bounds
+func RecoverChunkInto(encoded *XorEncoded, lostChunkIndex int, dst []byte) ([]byte, error) {
+	if lostChunkIndex >= len(encoded.DataChunks) {
+		return nil, ErrInvalidChunkIndex
 	}
 
+	recovered := reuseChunk(dst, encoded.ChunkSize)
+	copy(recovered, encoded.ParityChunk)
+	xorInto(recovered, encoded.DataChunks, lostChunkIndex)
 	return recovered, nil
 }
 
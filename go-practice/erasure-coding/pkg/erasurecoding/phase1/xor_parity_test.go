@@ -287,3 +287,76 @@ func ExampleRecoverChunk() {
 	// Output:
 	// Recovery successful!
 }
+
+func TestEncodeInto_MatchesEncode(t *testing.T) {
+	var encoded XorEncoded
+	// Shrinking objects must not leave old bytes in the padding
+	for _, tc := range []struct {
+		data      string
+		numChunks int
+	}{
+		{"THE QUICK BROWN FOX JUMPS", 4},
+		{"HELLO WORLD", 3},
+		{"HI", 5},
+		{"A MUCH LONGER OBJECT THAN ANY BEFORE IT", 6},
+	} {
+		want, _ := Encode([]byte(tc.data), tc.numChunks)
+		if err := EncodeInto([]byte(tc.data), tc.numChunks, &encoded); err != nil {
+			t.Fatalf("EncodeInto(%q) error = %v", tc.data, err)
+		}
+		if encoded.ChunkSize != want.ChunkSize || len(encoded.DataChunks) != tc.numChunks {
+			t.Fatalf("EncodeInto(%q) = %d chunks of %d, want %d of %d", tc.data, len(encoded.DataChunks), encoded.ChunkSize, tc.numChunks, want.ChunkSize)
+		}
+		for i := range want.DataChunks {
+			if !bytes.Equal(encoded.DataChunks[i], want.DataChunks[i]) {
+				t.Errorf("EncodeInto(%q) chunk %d = %q, want %q", tc.data, i, encoded.DataChunks[i], want.DataChunks[i])
+			}
+		}
+		if !bytes.Equal(encoded.ParityChunk, want.ParityChunk) {
+			t.Errorf("EncodeInto(%q) parity differs", tc.data)
+		}
+	}
+
+	if err := EncodeInto(nil, 3, &encoded); err != ErrEmptyData {
+		t.Errorf("EncodeInto(empty data) error = %v, want %v", err, ErrEmptyData)
+	}
+	if err := EncodeInto([]byte("HELLO"), 1, &encoded); err != ErrInvalidChunkCount {
+		t.Errorf("EncodeInto(chunk count=1) error = %v, want %v", err, ErrInvalidChunkCount)
+	}
+}
+
+func TestRecoverChunkInto(t *testing.T) {
+	encoded, _ := Encode([]byte("HELLO WORLD"), 3)
+	dst := make([]byte, 0, encoded.ChunkSize)
+	for i := range encoded.DataChunks {
+		got, err := RecoverChunkInto(encoded, i, dst)
+		if err != nil {
+			t.Fatalf("RecoverChunkInto(%d) error = %v", i, err)
+		}
+		if !bytes.Equal(got, encoded.DataChunks[i]) {
+			t.Errorf("RecoverChunkInto(%d) = %q, want %q", i, got, encoded.DataChunks[i])
+		}
+		if &got[0] != &dst[:1][0] {
+			t.Errorf("RecoverChunkInto(%d) did not reuse dst", i)
+		}
+	}
+	if _, err := RecoverChunkInto(encoded, 3, dst); err != ErrInvalidChunkIndex {
+		t.Errorf("RecoverChunkInto(3) error = %v, want %v", err, ErrInvalidChunkIndex)
+	}
+}
+
+// Benchmark encoding into reused buffers, which must not allocate
+func BenchmarkEncodeInto(b *testing.B) {
+	data := bytes.Repeat([]byte("benchmark data "), 1000) // ~15KB
+	var encoded XorEncoded
+	_ = EncodeInto(data, 5, &encoded)
+	if allocs := testing.AllocsPerRun(10, func() { _ = EncodeInto(data, 5, &encoded) }); allocs != 0 {
+		b.Fatalf("EncodeInto: %v allocs/op, want 0", allocs)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_ = EncodeInto(data, 5, &encoded)
+	}
+}
@@ -0,0 +1,299 @@
+// Package pipeline compresses and encrypts objects before they are
+// erasure-coded: compress → encrypt → erasure-encode on write, and the
+// reverse on read.
+//
+// A compressed or encrypted stream can normally only be read from the
+// start. To keep range reads cheap, the plaintext is cut into fixed-size
+// blocks and every block is compressed (compress/flate) and sealed
+// (AES-GCM) on its own. The encoded blocks are concatenated, and their
+// offsets are recorded in Params next to the stage parameters. Reading
+// [off, off+n) then decodes only the blocks that overlap the range, and
+// those blocks come from a codec.RangeReader, which rebuilds missing shards.
+// Degraded reads therefore work exactly as for plain objects.
+//
+// Encoded block layout:
+//
+//	compression only:  flag(1) || payload         flag 1 = deflate, 0 = stored
+//	encryption:        AES-GCM(flag || payload)   or AES-GCM(plaintext) without compression
+//
+// Each block has a different GCM nonce: an 8-byte random prefix chosen per
+// object, followed by the 4-byte block index. The additional data binds
+// every block to its index and to the plaintext size, so blocks cannot be
+// reordered, and an object cannot be truncated without detection. Params
+// hold no secret, so decoding needs nothing but the key.
+package pipeline
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// PipelineError represents errors returned by the pipeline package
+type PipelineError struct {
+	message string
+}
+
+func (e *PipelineError) Error() string {
+	return e.message
+}
+
+// Common errors
+var (
+	ErrInvalidKey       = &PipelineError{"key must be 16, 24 or 32 bytes"}
+	ErrKeyRequired      = &PipelineError{"object is encrypted and no key was given"}
+	ErrInvalidLevel     = &PipelineError{"compression level must be 0 to 9"}
+	ErrInvalidBlockSize = &PipelineError{"block size must be positive"}
+	ErrInvalidParams    = &PipelineError{"invalid pipeline parameters"}
+	ErrAuthentication   = &PipelineError{"decryption failed: wrong key or tampered data"}
+	ErrCorruptBlock     = &PipelineError{"corrupt block"}
+)
+
+const (
+	// Version is the current Params format
+	Version = 1
+	// DefaultBlockSize trades range read granularity against per-block
+	// overhead (16-byte GCM tag, restarted compression dictionary)
+	DefaultBlockSize = 256 << 10
+
+	// CompressionFlate and EncryptionAESGCM name the stages in Params
+	CompressionFlate = "flate"
+	EncryptionAESGCM = "aes-gcm"
+
+	noncePrefixSize = 8
+	flagStored      = 0
+	flagDeflate     = 1
+)
+
+// Options selects the stages applied to an object
+type Options struct {
+	// Compress enables per-block flate compression
+	Compress bool
+	// Level is the flate level 1-9; 0 means flate.DefaultCompression
+	Level int
+	// Key is the per-object AES key (16, 24 or 32 bytes); nil disables
+	// encryption
+	Key []byte
+	// BlockSize is the plaintext block size, DefaultBlockSize if zero
+	BlockSize int
+}
+
+// Params records how an object was transformed; it is stored as JSON in
+// the object metadata
+type Params struct {
+	Version int `json:"version"`
+	// Size is the plaintext size
+	Size      int64 `json:"size"`
+	BlockSize int   `json:"blockSize"`
+	// Compression is CompressionFlate or empty
+	Compression string `json:"compression,omitempty"`
+	Level       int    `json:"level,omitempty"`
+	// Encryption is EncryptionAESGCM or empty; Nonce is the per-object
+	// nonce prefix
+	Encryption string `json:"encryption,omitempty"`
+	Nonce      []byte `json:"nonce,omitempty"`
+	// Offsets[i] is where encoded block i starts; the last entry is the
+	// encoded size. Empty when no stage is enabled (identity transform).
+	Offsets []int64 `json:"offsets,omitempty"`
+}
+
+// Identity returns the Params of an untransformed object of size bytes
+func Identity(size int64) *Params {
+	return &Params{Version: Version, Size: size}
+}
+
+// transformed reports whether any stage is enabled
+func (p *Params) transformed() bool {
+	return p.Compression != "" || p.Encryption != ""
+}
+
+// numBlocks returns the number of plaintext blocks
+func (p *Params) numBlocks() int {
+	return int((p.Size + int64(p.BlockSize) - 1) / int64(p.BlockSize))
+}
+
+// blockLen returns the plaintext length of block i
+func (p *Params) blockLen(i int) int {
+	return int(min(int64(p.BlockSize), p.Size-int64(i)*int64(p.BlockSize)))
+}
+
+// validate checks Params read back from metadata
+func (p *Params) validate() error {
+	if p.Version != Version || p.Size < 0 {
+		return ErrInvalidParams
+	}
+	if !p.transformed() {
+		return nil
+	}
+	if p.BlockSize <= 0 || (p.Compression != "" && p.Compression != CompressionFlate) ||
+		(p.Encryption != "" && (p.Encryption != EncryptionAESGCM || len(p.Nonce) != noncePrefixSize)) ||
+		len(p.Offsets) != p.numBlocks()+1 || p.Offsets[0] != 0 {
+		return ErrInvalidParams
+	}
+	for i := 1; i < len(p.Offsets); i++ {
+		if p.Offsets[i] < p.Offsets[i-1] {
+			return ErrInvalidParams
+		}
+	}
+	return nil
+}
+
+// newAEAD returns AES-GCM for key
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+	return cipher.NewGCM(block)
+}
+
+// nonce returns the GCM nonce of block i
+func (p *Params) nonce(i int) []byte {
+	n := make([]byte, noncePrefixSize+4)
+	copy(n, p.Nonce)
+	binary.BigEndian.PutUint32(n[noncePrefixSize:], uint32(i))
+	return n
+}
+
+// additionalData binds block i to its position and the object size
+func (p *Params) additionalData(i int) []byte {
+	ad := make([]byte, 12)
+	binary.BigEndian.PutUint64(ad, uint64(p.Size))
+	binary.BigEndian.PutUint32(ad[8:], uint32(i))
+	return ad
+}
+
+// Encode applies the stages selected by opts to data and returns the
+// encoded bytes with the parameters needed to decode them
+//
+// Errors:
+//   - ErrInvalidKey for a key that is not 16, 24 or 32 bytes
+//   - ErrInvalidLevel for a level outside 0-9
+//   - ErrInvalidBlockSize for a negative block size
+func Encode(data []byte, opts Options) ([]byte, *Params, error) {
+	if opts.Level < 0 || opts.Level > 9 {
+		return nil, nil, ErrInvalidLevel
+	}
+	if opts.BlockSize < 0 {
+		return nil, nil, ErrInvalidBlockSize
+	}
+	p := Identity(int64(len(data)))
+	if !opts.Compress && opts.Key == nil {
+		return data, p, nil
+	}
+
+	p.BlockSize = opts.BlockSize
+	if p.BlockSize == 0 {
+		p.BlockSize = DefaultBlockSize
+	}
+	if p.numBlocks() > 1<<32-1 {
+		return nil, nil, ErrInvalidBlockSize
+	}
+	var aead cipher.AEAD
+	if opts.Key != nil {
+		var err error
+		if aead, err = newAEAD(opts.Key); err != nil {
+			return nil, nil, err
+		}
+		p.Encryption = EncryptionAESGCM
+		p.Nonce = make([]byte, noncePrefixSize)
+		if _, err := rand.Read(p.Nonce); err != nil {
+			return nil, nil, err
+		}
+	}
+	level := opts.Level
+	if opts.Compress {
+		p.Compression = CompressionFlate
+		p.Level = level
+		if level == 0 {
+			level = flate.DefaultCompression
+		}
+	}
+
+	var out bytes.Buffer
+	var zbuf bytes.Buffer
+	p.Offsets = append(p.Offsets, 0)
+	for i := 0; i < p.numBlocks(); i++ {
+		plain := data[int64(i)*int64(p.BlockSize):][:p.blockLen(i)]
+		block := plain
+		if opts.Compress {
+			zbuf.Reset()
+			zbuf.WriteByte(flagDeflate)
+			zw, err := flate.NewWriter(&zbuf, level)
+			if err != nil {
+				return nil, nil, err
+			}
+			zw.Write(plain)
+			zw.Close()
+			if zbuf.Len() > len(plain) {
+				zbuf.Reset()
+				zbuf.WriteByte(flagStored)
+				zbuf.Write(plain)
+			}
+			block = zbuf.Bytes()
+		}
+		if aead != nil {
+			block = aead.Seal(nil, p.nonce(i), block, p.additionalData(i))
+		}
+		out.Write(block)
+		p.Offsets = append(p.Offsets, int64(out.Len()))
+	}
+	return out.Bytes(), p, nil
+}
+
+// Decode reverses Encode
+//
+// Errors:
+//   - ErrKeyRequired, ErrInvalidKey or ErrAuthentication for encrypted data
+//   - ErrInvalidParams or ErrCorruptBlock for malformed input
+func Decode(encoded []byte, p *Params, key []byte) ([]byte, error) {
+	r, err := NewReader(bytes.NewReader(encoded), p, key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, r.Size())
+	if _, err := r.ReadAt(out, 0); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return out, nil
+}
+
+// decodeBlock turns encoded block i back into plaintext
+func (p *Params) decodeBlock(aead cipher.AEAD, i int, block []byte) ([]byte, error) {
+	if aead != nil {
+		var err error
+		block, err = aead.Open(block[:0], p.nonce(i), block, p.additionalData(i))
+		if err != nil {
+			return nil, fmt.Errorf("%w: block %d", ErrAuthentication, i)
+		}
+	}
+	want := p.blockLen(i)
+	if p.Compression != "" {
+		if len(block) == 0 {
+			return nil, fmt.Errorf("%w: block %d", ErrCorruptBlock, i)
+		}
+		switch block[0] {
+		case flagStored:
+			block = block[1:]
+		case flagDeflate:
+			plain := make([]byte, want)
+			zr := flate.NewReader(bytes.NewReader(block[1:]))
+			n, err := io.ReadFull(zr, plain)
+			if err != nil || n != want {
+				return nil, fmt.Errorf("%w: block %d: %v", ErrCorruptBlock, i, err)
+			}
+			block = plain
+		default:
+			return nil, fmt.Errorf("%w: block %d", ErrCorruptBlock, i)
+		}
+	}
+	if len(block) != want {
+		return nil, fmt.Errorf("%w: block %d", ErrCorruptBlock, i)
+	}
+	return block, nil
+}
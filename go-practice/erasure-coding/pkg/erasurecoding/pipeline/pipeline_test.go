@@ -0,0 +1,191 @@
+package pipeline
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+var testKey = bytes.Repeat([]byte{0x5a}, 32)
+
+// textData returns compressible data with some randomness mixed in
+func textData(seed int64, n int) []byte {
+	rng := rand.New(rand.NewSource(seed))
+	var b bytes.Buffer
+	for b.Len() < n {
+		fmt.Fprintf(&b, "line %d: value=%d status=ok\n", b.Len(), rng.Intn(1000))
+	}
+	return b.Bytes()[:n]
+}
+
+func randomData(seed int64, n int) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+func TestEncodeDecode_Roundtrip(t *testing.T) {
+	stages := []struct {
+		name string
+		opts Options
+	}{
+		{"identity", Options{}},
+		{"compress", Options{Compress: true}},
+		{"compress-best", Options{Compress: true, Level: 9}},
+		{"encrypt", Options{Key: testKey[:16]}},
+		{"compress+encrypt", Options{Compress: true, Key: testKey}},
+		{"small-blocks", Options{Compress: true, Key: testKey[:24], BlockSize: 1000}},
+	}
+	inputs := map[string][]byte{
+		"empty":  nil,
+		"byte":   {42},
+		"text":   textData(1, 700_000),
+		"random": randomData(2, 300_000),
+	}
+	for _, st := range stages {
+		for name, data := range inputs {
+			t.Run(st.name+"/"+name, func(t *testing.T) {
+				enc, p, err := Encode(data, st.opts)
+				if err != nil {
+					t.Fatalf("Encode() error = %v", err)
+				}
+				if p.Size != int64(len(data)) {
+					t.Errorf("Params.Size = %d, want %d", p.Size, len(data))
+				}
+				if st.opts.Key != nil && len(data) > 16 && bytes.Contains(enc, data[:16]) {
+					t.Error("encrypted output contains plaintext")
+				}
+				got, err := Decode(enc, p, st.opts.Key)
+				if err != nil || !bytes.Equal(got, data) {
+					t.Fatalf("Decode() = %d bytes, %v; want %d bytes", len(got), err, len(data))
+				}
+			})
+		}
+	}
+}
+
+func TestEncode_CompressionShrinksText(t *testing.T) {
+	data := textData(3, 1<<20)
+	enc, _, err := Encode(data, Options{Compress: true, Key: testKey})
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if len(enc) > len(data)/2 {
+		t.Errorf("encoded %d bytes to %d, want at least 2x smaller", len(data), len(enc))
+	}
+
+	// Incompressible blocks are stored, costing only the flag and tag
+	random := randomData(4, 1<<20)
+	enc, p, _ := Encode(random, Options{Compress: true, Key: testKey})
+	if overhead := len(enc) - len(random); overhead > 17*p.numBlocks() {
+		t.Errorf("overhead on random data = %d bytes", overhead)
+	}
+}
+
+func TestDecode_WrongKeyAndTampering(t *testing.T) {
+	data := textData(5, 100_000)
+	enc, p, err := Encode(data, Options{Compress: true, Key: testKey, BlockSize: 10_000})
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	if _, err := Decode(enc, p, nil); !errors.Is(err, ErrKeyRequired) {
+		t.Errorf("Decode(no key) error = %v, want ErrKeyRequired", err)
+	}
+	if _, err := Decode(enc, p, []byte("short")); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Decode(short key) error = %v, want ErrInvalidKey", err)
+	}
+	wrong := bytes.Repeat([]byte{1}, 32)
+	if _, err := Decode(enc, p, wrong); !errors.Is(err, ErrAuthentication) {
+		t.Errorf("Decode(wrong key) error = %v, want ErrAuthentication", err)
+	}
+
+	flipped := append([]byte(nil), enc...)
+	flipped[len(flipped)/2] ^= 1
+	if _, err := Decode(flipped, p, testKey); !errors.Is(err, ErrAuthentication) {
+		t.Errorf("Decode(flipped bit) error = %v, want ErrAuthentication", err)
+	}
+
+	// Swapping two blocks (and their offsets) must not go unnoticed
+	b0, b1 := enc[p.Offsets[0]:p.Offsets[1]], enc[p.Offsets[1]:p.Offsets[2]]
+	swapped := append(append(append([]byte(nil), b1...), b0...), enc[p.Offsets[2]:]...)
+	ps := *p
+	ps.Offsets = append([]int64{0, int64(len(b1))}, p.Offsets[2:]...)
+	if _, err := Decode(swapped, &ps, testKey); !errors.Is(err, ErrAuthentication) {
+		t.Errorf("Decode(swapped blocks) error = %v, want ErrAuthentication", err)
+	}
+
+	// Truncating the object (dropping the last block) changes Size and
+	// therefore the additional data of every block
+	pt := *p
+	pt.Size -= int64(p.blockLen(p.numBlocks() - 1))
+	pt.Offsets = p.Offsets[:len(p.Offsets)-1]
+	if _, err := Decode(enc[:pt.Offsets[len(pt.Offsets)-1]], &pt, testKey); !errors.Is(err, ErrAuthentication) {
+		t.Errorf("Decode(truncated) error = %v, want ErrAuthentication", err)
+	}
+}
+
+func TestEncode_FreshNoncePerObject(t *testing.T) {
+	data := textData(6, 5000)
+	enc1, p1, _ := Encode(data, Options{Key: testKey})
+	enc2, p2, _ := Encode(data, Options{Key: testKey})
+	if bytes.Equal(p1.Nonce, p2.Nonce) || bytes.Equal(enc1, enc2) {
+		t.Error("two encryptions of the same object share a nonce")
+	}
+}
+
+func TestEncode_InvalidOptions(t *testing.T) {
+	tests := []struct {
+		opts Options
+		want error
+	}{
+		{Options{Key: []byte("0123456789")}, ErrInvalidKey},
+		{Options{Compress: true, Level: 10}, ErrInvalidLevel},
+		{Options{Compress: true, BlockSize: -1}, ErrInvalidBlockSize},
+	}
+	for _, tt := range tests {
+		if _, _, err := Encode([]byte("data"), tt.opts); !errors.Is(err, tt.want) {
+			t.Errorf("Encode(%+v) error = %v, want %v", tt.opts, err, tt.want)
+		}
+	}
+}
+
+func TestNewReader_InvalidParams(t *testing.T) {
+	_, p, _ := Encode(textData(7, 10_000), Options{Compress: true, BlockSize: 1000})
+	bad := []func(p *Params){
+		func(p *Params) { p.Version = 99 },
+		func(p *Params) { p.Compression = "zstd" },
+		func(p *Params) { p.Offsets = p.Offsets[1:] },
+		func(p *Params) { p.Offsets[3], p.Offsets[4] = p.Offsets[4], p.Offsets[3] },
+		func(p *Params) { p.Encryption = EncryptionAESGCM },
+	}
+	for i, mutate := range bad {
+		q := *p
+		q.Offsets = append([]int64(nil), p.Offsets...)
+		mutate(&q)
+		if _, err := NewReader(bytes.NewReader(nil), &q, testKey); !errors.Is(err, ErrInvalidParams) {
+			t.Errorf("case %d: NewReader() error = %v, want ErrInvalidParams", i, err)
+		}
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	data := textData(8, 8<<20)
+	for _, bm := range []struct {
+		name string
+		opts Options
+	}{
+		{"compress", Options{Compress: true, Level: 1}},
+		{"encrypt", Options{Key: testKey}},
+		{"compress+encrypt", Options{Compress: true, Level: 1, Key: testKey}},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				Encode(data, bm.opts)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package pipeline
+
+import (
+	"crypto/cipher"
+	"io"
+	"sync"
+)
+
+// Reader serves random-access reads of the plaintext of an encoded object
+//
+// Only the blocks overlapping a read are fetched from src and decoded; the
+// most recently decoded block is cached, so sequential reads with small
+// buffers decode every block once. Reader is safe for concurrent use.
+type Reader struct {
+	src  io.ReaderAt
+	p    *Params
+	aead cipher.AEAD
+
+	mu       sync.Mutex
+	cacheIdx int
+	cache    []byte
+}
+
+// NewReader returns a Reader over the encoded bytes in src
+//
+// Errors:
+//   - ErrInvalidParams for malformed parameters
+//   - ErrKeyRequired if the object is encrypted and key is nil
+//   - ErrInvalidKey for a key of the wrong length
+func NewReader(src io.ReaderAt, p *Params, key []byte) (*Reader, error) {
+	if p == nil {
+		return nil, ErrInvalidParams
+	}
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	r := &Reader{src: src, p: p, cacheIdx: -1}
+	if p.Encryption != "" {
+		if key == nil {
+			return nil, ErrKeyRequired
+		}
+		var err error
+		if r.aead, err = newAEAD(key); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Size returns the plaintext size
+func (r *Reader) Size() int64 {
+	return r.p.Size
+}
+
+// Params returns the parameters the reader decodes with
+func (r *Reader) Params() *Params {
+	return r.p
+}
+
+// ReadAt implements io.ReaderAt over the plaintext
+func (r *Reader) ReadAt(b []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, ErrInvalidParams
+	}
+	if off >= r.p.Size {
+		return 0, io.EOF
+	}
+	want := len(b)
+	if rest := r.p.Size - off; int64(want) > rest {
+		b = b[:rest]
+	}
+	if !r.p.transformed() {
+		n, err := r.src.ReadAt(b, off)
+		if err == nil && n < want {
+			err = io.EOF
+		}
+		return n, err
+	}
+
+	n := 0
+	for n < len(b) {
+		pos := off + int64(n)
+		i := int(pos / int64(r.p.BlockSize))
+		plain, err := r.block(i)
+		if err != nil {
+			return n, err
+		}
+		n += copy(b[n:], plain[pos-int64(i)*int64(r.p.BlockSize):])
+	}
+	if n < want {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// block returns the decoded block i, from the cache if possible
+func (r *Reader) block(i int) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cacheIdx == i {
+		return r.cache, nil
+	}
+	start, end := r.p.Offsets[i], r.p.Offsets[i+1]
+	enc := make([]byte, end-start)
+	if n, err := r.src.ReadAt(enc, start); err != nil && !(err == io.EOF && n == len(enc)) {
+		return nil, err
+	}
+	plain, err := r.p.decodeBlock(r.aead, i, enc)
+	if err != nil {
+		return nil, err
+	}
+	r.cacheIdx, r.cache = i, plain
+	return plain, nil
+}
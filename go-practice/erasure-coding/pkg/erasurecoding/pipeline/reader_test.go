@@ -0,0 +1,97 @@
+package pipeline
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+)
+
+// countingReaderAt counts the bytes read from the encoded object
+type countingReaderAt struct {
+	r    io.ReaderAt
+	read atomic.Int64
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := c.r.ReadAt(p, off)
+	c.read.Add(int64(n))
+	return n, err
+}
+
+func TestReader_RandomRanges(t *testing.T) {
+	data := textData(20, 500_000)
+	enc, p, err := Encode(data, Options{Compress: true, Key: testKey, BlockSize: 4096})
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	r, err := NewReader(bytes.NewReader(enc), p, testKey)
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	rng := rand.New(rand.NewSource(21))
+	for i := 0; i < 300; i++ {
+		off := rng.Int63n(int64(len(data)))
+		buf := make([]byte, rng.Intn(20_000)+1)
+		n, err := r.ReadAt(buf, off)
+		want := data[off:min(off+int64(len(buf)), int64(len(data)))]
+		if n != len(want) || !bytes.Equal(buf[:n], want) {
+			t.Fatalf("ReadAt(%d bytes, %d) = %d, %v", len(buf), off, n, err)
+		}
+		if (n < len(buf)) != (err == io.EOF) {
+			t.Fatalf("ReadAt(%d bytes, %d) = %d, %v", len(buf), off, n, err)
+		}
+	}
+	if _, err := r.ReadAt(make([]byte, 1), int64(len(data))); err != io.EOF {
+		t.Errorf("ReadAt(end) error = %v, want io.EOF", err)
+	}
+}
+
+func TestReader_RangeReadsFetchOnlyOverlappingBlocks(t *testing.T) {
+	data := randomData(22, 1<<20)
+	enc, p, _ := Encode(data, Options{Key: testKey, BlockSize: 16 << 10})
+	src := &countingReaderAt{r: bytes.NewReader(enc)}
+	r, err := NewReader(src, p, testKey)
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+
+	// 100 bytes straddling a block boundary touch exactly two blocks
+	buf := make([]byte, 100)
+	if _, err := r.ReadAt(buf, 32<<10-50); err != nil || !bytes.Equal(buf, data[32<<10-50:32<<10+50]) {
+		t.Fatalf("ReadAt() error = %v", err)
+	}
+	if got, want := src.read.Load(), 2*(16<<10+16); got != int64(want) {
+		t.Errorf("read %d encoded bytes, want %d", got, want)
+	}
+}
+
+func TestReader_Identity(t *testing.T) {
+	data := []byte("plain bytes, no stages")
+	enc, p, _ := Encode(data, Options{})
+	if !bytes.Equal(enc, data) || p.Offsets != nil {
+		t.Fatalf("identity Encode() changed the data or recorded offsets: %+v", p)
+	}
+	r, err := NewReader(bytes.NewReader(enc), p, nil)
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	got, err := io.ReadAll(io.NewSectionReader(r, 0, r.Size()))
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("ReadAll() = %q, %v", got, err)
+	}
+}
+
+func BenchmarkReader_ReadAt4K(b *testing.B) {
+	data := textData(23, 16<<20)
+	enc, p, _ := Encode(data, Options{Compress: true, Level: 1, Key: testKey})
+	r, _ := NewReader(bytes.NewReader(enc), p, testKey)
+	buf := make([]byte, 4096)
+	rng := rand.New(rand.NewSource(24))
+	b.SetBytes(int64(len(buf)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.ReadAt(buf, rng.Int63n(int64(len(data)-len(buf))))
+	}
+}
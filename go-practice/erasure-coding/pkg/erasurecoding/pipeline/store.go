@@ -0,0 +1,103 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/objstore"
+)
+
+// Store runs objects through the pipeline on their way into and out of an
+// objstore.Store
+type Store struct {
+	objs *objstore.Store
+}
+
+// NewStore wraps objs
+func NewStore(objs *objstore.Store) *Store {
+	return &Store{objs: objs}
+}
+
+// Put encodes data with opts, erasure-codes the result and records the
+// Params in the object metadata
+//
+// Errors:
+//   - errors of Encode and objstore.Store.Put
+func (s *Store) Put(bucket, key string, data []byte, opts Options, putOpts objstore.PutOptions) (*objstore.Meta, error) {
+	encoded, p, err := Encode(data, opts)
+	if err != nil {
+		return nil, err
+	}
+	if p.transformed() {
+		if putOpts.Pipeline, err = json.Marshal(p); err != nil {
+			return nil, err
+		}
+	}
+	return s.objs.Put(bucket, key, encoded, putOpts)
+}
+
+// Object is an open object whose plaintext can be read at any offset,
+// including while shards are missing
+type Object struct {
+	*Reader
+	obj *objstore.Object
+}
+
+// Meta returns the metadata of the stored (encoded) object
+func (o *Object) Meta() *objstore.Meta {
+	return o.obj.Meta
+}
+
+// Degraded reports whether any shard of the object is unavailable
+func (o *Object) Degraded() bool {
+	return o.obj.Degraded()
+}
+
+// Close releases the shard files
+func (o *Object) Close() error {
+	return o.obj.Close()
+}
+
+// Open opens an object for reading; key may be nil for objects that are
+// not encrypted. Objects written without the pipeline are read unchanged.
+//
+// Errors:
+//   - errors of objstore.Store.Open
+//   - ErrInvalidParams if the recorded parameters cannot be decoded
+//   - ErrKeyRequired or ErrInvalidKey as for NewReader
+func (s *Store) Open(bucket, key string, objKey []byte) (*Object, error) {
+	obj, err := s.objs.Open(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	p := Identity(obj.Meta.Size)
+	if len(obj.Meta.Pipeline) > 0 {
+		p = &Params{}
+		if err := json.Unmarshal(obj.Meta.Pipeline, p); err != nil {
+			obj.Close()
+			return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+		}
+	}
+	r, err := NewReader(obj, p, objKey)
+	if err != nil {
+		obj.Close()
+		return nil, err
+	}
+	return &Object{Reader: r, obj: obj}, nil
+}
+
+// Get returns the whole plaintext of an object; errors are those of Open
+// and ReadAt (ErrAuthentication for a wrong key)
+func (s *Store) Get(bucket, key string, objKey []byte) ([]byte, error) {
+	o, err := s.Open(bucket, key, objKey)
+	if err != nil {
+		return nil, err
+	}
+	defer o.Close()
+	data := make([]byte, o.Size())
+	if _, err := o.ReadAt(data, 0); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return data, nil
+}
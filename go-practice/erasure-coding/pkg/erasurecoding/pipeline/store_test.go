@@ -0,0 +1,124 @@
+package pipeline
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/codec"
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/objstore"
+)
+
+func newTestStore(t *testing.T, numDirs int, spec string) (*Store, *objstore.Store) {
+	t.Helper()
+	c, err := codec.Parse(spec)
+	if err != nil {
+		t.Fatalf("Parse(%q) error = %v", spec, err)
+	}
+	root := t.TempDir()
+	dirs := make([]string, numDirs)
+	for i := range dirs {
+		dirs[i] = filepath.Join(root, fmt.Sprintf("disk%d", i))
+	}
+	objs, err := objstore.New(dirs, c)
+	if err != nil {
+		t.Fatalf("objstore.New() error = %v", err)
+	}
+	return NewStore(objs), objs
+}
+
+func TestStore_PutGet(t *testing.T) {
+	s, objs := newTestStore(t, 6, "rs:4+2")
+	data := textData(30, 1<<20)
+	meta, err := s.Put("bucket", "logs/app.log", data, Options{Compress: true, Key: testKey},
+		objstore.PutOptions{ContentType: "text/plain"})
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if meta.Size >= int64(len(data))/2 || len(meta.Pipeline) == 0 || meta.ContentType != "text/plain" {
+		t.Errorf("Put() meta size %d, pipeline %s", meta.Size, meta.Pipeline)
+	}
+
+	// Only the key is needed; the stages come from the stored metadata
+	got, err := s.Get("bucket", "logs/app.log", testKey)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("Get() = %d bytes, %v", len(got), err)
+	}
+	if _, err := s.Get("bucket", "logs/app.log", nil); !errors.Is(err, ErrKeyRequired) {
+		t.Errorf("Get(no key) error = %v, want ErrKeyRequired", err)
+	}
+
+	// The shards hold ciphertext only
+	raw, _ := objs.Open("bucket", "logs/app.log")
+	defer raw.Close()
+	enc := make([]byte, raw.Size())
+	raw.ReadAt(enc, 0)
+	if bytes.Contains(enc, []byte("status=ok")) {
+		t.Error("stored bytes contain plaintext")
+	}
+}
+
+func TestStore_DegradedRangeRead(t *testing.T) {
+	s, objs := newTestStore(t, 6, "rs:4+2")
+	data := textData(31, 2<<20)
+	meta, err := s.Put("bucket", "k", data, Options{Compress: true, Key: testKey, BlockSize: 32 << 10}, objstore.PutOptions{})
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	dirs := objs.Dirs()
+	for _, d := range meta.Placement[:2] {
+		if err := os.RemoveAll(dirs[d]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	o, err := s.Open("bucket", "k", testKey)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer o.Close()
+	if !o.Degraded() || o.Size() != int64(len(data)) {
+		t.Errorf("Degraded() = %v, Size() = %d", o.Degraded(), o.Size())
+	}
+	for _, off := range []int64{0, 12345, 1 << 20, int64(len(data)) - 100} {
+		buf := make([]byte, 100)
+		if _, err := o.ReadAt(buf, off); err != nil || !bytes.Equal(buf, data[off:off+100]) {
+			t.Errorf("ReadAt(%d) error = %v", off, err)
+		}
+	}
+}
+
+func TestStore_PlainObjectsReadUnchanged(t *testing.T) {
+	s, objs := newTestStore(t, 5, "xor:4+1")
+	data := []byte("written without the pipeline")
+	if _, err := objs.Put("bucket", "plain", data, objstore.PutOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Get("bucket", "plain", nil)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("Get() = %q, %v", got, err)
+	}
+	meta, _ := s.Put("bucket", "identity", data, Options{}, objstore.PutOptions{})
+	if len(meta.Pipeline) != 0 {
+		t.Errorf("identity Put recorded pipeline %s", meta.Pipeline)
+	}
+}
+
+func TestStore_TranscodeKeepsPipeline(t *testing.T) {
+	s, objs := newTestStore(t, 6, "xor:4+1")
+	data := textData(32, 300_000)
+	if _, err := s.Put("bucket", "k", data, Options{Compress: true, Key: testKey}, objstore.PutOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	c, _ := codec.Parse("rs:4+2")
+	if _, err := objs.Transcode("bucket", "k", c); err != nil {
+		t.Fatalf("Transcode() error = %v", err)
+	}
+	got, err := s.Get("bucket", "k", testKey)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("Get() after transcode = %d bytes, %v", len(got), err)
+	}
+}
@@ -0,0 +1,102 @@
+package placement
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MovementReport estimates how much data moves after a topology change
+type MovementReport struct {
+	// Objects is the number of sampled object IDs
+	Objects int
+	// Shards is the total number of shards placed per topology
+	Shards int
+	// Moved counts shards whose disk differs between the two placements
+	Moved int
+	// MovedFraction is Moved / Shards
+	MovedFraction float64
+	// MinimalFraction is the share of shards that must move at the very
+	// least: the total weight share gained by disks that grew or appeared
+	MinimalFraction float64
+	// Incoming and Outgoing count moved shards per disk
+	Incoming map[string]int
+	Outgoing map[string]int
+}
+
+// ExpectedMovement places the sample object IDs "obj-0".."obj-<n-1>" with
+// both topologies and reports how many shards change disks
+//
+// With straw2 selection the moved fraction stays close to MinimalFraction;
+// a naive hash-mod-N placement would move nearly everything.
+func ExpectedMovement(before, after *Topology, rule Rule, samples int) (*MovementReport, error) {
+	report := &MovementReport{
+		Objects:  samples,
+		Incoming: map[string]int{},
+		Outgoing: map[string]int{},
+	}
+
+	for i := 0; i < samples; i++ {
+		id := fmt.Sprintf("obj-%d", i)
+		oldDisks, err := before.Place(id, rule)
+		if err != nil {
+			return nil, fmt.Errorf("before: %w", err)
+		}
+		newDisks, err := after.Place(id, rule)
+		if err != nil {
+			return nil, fmt.Errorf("after: %w", err)
+		}
+		for shard := range oldDisks {
+			report.Shards++
+			if oldDisks[shard] != newDisks[shard] {
+				report.Moved++
+				report.Outgoing[oldDisks[shard]]++
+				report.Incoming[newDisks[shard]]++
+			}
+		}
+	}
+
+	if report.Shards > 0 {
+		report.MovedFraction = float64(report.Moved) / float64(report.Shards)
+	}
+	report.MinimalFraction = minimalMovement(before, after)
+	return report, nil
+}
+
+// minimalMovement sums the weight share gained by every disk that grew
+func minimalMovement(before, after *Topology) float64 {
+	total := 0.0
+	for _, disk := range after.Disks() {
+		oldShare := before.Weight(disk) / before.root.weight
+		newShare := after.Weight(disk) / after.root.weight
+		if newShare > oldShare {
+			total += newShare - oldShare
+		}
+	}
+	return total
+}
+
+// String formats the report for humans
+func (r *MovementReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Sampled objects:   %d (%d shards)\n", r.Objects, r.Shards)
+	fmt.Fprintf(&b, "Shards moved:      %d (%.1f%%)\n", r.Moved, 100*r.MovedFraction)
+	fmt.Fprintf(&b, "Minimal movement:  %.1f%%\n", 100*r.MinimalFraction)
+
+	disks := map[string]bool{}
+	for d := range r.Incoming {
+		disks[d] = true
+	}
+	for d := range r.Outgoing {
+		disks[d] = true
+	}
+	names := make([]string, 0, len(disks))
+	for d := range disks {
+		names = append(names, d)
+	}
+	sort.Strings(names)
+	for _, d := range names {
+		fmt.Fprintf(&b, "  %-12s +%d / -%d\n", d, r.Incoming[d], r.Outgoing[d])
+	}
+	return b.String()
+}
@@ -0,0 +1,312 @@
+// Package placement computes deterministic, CRUSH-like shard placement over a
+// weighted hierarchy of failure domains.
+//
+// A topology is a tree, for example root → zones → racks → hosts → disks.
+// Disks are the leaves and carry weights (typically their capacity); every
+// inner bucket weighs the sum of its children. Given an object ID and a rule
+// asking for n shards separated at some failure domain level (say "host"),
+// Place returns n distinct disks, no two of which share a host. Nothing is
+// stored: anyone holding the same topology computes the same answer.
+//
+// Selection uses straw2: for shard i every eligible disk draws a
+// pseudo-random "straw" ln(u)/weight from a hash of (object, i, disk), and
+// the longest straw wins. Disks in a failure domain already taken by an
+// earlier shard are not eligible. A disk's draw depends only on its own
+// name and weight, so adding, removing or reweighting one disk moves little
+// more than the shards that now prefer (or may no longer use) that disk.
+// Buckets still matter: a host's chance of being picked is the sum of its
+// disks' weights, exactly as if straw2 had descended the tree.
+//
+// Example:
+//
+//	topo, _ := placement.NewTopology(root)
+//	disks, err := topo.Place("bucket/photo.jpg", placement.Rule{
+//	    Shards:        6,
+//	    FailureDomain: "host",
+//	})
+package placement
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+)
+
+// PlacementError represents errors returned by the placement functions
+type PlacementError struct {
+	message string
+}
+
+func (e *PlacementError) Error() string {
+	return e.message
+}
+
+// Common errors
+var (
+	ErrEmptyTopology      = &PlacementError{"topology has no disks with positive weight"}
+	ErrDuplicateName      = &PlacementError{"topology node names must be unique"}
+	ErrInvalidWeight      = &PlacementError{"disk weights must be non-negative"}
+	ErrInvalidRule        = &PlacementError{"rule must ask for at least one shard"}
+	ErrUnknownDomain      = &PlacementError{"failure domain type does not exist in topology"}
+	ErrUnknownRoot        = &PlacementError{"rule root does not exist in topology"}
+	ErrNotEnoughDomains   = &PlacementError{"not enough failure domains for the requested shards"}
+	ErrPlacementExhausted = &PlacementError{"no eligible disk left for shard"}
+)
+
+// DiskType is the node type of leaves in the topology
+const DiskType = "disk"
+
+// Node is a bucket (zone, rack, host, ...) or a disk in the topology
+type Node struct {
+	// Name identifies the node and must be unique within the topology
+	Name string `json:"name"`
+	// Type is the level of the node, e.g. "zone", "rack", "host" or "disk"
+	Type string `json:"type"`
+	// Weight of a disk; ignored for buckets, which weigh their children
+	Weight float64 `json:"weight,omitempty"`
+	// Children of a bucket; disks have none
+	Children []*Node `json:"children,omitempty"`
+
+	weight float64 // effective weight computed by NewTopology
+	parent *Node
+}
+
+// Bucket is a convenience constructor for an inner node
+func Bucket(typ, name string, children ...*Node) *Node {
+	return &Node{Name: name, Type: typ, Children: children}
+}
+
+// Disk is a convenience constructor for a leaf
+func Disk(name string, weight float64) *Node {
+	return &Node{Name: name, Type: DiskType, Weight: weight}
+}
+
+// Rule describes how to place one stripe
+type Rule struct {
+	// Shards is the number of distinct disks to choose (k+m)
+	Shards int
+	// FailureDomain is the node type no two shards may share, e.g. "host".
+	// Empty or "disk" only requires distinct disks.
+	FailureDomain string
+	// Root optionally restricts placement to the subtree with this name
+	Root string
+}
+
+// Topology is a validated, weighted placement hierarchy
+type Topology struct {
+	root   *Node
+	byName map[string]*Node
+	types  map[string]int // node type -> number of nodes with positive weight
+}
+
+// NewTopology validates root and computes bucket weights
+//
+// The tree is not copied; it must not be modified after the call.
+//
+// Errors:
+//   - ErrDuplicateName if two nodes share a name
+//   - ErrInvalidWeight if a disk has a negative weight
+//   - ErrEmptyTopology if no disk has a positive weight
+func NewTopology(root *Node) (*Topology, error) {
+	t := &Topology{root: root, byName: map[string]*Node{}, types: map[string]int{}}
+	if err := t.index(root, nil); err != nil {
+		return nil, err
+	}
+	if root.weight <= 0 {
+		return nil, ErrEmptyTopology
+	}
+	return t, nil
+}
+
+// index records every node by name and sums weights bottom-up
+func (t *Topology) index(n, parent *Node) error {
+	if _, dup := t.byName[n.Name]; dup {
+		return fmt.Errorf("%w: %q", ErrDuplicateName, n.Name)
+	}
+	t.byName[n.Name] = n
+	n.parent = parent
+
+	if len(n.Children) == 0 {
+		if n.Weight < 0 || math.IsNaN(n.Weight) {
+			return fmt.Errorf("%w: %q", ErrInvalidWeight, n.Name)
+		}
+		n.weight = n.Weight
+	} else {
+		n.weight = 0
+		for _, c := range n.Children {
+			if err := t.index(c, n); err != nil {
+				return err
+			}
+			n.weight += c.weight
+		}
+	}
+	if n.weight > 0 {
+		t.types[n.Type]++
+	}
+	return nil
+}
+
+// Root returns the root node of the topology
+func (t *Topology) Root() *Node {
+	return t.root
+}
+
+// Disks returns the names of all disks with positive weight in tree order
+func (t *Topology) Disks() []string {
+	var disks []string
+	var walk func(n *Node)
+	walk = func(n *Node) {
+		if len(n.Children) == 0 {
+			if n.weight > 0 {
+				disks = append(disks, n.Name)
+			}
+			return
+		}
+		for _, c := range n.Children {
+			walk(c)
+		}
+	}
+	walk(t.root)
+	return disks
+}
+
+// Weight returns the effective weight of the named node (0 if unknown)
+func (t *Topology) Weight(name string) float64 {
+	if n, ok := t.byName[name]; ok {
+		return n.weight
+	}
+	return 0
+}
+
+// Domain returns the ancestor of disk with the given type (or the disk
+// itself for "disk"), or "" if there is none
+func (t *Topology) Domain(disk, typ string) string {
+	for n := t.byName[disk]; n != nil; n = n.parent {
+		if n.Type == typ {
+			return n.Name
+		}
+	}
+	return ""
+}
+
+// Place picks rule.Shards distinct disks for objectID
+//
+// The result is ordered: element i is the disk for shard i. The same
+// topology, rule and object ID always give the same placement.
+//
+// Errors:
+//   - ErrInvalidRule, ErrUnknownRoot or ErrUnknownDomain for bad rules
+//   - ErrNotEnoughDomains if the topology has fewer failure domains than
+//     requested shards
+//   - ErrPlacementExhausted if no eligible disk is left for a shard
+func (t *Topology) Place(objectID string, rule Rule) ([]string, error) {
+	if rule.Shards < 1 {
+		return nil, ErrInvalidRule
+	}
+	root := t.root
+	if rule.Root != "" {
+		var ok bool
+		if root, ok = t.byName[rule.Root]; !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownRoot, rule.Root)
+		}
+	}
+	domain := rule.FailureDomain
+	if domain == "" {
+		domain = DiskType
+	}
+	if _, ok := t.types[domain]; !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownDomain, domain)
+	}
+	if count := countType(root, domain); count < rule.Shards {
+		return nil, fmt.Errorf("%w: have %d %ss, need %d", ErrNotEnoughDomains, count, domain, rule.Shards)
+	}
+
+	// Every position draws straws for all eligible disks under root (those
+	// whose failure domain is still free) and takes the longest. A disk's
+	// straw depends only on its own name and weight, so a position moves
+	// only if the changed disk wins or loses its draw, or if an earlier
+	// position moved into its domain.
+	var leaves []*Node
+	collectLeaves(root, &leaves)
+	disks := make([]string, rule.Shards)
+	usedDomains := map[*Node]bool{}
+	for shard := range disks {
+		var best, bestDomain *Node
+		bestStraw := math.Inf(-1)
+		for _, leaf := range leaves {
+			dom := ancestor(leaf, domain)
+			if usedDomains[dom] {
+				continue
+			}
+			straw := math.Log(unitHash(objectID, uint32(shard), leaf.Name)) / leaf.weight
+			if best == nil || straw > bestStraw {
+				best, bestDomain, bestStraw = leaf, dom, straw
+			}
+		}
+		if best == nil {
+			return nil, ErrPlacementExhausted
+		}
+		usedDomains[bestDomain] = true
+		disks[shard] = best.Name
+	}
+	return disks, nil
+}
+
+// collectLeaves appends the disks with positive weight under n
+func collectLeaves(n *Node, out *[]*Node) {
+	if n.weight <= 0 {
+		return
+	}
+	if len(n.Children) == 0 {
+		*out = append(*out, n)
+		return
+	}
+	for _, c := range n.Children {
+		collectLeaves(c, out)
+	}
+}
+
+// ancestor returns the node of type typ on the path from n to the root
+func ancestor(n *Node, typ string) *Node {
+	for ; n != nil; n = n.parent {
+		if n.Type == typ {
+			return n
+		}
+	}
+	return nil
+}
+
+// unitHash maps (object, replica, node) to a uniform value in (0, 1]
+func unitHash(objectID string, r uint32, name string) float64 {
+	h := fnv.New64a()
+	h.Write([]byte(objectID))
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], r)
+	h.Write(buf[:])
+	h.Write([]byte(name))
+
+	// FNV mixes the tail poorly; finish with the splitmix64 finalizer
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return (float64(x>>11) + 1) / (1 << 53)
+}
+
+// countType counts nodes of type typ with positive weight under n
+func countType(n *Node, typ string) int {
+	if n.weight <= 0 {
+		return 0
+	}
+	if n.Type == typ {
+		return 1
+	}
+	count := 0
+	for _, c := range n.Children {
+		count += countType(c, typ)
+	}
+	return count
+}
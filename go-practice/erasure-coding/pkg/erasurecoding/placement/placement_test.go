@@ -0,0 +1,224 @@
+package placement
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+)
+
+// testTree builds zones × racks × hosts × disks, every disk weighing 1
+func testTree(zones, racks, hosts, disks int) *Node {
+	root := Bucket("root", "root")
+	for z := 0; z < zones; z++ {
+		zone := Bucket("zone", fmt.Sprintf("z%d", z))
+		for r := 0; r < racks; r++ {
+			rack := Bucket("rack", fmt.Sprintf("z%d-r%d", z, r))
+			for h := 0; h < hosts; h++ {
+				host := Bucket("host", fmt.Sprintf("z%d-r%d-h%d", z, r, h))
+				for d := 0; d < disks; d++ {
+					host.Children = append(host.Children, Disk(fmt.Sprintf("z%d-r%d-h%d-d%d", z, r, h, d), 1))
+				}
+				rack.Children = append(rack.Children, host)
+			}
+			zone.Children = append(zone.Children, rack)
+		}
+		root.Children = append(root.Children, zone)
+	}
+	return root
+}
+
+func mustTopology(t *testing.T, root *Node) *Topology {
+	t.Helper()
+	topo, err := NewTopology(root)
+	if err != nil {
+		t.Fatalf("NewTopology() error = %v", err)
+	}
+	return topo
+}
+
+func TestPlace_DistinctFailureDomains(t *testing.T) {
+	topo := mustTopology(t, testTree(3, 2, 3, 4))
+
+	for _, domain := range []string{"disk", "host", "rack", "zone"} {
+		shards := map[string]int{"disk": 9, "host": 9, "rack": 6, "zone": 3}[domain]
+		t.Run(domain, func(t *testing.T) {
+			for i := 0; i < 200; i++ {
+				disks, err := topo.Place(fmt.Sprintf("obj-%d", i), Rule{Shards: shards, FailureDomain: domain})
+				if err != nil {
+					t.Fatalf("Place() error = %v", err)
+				}
+				if len(disks) != shards {
+					t.Fatalf("Place() returned %d disks, want %d", len(disks), shards)
+				}
+				seen := map[string]bool{}
+				for _, d := range disks {
+					dom := topo.Domain(d, domain)
+					if seen[dom] {
+						t.Fatalf("Place(obj-%d) puts two shards in %s %s: %v", i, domain, dom, disks)
+					}
+					seen[dom] = true
+				}
+			}
+		})
+	}
+}
+
+func TestPlace_Deterministic(t *testing.T) {
+	a := mustTopology(t, testTree(2, 2, 2, 2))
+	b := mustTopology(t, testTree(2, 2, 2, 2))
+	rule := Rule{Shards: 6, FailureDomain: "host"}
+
+	for i := 0; i < 50; i++ {
+		id := fmt.Sprintf("object-%d", i)
+		x, _ := a.Place(id, rule)
+		y, _ := b.Place(id, rule)
+		if fmt.Sprint(x) != fmt.Sprint(y) {
+			t.Fatalf("Place(%s) differs between identical topologies: %v vs %v", id, x, y)
+		}
+	}
+}
+
+func TestPlace_RespectsWeights(t *testing.T) {
+	root := Bucket("root", "root",
+		Bucket("host", "h0", Disk("big", 3)),
+		Bucket("host", "h1", Disk("small", 1)),
+	)
+	topo := mustTopology(t, root)
+
+	counts := map[string]int{}
+	const n = 20000
+	for i := 0; i < n; i++ {
+		disks, _ := topo.Place(fmt.Sprintf("obj-%d", i), Rule{Shards: 1})
+		counts[disks[0]]++
+	}
+	if share := float64(counts["big"]) / n; math.Abs(share-0.75) > 0.02 {
+		t.Errorf("big disk share = %.3f, want ~0.75", share)
+	}
+}
+
+func TestPlace_ZeroWeightDiskUnused(t *testing.T) {
+	root := testTree(1, 1, 4, 2)
+	retired := root.Children[0].Children[0].Children[0].Children[0]
+	retired.Weight = 0
+	topo := mustTopology(t, root)
+
+	for i := 0; i < 500; i++ {
+		disks, _ := topo.Place(fmt.Sprintf("obj-%d", i), Rule{Shards: 4, FailureDomain: "host"})
+		for _, d := range disks {
+			if d == retired.Name {
+				t.Fatalf("Place() used zero-weight disk %s", d)
+			}
+		}
+	}
+}
+
+func TestPlace_Errors(t *testing.T) {
+	topo := mustTopology(t, testTree(1, 2, 2, 2))
+
+	tests := []struct {
+		name string
+		rule Rule
+		want error
+	}{
+		{"no shards", Rule{}, ErrInvalidRule},
+		{"unknown domain", Rule{Shards: 1, FailureDomain: "planet"}, ErrUnknownDomain},
+		{"unknown root", Rule{Shards: 1, Root: "nowhere"}, ErrUnknownRoot},
+		{"too few hosts", Rule{Shards: 5, FailureDomain: "host"}, ErrNotEnoughDomains},
+		{"too few racks in subtree", Rule{Shards: 2, FailureDomain: "host", Root: "z0-r0-h0"}, ErrNotEnoughDomains},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := topo.Place("obj", tt.rule); !errors.Is(err, tt.want) {
+				t.Errorf("Place() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewTopology_Validation(t *testing.T) {
+	dup := Bucket("root", "root", Disk("d", 1), Disk("d", 1))
+	if _, err := NewTopology(dup); !errors.Is(err, ErrDuplicateName) {
+		t.Errorf("NewTopology(duplicate) error = %v, want %v", err, ErrDuplicateName)
+	}
+	neg := Bucket("root", "root", Disk("d", -1))
+	if _, err := NewTopology(neg); !errors.Is(err, ErrInvalidWeight) {
+		t.Errorf("NewTopology(negative) error = %v, want %v", err, ErrInvalidWeight)
+	}
+	empty := Bucket("root", "root", Disk("d", 0))
+	if _, err := NewTopology(empty); err != ErrEmptyTopology {
+		t.Errorf("NewTopology(empty) error = %v, want %v", err, ErrEmptyTopology)
+	}
+}
+
+func TestExpectedMovement_AddDisk(t *testing.T) {
+	before := mustTopology(t, testTree(1, 2, 4, 3))
+	grown := testTree(1, 2, 4, 3)
+	host := grown.Children[0].Children[1].Children[2]
+	host.Children = append(host.Children, Disk("new-disk", 1))
+	after := mustTopology(t, grown)
+
+	report, err := ExpectedMovement(before, after, Rule{Shards: 6, FailureDomain: "host"}, 2000)
+	if err != nil {
+		t.Fatalf("ExpectedMovement() error = %v", err)
+	}
+	// One disk in 25 is new, so ~4% of shards must move. Allow some slack
+	// for CRUSH's collision retries but stay far from a full reshuffle.
+	if report.MovedFraction < report.MinimalFraction*0.7 || report.MovedFraction > report.MinimalFraction*2 {
+		t.Errorf("moved %.3f, minimal %.3f\n%s", report.MovedFraction, report.MinimalFraction, report)
+	}
+	for disk, n := range report.Incoming {
+		if disk != "new-disk" && n > report.Incoming["new-disk"]/4 {
+			t.Errorf("disk %s received %d shards, expected moves to go to new-disk", disk, n)
+		}
+	}
+}
+
+func TestExpectedMovement_RemoveDisk(t *testing.T) {
+	before := mustTopology(t, testTree(1, 2, 4, 3))
+	shrunk := testTree(1, 2, 4, 3)
+	removed := shrunk.Children[0].Children[0].Children[1].Children[0]
+	removed.Weight = 0
+	after := mustTopology(t, shrunk)
+
+	report, err := ExpectedMovement(before, after, Rule{Shards: 6, FailureDomain: "host"}, 2000)
+	if err != nil {
+		t.Fatalf("ExpectedMovement() error = %v", err)
+	}
+	if report.Incoming[removed.Name] != 0 {
+		t.Errorf("removed disk received %d shards", report.Incoming[removed.Name])
+	}
+	if out := report.Outgoing[removed.Name]; out == 0 || report.Moved > out*2 {
+		t.Errorf("moved %d shards, %d of them off the removed disk\n%s", report.Moved, out, report)
+	}
+}
+
+// Benchmark placing a 6-shard stripe across hosts
+func BenchmarkPlace(b *testing.B) {
+	topo, _ := NewTopology(testTree(3, 4, 4, 6))
+	rule := Rule{Shards: 6, FailureDomain: "host"}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, _ = topo.Place("benchmark-object", rule)
+	}
+}
+
+// Example demonstrates host-separated placement
+func ExampleTopology_Place() {
+	root := Bucket("root", "root",
+		Bucket("host", "h0", Disk("h0-d0", 1), Disk("h0-d1", 1)),
+		Bucket("host", "h1", Disk("h1-d0", 1), Disk("h1-d1", 1)),
+		Bucket("host", "h2", Disk("h2-d0", 1), Disk("h2-d1", 1)),
+	)
+	topo, _ := NewTopology(root)
+
+	disks, _ := topo.Place("photo.jpg", Rule{Shards: 3, FailureDomain: "host"})
+	hosts := map[string]bool{}
+	for _, d := range disks {
+		hosts[topo.Domain(d, "host")] = true
+	}
+	fmt.Println(len(disks), "shards on", len(hosts), "hosts")
+	// Output:
+	// 3 shards on 3 hosts
+}
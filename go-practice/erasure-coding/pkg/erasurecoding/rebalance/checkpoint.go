@@ -0,0 +1,103 @@
+package rebalance
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// checkpoint is an append-only progress log:
+//
+//	plan <plan ID>
+//	done <move index>
+//	done <move index>
+//	...
+//
+// Every line is fsynced before the next move starts. A torn last line (a
+// crash mid-append) is dropped when the file is reopened.
+type checkpoint struct {
+	f    *os.File // nil when checkpointing is disabled
+	done map[int]bool
+}
+
+// openCheckpoint opens or creates the checkpoint at path for planID
+func openCheckpoint(path, planID string) (*checkpoint, error) {
+	cp := &checkpoint{done: map[int]bool{}}
+	if path == "" {
+		return cp, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	// Keep complete lines only
+	valid := bytes.LastIndexByte(data, '\n') + 1
+	lines := strings.Split(string(data[:valid]), "\n")
+	lines = lines[:len(lines)-1]
+
+	if len(lines) > 0 {
+		id, ok := strings.CutPrefix(lines[0], "plan ")
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrCorruptedCheckpoint, path)
+		}
+		if id != planID {
+			return nil, fmt.Errorf("%w: %s", ErrCheckpointMismatch, path)
+		}
+		for _, line := range lines[1:] {
+			idx, ok := strings.CutPrefix(line, "done ")
+			n, err := strconv.Atoi(idx)
+			if !ok || err != nil || n < 0 {
+				return nil, fmt.Errorf("%w: %s: %q", ErrCorruptedCheckpoint, path, line)
+			}
+			cp.done[n] = true
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Truncate(int64(valid)); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(int64(valid), 0); err != nil {
+		f.Close()
+		return nil, err
+	}
+	cp.f = f
+	if len(lines) == 0 {
+		if err := cp.append("plan " + planID); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return cp, nil
+}
+
+// record durably marks move i as finished
+func (cp *checkpoint) record(i int) error {
+	cp.done[i] = true
+	if cp.f == nil {
+		return nil
+	}
+	return cp.append("done " + strconv.Itoa(i))
+}
+
+func (cp *checkpoint) append(line string) error {
+	if _, err := cp.f.WriteString(line + "\n"); err != nil {
+		return err
+	}
+	return cp.f.Sync()
+}
+
+// Close closes the checkpoint file
+func (cp *checkpoint) Close() error {
+	if cp.f == nil {
+		return nil
+	}
+	return cp.f.Close()
+}
@@ -0,0 +1,294 @@
+package rebalance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/codec"
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/storagenode"
+)
+
+// ErrUnknownDisk is returned by NodeStore for disks it has no client for
+var ErrUnknownDisk = &RebalanceError{"unknown disk"}
+
+// Store reads and writes shards on named disks
+type Store interface {
+	// Get returns the shard stored under key on disk
+	Get(ctx context.Context, disk, key string) ([]byte, error)
+	// Put stores data under key on disk
+	Put(ctx context.Context, disk, key string, data []byte) error
+	// Delete removes key from disk; deleting a missing shard is not an error
+	Delete(ctx context.Context, disk, key string) error
+}
+
+// NodeStore is a Store backed by storage nodes, one per disk name
+type NodeStore map[string]*storagenode.Client
+
+// Get implements Store
+func (s NodeStore) Get(ctx context.Context, disk, key string) ([]byte, error) {
+	c, ok := s[disk]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownDisk, disk)
+	}
+	return c.Get(ctx, key)
+}
+
+// Put implements Store
+func (s NodeStore) Put(ctx context.Context, disk, key string, data []byte) error {
+	c, ok := s[disk]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownDisk, disk)
+	}
+	return c.Put(ctx, key, data)
+}
+
+// Delete implements Store
+func (s NodeStore) Delete(ctx context.Context, disk, key string) error {
+	c, ok := s[disk]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownDisk, disk)
+	}
+	return c.Delete(ctx, key)
+}
+
+// ExecOptions configures Execute
+type ExecOptions struct {
+	// Store moves the shard bytes
+	Store Store
+	// BytesPerSecond limits how fast shards are read (0 = unlimited).
+	// Reconstruction counts all k helper shards.
+	BytesPerSecond int64
+	// Checkpoint is the path of the progress file ("" = no checkpoint). If
+	// it exists and belongs to the same plan, finished moves are skipped.
+	Checkpoint string
+}
+
+// Result summarises an Execute run
+type Result struct {
+	// Stripes are the shard locations after the run; persist them in place
+	// of the plan's input stripes
+	Stripes []Stripe
+	// Copied and Reconstructed count moves done in this run
+	Copied        int
+	Reconstructed int
+	// Resumed counts moves skipped because the checkpoint had them
+	Resumed int
+	// Bytes is the number of shard bytes read in this run
+	Bytes int64
+	// Orphans lists "<disk>:<key>" shards that were moved but could not be
+	// deleted from their old disk
+	Orphans []string
+}
+
+// Execute carries out plan
+//
+// Each move writes the shard to its target before deleting the old copy and
+// is recorded in the checkpoint only afterwards, so a crash at any point
+// leaves every shard readable and re-running Execute with the same plan and
+// checkpoint finishes the job. A copy whose source has gone missing (for
+// example because the previous run crashed right after deleting it) falls
+// back to reconstruction.
+//
+// On error the returned Result still describes the moves completed so far.
+//
+// Errors:
+//   - ErrNoStore if opts.Store is nil
+//   - ErrInvalidBandwidth if BytesPerSecond is negative
+//   - ErrCheckpointMismatch or ErrCorruptedCheckpoint for a bad checkpoint
+//   - ErrUnrecoverable if a shard can be neither copied nor reconstructed
+//   - store and context errors
+func Execute(ctx context.Context, plan *Plan, opts ExecOptions) (*Result, error) {
+	if opts.Store == nil {
+		return nil, ErrNoStore
+	}
+	if opts.BytesPerSecond < 0 {
+		return nil, ErrInvalidBandwidth
+	}
+	cp, err := openCheckpoint(opts.Checkpoint, plan.ID())
+	if err != nil {
+		return nil, err
+	}
+	defer cp.Close()
+
+	res := &Result{Stripes: make([]Stripe, len(plan.Stripes))}
+	for i, s := range plan.Stripes {
+		s.Disks = append([]string(nil), s.Disks...)
+		res.Stripes[i] = s
+	}
+	for i, m := range plan.Moves {
+		if cp.done[i] {
+			res.Stripes[m.Stripe].Disks[m.Shard] = m.To
+			res.Resumed++
+		}
+	}
+
+	x := &executor{store: opts.Store, limit: newLimiter(opts.BytesPerSecond), res: res}
+	for i, m := range plan.Moves {
+		if cp.done[i] {
+			continue
+		}
+		if err := x.move(ctx, m); err != nil {
+			return res, fmt.Errorf("move %d (%s shard %d %s -> %s): %w",
+				i, plan.Stripes[m.Stripe].ID, m.Shard, m.From, m.To, err)
+		}
+		if err := cp.record(i); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// executor holds the state of one Execute run
+type executor struct {
+	store Store
+	limit *limiter
+	res   *Result
+}
+
+// move relocates one shard and updates the live locations
+func (x *executor) move(ctx context.Context, m Move) error {
+	stripe := &x.res.Stripes[m.Stripe]
+	key := ShardKey(stripe.ID, m.Shard)
+
+	var data []byte
+	var err error
+	if !m.Reconstruct {
+		if err = x.limit.wait(ctx, stripe.ShardSize); err != nil {
+			return err
+		}
+		x.res.Bytes += stripe.ShardSize
+		data, err = x.store.Get(ctx, m.From, key)
+		if err == nil && int64(len(data)) != stripe.ShardSize {
+			err = fmt.Errorf("source shard is %d bytes, want %d", len(data), stripe.ShardSize)
+		}
+		if err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	rebuilt := m.Reconstruct || err != nil
+	if rebuilt {
+		if data, err = x.reconstruct(ctx, stripe, m); err != nil {
+			return err
+		}
+	}
+
+	if err := x.store.Put(ctx, m.To, key, data); err != nil {
+		return err
+	}
+	if rebuilt {
+		x.res.Reconstructed++
+	} else {
+		x.res.Copied++
+	}
+	stripe.Disks[m.Shard] = m.To
+	if m.From != "" {
+		if err := x.store.Delete(ctx, m.From, key); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			x.res.Orphans = append(x.res.Orphans, m.From+":"+key)
+		}
+	}
+	return nil
+}
+
+// reconstruct rebuilds shard m.Shard from k other shards, trying the
+// planned helpers first and any other located shard after them
+func (x *executor) reconstruct(ctx context.Context, stripe *Stripe, m Move) ([]byte, error) {
+	c, err := codec.Parse(stripe.Codec)
+	if err != nil {
+		return nil, err
+	}
+	order := append([]int(nil), m.Helpers...)
+	for i := range stripe.Disks {
+		if i != m.Shard && !containsInt(m.Helpers, i) {
+			order = append(order, i)
+		}
+	}
+
+	shards := make([][]byte, len(stripe.Disks))
+	have := 0
+	var errs []error
+	for _, i := range order {
+		if have == c.DataShards() {
+			break
+		}
+		disk := stripe.Disks[i]
+		if disk == "" {
+			continue
+		}
+		if err := x.limit.wait(ctx, stripe.ShardSize); err != nil {
+			return nil, err
+		}
+		x.res.Bytes += stripe.ShardSize
+		data, err := x.store.Get(ctx, disk, ShardKey(stripe.ID, i))
+		if err == nil && int64(len(data)) != stripe.ShardSize {
+			err = fmt.Errorf("shard is %d bytes, want %d", len(data), stripe.ShardSize)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			errs = append(errs, fmt.Errorf("shard %d on %s: %w", i, disk, err))
+			continue
+		}
+		shards[i] = data
+		have++
+	}
+	if have < c.DataShards() {
+		return nil, fmt.Errorf("%w: %v", ErrUnrecoverable, errors.Join(errs...))
+	}
+	if err := c.Reconstruct(shards); err != nil {
+		return nil, err
+	}
+	return shards[m.Shard], nil
+}
+
+func containsInt(list []int, v int) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// limiter paces reads to a byte rate: each request is scheduled after the
+// previous one has "drained" at the configured rate
+type limiter struct {
+	rate float64 // bytes per second; 0 = unlimited
+	next time.Time
+}
+
+func newLimiter(bytesPerSecond int64) *limiter {
+	return &limiter{rate: float64(bytesPerSecond)}
+}
+
+// wait blocks until n more bytes may be transferred
+func (l *limiter) wait(ctx context.Context, n int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if l.rate == 0 {
+		return nil
+	}
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	start := l.next
+	l.next = l.next.Add(time.Duration(float64(n) / l.rate * float64(time.Second)))
+
+	if d := time.Until(start); d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
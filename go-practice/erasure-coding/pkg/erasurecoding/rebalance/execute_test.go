@@ -0,0 +1,341 @@
+package rebalance
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/codec"
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/storagenode"
+)
+
+// memStore keeps shards in memory and can simulate dead disks and crashes
+type memStore struct {
+	mu        sync.Mutex
+	disks     map[string]map[string][]byte
+	down      map[string]bool
+	gets      map[string]int
+	failAfter int // fail every Put after this many (0 = never)
+	puts      int
+}
+
+func newMemStore() *memStore {
+	return &memStore{disks: map[string]map[string][]byte{}, down: map[string]bool{}, gets: map[string]int{}}
+}
+
+func (s *memStore) Get(_ context.Context, disk, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gets[disk]++
+	if s.down[disk] {
+		return nil, fmt.Errorf("disk %s is down", disk)
+	}
+	data, ok := s.disks[disk][key]
+	if !ok {
+		return nil, storagenode.ErrShardNotFound
+	}
+	return append([]byte(nil), data...), nil
+}
+
+func (s *memStore) Put(_ context.Context, disk, key string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failAfter > 0 && s.puts >= s.failAfter {
+		return errors.New("simulated crash")
+	}
+	s.puts++
+	if s.disks[disk] == nil {
+		s.disks[disk] = map[string][]byte{}
+	}
+	s.disks[disk][key] = append([]byte(nil), data...)
+	return nil
+}
+
+func (s *memStore) Delete(_ context.Context, disk, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down[disk] {
+		return fmt.Errorf("disk %s is down", disk)
+	}
+	delete(s.disks[disk], key)
+	return nil
+}
+
+// fill encodes random data for every stripe and stores the shards where
+// the stripe says they are; it returns the shards by stripe
+func fill(t *testing.T, s *memStore, stripes []Stripe) [][][]byte {
+	t.Helper()
+	rng := rand.New(rand.NewSource(1))
+	all := make([][][]byte, len(stripes))
+	for i, st := range stripes {
+		c, _ := codec.Parse(st.Codec)
+		data := make([]byte, int(st.ShardSize)*c.DataShards())
+		rng.Read(data)
+		shards, err := codec.Split(c, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Encode(shards); err != nil {
+			t.Fatal(err)
+		}
+		for shard, disk := range st.Disks {
+			if disk != "" {
+				_ = s.Put(context.Background(), disk, ShardKey(st.ID, shard), shards[shard])
+			}
+		}
+		all[i] = shards
+	}
+	return all
+}
+
+// checkLayout verifies every shard sits exactly where res says
+func checkLayout(t *testing.T, s *memStore, res *Result, want [][][]byte) {
+	t.Helper()
+	total := 0
+	for i, st := range res.Stripes {
+		for shard, disk := range st.Disks {
+			got := s.disks[disk][ShardKey(st.ID, shard)]
+			if !bytes.Equal(got, want[i][shard]) {
+				t.Fatalf("stripe %s shard %d on %s has wrong contents", st.ID, shard, disk)
+			}
+			total++
+		}
+	}
+	stored := 0
+	for _, keys := range s.disks {
+		stored += len(keys)
+	}
+	if stored != total+len(res.Orphans) {
+		t.Errorf("store holds %d shards, want %d plus %d orphans", stored, total, len(res.Orphans))
+	}
+}
+
+func TestExecute_AddDisk(t *testing.T) {
+	before := hostTopology(t, "a", "b", "c", "d")
+	after := hostTopology(t, "a", "b", "c", "d", "e")
+	stripes := placedStripes(t, before, "xor:2+1", 3, 100)
+	store := newMemStore()
+	shards := fill(t, store, stripes)
+
+	plan, _ := NewPlan(after, stripes, PlanOptions{FailureDomain: "host"})
+	res, err := Execute(context.Background(), plan, ExecOptions{Store: store})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if res.Copied != len(plan.Moves) || res.Reconstructed != 0 {
+		t.Errorf("Execute() copied %d, reconstructed %d, want %d copies", res.Copied, res.Reconstructed, len(plan.Moves))
+	}
+	checkLayout(t, store, res, shards)
+
+	again, _ := NewPlan(after, res.Stripes, PlanOptions{FailureDomain: "host"})
+	if len(again.Moves) != 0 {
+		t.Errorf("re-planning after Execute found %d moves", len(again.Moves))
+	}
+}
+
+func TestExecute_ReconstructsOffFailedDisk(t *testing.T) {
+	before := hostTopology(t, "a", "b", "c", "d", "e")
+	after := hostTopology(t, "a", "b", "c", "d")
+	stripes := placedStripes(t, before, "xor:2+1", 3, 60)
+	store := newMemStore()
+	shards := fill(t, store, stripes)
+	store.down["e"] = true
+
+	plan, err := NewPlan(after, stripes, PlanOptions{FailureDomain: "host", Unavailable: []string{"e"}})
+	if err != nil {
+		t.Fatalf("NewPlan() error = %v", err)
+	}
+	res, err := Execute(context.Background(), plan, ExecOptions{Store: store})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if res.Reconstructed == 0 || store.gets["e"] != 0 {
+		t.Errorf("reconstructed %d shards with %d reads from the failed disk", res.Reconstructed, store.gets["e"])
+	}
+	delete(store.disks, "e")
+	res.Orphans = nil
+	checkLayout(t, store, res, shards)
+}
+
+func TestExecute_CopyFallsBackToReconstruct(t *testing.T) {
+	topo := hostTopology(t, "a", "b", "c", "d")
+	stripes := placedStripes(t, topo, "xor:2+1", 3, 1)
+	store := newMemStore()
+	shards := fill(t, store, stripes)
+
+	// Pretend shard 0 sits on the only unused disk, which lost it
+	var spare string
+	for _, d := range []string{"a", "b", "c", "d"} {
+		if !containsString(stripes[0].Disks, d) {
+			spare = d
+		}
+	}
+	stale := stripes[0]
+	stale.Disks = append([]string{spare}, stale.Disks[1:]...)
+	store.disks[stripes[0].Disks[0]] = nil
+
+	plan, _ := NewPlan(topo, []Stripe{stale}, PlanOptions{FailureDomain: "host"})
+	res, err := Execute(context.Background(), plan, ExecOptions{Store: store})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if res.Copied != 0 || res.Reconstructed != 1 {
+		t.Errorf("copied %d, reconstructed %d, want 1 reconstruction", res.Copied, res.Reconstructed)
+	}
+	checkLayout(t, store, res, shards)
+}
+
+func containsString(list []string, v string) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func TestExecute_ResumesFromCheckpoint(t *testing.T) {
+	before := hostTopology(t, "a", "b", "c", "d")
+	after := hostTopology(t, "a", "b", "c", "d", "e")
+	stripes := placedStripes(t, before, "xor:2+1", 3, 100)
+	store := newMemStore()
+	shards := fill(t, store, stripes)
+	plan, _ := NewPlan(after, stripes, PlanOptions{FailureDomain: "host"})
+	if len(plan.Moves) < 10 {
+		t.Fatalf("plan has only %d moves", len(plan.Moves))
+	}
+	path := filepath.Join(t.TempDir(), "rebalance.ckpt")
+
+	store.failAfter = store.puts + 7
+	res, err := Execute(context.Background(), plan, ExecOptions{Store: store, Checkpoint: path})
+	if err == nil {
+		t.Fatal("Execute() with crashing store succeeded")
+	}
+	if res.Copied != 7 {
+		t.Errorf("first run copied %d shards, want 7", res.Copied)
+	}
+
+	store.failAfter = 0
+	res, err = Execute(context.Background(), plan, ExecOptions{Store: store, Checkpoint: path})
+	if err != nil {
+		t.Fatalf("resumed Execute() error = %v", err)
+	}
+	if res.Resumed != 7 || res.Copied != len(plan.Moves)-7 {
+		t.Errorf("resumed run skipped %d and copied %d of %d moves", res.Resumed, res.Copied, len(plan.Moves))
+	}
+	checkLayout(t, store, res, shards)
+}
+
+func TestExecute_CheckpointTornLineAndMismatch(t *testing.T) {
+	before := hostTopology(t, "a", "b", "c", "d")
+	after := hostTopology(t, "a", "b", "c", "d", "e")
+	stripes := placedStripes(t, before, "xor:2+1", 3, 50)
+	store := newMemStore()
+	shards := fill(t, store, stripes)
+	plan, _ := NewPlan(after, stripes, PlanOptions{FailureDomain: "host"})
+	path := filepath.Join(t.TempDir(), "rebalance.ckpt")
+
+	other, _ := NewPlan(after, stripes[:10], PlanOptions{FailureDomain: "host"})
+	os.WriteFile(path, []byte("plan "+other.ID()+"\n"), 0o644)
+	if _, err := Execute(context.Background(), plan, ExecOptions{Store: store, Checkpoint: path}); !errors.Is(err, ErrCheckpointMismatch) {
+		t.Fatalf("Execute() with foreign checkpoint error = %v, want %v", err, ErrCheckpointMismatch)
+	}
+
+	// Move 0 done and a torn record for move 1: only move 0 is skipped
+	m := plan.Moves[0]
+	store.Put(context.Background(), m.To, ShardKey(stripes[m.Stripe].ID, m.Shard), shards[m.Stripe][m.Shard])
+	store.Delete(context.Background(), m.From, ShardKey(stripes[m.Stripe].ID, m.Shard))
+	os.WriteFile(path, []byte("plan "+plan.ID()+"\ndone 0\ndo"), 0o644)
+
+	res, err := Execute(context.Background(), plan, ExecOptions{Store: store, Checkpoint: path})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if res.Resumed != 1 {
+		t.Errorf("Resumed = %d, want 1", res.Resumed)
+	}
+	checkLayout(t, store, res, shards)
+}
+
+func TestExecute_BandwidthLimit(t *testing.T) {
+	before := hostTopology(t, "a", "b", "c", "d")
+	after := hostTopology(t, "a", "b", "c", "d", "e")
+	stripes := placedStripes(t, before, "xor:2+1", 3, 40)
+	store := newMemStore()
+	fill(t, store, stripes)
+	plan, _ := NewPlan(after, stripes, PlanOptions{FailureDomain: "host"})
+
+	// 16-byte shards at 1600 B/s: every copy after the first waits 10ms
+	start := time.Now()
+	res, err := Execute(context.Background(), plan, ExecOptions{Store: store, BytesPerSecond: 1600})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	want := time.Duration(len(plan.Moves)-1) * 10 * time.Millisecond
+	if elapsed := time.Since(start); elapsed < want*8/10 {
+		t.Errorf("moving %d bytes took %v, want at least ~%v", res.Bytes, elapsed, want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Execute(ctx, plan, ExecOptions{Store: store, BytesPerSecond: 1}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Execute(cancelled) error = %v", err)
+	}
+}
+
+func TestExecute_NodeStore(t *testing.T) {
+	names := []string{"a", "b", "c", "d"}
+	store := NodeStore{}
+	for _, name := range names {
+		node, err := storagenode.New(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv := httptest.NewServer(node)
+		t.Cleanup(srv.Close)
+		store[name] = storagenode.NewClient(srv.URL, nil)
+	}
+	ctx := context.Background()
+
+	before := hostTopology(t, "a", "b", "c")
+	after := hostTopology(t, names...)
+	stripes := placedStripes(t, before, "replica:1+1", 2, 10)
+	for _, st := range stripes {
+		for shard, disk := range st.Disks {
+			store.Put(ctx, disk, ShardKey(st.ID, shard), bytes.Repeat([]byte{byte(shard)}, 16))
+		}
+	}
+
+	plan, _ := NewPlan(after, stripes, PlanOptions{FailureDomain: "host"})
+	res, err := Execute(ctx, plan, ExecOptions{Store: store})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	for _, st := range res.Stripes {
+		for shard, disk := range st.Disks {
+			if _, err := store.Get(ctx, disk, ShardKey(st.ID, shard)); err != nil {
+				t.Errorf("shard %s/%d missing on %s: %v", st.ID, shard, disk, err)
+			}
+		}
+	}
+	if _, err := store.Get(ctx, "z", "x"); !errors.Is(err, ErrUnknownDisk) {
+		t.Errorf("Get(unknown disk) error = %v, want %v", err, ErrUnknownDisk)
+	}
+}
+
+func TestExecute_Validation(t *testing.T) {
+	if _, err := Execute(context.Background(), &Plan{}, ExecOptions{}); err != ErrNoStore {
+		t.Errorf("Execute(no store) error = %v, want %v", err, ErrNoStore)
+	}
+	if _, err := Execute(context.Background(), &Plan{}, ExecOptions{Store: newMemStore(), BytesPerSecond: -1}); err != ErrInvalidBandwidth {
+		t.Errorf("Execute(-1 B/s) error = %v, want %v", err, ErrInvalidBandwidth)
+	}
+}
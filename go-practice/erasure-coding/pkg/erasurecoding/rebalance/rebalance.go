@@ -0,0 +1,242 @@
+// Package rebalance moves stored shards to where a placement topology says
+// they should live.
+//
+// After disks are added, reweighted or retired, placement.Topology maps many
+// stripes to different disks than the ones currently holding their shards.
+// NewPlan compares the current locations with the desired placement and
+// lists the shard moves needed. Execute carries them out against a Store:
+//
+//   - a move copies the shard from its current disk to the target, or, when
+//     the source is unavailable or already serving its share of copies,
+//     reads k other shards of the stripe and reconstructs it on the target;
+//   - transfers are throttled to a bytes-per-second limit;
+//   - every finished move is appended to a checkpoint file, so a rebalance
+//     interrupted by a crash resumes where it stopped.
+//
+// Shards are keyed as "<stripe ID>/<shard>", which matches
+// coordinator.ShardID when the stripe ID is "<object>/<stripe>".
+package rebalance
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/codec"
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/placement"
+)
+
+// RebalanceError represents errors returned by the rebalancer
+type RebalanceError struct {
+	message string
+}
+
+func (e *RebalanceError) Error() string {
+	return e.message
+}
+
+// Common errors
+var (
+	ErrNoTopology          = &RebalanceError{"a topology is required"}
+	ErrNoStore             = &RebalanceError{"a store is required"}
+	ErrInvalidStripe       = &RebalanceError{"stripe shard count does not match its codec"}
+	ErrUnrecoverable       = &RebalanceError{"too few readable shards to reconstruct"}
+	ErrCheckpointMismatch  = &RebalanceError{"checkpoint belongs to a different plan"}
+	ErrInvalidBandwidth    = &RebalanceError{"bandwidth limit cannot be negative"}
+	ErrInvalidSourceLimit  = &RebalanceError{"source copy limit cannot be negative"}
+	ErrDuplicateStripeID   = &RebalanceError{"stripe IDs must be unique"}
+	ErrCorruptedCheckpoint = &RebalanceError{"checkpoint file is corrupted"}
+)
+
+// Stripe records where the shards of one stripe are stored today
+type Stripe struct {
+	// ID names the stripe; it is also the placement key
+	ID string `json:"id"`
+	// Codec is the codec spec the stripe was encoded with, e.g. "xor:4+1"
+	Codec string `json:"codec"`
+	// ShardSize is the size of every shard in bytes
+	ShardSize int64 `json:"shardSize"`
+	// Disks[i] is the disk holding shard i, or "" if the shard is lost
+	Disks []string `json:"disks"`
+}
+
+// ShardKey returns the store key of one shard of a stripe
+func ShardKey(stripeID string, shard int) string {
+	return fmt.Sprintf("%s/%d", stripeID, shard)
+}
+
+// Move relocates one shard
+type Move struct {
+	// Stripe indexes Plan.Stripes
+	Stripe int `json:"stripe"`
+	// Shard is the shard index within the stripe
+	Shard int `json:"shard"`
+	// From is the current disk ("" if the shard is lost)
+	From string `json:"from"`
+	// To is the disk the placement wants the shard on
+	To string `json:"to"`
+	// Reconstruct rebuilds the shard from Helpers instead of copying it
+	Reconstruct bool `json:"reconstruct,omitempty"`
+	// Helpers are the shard indexes read to reconstruct
+	Helpers []int `json:"helpers,omitempty"`
+}
+
+// Plan is the list of moves that brings stripes in line with a topology
+type Plan struct {
+	// Stripes are the locations the plan was computed from
+	Stripes []Stripe `json:"stripes"`
+	// Moves in execution order
+	Moves []Move `json:"moves"`
+	// Reads and Writes count planned bytes per disk
+	Reads  map[string]int64 `json:"reads"`
+	Writes map[string]int64 `json:"writes"`
+}
+
+// PlanOptions tunes how moves are sourced
+type PlanOptions struct {
+	// FailureDomain and Root are passed to placement.Rule; Shards comes
+	// from each stripe
+	FailureDomain string
+	Root          string
+	// MaxCopiesPerSource caps how many shards are copied off one disk;
+	// further moves from that disk reconstruct on the target from the other
+	// shards instead (0 = no cap)
+	MaxCopiesPerSource int
+	// Unavailable lists disks that cannot be read, e.g. failed ones; their
+	// shards are always reconstructed
+	Unavailable []string
+}
+
+// NewPlan computes the moves that bring stripes to their placement in topo
+//
+// Shards already on their target disk stay put. For the rest a copy is
+// planned unless the source is unavailable or has reached
+// MaxCopiesPerSource, in which case the k least-loaded readable shards of
+// the stripe are chosen as reconstruction helpers.
+//
+// Errors:
+//   - ErrNoTopology if topo is nil
+//   - ErrInvalidSourceLimit if MaxCopiesPerSource is negative
+//   - ErrDuplicateStripeID if two stripes share an ID
+//   - ErrInvalidStripe if a stripe's disk count differs from its codec
+//   - ErrUnrecoverable if a shard must be rebuilt but fewer than k shards
+//     are readable
+//   - placement and codec errors
+func NewPlan(topo *placement.Topology, stripes []Stripe, opts PlanOptions) (*Plan, error) {
+	if topo == nil {
+		return nil, ErrNoTopology
+	}
+	if opts.MaxCopiesPerSource < 0 {
+		return nil, ErrInvalidSourceLimit
+	}
+	unavailable := map[string]bool{"": true}
+	for _, d := range opts.Unavailable {
+		unavailable[d] = true
+	}
+
+	plan := &Plan{
+		Stripes: stripes,
+		Reads:   map[string]int64{},
+		Writes:  map[string]int64{},
+	}
+	copies := map[string]int{}
+	seen := map[string]bool{}
+
+	for s, stripe := range stripes {
+		if seen[stripe.ID] {
+			return nil, fmt.Errorf("%w: %q", ErrDuplicateStripeID, stripe.ID)
+		}
+		seen[stripe.ID] = true
+
+		c, err := codec.Parse(stripe.Codec)
+		if err != nil {
+			return nil, fmt.Errorf("stripe %q: %w", stripe.ID, err)
+		}
+		if len(stripe.Disks) != codec.TotalShards(c) {
+			return nil, fmt.Errorf("%w: stripe %q", ErrInvalidStripe, stripe.ID)
+		}
+		target, err := topo.Place(stripe.ID, placement.Rule{
+			Shards:        len(stripe.Disks),
+			FailureDomain: opts.FailureDomain,
+			Root:          opts.Root,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("stripe %q: %w", stripe.ID, err)
+		}
+
+		for shard, from := range stripe.Disks {
+			to := target[shard]
+			if from == to {
+				continue
+			}
+			move := Move{Stripe: s, Shard: shard, From: from, To: to}
+			limited := opts.MaxCopiesPerSource > 0 && copies[from] >= opts.MaxCopiesPerSource
+			if unavailable[from] || limited {
+				helpers := plan.pickHelpers(stripe, shard, c.DataShards(), unavailable)
+				if helpers == nil && unavailable[from] {
+					return nil, fmt.Errorf("%w: stripe %q shard %d", ErrUnrecoverable, stripe.ID, shard)
+				}
+				if helpers != nil {
+					move.Reconstruct = true
+					move.Helpers = helpers
+				}
+			}
+			if !move.Reconstruct {
+				copies[from]++
+				plan.Reads[from] += stripe.ShardSize
+			}
+			plan.Writes[to] += stripe.ShardSize
+			plan.Moves = append(plan.Moves, move)
+		}
+	}
+	return plan, nil
+}
+
+// pickHelpers chooses k readable shards other than skip on the least-read
+// disks and charges the reads to them, or returns nil if there are too few
+func (p *Plan) pickHelpers(stripe Stripe, skip, k int, unavailable map[string]bool) []int {
+	var candidates []int
+	for i, d := range stripe.Disks {
+		if i != skip && !unavailable[d] {
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) < k {
+		return nil
+	}
+	// Selection sort by planned reads keeps ties in shard order
+	for i := 0; i < k; i++ {
+		best := i
+		for j := i + 1; j < len(candidates); j++ {
+			if p.Reads[stripe.Disks[candidates[j]]] < p.Reads[stripe.Disks[candidates[best]]] {
+				best = j
+			}
+		}
+		candidates[i], candidates[best] = candidates[best], candidates[i]
+	}
+	helpers := candidates[:k]
+	for _, h := range helpers {
+		p.Reads[stripe.Disks[h]] += stripe.ShardSize
+	}
+	return helpers
+}
+
+// Bytes returns the total number of bytes the plan reads
+func (p *Plan) Bytes() int64 {
+	var total int64
+	for _, n := range p.Reads {
+		total += n
+	}
+	return total
+}
+
+// ID fingerprints the plan so a checkpoint can only resume the plan that
+// wrote it
+func (p *Plan) ID() string {
+	h := sha256.New()
+	for _, m := range p.Moves {
+		stripe := p.Stripes[m.Stripe]
+		fmt.Fprintf(h, "%s\x00%d\x00%s\x00%s\x00%t\x00%v\n", stripe.ID, m.Shard, m.From, m.To, m.Reconstruct, m.Helpers)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,176 @@
+package rebalance
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/placement"
+)
+
+// hostTopology builds one host per disk name, every disk weighing 1
+func hostTopology(t *testing.T, disks ...string) *placement.Topology {
+	t.Helper()
+	root := placement.Bucket("root", "root")
+	for _, d := range disks {
+		root.Children = append(root.Children, placement.Bucket("host", "host-"+d, placement.Disk(d, 1)))
+	}
+	topo, err := placement.NewTopology(root)
+	if err != nil {
+		t.Fatalf("NewTopology() error = %v", err)
+	}
+	return topo
+}
+
+// placedStripes places count stripes of spec with topo
+func placedStripes(t *testing.T, topo *placement.Topology, spec string, shards, count int) []Stripe {
+	t.Helper()
+	stripes := make([]Stripe, count)
+	for i := range stripes {
+		id := fmt.Sprintf("obj/%d", i)
+		disks, err := topo.Place(id, placement.Rule{Shards: shards, FailureDomain: "host"})
+		if err != nil {
+			t.Fatalf("Place() error = %v", err)
+		}
+		stripes[i] = Stripe{ID: id, Codec: spec, ShardSize: 16, Disks: disks}
+	}
+	return stripes
+}
+
+func TestNewPlan_NothingToMove(t *testing.T) {
+	topo := hostTopology(t, "a", "b", "c", "d")
+	stripes := placedStripes(t, topo, "xor:2+1", 3, 50)
+
+	plan, err := NewPlan(topo, stripes, PlanOptions{FailureDomain: "host"})
+	if err != nil {
+		t.Fatalf("NewPlan() error = %v", err)
+	}
+	if len(plan.Moves) != 0 || plan.Bytes() != 0 {
+		t.Errorf("NewPlan() on a placed cluster = %d moves, %d bytes", len(plan.Moves), plan.Bytes())
+	}
+}
+
+func TestNewPlan_AddDisk(t *testing.T) {
+	before := hostTopology(t, "a", "b", "c", "d")
+	after := hostTopology(t, "a", "b", "c", "d", "e")
+	stripes := placedStripes(t, before, "xor:2+1", 3, 200)
+
+	plan, err := NewPlan(after, stripes, PlanOptions{FailureDomain: "host"})
+	if err != nil {
+		t.Fatalf("NewPlan() error = %v", err)
+	}
+	if len(plan.Moves) == 0 {
+		t.Fatal("NewPlan() found nothing to move after adding a disk")
+	}
+	toNew := 0
+	for _, m := range plan.Moves {
+		want, _ := after.Place(stripes[m.Stripe].ID, placement.Rule{Shards: 3, FailureDomain: "host"})
+		if m.To != want[m.Shard] || m.From != stripes[m.Stripe].Disks[m.Shard] || m.Reconstruct {
+			t.Fatalf("move %+v, want copy %s -> %s", m, stripes[m.Stripe].Disks[m.Shard], want[m.Shard])
+		}
+		if m.To == "e" {
+			toNew++
+		}
+	}
+	// Most of the traffic should be filling the new disk
+	if toNew*2 < len(plan.Moves) {
+		t.Errorf("%d of %d moves target the new disk", toNew, len(plan.Moves))
+	}
+	if plan.Writes["e"] != int64(toNew)*16 {
+		t.Errorf("Writes[e] = %d, want %d", plan.Writes["e"], toNew*16)
+	}
+}
+
+func TestNewPlan_UnavailableSourceReconstructs(t *testing.T) {
+	before := hostTopology(t, "a", "b", "c", "d", "e")
+	after := hostTopology(t, "a", "b", "c", "d")
+	stripes := placedStripes(t, before, "xor:2+1", 3, 100)
+
+	plan, err := NewPlan(after, stripes, PlanOptions{FailureDomain: "host", Unavailable: []string{"e"}})
+	if err != nil {
+		t.Fatalf("NewPlan() error = %v", err)
+	}
+	for _, m := range plan.Moves {
+		if m.From != "e" {
+			continue
+		}
+		if !m.Reconstruct || len(m.Helpers) != 2 {
+			t.Fatalf("move off failed disk = %+v, want reconstruction from 2 helpers", m)
+		}
+		for _, h := range m.Helpers {
+			if stripes[m.Stripe].Disks[h] == "e" || h == m.Shard {
+				t.Fatalf("move %+v uses an unusable helper", m)
+			}
+		}
+	}
+	if plan.Reads["e"] != 0 {
+		t.Errorf("plan reads %d bytes from the failed disk", plan.Reads["e"])
+	}
+}
+
+func TestNewPlan_MaxCopiesPerSource(t *testing.T) {
+	before := hostTopology(t, "a", "b", "c", "d", "e")
+	after := hostTopology(t, "a", "b", "c", "d")
+	stripes := placedStripes(t, before, "xor:2+1", 3, 100)
+
+	plan, err := NewPlan(after, stripes, PlanOptions{FailureDomain: "host", MaxCopiesPerSource: 5})
+	if err != nil {
+		t.Fatalf("NewPlan() error = %v", err)
+	}
+	copies, rebuilt := map[string]int{}, 0
+	for _, m := range plan.Moves {
+		if m.Reconstruct {
+			rebuilt++
+		} else {
+			copies[m.From]++
+		}
+	}
+	for disk, n := range copies {
+		if n > 5 {
+			t.Errorf("%d copies planned from %s, limit 5", n, disk)
+		}
+	}
+	if rebuilt == 0 {
+		t.Error("expected moves beyond the copy limit to reconstruct")
+	}
+}
+
+func TestNewPlan_Errors(t *testing.T) {
+	topo := hostTopology(t, "a", "b", "c")
+
+	if _, err := NewPlan(nil, nil, PlanOptions{}); err != ErrNoTopology {
+		t.Errorf("NewPlan(nil) error = %v, want %v", err, ErrNoTopology)
+	}
+	bad := []Stripe{{ID: "s", Codec: "xor:2+1", Disks: []string{"a", "b"}}}
+	if _, err := NewPlan(topo, bad, PlanOptions{}); !errors.Is(err, ErrInvalidStripe) {
+		t.Errorf("NewPlan(short stripe) error = %v, want %v", err, ErrInvalidStripe)
+	}
+	dup := []Stripe{
+		{ID: "s", Codec: "xor:2+1", Disks: []string{"a", "b", "c"}},
+		{ID: "s", Codec: "xor:2+1", Disks: []string{"a", "b", "c"}},
+	}
+	if _, err := NewPlan(topo, dup, PlanOptions{}); !errors.Is(err, ErrDuplicateStripeID) {
+		t.Errorf("NewPlan(duplicate) error = %v, want %v", err, ErrDuplicateStripeID)
+	}
+	// Two of three shards lost: nothing left to rebuild from
+	lost := []Stripe{{ID: "s", Codec: "xor:2+1", Disks: []string{"", "", "c"}}}
+	if _, err := NewPlan(topo, lost, PlanOptions{FailureDomain: "host"}); !errors.Is(err, ErrUnrecoverable) {
+		t.Errorf("NewPlan(lost) error = %v, want %v", err, ErrUnrecoverable)
+	}
+}
+
+func TestPlanID(t *testing.T) {
+	before := hostTopology(t, "a", "b", "c", "d")
+	after := hostTopology(t, "a", "b", "c", "d", "e")
+	stripes := placedStripes(t, before, "xor:2+1", 3, 20)
+
+	p1, _ := NewPlan(after, stripes, PlanOptions{FailureDomain: "host"})
+	p2, _ := NewPlan(after, stripes, PlanOptions{FailureDomain: "host"})
+	p3, _ := NewPlan(after, stripes[:10], PlanOptions{FailureDomain: "host"})
+	if p1.ID() != p2.ID() {
+		t.Error("identical plans have different IDs")
+	}
+	if p1.ID() == p3.ID() {
+		t.Error("different plans share an ID")
+	}
+}
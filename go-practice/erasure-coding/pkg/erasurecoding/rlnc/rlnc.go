@@ -0,0 +1,327 @@
+// Package rlnc implements random linear network coding over GF(2^8).
+//
+// A generation of k equal-size source symbols is sent as coded packets.
+// Each packet is a random linear combination of the symbols and carries
+// its coefficient vector, so any k packets with independent vectors
+// recover the generation whichever node produced them. Unlike the codecs
+// in package codec there are no fixed shard indices: a relay that holds
+// some packets can send fresh combinations of them (Recode) without
+// decoding first, which is what makes peer-to-peer distribution robust to
+// losses on every hop.
+//
+// The Decoder runs Gauss-Jordan elimination one packet at a time. Its
+// rows stay in reduced row echelon form, so Rank reports progress after
+// every packet, a packet in the span of the rows already held is detected
+// and dropped as non-innovative, and the symbols are available the moment
+// the rank reaches k. The wire format of a packet is
+//
+//	generation uint32   big-endian
+//	k          uint16   big-endian
+//	coeffs     [k]byte
+//	payload    [...]byte
+//
+// Example:
+//
+//	rng := rand.New(rand.NewSource(1))
+//	enc, _ := rlnc.NewEncoder(7, rlnc.Split(data, 16))
+//	dec, _ := rlnc.NewDecoder(7, 16, enc.SymbolSize())
+//	for !dec.Complete() {
+//	    dec.Add(enc.Packet(rng))
+//	}
+//	symbols, _ := dec.Symbols()
+package rlnc
+
+import (
+	"encoding/binary"
+	"math/rand"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/gf256"
+)
+
+// RLNCError represents errors returned by the rlnc package
+type RLNCError struct {
+	message string
+}
+
+func (e *RLNCError) Error() string {
+	return e.message
+}
+
+// Common errors
+var (
+	ErrInvalidParams    = &RLNCError{"need 1 to 65535 symbols of one non-zero size"}
+	ErrMismatchedPacket = &RLNCError{"packet belongs to another generation or shape"}
+	ErrMalformedPacket  = &RLNCError{"malformed packet"}
+	ErrIncomplete       = &RLNCError{"rank is below the generation size"}
+	ErrNothingToRecode  = &RLNCError{"no packets held to recode from"}
+)
+
+// MaxSymbols is the largest generation size the packet header can carry
+const MaxSymbols = 1<<16 - 1
+
+// Packet is one coded packet: Payload = Σ Coeffs[i] · symbol i
+type Packet struct {
+	Generation uint32
+	Coeffs     []byte
+	Payload    []byte
+}
+
+// Bytes returns the wire encoding of p
+func (p Packet) Bytes() []byte {
+	b := make([]byte, 6+len(p.Coeffs)+len(p.Payload))
+	binary.BigEndian.PutUint32(b, p.Generation)
+	binary.BigEndian.PutUint16(b[4:], uint16(len(p.Coeffs)))
+	copy(b[6:], p.Coeffs)
+	copy(b[6+len(p.Coeffs):], p.Payload)
+	return b
+}
+
+// ParsePacket decodes a packet produced by Packet.Bytes; the slices alias b
+//
+// Errors:
+//   - ErrMalformedPacket for a short header, k = 0 or an empty payload
+func ParsePacket(b []byte) (Packet, error) {
+	if len(b) < 6 {
+		return Packet{}, ErrMalformedPacket
+	}
+	k := int(binary.BigEndian.Uint16(b[4:]))
+	if k == 0 || len(b) <= 6+k {
+		return Packet{}, ErrMalformedPacket
+	}
+	return Packet{
+		Generation: binary.BigEndian.Uint32(b),
+		Coeffs:     b[6 : 6+k],
+		Payload:    b[6+k:],
+	}, nil
+}
+
+// Split cuts data into k equal symbols, zero-padding the last one; the
+// caller keeps len(data) to trim the decoded generation
+func Split(data []byte, k int) [][]byte {
+	if k < 1 {
+		return nil
+	}
+	size := max((len(data)+k-1)/k, 1)
+	buf := make([]byte, k*size)
+	copy(buf, data)
+	symbols := make([][]byte, k)
+	for i := range symbols {
+		symbols[i] = buf[i*size : (i+1)*size : (i+1)*size]
+	}
+	return symbols
+}
+
+// Encoder produces coded packets of one generation at the source
+type Encoder struct {
+	generation uint32
+	symbols    [][]byte
+}
+
+// NewEncoder creates an encoder for the given source symbols
+//
+// Errors:
+//   - ErrInvalidParams unless 1 <= len(symbols) <= MaxSymbols and all
+//     symbols have the same non-zero size
+func NewEncoder(generation uint32, symbols [][]byte) (*Encoder, error) {
+	if len(symbols) < 1 || len(symbols) > MaxSymbols || len(symbols[0]) == 0 {
+		return nil, ErrInvalidParams
+	}
+	for _, s := range symbols {
+		if len(s) != len(symbols[0]) {
+			return nil, ErrInvalidParams
+		}
+	}
+	return &Encoder{generation: generation, symbols: symbols}, nil
+}
+
+// Symbols returns k
+func (e *Encoder) Symbols() int { return len(e.symbols) }
+
+// SymbolSize returns the payload size of every packet
+func (e *Encoder) SymbolSize() int { return len(e.symbols[0]) }
+
+// Packet returns a combination of the symbols with coefficients drawn
+// from rng. Over GF(2^8) k random packets are independent with
+// probability above 99.6%, so decoding takes k packets plus a fraction
+// of one on average.
+func (e *Encoder) Packet(rng *rand.Rand) Packet {
+	p := Packet{Generation: e.generation, Coeffs: make([]byte, len(e.symbols)), Payload: make([]byte, e.SymbolSize())}
+	rng.Read(p.Coeffs)
+	for i, c := range p.Coeffs {
+		if c != 0 {
+			gf256.MulAddSlice(c, e.symbols[i], p.Payload)
+		}
+	}
+	return p
+}
+
+// Systematic returns symbol i uncoded, with the unit coefficient vector.
+// Sending the k systematic packets first lets a loss-free receiver skip
+// elimination altogether.
+func (e *Encoder) Systematic(i int) Packet {
+	p := Packet{Generation: e.generation, Coeffs: make([]byte, len(e.symbols)), Payload: append([]byte(nil), e.symbols[i]...)}
+	p.Coeffs[i] = 1
+	return p
+}
+
+// Decoder collects packets of one generation, at a sink or at a relay
+type Decoder struct {
+	generation uint32
+	k, size    int
+
+	// coeffs and payloads are the rows in reduced row echelon form: row
+	// r has a 1 in column pivots[r] and 0 there in every other row
+	coeffs   [][]byte
+	payloads [][]byte
+	pivots   []int
+	// row[c] is the row whose pivot is column c, or -1
+	row []int
+
+	received, dropped int
+}
+
+// NewDecoder creates a decoder for a generation of k symbols of size bytes
+//
+// Errors:
+//   - ErrInvalidParams unless 1 <= k <= MaxSymbols and size >= 1
+func NewDecoder(generation uint32, k, size int) (*Decoder, error) {
+	if k < 1 || k > MaxSymbols || size < 1 {
+		return nil, ErrInvalidParams
+	}
+	row := make([]int, k)
+	for c := range row {
+		row[c] = -1
+	}
+	return &Decoder{generation: generation, k: k, size: size, row: row}, nil
+}
+
+// Rank returns the number of innovative packets received so far
+func (d *Decoder) Rank() int { return len(d.pivots) }
+
+// Complete reports whether the rank has reached k
+func (d *Decoder) Complete() bool { return len(d.pivots) == d.k }
+
+// Received returns the number of packets added, innovative or not
+func (d *Decoder) Received() int { return d.received }
+
+// NonInnovative returns the number of packets dropped because they were in
+// the span of the packets already held
+func (d *Decoder) NonInnovative() int { return d.dropped }
+
+// Add eliminates p against the rows held and keeps it if it raises the
+// rank; it reports whether p was innovative. p is not modified.
+//
+// Errors:
+//   - ErrMismatchedPacket if p has another generation, k or symbol size
+func (d *Decoder) Add(p Packet) (bool, error) {
+	if p.Generation != d.generation || len(p.Coeffs) != d.k || len(p.Payload) != d.size {
+		return false, ErrMismatchedPacket
+	}
+	d.received++
+	if d.Complete() {
+		d.dropped++
+		return false, nil
+	}
+
+	coeffs := append([]byte(nil), p.Coeffs...)
+	var payload []byte
+	// Forward-eliminate the held pivots, finding the new pivot on the way
+	pivot := -1
+	for c, f := range coeffs {
+		if f == 0 {
+			continue
+		}
+		r := d.row[c]
+		if r < 0 {
+			if pivot < 0 {
+				pivot = c
+			}
+			continue
+		}
+		if payload == nil {
+			payload = append([]byte(nil), p.Payload...)
+		}
+		gf256.MulAddSlice(f, d.coeffs[r], coeffs)
+		gf256.MulAddSlice(f, d.payloads[r], payload)
+	}
+	if pivot < 0 {
+		d.dropped++
+		return false, nil
+	}
+	if payload == nil {
+		payload = append([]byte(nil), p.Payload...)
+	}
+	// Every row is zero left of its pivot, so eliminating it only changes
+	// columns from its pivot on: the columns already passed are final and
+	// the new row is zero left of pivot too
+	if f := coeffs[pivot]; f != 1 {
+		inv := gf256.Inv(f)
+		gf256.MulSlice(inv, coeffs, coeffs)
+		gf256.MulSlice(inv, payload, payload)
+	}
+	// Back-substitute so no other row has a term in the new pivot column
+	for r := range d.coeffs {
+		if f := d.coeffs[r][pivot]; f != 0 {
+			gf256.MulAddSlice(f, coeffs, d.coeffs[r])
+			gf256.MulAddSlice(f, payload, d.payloads[r])
+		}
+	}
+	d.row[pivot] = len(d.pivots)
+	d.coeffs = append(d.coeffs, coeffs)
+	d.payloads = append(d.payloads, payload)
+	d.pivots = append(d.pivots, pivot)
+	return true, nil
+}
+
+// Symbol returns source symbol i if it is already decoded. Symbols can
+// come out before the rank reaches k, when a row has only its pivot left.
+func (d *Decoder) Symbol(i int) ([]byte, bool) {
+	r := d.row[i]
+	if r < 0 {
+		return nil, false
+	}
+	for c, f := range d.coeffs[r] {
+		if f != 0 && c != i {
+			return nil, false
+		}
+	}
+	return d.payloads[r], true
+}
+
+// Symbols returns the k source symbols in order; they alias the decoder
+//
+// Errors:
+//   - ErrIncomplete if the rank is below k
+func (d *Decoder) Symbols() ([][]byte, error) {
+	if !d.Complete() {
+		return nil, ErrIncomplete
+	}
+	symbols := make([][]byte, d.k)
+	for i := range symbols {
+		symbols[i] = d.payloads[d.row[i]]
+	}
+	return symbols, nil
+}
+
+// Recode returns a fresh packet: a random combination of the rows held,
+// with coefficients from rng. The rows span exactly the packets received,
+// so this is a random combination of those packets, and it is innovative
+// for any receiver whose span does not already contain them.
+//
+// Errors:
+//   - ErrNothingToRecode if no innovative packet has been added
+func (d *Decoder) Recode(rng *rand.Rand) (Packet, error) {
+	if len(d.pivots) == 0 {
+		return Packet{}, ErrNothingToRecode
+	}
+	p := Packet{Generation: d.generation, Coeffs: make([]byte, d.k), Payload: make([]byte, d.size)}
+	w := make([]byte, len(d.pivots))
+	rng.Read(w)
+	for r, f := range w {
+		if f != 0 {
+			gf256.MulAddSlice(f, d.coeffs[r], p.Coeffs)
+			gf256.MulAddSlice(f, d.payloads[r], p.Payload)
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,317 @@
+package rlnc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// source returns an encoder for k random symbols of size bytes
+func source(t testing.TB, rng *rand.Rand, k, size int) *Encoder {
+	t.Helper()
+	symbols := make([][]byte, k)
+	for i := range symbols {
+		symbols[i] = make([]byte, size)
+		rng.Read(symbols[i])
+	}
+	enc, err := NewEncoder(1, symbols)
+	if err != nil {
+		t.Fatalf("NewEncoder() error = %v", err)
+	}
+	return enc
+}
+
+// checkSymbols fails unless d decoded exactly the encoder's symbols
+func checkSymbols(t *testing.T, d *Decoder, enc *Encoder) {
+	t.Helper()
+	got, err := d.Symbols()
+	if err != nil {
+		t.Fatalf("Symbols() error = %v", err)
+	}
+	for i := range got {
+		if !bytes.Equal(got[i], enc.symbols[i]) {
+			t.Fatalf("symbol %d differs", i)
+		}
+	}
+}
+
+func TestDecoder_RandomPackets(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, k := range []int{1, 2, 8, 32, 100} {
+		t.Run(fmt.Sprint(k), func(t *testing.T) {
+			enc := source(t, rng, k, 50)
+			d, _ := NewDecoder(1, k, 50)
+			for !d.Complete() {
+				before := d.Rank()
+				innovative, err := d.Add(enc.Packet(rng))
+				if err != nil {
+					t.Fatalf("Add() error = %v", err)
+				}
+				if want := before + 1; innovative && d.Rank() != want || !innovative && d.Rank() != before {
+					t.Fatalf("innovative = %v, rank %d -> %d", innovative, before, d.Rank())
+				}
+				if d.Received() > k+10 {
+					t.Fatalf("rank %d after %d packets", d.Rank(), d.Received())
+				}
+			}
+			if d.Received()-d.NonInnovative() != k {
+				t.Errorf("Received %d, NonInnovative %d, want a difference of %d", d.Received(), d.NonInnovative(), k)
+			}
+			checkSymbols(t, d, enc)
+		})
+	}
+}
+
+func TestDecoder_DropsNonInnovative(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	enc := source(t, rng, 4, 16)
+	d, _ := NewDecoder(1, 4, 16)
+	p, q := enc.Packet(rng), enc.Packet(rng)
+	d.Add(p)
+	d.Add(q)
+
+	// A duplicate and a combination of held packets add nothing
+	sum := Packet{Generation: 1, Coeffs: make([]byte, 4), Payload: make([]byte, 16)}
+	for _, x := range []Packet{p, q} {
+		for i := range x.Coeffs {
+			sum.Coeffs[i] ^= x.Coeffs[i]
+		}
+		for i := range x.Payload {
+			sum.Payload[i] ^= x.Payload[i]
+		}
+	}
+	for _, x := range []Packet{p, sum} {
+		if innovative, _ := d.Add(x); innovative {
+			t.Error("Add(packet in span) = innovative")
+		}
+	}
+	if d.Rank() != 2 || d.NonInnovative() != 2 || d.Received() != 4 {
+		t.Errorf("Rank %d, NonInnovative %d, Received %d, want 2, 2, 4", d.Rank(), d.NonInnovative(), d.Received())
+	}
+	if _, err := d.Symbols(); !errors.Is(err, ErrIncomplete) {
+		t.Errorf("Symbols() error = %v, want %v", err, ErrIncomplete)
+	}
+}
+
+func TestDecoder_SymbolBeforeComplete(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	enc := source(t, rng, 5, 8)
+	d, _ := NewDecoder(1, 5, 8)
+	d.Add(enc.Packet(rng))
+	d.Add(enc.Systematic(3))
+	if got, ok := d.Symbol(3); !ok || !bytes.Equal(got, enc.symbols[3]) {
+		t.Errorf("Symbol(3) = %v, %v after its systematic packet", got, ok)
+	}
+	if _, ok := d.Symbol(0); ok {
+		t.Error("Symbol(0) decoded from one coded packet")
+	}
+	for i := 0; i < 5; i++ {
+		d.Add(enc.Systematic(i))
+	}
+	checkSymbols(t, d, enc)
+}
+
+// A line source → relay → relay → sink where every hop loses packets and
+// the relays only recode what they hold
+func TestRecode_RelayChain(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+	const k, size, loss = 20, 64, 0.3
+	enc := source(t, rng, k, size)
+	hops := make([]*Decoder, 3)
+	for i := range hops {
+		hops[i], _ = NewDecoder(1, k, size)
+	}
+	sink := hops[2]
+	for round := 0; !sink.Complete(); round++ {
+		if round > 10*k {
+			t.Fatalf("sink rank %d after %d rounds", sink.Rank(), round)
+		}
+		if rng.Float64() >= loss {
+			hops[0].Add(enc.Packet(rng))
+		}
+		// Forward from the hop nearest the sink first so a packet takes
+		// one round per hop
+		for h := len(hops) - 1; h > 0; h-- {
+			p, err := hops[h-1].Recode(rng)
+			if err == nil && rng.Float64() >= loss {
+				hops[h].Add(p)
+			}
+		}
+	}
+	checkSymbols(t, sink, enc)
+}
+
+// Two relays each hold half the generation; neither can decode, but the
+// sink decodes from their recoded packets together
+func TestRecode_MergesPartialRelays(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	enc := source(t, rng, 10, 32)
+	a, _ := NewDecoder(1, 10, 32)
+	b, _ := NewDecoder(1, 10, 32)
+	for i := 0; i < 5; i++ {
+		a.Add(enc.Packet(rng))
+		b.Add(enc.Packet(rng))
+	}
+	sink, _ := NewDecoder(1, 10, 32)
+	for i := 0; i < 5; i++ {
+		pa, _ := a.Recode(rng)
+		pb, _ := b.Recode(rng)
+		sink.Add(pa)
+		sink.Add(pb)
+	}
+	for n := 0; !sink.Complete() && n < 10; n++ {
+		p, _ := a.Recode(rng)
+		sink.Add(p)
+		p, _ = b.Recode(rng)
+		sink.Add(p)
+	}
+	checkSymbols(t, sink, enc)
+
+	// Recoded packets never lift a receiver above the relay's own rank
+	c, _ := NewDecoder(1, 10, 32)
+	for i := 0; i < 20; i++ {
+		p, _ := a.Recode(rng)
+		c.Add(p)
+	}
+	if c.Rank() != a.Rank() {
+		t.Errorf("rank from one relay = %d, want its rank %d", c.Rank(), a.Rank())
+	}
+}
+
+func TestPacketEncoding(t *testing.T) {
+	rng := rand.New(rand.NewSource(6))
+	enc := source(t, rng, 7, 13)
+	p := enc.Packet(rng)
+	got, err := ParsePacket(p.Bytes())
+	if err != nil {
+		t.Fatalf("ParsePacket() error = %v", err)
+	}
+	if got.Generation != 1 || !bytes.Equal(got.Coeffs, p.Coeffs) || !bytes.Equal(got.Payload, p.Payload) {
+		t.Errorf("ParsePacket(Bytes()) = %+v, want %+v", got, p)
+	}
+	for _, b := range [][]byte{nil, {0, 0, 0, 1, 0}, {0, 0, 0, 1, 0, 0, 9}, {0, 0, 0, 1, 0, 2, 1, 2}} {
+		if _, err := ParsePacket(b); !errors.Is(err, ErrMalformedPacket) {
+			t.Errorf("ParsePacket(%v) error = %v, want %v", b, err, ErrMalformedPacket)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	data := []byte("random linear network coding!")
+	symbols := Split(data, 4)
+	if len(symbols) != 4 || len(symbols[0]) != 8 {
+		t.Fatalf("Split(%d bytes, 4) = %d symbols of %d", len(data), len(symbols), len(symbols[0]))
+	}
+	if got := bytes.Join(symbols, nil); !bytes.Equal(got[:len(data)], data) || got[len(got)-1] != 0 {
+		t.Errorf("joined symbols = %q", got)
+	}
+	if got := Split(nil, 3); len(got) != 3 || len(got[0]) != 1 {
+		t.Errorf("Split(nil, 3) = %v, want 3 one-byte symbols", got)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for _, symbols := range [][][]byte{nil, {{}}, {{1}, {1, 2}}} {
+		if _, err := NewEncoder(0, symbols); !errors.Is(err, ErrInvalidParams) {
+			t.Errorf("NewEncoder(%v) error = %v, want %v", symbols, err, ErrInvalidParams)
+		}
+	}
+	for _, tc := range [][2]int{{0, 1}, {MaxSymbols + 1, 1}, {4, 0}} {
+		if _, err := NewDecoder(0, tc[0], tc[1]); !errors.Is(err, ErrInvalidParams) {
+			t.Errorf("NewDecoder(%d, %d) error = %v, want %v", tc[0], tc[1], err, ErrInvalidParams)
+		}
+	}
+	d, _ := NewDecoder(1, 4, 8)
+	for _, p := range []Packet{
+		{Generation: 2, Coeffs: make([]byte, 4), Payload: make([]byte, 8)},
+		{Generation: 1, Coeffs: make([]byte, 3), Payload: make([]byte, 8)},
+		{Generation: 1, Coeffs: make([]byte, 4), Payload: make([]byte, 9)},
+	} {
+		if _, err := d.Add(p); !errors.Is(err, ErrMismatchedPacket) {
+			t.Errorf("Add(%+v) error = %v, want %v", p, err, ErrMismatchedPacket)
+		}
+	}
+	if d.Received() != 0 {
+		t.Errorf("Received() = %d after rejected packets", d.Received())
+	}
+	if _, err := d.Recode(rand.New(rand.NewSource(0))); !errors.Is(err, ErrNothingToRecode) {
+		t.Errorf("Recode(empty) error = %v, want %v", err, ErrNothingToRecode)
+	}
+}
+
+// Benchmark decoding a generation from random packets; bytes are the
+// generation size
+func BenchmarkDecode(b *testing.B) {
+	for _, k := range []int{16, 64, 256} {
+		b.Run(fmt.Sprint(k), func(b *testing.B) {
+			const size = 1024
+			rng := rand.New(rand.NewSource(1))
+			enc := source(b, rng, k, size)
+			packets := make([]Packet, k+4)
+			for i := range packets {
+				packets[i] = enc.Packet(rng)
+			}
+			b.SetBytes(int64(k * size))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				d, _ := NewDecoder(1, k, size)
+				for _, p := range packets {
+					if d.Complete() {
+						break
+					}
+					d.Add(p)
+				}
+			}
+		})
+	}
+}
+
+// Benchmark producing one recoded packet at a relay holding a full
+// generation; bytes are one packet payload
+func BenchmarkRecode(b *testing.B) {
+	for _, k := range []int{16, 64, 256} {
+		b.Run(fmt.Sprint(k), func(b *testing.B) {
+			const size = 1024
+			rng := rand.New(rand.NewSource(1))
+			enc := source(b, rng, k, size)
+			d, _ := NewDecoder(1, k, size)
+			for !d.Complete() {
+				d.Add(enc.Packet(rng))
+			}
+			b.SetBytes(size)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, _ = d.Recode(rng)
+			}
+		})
+	}
+}
+
+func ExampleDecoder() {
+	rng := rand.New(rand.NewSource(1))
+	data := []byte("relays recode without decoding")
+	enc, _ := NewEncoder(7, Split(data, 4))
+
+	// The relay holds three of the four dimensions, the sink gets its
+	// recoded packets and then one more from the source
+	relay, _ := NewDecoder(7, 4, enc.SymbolSize())
+	for relay.Rank() < 3 {
+		relay.Add(enc.Packet(rng))
+	}
+	sink, _ := NewDecoder(7, 4, enc.SymbolSize())
+	for i := 0; i < 4; i++ {
+		p, _ := relay.Recode(rng)
+		sink.Add(p)
+	}
+	fmt.Println("rank from relay:", sink.Rank(), "dropped:", sink.NonInnovative())
+	for !sink.Complete() {
+		sink.Add(enc.Packet(rng))
+	}
+	symbols, _ := sink.Symbols()
+	fmt.Printf("%s\n", bytes.Join(symbols, nil)[:len(data)])
+	// Output:
+	// rank from relay: 3 dropped: 1
+	// relays recode without decoding
+}
@@ -0,0 +1,321 @@
+// Package s3gateway serves an objstore.Store over a small subset of the S3
+// HTTP API, so existing S3 clients can exercise erasure coding locally.
+//
+// Supported operations (path-style addressing, no authentication):
+//
+//	PUT    /bucket/key                PutObject
+//	GET    /bucket/key                GetObject, including Range requests
+//	HEAD   /bucket/key                HeadObject
+//	DELETE /bucket/key                DeleteObject
+//	GET    /bucket?list-type=2        ListObjectsV2 (prefix, delimiter,
+//	                                  max-keys, start-after, continuation-token)
+//
+// Buckets are implicit: they exist as soon as an object is written to them.
+//
+// Two extension headers expose the erasure coding:
+//
+//	X-Ec-Codec          on PUT, selects the codec spec (e.g. "replica:1+2");
+//	                    on GET/HEAD, reports the codec the object uses
+//	X-Ec-Degraded-Read  on GET/HEAD, set to the number of unavailable shards
+//	                    when the object had to be reconstructed
+package s3gateway
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/codec"
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/objstore"
+)
+
+// Extension headers
+const (
+	HeaderCodec    = "X-Ec-Codec"
+	HeaderDegraded = "X-Ec-Degraded-Read"
+)
+
+// MaxObjectSize bounds PUT bodies, since objects are encoded in memory
+const MaxObjectSize = 1 << 30
+
+const defaultMaxKeys = 1000
+
+// Server is an http.Handler implementing the S3 subset
+type Server struct {
+	store *objstore.Store
+}
+
+// New creates a Server backed by store
+func New(store *objstore.Store) *Server {
+	return &Server{store: store}
+}
+
+// ServeHTTP dispatches a request to the matching S3 operation
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket == "" {
+		writeError(w, r, http.StatusNotImplemented, "NotImplemented", "ListBuckets is not supported")
+		return
+	}
+
+	if key == "" {
+		switch r.Method {
+		case http.MethodGet:
+			s.listObjectsV2(w, r, bucket)
+		case http.MethodPut, http.MethodHead:
+			// Buckets are implicit, so creating or probing one always succeeds
+			w.WriteHeader(http.StatusOK)
+		default:
+			writeError(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed", "method not allowed on bucket")
+		}
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		s.putObject(w, r, bucket, key)
+	case http.MethodGet, http.MethodHead:
+		s.getObject(w, r, bucket, key)
+	case http.MethodDelete:
+		s.deleteObject(w, r, bucket, key)
+	default:
+		writeError(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed", "method not allowed on object")
+	}
+}
+
+func (s *Server) putObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	opts := objstore.PutOptions{ContentType: r.Header.Get("Content-Type")}
+	if spec := r.Header.Get(HeaderCodec); spec != "" {
+		c, err := codec.Parse(spec)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "InvalidArgument", err.Error())
+			return
+		}
+		opts.Codec = c
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, MaxObjectSize+1))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+	if len(data) > MaxObjectSize {
+		writeError(w, r, http.StatusBadRequest, "EntityTooLarge", "object exceeds maximum size")
+		return
+	}
+
+	meta, err := s.store.Put(bucket, key, data, opts)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", quoteETag(meta.ETag))
+	w.Header().Set(HeaderCodec, meta.Codec)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) getObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	obj, err := s.store.Open(bucket, key)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	defer obj.Close()
+
+	h := w.Header()
+	h.Set("ETag", quoteETag(obj.Meta.ETag))
+	h.Set(HeaderCodec, obj.Meta.Codec)
+	if obj.Meta.ContentType != "" {
+		h.Set("Content-Type", obj.Meta.ContentType)
+	} else {
+		h.Set("Content-Type", "application/octet-stream")
+	}
+	if obj.Degraded() {
+		h.Set(HeaderDegraded, strconv.Itoa(len(obj.Missing)))
+	}
+
+	// ServeContent handles Range, If-Match, If-None-Match, etc. and only
+	// reads the requested bytes through the range reader
+	http.ServeContent(w, r, "", obj.Meta.ModTime, obj.NewSectionReader(0, obj.Size()))
+}
+
+func (s *Server) deleteObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	if err := s.store.Delete(bucket, key); err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listBucketResult is the ListObjectsV2 response body
+type listBucketResult struct {
+	XMLName               xml.Name       `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+	Name                  string         `xml:"Name"`
+	Prefix                string         `xml:"Prefix"`
+	Delimiter             string         `xml:"Delimiter,omitempty"`
+	StartAfter            string         `xml:"StartAfter,omitempty"`
+	ContinuationToken     string         `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+	KeyCount              int            `xml:"KeyCount"`
+	MaxKeys               int            `xml:"MaxKeys"`
+	IsTruncated           bool           `xml:"IsTruncated"`
+	Contents              []listContents `xml:"Contents"`
+	CommonPrefixes        []commonPrefix `xml:"CommonPrefixes"`
+}
+
+type listContents struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type commonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+func (s *Server) listObjectsV2(w http.ResponseWriter, r *http.Request, bucket string) {
+	q := r.URL.Query()
+	if q.Get("list-type") != "2" {
+		writeError(w, r, http.StatusNotImplemented, "NotImplemented", "only ListObjectsV2 (list-type=2) is supported")
+		return
+	}
+
+	maxKeys := defaultMaxKeys
+	if v := q.Get("max-keys"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, r, http.StatusBadRequest, "InvalidArgument", "invalid max-keys")
+			return
+		}
+		if n < maxKeys {
+			maxKeys = n
+		}
+	}
+
+	result := listBucketResult{
+		Name:              bucket,
+		Prefix:            q.Get("prefix"),
+		Delimiter:         q.Get("delimiter"),
+		StartAfter:        q.Get("start-after"),
+		ContinuationToken: q.Get("continuation-token"),
+		MaxKeys:           maxKeys,
+	}
+
+	after := result.StartAfter
+	if result.ContinuationToken != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(result.ContinuationToken)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "InvalidArgument", "invalid continuation token")
+			return
+		}
+		after = string(raw)
+	}
+
+	metas, err := s.store.List(bucket, result.Prefix)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+
+	// Walk keys in order, folding everything under a delimiter into a
+	// common prefix. Each content or prefix entry counts towards max-keys.
+	entries := listEntries(metas, result.Prefix, result.Delimiter)
+	start := sort.Search(len(entries), func(i int) bool { return entries[i].name > after })
+	for _, e := range entries[start:] {
+		if result.KeyCount == maxKeys {
+			result.IsTruncated = true
+			break
+		}
+		if e.meta == nil {
+			result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: e.name})
+		} else {
+			result.Contents = append(result.Contents, listContents{
+				Key:          e.meta.Key,
+				LastModified: e.meta.ModTime.UTC().Format(time.RFC3339Nano),
+				ETag:         quoteETag(e.meta.ETag),
+				Size:         e.meta.Size,
+				StorageClass: "STANDARD",
+			})
+		}
+		result.KeyCount++
+	}
+	if result.IsTruncated && result.KeyCount > 0 {
+		last := entries[start+result.KeyCount-1].name
+		result.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(last))
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	io.WriteString(w, xml.Header)
+	xml.NewEncoder(w).Encode(result)
+}
+
+// listEntry is either an object (meta set) or a common prefix
+type listEntry struct {
+	name string
+	meta *objstore.Meta
+}
+
+// listEntries turns sorted object metadata into sorted list entries,
+// collapsing keys that contain delimiter after prefix into common prefixes
+func listEntries(metas []*objstore.Meta, prefix, delimiter string) []listEntry {
+	var entries []listEntry
+	for _, meta := range metas {
+		if delimiter != "" {
+			if i := strings.Index(meta.Key[len(prefix):], delimiter); i >= 0 {
+				cp := meta.Key[:len(prefix)+i+len(delimiter)]
+				if n := len(entries); n == 0 || entries[n-1].name != cp {
+					entries = append(entries, listEntry{name: cp})
+				}
+				continue
+			}
+		}
+		entries = append(entries, listEntry{name: meta.Key, meta: meta})
+	}
+	return entries
+}
+
+// errorResponse is the S3 XML error body
+type errorResponse struct {
+	XMLName  xml.Name `xml:"Error"`
+	Code     string   `xml:"Code"`
+	Message  string   `xml:"Message"`
+	Resource string   `xml:"Resource"`
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	if r.Method == http.MethodHead {
+		return
+	}
+	io.WriteString(w, xml.Header)
+	xml.NewEncoder(w).Encode(errorResponse{Code: code, Message: message, Resource: r.URL.Path})
+}
+
+// writeStoreError maps objstore errors onto S3 error codes
+func writeStoreError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, objstore.ErrNotFound):
+		writeError(w, r, http.StatusNotFound, "NoSuchKey", err.Error())
+	case errors.Is(err, objstore.ErrInvalidBucket):
+		writeError(w, r, http.StatusBadRequest, "InvalidBucketName", err.Error())
+	case errors.Is(err, objstore.ErrInvalidKey):
+		writeError(w, r, http.StatusBadRequest, "InvalidArgument", err.Error())
+	case errors.Is(err, objstore.ErrNotEnoughDirs), errors.Is(err, objstore.ErrObjectDegraded):
+		writeError(w, r, http.StatusServiceUnavailable, "ServiceUnavailable", err.Error())
+	default:
+		writeError(w, r, http.StatusInternalServerError, "InternalError", err.Error())
+	}
+}
+
+func quoteETag(etag string) string {
+	return strconv.Quote(etag)
+}
@@ -0,0 +1,252 @@
+package s3gateway
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/codec"
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/objstore"
+)
+
+func newTestGateway(t *testing.T, numDirs int, spec string) (*httptest.Server, *objstore.Store) {
+	t.Helper()
+	c, err := codec.Parse(spec)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	root := t.TempDir()
+	dirs := make([]string, numDirs)
+	for i := range dirs {
+		dirs[i] = filepath.Join(root, fmt.Sprintf("disk%d", i))
+	}
+	store, err := objstore.New(dirs, c)
+	if err != nil {
+		t.Fatalf("objstore.New() error = %v", err)
+	}
+	srv := httptest.NewServer(New(store))
+	t.Cleanup(srv.Close)
+	return srv, store
+}
+
+func do(t *testing.T, method, url string, body []byte, header map[string]string) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s error = %v", method, url, err)
+	}
+	defer resp.Body.Close()
+	got, _ := io.ReadAll(resp.Body)
+	return resp, got
+}
+
+func TestObjectLifecycle(t *testing.T) {
+	srv, _ := newTestGateway(t, 4, "xor:3+1")
+	url := srv.URL + "/photos/2024/cat.jpg"
+	data := bytes.Repeat([]byte("meow "), 200)
+
+	resp, _ := do(t, http.MethodPut, url, data, map[string]string{"Content-Type": "image/jpeg"})
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") == "" {
+		t.Fatalf("PUT status = %d, ETag = %q", resp.StatusCode, resp.Header.Get("ETag"))
+	}
+	etag := resp.Header.Get("ETag")
+
+	resp, got := do(t, http.MethodGet, url, nil, nil)
+	if resp.StatusCode != http.StatusOK || !bytes.Equal(got, data) {
+		t.Fatalf("GET status = %d, body match = %v", resp.StatusCode, bytes.Equal(got, data))
+	}
+	if resp.Header.Get("ETag") != etag || resp.Header.Get("Content-Type") != "image/jpeg" {
+		t.Errorf("GET headers = %v", resp.Header)
+	}
+	if resp.Header.Get(HeaderDegraded) != "" {
+		t.Errorf("GET %s = %q on healthy object", HeaderDegraded, resp.Header.Get(HeaderDegraded))
+	}
+
+	resp, got = do(t, http.MethodHead, url, nil, nil)
+	if resp.StatusCode != http.StatusOK || len(got) != 0 || resp.ContentLength != int64(len(data)) {
+		t.Errorf("HEAD status = %d, length = %d", resp.StatusCode, resp.ContentLength)
+	}
+
+	resp, _ = do(t, http.MethodDelete, url, nil, nil)
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("DELETE status = %d", resp.StatusCode)
+	}
+
+	resp, got = do(t, http.MethodGet, url, nil, nil)
+	if resp.StatusCode != http.StatusNotFound || !strings.Contains(string(got), "<Code>NoSuchKey</Code>") {
+		t.Errorf("GET after DELETE status = %d, body = %s", resp.StatusCode, got)
+	}
+}
+
+func TestRangeGet(t *testing.T) {
+	srv, _ := newTestGateway(t, 5, "xor:4+1")
+	url := srv.URL + "/bucket/range"
+	data := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	do(t, http.MethodPut, url, data, nil)
+
+	tests := []struct {
+		rangeHeader string
+		want        string
+	}{
+		{"bytes=0-3", "0123"},
+		{"bytes=8-12", "89abc"},
+		{"bytes=30-", "uvwxyz"},
+		{"bytes=-4", "wxyz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rangeHeader, func(t *testing.T) {
+			resp, got := do(t, http.MethodGet, url, nil, map[string]string{"Range": tt.rangeHeader})
+			if resp.StatusCode != http.StatusPartialContent || string(got) != tt.want {
+				t.Errorf("GET Range %s = %d %q, want 206 %q", tt.rangeHeader, resp.StatusCode, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDegradedGet(t *testing.T) {
+	srv, store := newTestGateway(t, 5, "xor:4+1")
+	url := srv.URL + "/bucket/degraded"
+	data := bytes.Repeat([]byte("0123456789"), 100)
+
+	do(t, http.MethodPut, url, data, nil)
+
+	meta, _ := store.Head("bucket", "degraded")
+	os.RemoveAll(store.Dirs()[meta.Placement[1]])
+
+	resp, got := do(t, http.MethodGet, url, nil, nil)
+	if resp.StatusCode != http.StatusOK || !bytes.Equal(got, data) {
+		t.Fatalf("degraded GET status = %d, body match = %v", resp.StatusCode, bytes.Equal(got, data))
+	}
+	if resp.Header.Get(HeaderDegraded) != "1" {
+		t.Errorf("degraded GET %s = %q, want 1", HeaderDegraded, resp.Header.Get(HeaderDegraded))
+	}
+
+	resp, got = do(t, http.MethodGet, url, nil, map[string]string{"Range": "bytes=250-259"})
+	if resp.StatusCode != http.StatusPartialContent || string(got) != "0123456789" {
+		t.Errorf("degraded range GET = %d %q", resp.StatusCode, got)
+	}
+
+	// A second lost directory is too many for xor:4+1
+	for _, d := range meta.Placement[2:] {
+		if _, err := os.Stat(store.Dirs()[d]); err == nil {
+			os.RemoveAll(store.Dirs()[d])
+			break
+		}
+	}
+	resp, _ = do(t, http.MethodGet, url, nil, nil)
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("GET with too many lost directories status = %d, want 503", resp.StatusCode)
+	}
+}
+
+func TestDegradedGet_SelectedCodec(t *testing.T) {
+	srv, store := newTestGateway(t, 5, "xor:4+1")
+	url := srv.URL + "/bucket/replicated"
+	data := []byte("replication tolerates more lost directories")
+
+	resp, _ := do(t, http.MethodPut, url, data, map[string]string{HeaderCodec: "replica:1+2"})
+	if resp.Header.Get(HeaderCodec) != "replica:1+2" {
+		t.Fatalf("PUT %s = %q", HeaderCodec, resp.Header.Get(HeaderCodec))
+	}
+
+	meta, _ := store.Head("bucket", "replicated")
+	os.RemoveAll(store.Dirs()[meta.Placement[0]])
+	os.RemoveAll(store.Dirs()[meta.Placement[1]])
+
+	resp, got := do(t, http.MethodGet, url, nil, nil)
+	if resp.StatusCode != http.StatusOK || !bytes.Equal(got, data) || resp.Header.Get(HeaderDegraded) != "2" {
+		t.Errorf("replica GET = %d %q, %s = %q", resp.StatusCode, got, HeaderDegraded, resp.Header.Get(HeaderDegraded))
+	}
+}
+
+func TestPutInvalidCodec(t *testing.T) {
+	srv, _ := newTestGateway(t, 3, "xor:2+1")
+	resp, _ := do(t, http.MethodPut, srv.URL+"/bucket/obj", []byte("x"), map[string]string{HeaderCodec: "bogus:1+1"})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("PUT invalid codec status = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestListObjectsV2(t *testing.T) {
+	srv, _ := newTestGateway(t, 3, "xor:2+1")
+	for _, key := range []string{"a.txt", "docs/1.txt", "docs/2.txt", "docs/sub/3.txt", "z.txt"} {
+		do(t, http.MethodPut, srv.URL+"/bucket/"+key, []byte(key), nil)
+	}
+
+	list := func(query string) listBucketResult {
+		t.Helper()
+		resp, body := do(t, http.MethodGet, srv.URL+"/bucket?list-type=2"+query, nil, nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("list status = %d, body = %s", resp.StatusCode, body)
+		}
+		var result listBucketResult
+		if err := xml.Unmarshal(body, &result); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+		return result
+	}
+	keys := func(r listBucketResult) []string {
+		var out []string
+		for _, c := range r.Contents {
+			out = append(out, c.Key)
+		}
+		for _, p := range r.CommonPrefixes {
+			out = append(out, p.Prefix)
+		}
+		return out
+	}
+
+	if got := keys(list("")); len(got) != 5 {
+		t.Errorf("list all = %v", got)
+	}
+
+	r := list("&delimiter=/")
+	if len(r.Contents) != 2 || len(r.CommonPrefixes) != 1 || r.CommonPrefixes[0].Prefix != "docs/" {
+		t.Errorf("list delimiter = %v", keys(r))
+	}
+
+	r = list("&prefix=docs/&delimiter=/")
+	if len(r.Contents) != 2 || len(r.CommonPrefixes) != 1 || r.CommonPrefixes[0].Prefix != "docs/sub/" {
+		t.Errorf("list prefix+delimiter = %v", keys(r))
+	}
+
+	// Page through with max-keys=2
+	var all []string
+	token := ""
+	for page := 0; page < 5; page++ {
+		q := "&max-keys=2"
+		if token != "" {
+			q += "&continuation-token=" + token
+		}
+		r = list(q)
+		for _, c := range r.Contents {
+			all = append(all, c.Key)
+		}
+		if !r.IsTruncated {
+			break
+		}
+		token = r.NextContinuationToken
+	}
+	if strings.Join(all, ",") != "a.txt,docs/1.txt,docs/2.txt,docs/sub/3.txt,z.txt" {
+		t.Errorf("paged list = %v", all)
+	}
+
+	r = list("&start-after=docs/2.txt")
+	if got := keys(r); len(got) != 2 || got[0] != "docs/sub/3.txt" {
+		t.Errorf("list start-after = %v", got)
+	}
+}
@@ -0,0 +1,203 @@
+// Package shamir implements Shamir's threshold secret sharing over GF(2^8).
+//
+// Erasure codes (and the phase1 XOR parity) protect availability, not
+// confidentiality: every data chunk is a verbatim slice of the input. For
+// key material we want the opposite trade-off. Split turns a secret into n
+// shares so that any t of them reconstruct it, and any t-1 or fewer reveal
+// nothing about it.
+//
+// Every byte of the secret is the constant term of its own random
+// polynomial of degree t-1; share i holds the values of all polynomials at
+// x = i. Combine recovers the constant terms by Lagrange interpolation at
+// x = 0. The share encoding is one byte of threshold, one byte of
+// x-coordinate and then the y bytes, so a share is only two bytes longer
+// than the secret and is self-describing:
+//
+//	threshold uint8
+//	x         uint8   1..255
+//	y         [len(secret)]byte
+//
+// Recording the threshold lets Combine refuse to return a (meaningless)
+// secret from too few shares. Shares beyond the threshold are checked
+// against the polynomial, which catches most accidental corruption; this
+// is not protection against a malicious shareholder.
+package shamir
+
+import (
+	"crypto/rand"
+	"io"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/gf256"
+)
+
+// ShamirError represents errors returned by the shamir package
+type ShamirError struct {
+	message string
+}
+
+func (e *ShamirError) Error() string {
+	return e.message
+}
+
+// Common errors
+var (
+	ErrInvalidThreshold   = &ShamirError{"threshold must be between 1 and the number of shares"}
+	ErrTooManyShares      = &ShamirError{"at most 255 shares are supported"}
+	ErrEmptySecret        = &ShamirError{"secret must not be empty"}
+	ErrNotEnoughShares    = &ShamirError{"fewer shares than the threshold"}
+	ErrMismatchedShares   = &ShamirError{"shares differ in threshold or length"}
+	ErrDuplicateShare     = &ShamirError{"two shares have the same x-coordinate"}
+	ErrInconsistentShares = &ShamirError{"shares do not lie on one polynomial"}
+	ErrMalformedShare     = &ShamirError{"malformed share"}
+)
+
+// MaxShares is the largest n: x-coordinates are the non-zero field elements
+const MaxShares = 255
+
+// Share is one share of a secret
+type Share struct {
+	// Threshold is the number of shares needed to recover the secret
+	Threshold int
+	// X is the evaluation point, never zero
+	X byte
+	// Y holds one polynomial value per secret byte
+	Y []byte
+}
+
+// Bytes returns the compact encoding of s
+func (s Share) Bytes() []byte {
+	b := make([]byte, 2+len(s.Y))
+	b[0] = byte(s.Threshold)
+	b[1] = s.X
+	copy(b[2:], s.Y)
+	return b
+}
+
+// ParseShare decodes a share produced by Share.Bytes
+//
+// Errors:
+//   - ErrMalformedShare for short input, a zero x or a zero threshold
+func ParseShare(b []byte) (Share, error) {
+	if len(b) < 3 || b[0] == 0 || b[1] == 0 {
+		return Share{}, ErrMalformedShare
+	}
+	return Share{Threshold: int(b[0]), X: b[1], Y: append([]byte(nil), b[2:]...)}, nil
+}
+
+// Split divides secret into n shares, any t of which recover it, using
+// crypto/rand for the polynomial coefficients
+//
+// Errors:
+//   - ErrEmptySecret for an empty secret
+//   - ErrTooManyShares if n > 255
+//   - ErrInvalidThreshold unless 1 <= t <= n
+func Split(secret []byte, n, t int) ([]Share, error) {
+	return SplitWithRand(secret, n, t, rand.Reader)
+}
+
+// SplitWithRand is Split with an explicit randomness source; the shares
+// are only as secret as rng is unpredictable
+func SplitWithRand(secret []byte, n, t int, rng io.Reader) ([]Share, error) {
+	if len(secret) == 0 {
+		return nil, ErrEmptySecret
+	}
+	if n > MaxShares {
+		return nil, ErrTooManyShares
+	}
+	if t < 1 || t > n {
+		return nil, ErrInvalidThreshold
+	}
+
+	// coeffs[j][i] is the coefficient of x^(j+1) of byte i's polynomial
+	coeffs := make([][]byte, t-1)
+	for j := range coeffs {
+		coeffs[j] = make([]byte, len(secret))
+		if _, err := io.ReadFull(rng, coeffs[j]); err != nil {
+			return nil, err
+		}
+	}
+
+	shares := make([]Share, n)
+	for i := range shares {
+		x := byte(i + 1)
+		// Horner: ((a_{t-1} x + a_{t-2}) x + ... + a_1) x + secret
+		y := make([]byte, len(secret))
+		for j := len(coeffs) - 1; j >= 0; j-- {
+			gf256.MulSlice(x, y, y)
+			xorInto(y, coeffs[j])
+		}
+		gf256.MulSlice(x, y, y)
+		xorInto(y, secret)
+		shares[i] = Share{Threshold: t, X: x, Y: y}
+	}
+	return shares, nil
+}
+
+// Combine recovers the secret from at least Threshold shares
+//
+// The first Threshold shares determine the polynomials; any further shares
+// must agree with them.
+//
+// Errors:
+//   - ErrNotEnoughShares if fewer shares than their threshold are given
+//   - ErrMismatchedShares if the shares disagree on threshold or length
+//   - ErrDuplicateShare if an x-coordinate repeats
+//   - ErrMalformedShare for a zero x, zero threshold or empty Y
+//   - ErrInconsistentShares if an extra share is not on the polynomial
+func Combine(shares []Share) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, ErrNotEnoughShares
+	}
+	t, size := shares[0].Threshold, len(shares[0].Y)
+	seen := map[byte]bool{}
+	for _, s := range shares {
+		if s.X == 0 || s.Threshold < 1 || len(s.Y) == 0 {
+			return nil, ErrMalformedShare
+		}
+		if s.Threshold != t || len(s.Y) != size {
+			return nil, ErrMismatchedShares
+		}
+		if seen[s.X] {
+			return nil, ErrDuplicateShare
+		}
+		seen[s.X] = true
+	}
+	if len(shares) < t {
+		return nil, ErrNotEnoughShares
+	}
+
+	basis := shares[:t]
+	secret := interpolate(basis, 0, size)
+	for _, s := range shares[t:] {
+		want := interpolate(basis, s.X, size)
+		for i := range want {
+			if want[i] != s.Y[i] {
+				return nil, ErrInconsistentShares
+			}
+		}
+	}
+	return secret, nil
+}
+
+// interpolate evaluates the polynomials through shares at x using the
+// Lagrange form: f(x) = Σ_j y_j · Π_{m≠j} (x - x_m) / (x_j - x_m)
+func interpolate(shares []Share, x byte, size int) []byte {
+	out := make([]byte, size)
+	for j, sj := range shares {
+		l := byte(1)
+		for m, sm := range shares {
+			if m != j {
+				l = gf256.Mul(l, gf256.Div(x^sm.X, sj.X^sm.X))
+			}
+		}
+		gf256.MulAddSlice(l, sj.Y, out)
+	}
+	return out
+}
+
+// xorInto sets dst[i] ^= src[i]
+func xorInto(dst, src []byte) {
+	for i, b := range src {
+		dst[i] ^= b
+	}
+}
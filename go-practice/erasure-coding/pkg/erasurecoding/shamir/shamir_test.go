@@ -0,0 +1,184 @@
+package shamir
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// subsets calls fn with every size-k subset of shares
+func subsets(shares []Share, k int, fn func([]Share)) {
+	var rec func(start int, picked []Share)
+	rec = func(start int, picked []Share) {
+		if len(picked) == k {
+			fn(append([]Share(nil), picked...))
+			return
+		}
+		for i := start; i < len(shares); i++ {
+			rec(i+1, append(picked, shares[i]))
+		}
+	}
+	rec(0, nil)
+}
+
+func TestSplitCombine_EveryThresholdSubset(t *testing.T) {
+	secret := []byte("correct horse battery staple")
+	for _, tc := range []struct{ n, t int }{{1, 1}, {3, 1}, {3, 2}, {5, 3}, {6, 6}} {
+		t.Run(fmt.Sprintf("%d-of-%d", tc.t, tc.n), func(t *testing.T) {
+			shares, err := Split(secret, tc.n, tc.t)
+			if err != nil {
+				t.Fatalf("Split() error = %v", err)
+			}
+			if len(shares) != tc.n {
+				t.Fatalf("Split() returned %d shares", len(shares))
+			}
+			for k := tc.t; k <= tc.n; k++ {
+				subsets(shares, k, func(sub []Share) {
+					rand.Shuffle(len(sub), func(i, j int) { sub[i], sub[j] = sub[j], sub[i] })
+					got, err := Combine(sub)
+					if err != nil || !bytes.Equal(got, secret) {
+						t.Fatalf("Combine(%d shares) = %q, %v", k, got, err)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestCombine_FewerThanThresholdFails(t *testing.T) {
+	shares, err := Split([]byte("top secret"), 5, 3)
+	if err != nil {
+		t.Fatalf("Split() error = %v", err)
+	}
+	for k := 0; k < 3; k++ {
+		subsets(shares, k, func(sub []Share) {
+			if _, err := Combine(sub); !errors.Is(err, ErrNotEnoughShares) {
+				t.Errorf("Combine(%d shares) error = %v, want ErrNotEnoughShares", k, err)
+			}
+		})
+	}
+}
+
+func TestSplit_SharesBelowThresholdRevealNothing(t *testing.T) {
+	// With t = 2 a single share's y is secret + a·x for a uniform a, so its
+	// distribution must not depend on the secret
+	const trials = 25600
+	for _, secret := range []byte{0x00, 0xff} {
+		var counts [256]int
+		for i := 0; i < trials; i++ {
+			shares, err := Split([]byte{secret}, 3, 2)
+			if err != nil {
+				t.Fatalf("Split() error = %v", err)
+			}
+			counts[shares[1].Y[0]]++
+		}
+		for y, c := range counts {
+			if c < 40 || c > 180 { // expected 100
+				t.Fatalf("secret %#x: y = %#x seen %d times, want about 100", secret, y, c)
+			}
+		}
+	}
+}
+
+func TestShareEncoding(t *testing.T) {
+	secret := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	shares, _ := Split(secret, 4, 2)
+	var parsed []Share
+	for _, s := range shares {
+		b := s.Bytes()
+		if len(b) != len(secret)+2 || b[0] != 2 || b[1] != s.X {
+			t.Fatalf("Bytes() = %x", b)
+		}
+		p, err := ParseShare(b)
+		if err != nil {
+			t.Fatalf("ParseShare() error = %v", err)
+		}
+		parsed = append(parsed, p)
+	}
+	got, err := Combine(parsed[2:])
+	if err != nil || !bytes.Equal(got, secret) {
+		t.Errorf("Combine(parsed) = %v, %v", got, err)
+	}
+
+	for _, b := range [][]byte{nil, {2, 1}, {0, 1, 5}, {2, 0, 5}} {
+		if _, err := ParseShare(b); !errors.Is(err, ErrMalformedShare) {
+			t.Errorf("ParseShare(%x) error = %v, want ErrMalformedShare", b, err)
+		}
+	}
+}
+
+func TestCombine_RejectsBadShares(t *testing.T) {
+	shares, _ := Split([]byte("secret"), 4, 2)
+	other, _ := Split([]byte("secret"), 4, 3)
+	short := shares[1]
+	short.Y = short.Y[:3]
+	corrupt := shares[3]
+	corrupt.Y = append([]byte(nil), corrupt.Y...)
+	corrupt.Y[0] ^= 1
+
+	tests := []struct {
+		name   string
+		shares []Share
+		want   error
+	}{
+		{"duplicate", []Share{shares[0], shares[0]}, ErrDuplicateShare},
+		{"mixed thresholds", []Share{shares[0], other[1], other[2]}, ErrMismatchedShares},
+		{"mixed lengths", []Share{shares[0], short}, ErrMismatchedShares},
+		{"zero x", []Share{shares[0], {Threshold: 2, X: 0, Y: shares[1].Y}}, ErrMalformedShare},
+		{"off polynomial", []Share{shares[0], shares[1], corrupt}, ErrInconsistentShares},
+	}
+	for _, tt := range tests {
+		if _, err := Combine(tt.shares); !errors.Is(err, tt.want) {
+			t.Errorf("%s: Combine() error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestSplit_InvalidArguments(t *testing.T) {
+	tests := []struct {
+		secret []byte
+		n, t   int
+		want   error
+	}{
+		{nil, 3, 2, ErrEmptySecret},
+		{[]byte("s"), 256, 2, ErrTooManyShares},
+		{[]byte("s"), 3, 0, ErrInvalidThreshold},
+		{[]byte("s"), 3, 4, ErrInvalidThreshold},
+	}
+	for _, tt := range tests {
+		if _, err := Split(tt.secret, tt.n, tt.t); !errors.Is(err, tt.want) {
+			t.Errorf("Split(%q, %d, %d) error = %v, want %v", tt.secret, tt.n, tt.t, err, tt.want)
+		}
+	}
+	if _, err := SplitWithRand([]byte("s"), 3, 2, bytes.NewReader(nil)); err == nil {
+		t.Error("SplitWithRand(exhausted rng) succeeded")
+	}
+}
+
+func BenchmarkSplit(b *testing.B) {
+	secret := make([]byte, 32)
+	for i := 0; i < b.N; i++ {
+		Split(secret, 5, 3)
+	}
+}
+
+func BenchmarkCombine(b *testing.B) {
+	shares, _ := Split(make([]byte, 32), 5, 3)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Combine(shares[:3])
+	}
+}
+
+func ExampleSplit() {
+	shares, _ := Split([]byte("launch code"), 5, 3)
+	secret, _ := Combine([]Share{shares[4], shares[0], shares[2]})
+	fmt.Println(string(secret))
+	_, err := Combine(shares[:2])
+	fmt.Println(err)
+	// Output:
+	// launch code
+	// fewer shares than the threshold
+}
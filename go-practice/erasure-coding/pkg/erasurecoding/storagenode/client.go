@@ -0,0 +1,149 @@
+package storagenode
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/audit"
+)
+
+// Client talks to a single storage node
+type Client struct {
+	baseURL string
+	http    *http.Client
+}
+
+// NewClient creates a client for the node at baseURL (e.g.
+// "http://127.0.0.1:7001"). A nil httpClient uses http.DefaultClient.
+func NewClient(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{baseURL: strings.TrimSuffix(baseURL, "/"), http: httpClient}
+}
+
+// BaseURL returns the node address the client talks to
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// Put stores a shard under id
+func (c *Client) Put(ctx context.Context, id string, data []byte) error {
+	resp, err := c.do(ctx, http.MethodPut, c.shardURL(id), bytes.NewReader(data), nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Get fetches a whole shard
+//
+// Errors:
+//   - ErrShardNotFound if the node does not have the shard
+func (c *Client) Get(ctx context.Context, id string) ([]byte, error) {
+	resp, err := c.do(ctx, http.MethodGet, c.shardURL(id), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// GetRange fetches length bytes of a shard starting at off
+func (c *Client) GetRange(ctx context.Context, id string, off, length int64) ([]byte, error) {
+	if length == 0 {
+		return []byte{}, nil
+	}
+	header := http.Header{"Range": {fmt.Sprintf("bytes=%d-%d", off, off+length-1)}}
+	resp, err := c.do(ctx, http.MethodGet, c.shardURL(id), nil, header)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) != length {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return data, nil
+}
+
+// Delete removes a shard; deleting a missing shard is not an error
+func (c *Client) Delete(ctx context.Context, id string) error {
+	resp, err := c.do(ctx, http.MethodDelete, c.shardURL(id), nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Prove asks the node to answer an audit challenge for a shard, so a
+// Client can serve as an audit.Responder
+//
+// Errors:
+//   - ErrShardNotFound if the node does not have the shard
+func (c *Client) Prove(ctx context.Context, id string, ch *audit.Challenge) ([]byte, error) {
+	body, err := json.Marshal(ch)
+	if err != nil {
+		return nil, err
+	}
+	header := http.Header{"Content-Type": {"application/json"}}
+	resp, err := c.do(ctx, http.MethodPost, c.shardURL(id), bytes.NewReader(body), header)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(io.LimitReader(resp.Body, audit.ProofSize+1))
+}
+
+// Health fetches the node's health report
+func (c *Client) Health(ctx context.Context) (*Health, error) {
+	resp, err := c.do(ctx, http.MethodGet, c.baseURL+"/health", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var h Health
+	if err := json.NewDecoder(resp.Body).Decode(&h); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+func (c *Client) shardURL(id string) string {
+	return c.baseURL + ShardPathPrefix + url.PathEscape(id)
+}
+
+// do sends a request and turns non-2xx responses into errors
+func (c *Client) do(ctx context.Context, method, u string, body io.Reader, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrShardNotFound
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return nil, fmt.Errorf("storagenode: %s %s: %s: %s", method, u, resp.Status, strings.TrimSpace(string(msg)))
+}
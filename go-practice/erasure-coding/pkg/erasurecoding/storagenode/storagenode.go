@@ -0,0 +1,265 @@
+// Package storagenode implements a tiny shard storage server, so a cluster of
+// independent storage nodes can be modelled on a single machine.
+//
+// A node stores opaque shards as files in one directory and serves them over
+// HTTP:
+//
+//	PUT    /shards/{id}   store a shard (body is the shard data)
+//	GET    /shards/{id}   fetch a shard, honouring Range headers
+//	HEAD   /shards/{id}   check whether a shard exists
+//	DELETE /shards/{id}   remove a shard
+//	POST   /shards/{id}   answer an audit challenge (see package audit)
+//	GET    /health        report node status as JSON
+//
+// Shard IDs are arbitrary strings (path-escaped in the URL). They are stored
+// under their base64url encoding so any ID maps to a safe file name. An ID
+// whose encoding is longer than maxEncodedID would exceed the 255-byte file
+// name limit, so its file is named "~" and the hex SHA-256 of the ID
+// instead.
+package storagenode
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/audit"
+)
+
+// NodeError represents errors returned by a storage node
+type NodeError struct {
+	message string
+}
+
+func (e *NodeError) Error() string {
+	return e.message
+}
+
+// Common errors
+var (
+	ErrInvalidShardID = &NodeError{"invalid shard id"}
+	ErrShardNotFound  = &NodeError{"shard not found"}
+)
+
+// MaxShardSize bounds the size of a single PUT body
+const MaxShardSize = 256 << 20
+
+// maxChallengeSize bounds the JSON body of an audit challenge
+const maxChallengeSize = 64 << 10
+
+// ShardPathPrefix is the URL prefix of the shard endpoints
+const ShardPathPrefix = "/shards/"
+
+// Health is the JSON body returned by GET /health
+type Health struct {
+	Status string `json:"status"`
+	Shards int    `json:"shards"`
+	Bytes  int64  `json:"bytes"`
+}
+
+// Node serves shards stored in a local directory
+type Node struct {
+	dir      string
+	requests atomic.Int64
+}
+
+// New creates a Node storing shards in dir, creating it if needed
+func New(dir string) (*Node, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Node{dir: dir}, nil
+}
+
+// Dir returns the directory the node stores shards in
+func (n *Node) Dir() string {
+	return n.dir
+}
+
+// Requests returns the number of shard requests served so far
+func (n *Node) Requests() int64 {
+	return n.requests.Load()
+}
+
+// ServeHTTP implements http.Handler
+func (n *Node) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/health" {
+		n.health(w, r)
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, ShardPathPrefix) {
+		http.NotFound(w, r)
+		return
+	}
+
+	n.requests.Add(1)
+	id := strings.TrimPrefix(r.URL.Path, ShardPathPrefix)
+	if id == "" {
+		http.Error(w, ErrInvalidShardID.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		n.put(w, r, id)
+	case http.MethodGet, http.MethodHead:
+		n.get(w, r, id)
+	case http.MethodDelete:
+		n.delete(w, id)
+	case http.MethodPost:
+		n.prove(w, r, id)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (n *Node) put(w http.ResponseWriter, r *http.Request, id string) {
+	data, err := io.ReadAll(io.LimitReader(r.Body, MaxShardSize+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(data) > MaxShardSize {
+		http.Error(w, "shard too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err := writeFileAtomic(n.path(id), data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (n *Node) get(w http.ResponseWriter, r *http.Request, id string) {
+	f, err := os.Open(n.path(id))
+	if err != nil {
+		http.Error(w, ErrShardNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, "", fi.ModTime(), f)
+}
+
+func (n *Node) delete(w http.ResponseWriter, id string) {
+	if err := os.Remove(n.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// prove answers an audit challenge from the stored shard
+func (n *Node) prove(w http.ResponseWriter, r *http.Request, id string) {
+	var ch audit.Challenge
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxChallengeSize)).Decode(&ch); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f, err := os.Open(n.path(id))
+	if err != nil {
+		http.Error(w, ErrShardNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	proof, err := audit.Respond(&ch, f, fi.Size())
+	if errors.Is(err, audit.ErrInvalidChallenge) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(proof)
+}
+
+func (n *Node) health(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	h := Health{Status: "ok"}
+	entries, err := os.ReadDir(n.dir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".tmp-") {
+			continue
+		}
+		if fi, err := e.Info(); err == nil {
+			h.Shards++
+			h.Bytes += fi.Size()
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h)
+}
+
+func (n *Node) path(id string) string {
+	return filepath.Join(n.dir, shardFile(id))
+}
+
+// maxEncodedID is the longest base64url ID used as a file name as it is,
+// leaving room under NAME_MAX
+const maxEncodedID = 200
+
+// hashedIDPrefix starts the file names of hashed IDs; it is not in the
+// base64url alphabet
+const hashedIDPrefix = "~"
+
+// shardFile returns the file name of a shard: base64url(id), or the hashed
+// form for long IDs
+func shardFile(id string) string {
+	name := base64.RawURLEncoding.EncodeToString([]byte(id))
+	if len(name) <= maxEncodedID {
+		return name
+	}
+	sum := sha256.Sum256([]byte(id))
+	return hashedIDPrefix + hex.EncodeToString(sum[:])
+}
+
+// writeFileAtomic writes data to a temporary file, syncs it and renames it
+// into place so readers never observe a partial shard
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
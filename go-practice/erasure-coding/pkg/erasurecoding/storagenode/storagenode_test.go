@@ -0,0 +1,147 @@
+package storagenode
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/audit"
+)
+
+func newTestNode(t *testing.T) (*Node, *Client) {
+	t.Helper()
+	node, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	srv := httptest.NewServer(node)
+	t.Cleanup(srv.Close)
+	return node, NewClient(srv.URL, srv.Client())
+}
+
+func TestShardLifecycle(t *testing.T) {
+	_, client := newTestNode(t)
+	ctx := context.Background()
+	id := "object/with/slashes/0/1"
+	data := []byte("shard contents")
+
+	if err := client.Put(ctx, id, data); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	got, err := client.Get(ctx, id)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("Get() = %q, %v, want %q", got, err, data)
+	}
+
+	part, err := client.GetRange(ctx, id, 6, 4)
+	if err != nil || string(part) != "cont" {
+		t.Errorf("GetRange(6, 4) = %q, %v, want %q", part, err, "cont")
+	}
+
+	h, err := client.Health(ctx)
+	if err != nil || h.Status != "ok" || h.Shards != 1 || h.Bytes != int64(len(data)) {
+		t.Errorf("Health() = %+v, %v", h, err)
+	}
+
+	if err := client.Delete(ctx, id); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := client.Get(ctx, id); err != ErrShardNotFound {
+		t.Errorf("Get(deleted) error = %v, want %v", err, ErrShardNotFound)
+	}
+	if err := client.Delete(ctx, id); err != nil {
+		t.Errorf("Delete(missing) error = %v", err)
+	}
+}
+
+func TestPut_Overwrite(t *testing.T) {
+	node, client := newTestNode(t)
+	ctx := context.Background()
+
+	client.Put(ctx, "s", []byte("old"))
+	client.Put(ctx, "s", []byte("new"))
+	got, _ := client.Get(ctx, "s")
+	if string(got) != "new" {
+		t.Errorf("Get() = %q, want %q", got, "new")
+	}
+	if node.Requests() != 3 {
+		t.Errorf("Requests() = %d, want 3", node.Requests())
+	}
+}
+
+// IDs whose base64url is over NAME_MAX are stored under their hash
+func TestPut_LongIDs(t *testing.T) {
+	_, client := newTestNode(t)
+	ctx := context.Background()
+	prefix := strings.Repeat("id/", 340)
+	long1, long2 := prefix+"1", prefix+"2"
+
+	for _, id := range []string{long1, long2} {
+		if err := client.Put(ctx, id, []byte(id[len(id)-1:])); err != nil {
+			t.Fatalf("Put(%d-byte id) error = %v", len(id), err)
+		}
+	}
+	for _, id := range []string{long1, long2} {
+		if got, err := client.Get(ctx, id); err != nil || string(got) != id[len(id)-1:] {
+			t.Errorf("Get(%d-byte id) = %q, %v", len(id), got, err)
+		}
+	}
+	if err := client.Delete(ctx, long1); err != nil {
+		t.Fatalf("Delete(long id) error = %v", err)
+	}
+	if _, err := client.Get(ctx, long1); err != ErrShardNotFound {
+		t.Errorf("Get(deleted long id) error = %v, want %v", err, ErrShardNotFound)
+	}
+	if h, err := client.Health(ctx); err != nil || h.Shards != 1 {
+		t.Errorf("Health() = %+v, %v, want 1 shard", h, err)
+	}
+}
+
+func TestClient_NodeDown(t *testing.T) {
+	node, _ := New(t.TempDir())
+	srv := httptest.NewServer(node)
+	client := NewClient(srv.URL, nil)
+	srv.Close()
+
+	if err := client.Put(context.Background(), "s", []byte("x")); err == nil {
+		t.Error("Put() to stopped node expected error")
+	}
+}
+
+func TestProve(t *testing.T) {
+	node, client := newTestNode(t)
+	ctx := context.Background()
+	auditor, err := audit.NewAuditor([]byte("storage node audit key"), audit.Params{SegmentSize: 16, Segments: 4, Challenges: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	shard := bytes.Repeat([]byte("0123456789"), 50)
+	rec := auditor.Prepare("obj/0/1", shard)
+	client.Put(ctx, "obj/0/1", shard)
+
+	if err := auditor.Audit(ctx, rec, client); err != nil {
+		t.Fatalf("Audit() error = %v", err)
+	}
+
+	// Overwrite the shard on disk behind the node's back
+	if err := os.WriteFile(node.path("obj/0/1"), make([]byte, len(shard)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := auditor.Audit(ctx, rec, client); !errors.Is(err, audit.ErrProofMismatch) {
+		t.Errorf("Audit(corrupted) error = %v, want ErrProofMismatch", err)
+	}
+	if err := auditor.Audit(ctx, auditor.Prepare("missing", shard), client); err != ErrShardNotFound {
+		t.Errorf("Audit(missing) error = %v, want %v", err, ErrShardNotFound)
+	}
+	if _, err := client.Prove(ctx, "obj/0/1", &audit.Challenge{SegmentSize: 16}); err == nil {
+		t.Error("Prove(invalid challenge) expected error")
+	}
+	huge := &audit.Challenge{Nonce: make([]byte, audit.NonceSize), SegmentSize: 1 << 30, Segments: []int64{0}}
+	if _, err := client.Prove(ctx, "obj/0/1", huge); err == nil {
+		t.Error("Prove(huge segment size) expected error")
+	}
+}
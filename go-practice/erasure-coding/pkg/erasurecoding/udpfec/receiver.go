@@ -0,0 +1,276 @@
+package udpfec
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/codec"
+)
+
+// Datagram is one datagram delivered by a Receiver
+type Datagram struct {
+	// Seq is the position in the stream: block·k + index
+	Seq       uint64
+	Payload   []byte
+	Recovered bool // rebuilt from parity rather than received
+}
+
+// ReceiverStats counts what a Receiver has seen. Lost counts only blocks
+// already finished; Recv finishes the rest when the connection fails. A
+// block of which no packet arrived counts as k lost datagrams once a later
+// block shows up, and one at the end of the stream goes unnoticed.
+type ReceiverStats struct {
+	Received  int // data packets received in time
+	Parity    int // parity packets received in time
+	Recovered int // datagrams rebuilt from parity
+	Lost      int // datagrams neither received nor rebuilt
+	Late      int // packets of blocks already finished
+	Malformed int
+}
+
+// ResidualLossRate returns the fraction of datagrams FEC could not
+// deliver
+func (s ReceiverStats) ResidualLossRate() float64 {
+	total := s.Received + s.Recovered + s.Lost
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Lost) / float64(total)
+}
+
+// rxBlock is a block being collected
+type rxBlock struct {
+	deadline  time.Time
+	n         int      // data datagrams, 0 until a parity packet says
+	size      int      // shard size, 0 until a parity packet says
+	data      [][]byte // received or rebuilt payloads, nil if missing
+	parity    [][]byte // received parity shards
+	delivered int
+}
+
+// Receiver reassembles a stream sent by a Sender. It is meant to be driven
+// by one goroutine calling Recv; Stats may be called from any goroutine.
+type Receiver struct {
+	conn     net.PacketConn
+	c        codec.Codec
+	k, total int
+	delay    time.Duration
+
+	blocks map[uint32]*rxBlock
+	// next is one past the highest block seen. Blocks below it that are
+	// not pending are finished, and their packets are late.
+	next  uint32
+	queue []Datagram
+	buf   []byte
+
+	mu    sync.Mutex
+	stats ReceiverStats
+}
+
+// NewReceiver creates a Receiver reading packets from conn
+//
+// Errors:
+//   - ErrTooManyShards if c has more than 256 shards
+func NewReceiver(conn net.PacketConn, c codec.Codec, opts Options) (*Receiver, error) {
+	k, total, err := checkCodec(c)
+	if err != nil {
+		return nil, err
+	}
+	return &Receiver{
+		conn: conn, c: c, k: k, total: total, delay: opts.maxDelay(),
+		blocks: map[uint32]*rxBlock{},
+		buf:    make([]byte, maxDatagram),
+	}, nil
+}
+
+// Recv returns the next datagram, received or rebuilt. Datagrams come in
+// arrival order, so a rebuilt one follows later datagrams of its block.
+// Malformed and late packets are counted and skipped.
+//
+// Errors:
+//   - errors from the connection other than read timeouts; all pending
+//     blocks are finished first, so Stats is final
+func (r *Receiver) Recv() (Datagram, error) {
+	for len(r.queue) == 0 {
+		r.expire(time.Now())
+		if err := r.conn.SetReadDeadline(r.nextDeadline()); err != nil {
+			return Datagram{}, err
+		}
+		n, _, err := r.conn.ReadFrom(r.buf)
+		if err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				continue
+			}
+			r.finish()
+			return Datagram{}, err
+		}
+		r.handle(r.buf[:n])
+	}
+	d := r.queue[0]
+	r.queue = r.queue[1:]
+	return d, nil
+}
+
+// Close closes the connection. A blocked Recv then finishes all pending
+// blocks, counting what they miss as lost, and returns the close error.
+func (r *Receiver) Close() error {
+	return r.conn.Close()
+}
+
+// Stats returns the counts so far
+func (r *Receiver) Stats() ReceiverStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stats
+}
+
+// count applies fn to the stats under the lock
+func (r *Receiver) count(fn func(*ReceiverStats)) {
+	r.mu.Lock()
+	fn(&r.stats)
+	r.mu.Unlock()
+}
+
+// nextDeadline returns the earliest block deadline, or none
+func (r *Receiver) nextDeadline() time.Time {
+	var next time.Time
+	for _, b := range r.blocks {
+		if next.IsZero() || b.deadline.Before(next) {
+			next = b.deadline
+		}
+	}
+	return next
+}
+
+// handle processes one packet
+func (r *Receiver) handle(pkt []byte) {
+	h, body, err := parseHeader(pkt, r.total)
+	if err == nil && h.index >= r.k && (h.n < 1 || h.n > r.k || len(body) == 0 || len(body)%codec.Alignment(r.c) != 0) {
+		err = ErrMalformed
+	}
+	if err != nil {
+		r.count(func(s *ReceiverStats) { s.Malformed++ })
+		return
+	}
+	b := r.blocks[h.block]
+	if b == nil && h.block < r.next {
+		r.count(func(s *ReceiverStats) { s.Late++ })
+		return
+	}
+	if b == nil {
+		if skipped := int(h.block - r.next); skipped > 0 {
+			// Not one packet of these blocks arrived
+			r.count(func(s *ReceiverStats) { s.Lost += skipped * r.k })
+		}
+		r.next = h.block + 1
+		b = &rxBlock{deadline: time.Now().Add(r.delay), data: make([][]byte, r.k), parity: make([][]byte, r.total-r.k)}
+		r.blocks[h.block] = b
+	}
+
+	if h.index < r.k {
+		if b.data[h.index] != nil || (b.n > 0 && h.index >= b.n) {
+			r.count(func(s *ReceiverStats) { s.Malformed++ })
+			return
+		}
+		b.data[h.index] = append([]byte{}, body...)
+		b.delivered++
+		r.count(func(s *ReceiverStats) { s.Received++ })
+		r.queue = append(r.queue, Datagram{Seq: r.seq(h.block, h.index), Payload: b.data[h.index]})
+	} else {
+		p := h.index - r.k
+		if b.parity[p] != nil || (b.size > 0 && (len(body) != b.size || h.n != b.n)) {
+			r.count(func(s *ReceiverStats) { s.Malformed++ })
+			return
+		}
+		b.n, b.size = h.n, len(body)
+		b.parity[p] = append([]byte(nil), body...)
+		r.count(func(s *ReceiverStats) { s.Parity++ })
+	}
+	r.repair(h.block, b)
+	if b.delivered == r.k || (b.n > 0 && b.delivered == b.n) {
+		r.finishBlock(h.block, b)
+	}
+}
+
+// repair rebuilds the missing datagrams of b once k shards are in
+func (r *Receiver) repair(block uint32, b *rxBlock) {
+	if b.n == 0 || b.delivered == b.n {
+		return
+	}
+	have := b.delivered + r.k - b.n
+	for _, p := range b.parity {
+		if p != nil {
+			have++
+		}
+	}
+	if have < r.k {
+		return
+	}
+
+	shards := make([][]byte, r.total)
+	for i := 0; i < r.k; i++ {
+		switch {
+		case i >= b.n:
+			shards[i] = make([]byte, b.size)
+		case b.data[i] != nil:
+			if 2+len(b.data[i]) > b.size {
+				return // a data packet longer than the block's shards
+			}
+			shards[i] = shardOf(b.data[i], b.size)
+		}
+	}
+	copy(shards[r.k:], b.parity)
+	if err := r.c.Reconstruct(shards); err != nil {
+		return
+	}
+	for i := 0; i < b.n; i++ {
+		if b.data[i] != nil {
+			continue
+		}
+		payload, err := payloadOf(shards[i])
+		if err != nil {
+			r.count(func(s *ReceiverStats) { s.Malformed++ })
+			return
+		}
+		b.data[i] = payload
+		b.delivered++
+		r.count(func(s *ReceiverStats) { s.Recovered++ })
+		r.queue = append(r.queue, Datagram{Seq: r.seq(block, i), Payload: payload, Recovered: true})
+	}
+}
+
+// expire gives up the blocks whose deadline has passed
+func (r *Receiver) expire(now time.Time) {
+	for id, b := range r.blocks {
+		if !now.Before(b.deadline) {
+			r.finishBlock(id, b)
+		}
+	}
+}
+
+// finish finishes every pending block
+func (r *Receiver) finish() {
+	for id, b := range r.blocks {
+		r.finishBlock(id, b)
+	}
+}
+
+// finishBlock counts what b is missing as lost and retires it. Without a
+// parity packet the block size is unknown, and a full block is assumed.
+func (r *Receiver) finishBlock(id uint32, b *rxBlock) {
+	n := b.n
+	if n == 0 {
+		n = r.k
+	}
+	if lost := n - b.delivered; lost > 0 {
+		r.count(func(s *ReceiverStats) { s.Lost += lost })
+	}
+	delete(r.blocks, id)
+}
+
+func (r *Receiver) seq(block uint32, index int) uint64 {
+	return uint64(block)*uint64(r.k) + uint64(index)
+}
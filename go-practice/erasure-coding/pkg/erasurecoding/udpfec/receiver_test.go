@@ -0,0 +1,291 @@
+package udpfec
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/codec"
+)
+
+// link is sender → relay → receiver over 127.0.0.1 with the receiver
+// drained by a goroutine. The receiver waits 4·delay for a block, so a
+// flush after delay reaches it in time however loaded the machine is.
+type link struct {
+	s     *Sender
+	r     *Receiver
+	relay *Relay
+	out   chan Datagram
+}
+
+func newLink(t testing.TB, spec string, loss LossModel, delay time.Duration) *link {
+	t.Helper()
+	c, err := codec.Parse(spec)
+	if err != nil {
+		t.Fatalf("Parse(%q) error = %v", spec, err)
+	}
+	rconn := listen(t)
+	r, _ := NewReceiver(rconn, c, Options{MaxDelay: 4 * delay})
+	relayConn := listen(t)
+	relay := NewRelay(relayConn, rconn.LocalAddr(), loss)
+	go relay.Run()
+	s, _ := NewSender(dial(t, relay.Addr()), c, Options{MaxDelay: delay})
+
+	l := &link{s: s, r: r, relay: relay, out: make(chan Datagram, 1<<16)}
+	go func() {
+		defer close(l.out)
+		for {
+			d, err := r.Recv()
+			if err != nil {
+				return
+			}
+			l.out <- d
+		}
+	}()
+	t.Cleanup(func() { r.Close() })
+	return l
+}
+
+// send sends the payloads, pausing now and then so the loopback socket
+// buffers never overflow, and closes the sender
+func (l *link) send(t testing.TB, payloads [][]byte) {
+	t.Helper()
+	for i, p := range payloads {
+		if err := l.s.Send(p); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		if i%64 == 63 {
+			time.Sleep(200 * time.Microsecond)
+		}
+	}
+	l.s.Close()
+}
+
+// next returns the next datagram delivered, failing after a second
+func (l *link) next(t testing.TB) Datagram {
+	t.Helper()
+	select {
+	case d := <-l.out:
+		return d
+	case <-time.After(time.Second):
+		t.Fatal("no datagram delivered")
+	}
+	return Datagram{}
+}
+
+// collect waits until the receiver has given up every block, closes it
+// and returns what it delivered by sequence number
+func (l *link) collect(wait time.Duration) map[uint64]Datagram {
+	time.Sleep(wait)
+	l.r.Close()
+	got := map[uint64]Datagram{}
+	for d := range l.out {
+		got[d.Seq] = d
+	}
+	return got
+}
+
+// payloads returns n datagrams of varying length
+func payloads(n int) [][]byte {
+	rng := rand.New(rand.NewSource(int64(n)))
+	ps := make([][]byte, n)
+	for i := range ps {
+		ps[i] = make([]byte, rng.Intn(300))
+		rng.Read(ps[i])
+	}
+	return ps
+}
+
+// dropPositions drops the packets at the given positions of every run of
+// period packets, which is one block when every block is full
+type dropPositions struct {
+	period int
+	drop   map[int]bool
+	n      int
+}
+
+func (d *dropPositions) Drop() bool {
+	i := d.n % d.period
+	d.n++
+	return d.drop[i]
+}
+
+func TestReceiver_NoLoss(t *testing.T) {
+	l := newLink(t, "rs:8+2", nil, 20*time.Millisecond)
+	ps := payloads(100)
+	l.send(t, ps)
+	got := l.collect(100 * time.Millisecond)
+	for i, p := range ps {
+		if d, ok := got[uint64(i)]; !ok || !bytes.Equal(d.Payload, p) || d.Recovered {
+			t.Fatalf("datagram %d = %+v, %v", i, d, ok)
+		}
+	}
+	if st := l.r.Stats(); st.Received != 100 || st.Lost != 0 || st.Recovered != 0 {
+		t.Errorf("Stats() = %+v", st)
+	}
+}
+
+// A MaxPayload datagram fills its parity packet up to the UDP limit, and
+// is rebuilt from it
+func TestReceiver_MaxPayload(t *testing.T) {
+	big := make([]byte, MaxPayload)
+	rand.New(rand.NewSource(1)).Read(big)
+	l := newLink(t, "rs:2+1", &dropPositions{period: 3, drop: map[int]bool{0: true}}, 20*time.Millisecond)
+	l.send(t, [][]byte{big, []byte("small")})
+
+	got := l.collect(100 * time.Millisecond)
+	if d := got[0]; !bytes.Equal(d.Payload, big) || !d.Recovered {
+		t.Errorf("datagram 0 = %d bytes, recovered %v", len(d.Payload), d.Recovered)
+	}
+	if d := got[1]; string(d.Payload) != "small" {
+		t.Errorf("datagram 1 = %q", d.Payload)
+	}
+	if st := l.s.Stats(); st.Parity != 1 {
+		t.Errorf("sender Stats() = %+v, want the parity sent", st)
+	}
+}
+
+func TestReceiver_RecoversUpToM(t *testing.T) {
+	for _, tc := range []struct {
+		spec string
+		drop []int
+	}{
+		{"xor:4+1", []int{2}},
+		{"xor:4+1", []int{4}},
+		{"rs:8+3", []int{0, 5, 7}},
+		{"rs:8+3", []int{1, 8, 10}},
+		{"cauchy:4+2", []int{0, 3}},
+		{"lrc:6+2+2", []int{1, 4}},
+	} {
+		t.Run(fmt.Sprintf("%s/%v", tc.spec, tc.drop), func(t *testing.T) {
+			c, _ := codec.Parse(tc.spec)
+			loss := &dropPositions{period: codec.TotalShards(c), drop: map[int]bool{}}
+			for _, i := range tc.drop {
+				loss.drop[i] = true
+			}
+			l := newLink(t, tc.spec, loss, 20*time.Millisecond)
+			ps := payloads(20 * c.DataShards())
+			l.send(t, ps)
+			got := l.collect(100 * time.Millisecond)
+			for i, p := range ps {
+				if d, ok := got[uint64(i)]; !ok || !bytes.Equal(d.Payload, p) {
+					t.Fatalf("datagram %d missing or wrong", i)
+				}
+			}
+			st := l.r.Stats()
+			if st.Lost != 0 || st.ResidualLossRate() != 0 {
+				t.Errorf("Stats() = %+v, want nothing lost", st)
+			}
+			dataDropped := 0
+			for _, i := range tc.drop {
+				if i < c.DataShards() {
+					dataDropped++
+				}
+			}
+			if st.Recovered != 20*dataDropped {
+				t.Errorf("Recovered = %d, want %d", st.Recovered, 20*dataDropped)
+			}
+		})
+	}
+}
+
+func TestReceiver_PartialBlock(t *testing.T) {
+	// Three datagrams of an 8-datagram block, the second lost; the
+	// MaxDelay flush still protects them
+	loss := &dropPositions{period: 100, drop: map[int]bool{1: true}}
+	l := newLink(t, "rs:8+2", loss, 20*time.Millisecond)
+	for _, p := range []string{"one", "two", "three"} {
+		l.s.Send([]byte(p))
+	}
+	d := l.next(t)
+	for d.Seq != 1 {
+		d = l.next(t)
+	}
+	if d.Seq != 1 || string(d.Payload) != "two" || !d.Recovered {
+		t.Errorf("datagram 1 = %+v, want recovered \"two\"", d)
+	}
+	l.s.Close()
+}
+
+func TestReceiver_GivesUpAfterMaxDelay(t *testing.T) {
+	// Drop two data packets and all parity of the first block: it cannot
+	// be repaired and is given up at its deadline
+	loss := &dropPositions{period: 1000, drop: map[int]bool{0: true, 2: true, 4: true, 5: true}}
+	const delay = 10 * time.Millisecond
+	l := newLink(t, "rs:4+2", loss, delay)
+	start := time.Now()
+	for _, p := range []string{"a", "b", "c", "d"} {
+		l.s.Send([]byte(p))
+	}
+	l.next(t)
+	l.next(t)
+	for l.r.Stats().Lost == 0 {
+		if time.Since(start) > time.Second {
+			t.Fatal("block not given up")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if waited := time.Since(start); waited < 4*delay {
+		t.Errorf("block given up after %v, before the receiver's MaxDelay %v", waited, 4*delay)
+	}
+	if st := l.r.Stats(); st.Lost != 2 || st.Received != 2 || st.ResidualLossRate() != 0.5 {
+		t.Errorf("Stats() = %+v, want 2 received and 2 lost", st)
+	}
+	l.s.Close()
+}
+
+// Burst loss on the relay: compare the raw loss with what is left after
+// FEC; run with -v for the table
+func TestReceiver_GilbertElliott(t *testing.T) {
+	const n = 4000
+	ps := payloads(n)
+	var plain float64
+	for _, spec := range []string{"replica:1+0", "xor:8+1", "rs:8+2", "rs:8+4", "rs:16+8"} {
+		loss := &GilbertElliott{P: 0.02, R: 0.3, LossGood: 0.005, LossBad: 0.6, Rand: rand.New(rand.NewSource(1))}
+		l := newLink(t, spec, loss, 20*time.Millisecond)
+		l.send(t, ps)
+		got := l.collect(100 * time.Millisecond)
+		st := l.r.Stats()
+		for seq, d := range got {
+			if !bytes.Equal(d.Payload, ps[seq]) {
+				t.Fatalf("%s: datagram %d corrupted", spec, seq)
+			}
+		}
+		if len(got)+st.Lost != n {
+			t.Errorf("%s: %d delivered + %d lost, want %d", spec, len(got), st.Lost, n)
+		}
+		raw := l.relay.Stats().LossRate()
+		t.Logf("%-12s link loss %.2f%%, residual loss %.2f%%", spec, 100*raw, 100*st.ResidualLossRate())
+		switch spec {
+		case "replica:1+0":
+			plain = st.ResidualLossRate()
+		case "rs:8+4":
+			if st.ResidualLossRate() > plain/2 {
+				t.Errorf("%s: residual loss %.4f, want under half of %.4f without FEC", spec, st.ResidualLossRate(), plain)
+			}
+		case "rs:16+8":
+			// Longer blocks spread a burst over more parity
+			if st.ResidualLossRate() > plain/4 {
+				t.Errorf("%s: residual loss %.4f, want under a quarter of %.4f without FEC", spec, st.ResidualLossRate(), plain)
+			}
+		}
+	}
+}
+
+// Benchmark a loss-free stream of 1 KiB datagrams through the relay
+func BenchmarkStream(b *testing.B) {
+	for _, spec := range []string{"replica:1+0", "xor:8+1", "rs:8+2"} {
+		b.Run(spec, func(b *testing.B) {
+			l := newLink(b, spec, nil, 20*time.Millisecond)
+			p := make([]byte, 1024)
+			b.SetBytes(1024)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				l.s.Send(p)
+				l.next(b)
+			}
+		})
+	}
+}
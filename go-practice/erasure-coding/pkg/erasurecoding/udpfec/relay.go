@@ -0,0 +1,133 @@
+package udpfec
+
+import (
+	"math/rand"
+	"net"
+	"sync"
+)
+
+// LossModel decides, packet by packet, whether a link drops it
+type LossModel interface {
+	Drop() bool
+}
+
+// Bernoulli drops every packet independently with probability P
+type Bernoulli struct {
+	P    float64
+	Rand *rand.Rand
+}
+
+// Drop implements LossModel
+func (b *Bernoulli) Drop() bool { return b.Rand.Float64() < b.P }
+
+// GilbertElliott is the two-state burst loss model: a Markov chain
+// between a good and a bad state, each with its own loss probability
+//
+// The chain moves from good to bad with probability P and back with
+// probability R before each packet. Losses cluster into bursts of mean
+// length about 1/R when LossBad is high. That is the pattern that defeats
+// FEC blocks sized for the average loss rate.
+type GilbertElliott struct {
+	P, R              float64
+	LossGood, LossBad float64
+	Rand              *rand.Rand
+	bad               bool
+}
+
+// Drop implements LossModel
+func (g *GilbertElliott) Drop() bool {
+	if g.bad {
+		g.bad = g.Rand.Float64() >= g.R
+	} else {
+		g.bad = g.Rand.Float64() < g.P
+	}
+	if g.bad {
+		return g.Rand.Float64() < g.LossBad
+	}
+	return g.Rand.Float64() < g.LossGood
+}
+
+// MeanLoss returns the long-run loss rate: the chain is bad a fraction
+// P/(P+R) of the time
+func (g *GilbertElliott) MeanLoss() float64 {
+	bad := g.P / (g.P + g.R)
+	return bad*g.LossBad + (1-bad)*g.LossGood
+}
+
+// RelayStats counts what a Relay has forwarded
+type RelayStats struct {
+	Forwarded int
+	Dropped   int
+}
+
+// LossRate returns the fraction of packets dropped
+func (s RelayStats) LossRate() float64 {
+	if s.Forwarded+s.Dropped == 0 {
+		return 0
+	}
+	return float64(s.Dropped) / float64(s.Forwarded+s.Dropped)
+}
+
+// Relay forwards every datagram arriving on a socket to one address,
+// dropping those its LossModel picks
+type Relay struct {
+	conn net.PacketConn
+	to   net.Addr
+	loss LossModel
+
+	mu    sync.Mutex
+	stats RelayStats
+}
+
+// NewRelay creates a Relay forwarding from conn to to; a nil loss drops
+// nothing. Run starts it.
+func NewRelay(conn net.PacketConn, to net.Addr, loss LossModel) *Relay {
+	return &Relay{conn: conn, to: to, loss: loss}
+}
+
+// ListenRelay creates a Relay on a fresh socket at addr, such as
+// "127.0.0.1:0", forwarding to to
+func ListenRelay(addr string, to net.Addr, loss LossModel) (*Relay, error) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return NewRelay(conn, to, loss), nil
+}
+
+// Addr returns the address senders should send to
+func (r *Relay) Addr() net.Addr { return r.conn.LocalAddr() }
+
+// Run forwards datagrams until the socket is closed, and returns the
+// error that stopped it
+func (r *Relay) Run() error {
+	buf := make([]byte, 1<<16)
+	for {
+		n, _, err := r.conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		if r.loss != nil && r.loss.Drop() {
+			r.mu.Lock()
+			r.stats.Dropped++
+			r.mu.Unlock()
+			continue
+		}
+		if _, err := r.conn.WriteTo(buf[:n], r.to); err != nil {
+			return err
+		}
+		r.mu.Lock()
+		r.stats.Forwarded++
+		r.mu.Unlock()
+	}
+}
+
+// Close closes the socket, stopping Run
+func (r *Relay) Close() error { return r.conn.Close() }
+
+// Stats returns the counts so far
+func (r *Relay) Stats() RelayStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stats
+}
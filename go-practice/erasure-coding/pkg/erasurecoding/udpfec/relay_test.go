@@ -0,0 +1,78 @@
+package udpfec
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// lossRuns returns the loss rate of model over n packets and the mean
+// length of its runs of consecutive losses
+func lossRuns(model LossModel, n int) (float64, float64) {
+	lost, runs, prev := 0, 0, false
+	for i := 0; i < n; i++ {
+		drop := model.Drop()
+		if drop {
+			lost++
+			if !prev {
+				runs++
+			}
+		}
+		prev = drop
+	}
+	return float64(lost) / float64(n), float64(lost) / float64(max(runs, 1))
+}
+
+func TestGilbertElliott_MeanLossAndBursts(t *testing.T) {
+	ge := &GilbertElliott{P: 0.02, R: 0.25, LossGood: 0, LossBad: 1, Rand: rand.New(rand.NewSource(1))}
+	rate, burst := lossRuns(ge, 200000)
+	if want := ge.MeanLoss(); math.Abs(rate-want) > 0.01 {
+		t.Errorf("loss rate %.4f, want MeanLoss %.4f", rate, want)
+	}
+	// With LossBad = 1 a burst is a stay in the bad state, 1/R long
+	if math.Abs(burst-1/ge.R) > 0.3 {
+		t.Errorf("mean burst %.2f, want %.2f", burst, 1/ge.R)
+	}
+
+	// Independent loss at the same rate has bursts barely over 1
+	b := &Bernoulli{P: ge.MeanLoss(), Rand: rand.New(rand.NewSource(1))}
+	if rate, burst := lossRuns(b, 200000); math.Abs(rate-b.P) > 0.01 || burst > 1.2 {
+		t.Errorf("Bernoulli loss %.4f with bursts of %.2f, want %.4f and about 1", rate, burst, b.P)
+	}
+}
+
+func TestRelay_ForwardsAndDrops(t *testing.T) {
+	dst := listen(t)
+	relay, err := ListenRelay("127.0.0.1:0", dst.LocalAddr(), &Bernoulli{P: 0.5, Rand: rand.New(rand.NewSource(2))})
+	if err != nil {
+		t.Fatalf("ListenRelay() error = %v", err)
+	}
+	done := make(chan error)
+	go func() { done <- relay.Run() }()
+
+	src := dial(t, relay.Addr())
+	for i := 0; i < 200; i++ {
+		src.Write([]byte{byte(i)})
+	}
+	buf := make([]byte, 16)
+	got := 0
+	dst.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	for {
+		if _, err := dst.Read(buf); err != nil {
+			break
+		}
+		got++
+	}
+	st := relay.Stats()
+	if st.Forwarded != got || st.Forwarded+st.Dropped != 200 {
+		t.Errorf("Stats() = %+v, %d arrived, want 200 in all", st, got)
+	}
+	if r := st.LossRate(); r < 0.35 || r > 0.65 {
+		t.Errorf("LossRate() = %.2f, want about 0.5", r)
+	}
+	relay.Close()
+	if err := <-done; err == nil {
+		t.Error("Run() returned nil after Close")
+	}
+}
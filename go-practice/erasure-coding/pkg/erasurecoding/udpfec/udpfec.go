@@ -0,0 +1,322 @@
+// Package udpfec protects a datagram stream with packet-level forward
+// error correction, using the codecs of package codec.
+//
+// The Sender sends every datagram at once, as it would without FEC, and
+// counts them into blocks of k. When a block is full, or MaxDelay after
+// its first datagram, it sends the block's m parity packets. The Receiver
+// delivers datagrams as they arrive. Once any k packets of a block are in
+// (data or parity), it rebuilds the lost ones and delivers them too. A
+// block that cannot be completed is given up MaxDelay after its first
+// packet, so a lost datagram costs at most that much extra latency.
+//
+// Datagrams in a block differ in length. For coding, each one becomes a
+// shard holding a 2-byte length followed by the payload. All shards of a
+// block are zero-padded to the longest, rounded up to codec.Alignment.
+// Only parity packets carry padded shards. Data packets carry the bare
+// payload, so a loss-free stream costs only the header and the parity.
+// Every packet starts with a 6-byte header:
+//
+//	block uint32   big-endian, counting up from 0 per Sender
+//	index uint8    shard index: data below k, parity from k
+//	n     uint8    data datagrams in the block; 0 in data packets
+//
+// A block flushed early holds n < k datagrams, and its missing data shards
+// count as empty. Parity packets tell the Receiver n.
+//
+// Relay forwards datagrams between two sockets and drops them as a
+// LossModel says. With GilbertElliott burst loss on 127.0.0.1 it stands
+// in for a lossy link in tests, and Receiver.Stats gives the loss that
+// FEC could not repair.
+//
+// Example:
+//
+//	c, _ := codec.Parse("rs:8+2")
+//	conn, _ := net.Dial("udp", "127.0.0.1:9000")
+//	s, _ := udpfec.NewSender(conn, c, udpfec.Options{MaxDelay: 20 * time.Millisecond})
+//	defer s.Close()
+//	s.Send([]byte("frame 1"))
+package udpfec
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/codec"
+)
+
+// FECError represents errors returned by the udpfec package
+type FECError struct {
+	message string
+}
+
+func (e *FECError) Error() string {
+	return e.message
+}
+
+// Common errors
+var (
+	ErrTooManyShards = &FECError{"codec has more than 256 shards"}
+	ErrTooLarge      = &FECError{"datagram is larger than MaxPayload"}
+	ErrMalformed     = &FECError{"malformed FEC packet"}
+	ErrClosed        = &FECError{"sender is closed"}
+)
+
+const (
+	// HeaderSize is the size of the header in front of every packet
+	HeaderSize = 6
+	// MaxPayload is the largest datagram whose parity packet still fits in
+	// a UDP datagram over IPv4. A codec with codec.Alignment above 1 pads
+	// parity shards further, so Send may reject payloads up to Alignment-1
+	// bytes below it.
+	MaxPayload = maxDatagram - HeaderSize - 2
+	// DefaultMaxDelay bounds how long a block waits for parity or repair
+	DefaultMaxDelay = 50 * time.Millisecond
+
+	// maxDatagram is the largest UDP payload over IPv4
+	maxDatagram = 65507
+)
+
+// Options configures a Sender or Receiver
+type Options struct {
+	// MaxDelay is how long after its first datagram a block is flushed
+	// by the Sender and given up by the Receiver; 0 means DefaultMaxDelay.
+	// The Receiver's must be longer than the Sender's by the network
+	// delay and its jitter, or a block flushed early is given up before
+	// its parity arrives.
+	MaxDelay time.Duration
+}
+
+func (o Options) maxDelay() time.Duration {
+	if o.MaxDelay <= 0 {
+		return DefaultMaxDelay
+	}
+	return o.MaxDelay
+}
+
+// header is the packet header
+type header struct {
+	block uint32
+	index int
+	n     int
+}
+
+func (h header) put(b []byte) {
+	binary.BigEndian.PutUint32(b, h.block)
+	b[4] = byte(h.index)
+	b[5] = byte(h.n)
+}
+
+// parseHeader splits a packet into its header and body
+//
+// Errors:
+//   - ErrMalformed for a packet shorter than the header or an index
+//     outside k+m
+func parseHeader(b []byte, total int) (header, []byte, error) {
+	if len(b) < HeaderSize || int(b[4]) >= total {
+		return header{}, nil, ErrMalformed
+	}
+	return header{block: binary.BigEndian.Uint32(b), index: int(b[4]), n: int(b[5])}, b[HeaderSize:], nil
+}
+
+// shardOf returns the coding shard of a datagram: its length and the
+// payload, zero-padded to size
+func shardOf(payload []byte, size int) []byte {
+	s := make([]byte, size)
+	binary.BigEndian.PutUint16(s, uint16(len(payload)))
+	copy(s[2:], payload)
+	return s
+}
+
+// payloadOf is the inverse of shardOf
+//
+// Errors:
+//   - ErrMalformed if the length does not fit in the shard
+func payloadOf(shard []byte) ([]byte, error) {
+	if len(shard) < 2 {
+		return nil, ErrMalformed
+	}
+	n := int(binary.BigEndian.Uint16(shard))
+	if 2+n > len(shard) {
+		return nil, ErrMalformed
+	}
+	return shard[2 : 2+n], nil
+}
+
+// checkCodec returns k and k+m for c
+func checkCodec(c codec.Codec) (int, int, error) {
+	total := codec.TotalShards(c)
+	if total > 256 {
+		return 0, 0, ErrTooManyShards
+	}
+	return c.DataShards(), total, nil
+}
+
+// SenderStats counts what a Sender has sent
+type SenderStats struct {
+	Data   int
+	Parity int
+	Blocks int
+}
+
+// Sender sends datagrams followed by the parity of every block. It is safe
+// for concurrent use.
+type Sender struct {
+	conn     net.Conn
+	c        codec.Codec
+	k, total int
+	delay    time.Duration
+
+	mu      sync.Mutex
+	block   uint32
+	pending [][]byte // datagrams of the current block
+	timer   *time.Timer
+	stats   SenderStats
+	closed  bool
+	err     error // first error of a timer flush, returned by the next call
+}
+
+// NewSender creates a Sender writing packets to conn, usually a UDP socket
+// from net.Dial
+//
+// Errors:
+//   - ErrTooManyShards if c has more than 256 shards
+func NewSender(conn net.Conn, c codec.Codec, opts Options) (*Sender, error) {
+	k, total, err := checkCodec(c)
+	if err != nil {
+		return nil, err
+	}
+	return &Sender{conn: conn, c: c, k: k, total: total, delay: opts.maxDelay()}, nil
+}
+
+// Send sends payload as the next datagram of the stream, and the block's
+// parity if payload completes it
+//
+// Errors:
+//   - ErrTooLarge if payload exceeds MaxPayload, or its padded parity
+//     shard would not fit in a datagram
+//   - ErrClosed after Close
+//   - errors from the connection, including those of an earlier flush
+//     after MaxDelay
+func (s *Sender) Send(payload []byte) error {
+	if len(payload) > MaxPayload || HeaderSize+shardSize([][]byte{payload}, codec.Alignment(s.c)) > maxDatagram {
+		return ErrTooLarge
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	if err := s.err; err != nil {
+		s.err = nil
+		return err
+	}
+
+	pkt := make([]byte, HeaderSize+len(payload))
+	header{block: s.block, index: len(s.pending)}.put(pkt)
+	copy(pkt[HeaderSize:], payload)
+	if _, err := s.conn.Write(pkt); err != nil {
+		return err
+	}
+	s.stats.Data++
+	s.pending = append(s.pending, pkt[HeaderSize:])
+	if len(s.pending) == 1 {
+		block := s.block
+		s.timer = time.AfterFunc(s.delay, func() { s.flushBlock(block) })
+	}
+	if len(s.pending) == s.k {
+		return s.flush()
+	}
+	return nil
+}
+
+// Flush sends the parity of the current block now, even if it holds
+// fewer than k datagrams
+func (s *Sender) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	return s.flush()
+}
+
+// Close flushes the current block and stops the MaxDelay timer; it does
+// not close the connection
+func (s *Sender) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	err := s.flush()
+	s.closed = true
+	return err
+}
+
+// Stats returns the packet counts so far
+func (s *Sender) Stats() SenderStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+// flushBlock is the MaxDelay timer: it flushes block unless it has been
+// flushed already
+func (s *Sender) flushBlock(block uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed || s.block != block || len(s.pending) == 0 {
+		return
+	}
+	if err := s.flush(); err != nil && s.err == nil {
+		s.err = err
+	}
+}
+
+// flush encodes and sends the parity of the pending datagrams and starts
+// the next block; s.mu must be held
+func (s *Sender) flush() error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	s.timer.Stop()
+	size := shardSize(s.pending, codec.Alignment(s.c))
+	shards := make([][]byte, s.total)
+	for i := range shards {
+		if i < len(s.pending) {
+			shards[i] = shardOf(s.pending[i], size)
+		} else {
+			shards[i] = make([]byte, size)
+		}
+	}
+	n := len(s.pending)
+	block := s.block
+	s.block++
+	s.pending = s.pending[:0]
+	s.stats.Blocks++
+	if err := s.c.Encode(shards); err != nil {
+		return err
+	}
+	for i := s.k; i < s.total; i++ {
+		pkt := make([]byte, HeaderSize+size)
+		header{block: block, index: i, n: n}.put(pkt)
+		copy(pkt[HeaderSize:], shards[i])
+		if _, err := s.conn.Write(pkt); err != nil {
+			return err
+		}
+		s.stats.Parity++
+	}
+	return nil
+}
+
+// shardSize returns the coding shard size for a block of datagrams: the
+// longest plus its length prefix, rounded up to align
+func shardSize(payloads [][]byte, align int) int {
+	size := 0
+	for _, p := range payloads {
+		size = max(size, 2+len(p))
+	}
+	return (size + align - 1) / align * align
+}
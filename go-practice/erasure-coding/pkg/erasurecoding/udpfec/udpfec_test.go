@@ -0,0 +1,147 @@
+package udpfec
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/codec"
+)
+
+// listen returns a loopback UDP socket with a large receive buffer, so a
+// burst of test packets is not dropped by the kernel
+func listen(t testing.TB) *net.UDPConn {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("ListenUDP() error = %v", err)
+	}
+	_ = conn.SetReadBuffer(4 << 20)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// dial returns a socket connected to addr
+func dial(t testing.TB, addr net.Addr) net.Conn {
+	t.Helper()
+	conn, err := net.DialUDP("udp", nil, addr.(*net.UDPAddr))
+	if err != nil {
+		t.Fatalf("DialUDP() error = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readHeaders reads n packets from conn and returns their headers
+func readHeaders(t *testing.T, conn *net.UDPConn, n, total int) []header {
+	t.Helper()
+	buf := make([]byte, 1<<16)
+	var hs []header
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for len(hs) < n {
+		m, err := conn.Read(buf)
+		if err != nil {
+			t.Fatalf("read packet %d: %v", len(hs), err)
+		}
+		h, _, err := parseHeader(buf[:m], total)
+		if err != nil {
+			t.Fatalf("parseHeader() error = %v", err)
+		}
+		hs = append(hs, h)
+	}
+	return hs
+}
+
+func TestSender_PacketLayout(t *testing.T) {
+	c, _ := codec.Parse("rs:4+2")
+	rx := listen(t)
+	s, _ := NewSender(dial(t, rx.LocalAddr()), c, Options{MaxDelay: time.Hour})
+	for i := 0; i < 6; i++ {
+		if err := s.Send(bytes.Repeat([]byte{byte(i)}, i)); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+	s.Close()
+
+	// Block 0: four data then two parity; block 1 flushed with n = 2
+	want := []header{
+		{0, 0, 0}, {0, 1, 0}, {0, 2, 0}, {0, 3, 0}, {0, 4, 4}, {0, 5, 4},
+		{1, 0, 0}, {1, 1, 0}, {1, 4, 2}, {1, 5, 2},
+	}
+	got := readHeaders(t, rx, len(want), 6)
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("packet %d header = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if st := s.Stats(); st != (SenderStats{Data: 6, Parity: 4, Blocks: 2}) {
+		t.Errorf("Stats() = %+v", st)
+	}
+}
+
+func TestSender_FlushesAfterMaxDelay(t *testing.T) {
+	c, _ := codec.Parse("xor:8+1")
+	rx := listen(t)
+	s, _ := NewSender(dial(t, rx.LocalAddr()), c, Options{MaxDelay: 20 * time.Millisecond})
+	defer s.Close()
+	start := time.Now()
+	s.Send([]byte("alone"))
+	got := readHeaders(t, rx, 2, 9)
+	if got[1] != (header{0, 8, 1}) {
+		t.Errorf("parity header = %+v, want block 0 index 8 n 1", got[1])
+	}
+	if waited := time.Since(start); waited < 20*time.Millisecond {
+		t.Errorf("parity after %v, before MaxDelay", waited)
+	}
+	// The next datagram starts block 1
+	s.Send([]byte("next"))
+	if got := readHeaders(t, rx, 1, 9); got[0] != (header{1, 0, 0}) {
+		t.Errorf("next header = %+v, want block 1", got[0])
+	}
+}
+
+func TestSender_Errors(t *testing.T) {
+	c, _ := codec.Parse("rs:4+2")
+	rx := listen(t)
+	s, _ := NewSender(dial(t, rx.LocalAddr()), c, Options{})
+	if err := s.Send(make([]byte, MaxPayload+1)); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Send(too large) error = %v, want %v", err, ErrTooLarge)
+	}
+	// Cauchy pads shards to whole words, which would overflow the parity
+	cauchy, _ := codec.Parse("cauchy:4+2")
+	cs, _ := NewSender(dial(t, rx.LocalAddr()), cauchy, Options{})
+	if err := cs.Send(make([]byte, MaxPayload)); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Send(MaxPayload, cauchy) error = %v, want %v", err, ErrTooLarge)
+	}
+	s.Close()
+	if err := s.Send(nil); !errors.Is(err, ErrClosed) {
+		t.Errorf("Send() after Close error = %v, want %v", err, ErrClosed)
+	}
+	wide, _ := codec.Parse("rs16:250+10")
+	if _, err := NewSender(dial(t, rx.LocalAddr()), wide, Options{}); !errors.Is(err, ErrTooManyShards) {
+		t.Errorf("NewSender(260 shards) error = %v, want %v", err, ErrTooManyShards)
+	}
+}
+
+func TestShardEncoding(t *testing.T) {
+	for _, p := range [][]byte{{}, []byte("x"), bytes.Repeat([]byte{7}, 300)} {
+		size := shardSize([][]byte{p, []byte("abc")}, 8)
+		if size%8 != 0 || size < 2+len(p) {
+			t.Fatalf("shardSize() = %d for %d bytes", size, len(p))
+		}
+		got, err := payloadOf(shardOf(p, size))
+		if err != nil || !bytes.Equal(got, p) {
+			t.Errorf("payloadOf(shardOf(%d bytes)) = %d bytes, %v", len(p), len(got), err)
+		}
+	}
+	if _, err := payloadOf([]byte{0, 9, 1}); !errors.Is(err, ErrMalformed) {
+		t.Errorf("payloadOf(bad length) error = %v, want %v", err, ErrMalformed)
+	}
+	for _, b := range [][]byte{nil, {0, 0, 0, 0, 6}, {0, 0, 0, 0, 9, 0}} {
+		if _, _, err := parseHeader(b, 6); !errors.Is(err, ErrMalformed) {
+			t.Errorf("parseHeader(%v) error = %v, want %v", b, err, ErrMalformed)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package volume
+
+import "sort"
+
+// CompactResult summarises a Compact run
+type CompactResult struct {
+	// Volumes is the number of sealed volumes rewritten and removed
+	Volumes int
+	// Moved is the number of live objects copied to the active volume
+	Moved int
+	// Reclaimed is the number of volume bytes freed (before encoding)
+	Reclaimed int64
+}
+
+// Compact rewrites every sealed volume whose live fraction (Live / Size) is
+// below maxLive, e.g. 0.5 to rewrite volumes that are more than half
+// garbage
+//
+// Live needles are appended to the active volume with their original
+// sequence numbers and the old volume is removed afterwards. Tombstones are
+// carried over as long as some older needle of the same key may still
+// exist in another volume, so a deleted object can never come back after a
+// restart. A crash mid-compaction at worst leaves duplicate needles with the
+// same sequence number, which Open resolves.
+func (s *Store) Compact(maxLive float64) (*CompactResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, ErrClosed
+	}
+
+	var victims []*VolumeInfo
+	for _, v := range s.volumes {
+		if v.Sealed && v.Size > 0 && float64(v.Live)/float64(v.Size) < maxLive {
+			victims = append(victims, v)
+		}
+	}
+	sort.Slice(victims, func(i, j int) bool { return victims[i].ID < victims[j].ID })
+
+	res := &CompactResult{}
+	for _, v := range victims {
+		moved, written, err := s.compactVolume(v)
+		res.Moved += moved
+		if err != nil {
+			return res, err
+		}
+		res.Volumes++
+		res.Reclaimed += v.Size - written
+	}
+	return res, nil
+}
+
+// compactVolume moves the live needles and needed tombstones of v to the
+// active volume and removes v; it returns the objects moved and the bytes
+// written
+func (s *Store) compactVolume(v *VolumeInfo) (int, int64, error) {
+	sv, err := s.openSealed(v)
+	if err != nil {
+		return 0, 0, err
+	}
+	type tombstone struct {
+		key string
+		seq uint64
+	}
+	var tombstones []tombstone
+	moved := 0
+	var written int64
+
+	// Live puts first; dead ones are dropped and no longer counted
+	_, err = scanNeedles(sv.reader, v.Size, func(n *needle, off int64) error {
+		if n.flags&flagTombstone != 0 {
+			tombstones = append(tombstones, tombstone{n.key, n.seq})
+			return nil
+		}
+		loc, ok := s.index[n.key]
+		if !ok || loc.Volume != v.ID || loc.Needle != off {
+			s.puts[n.key]--
+			if s.puts[n.key] <= 0 {
+				delete(s.puts, n.key)
+			}
+			return nil
+		}
+		data, err := s.readLocked(loc)
+		if err != nil {
+			return err
+		}
+		newLoc, err := s.appendLocked(n.key, data, 0, n.seq)
+		if err != nil {
+			return err
+		}
+		s.setLive(n.key, newLoc)
+		moved++
+		written += n.size()
+		return nil
+	})
+	if err != nil {
+		return moved, written, err
+	}
+
+	// A tombstone still matters while any put needle of its key survives
+	for _, t := range tombstones {
+		if s.puts[t.key] == 0 {
+			continue
+		}
+		if _, err := s.appendLocked(t.key, nil, flagTombstone, t.seq); err != nil {
+			return moved, written, err
+		}
+		written += headerSize + int64(len(t.key))
+	}
+	return moved, written, s.removeSealed(v)
+}
@@ -0,0 +1,101 @@
+package volume
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCompact_ReclaimsSparseVolumes(t *testing.T) {
+	cfg := testConfig(t, "rs:3+2", 5, 2048)
+	s := mustOpen(t, cfg)
+	keys := map[string][]byte{}
+	for i := 0; i < 60; i++ {
+		key := fmt.Sprintf("k%02d", i)
+		keys[key] = object(i)
+		s.Put(key, keys[key])
+	}
+	s.Seal()
+	before := s.Volumes()
+
+	// Delete three out of four objects
+	for i := 0; i < 60; i++ {
+		if i%4 != 0 {
+			key := fmt.Sprintf("k%02d", i)
+			s.Delete(key)
+			delete(keys, key)
+		}
+	}
+	s.Seal()
+
+	res, err := s.Compact(0.5)
+	if err != nil {
+		t.Fatalf("Compact() error = %v", err)
+	}
+	if res.Volumes < len(before)-1 || res.Moved == 0 || res.Reclaimed <= 0 {
+		t.Errorf("Compact() = %+v over %d volumes", res, len(before))
+	}
+	for _, v := range s.Volumes() {
+		if v.Sealed && float64(v.Live)/float64(v.Size) < 0.5 && v.Size > 0 {
+			// Only volumes created by this compaction may be sparse
+			if v.ID <= before[len(before)-1].ID {
+				t.Errorf("volume %d still sparse after Compact: %+v", v.ID, v)
+			}
+		}
+	}
+	checkAll(t, s, keys)
+
+	// Deleted objects stay deleted, live ones survive a restart
+	s.Close()
+	s = mustOpen(t, cfg)
+	checkAll(t, s, keys)
+	for i := 1; i < 60; i += 4 {
+		if _, err := s.Get(fmt.Sprintf("k%02d", i)); err != ErrNotFound {
+			t.Fatalf("deleted k%02d came back after compaction and restart: %v", i, err)
+		}
+	}
+}
+
+func TestCompact_TombstoneSurvivesWhileOldNeedleExists(t *testing.T) {
+	cfg := testConfig(t, "xor:2+1", 3, 1<<20)
+	s := mustOpen(t, cfg)
+
+	s.Put("victim", []byte("old data")) // volume 1
+	s.Put("filler", []byte("keeps volume 1 dense"))
+	s.Seal()
+	s.Delete("victim") // tombstone in volume 2
+	s.Put("other", []byte("x"))
+	s.Delete("other")
+	s.Seal()
+
+	// Compact only the tombstone volume; volume 1 still holds the old put
+	res, err := s.Compact(0.01)
+	if err != nil || res.Volumes != 1 {
+		t.Fatalf("Compact() = %+v, %v, want the tombstone volume rewritten", res, err)
+	}
+	s.Close()
+	s = mustOpen(t, cfg)
+	if _, err := s.Get("victim"); err != ErrNotFound {
+		t.Errorf("Get(victim) after compaction and restart error = %v, want %v", err, ErrNotFound)
+	}
+	if _, err := s.Get("other"); err != ErrNotFound {
+		t.Errorf("Get(other) error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestCompact_ReputAfterDelete(t *testing.T) {
+	cfg := testConfig(t, "xor:2+1", 3, 1<<20)
+	s := mustOpen(t, cfg)
+	s.Put("k", []byte("v1"))
+	s.Delete("k")
+	s.Put("k", []byte("v2"))
+	s.Seal()
+
+	if _, err := s.Compact(1.01); err != nil {
+		t.Fatalf("Compact() error = %v", err)
+	}
+	s.Close()
+	s = mustOpen(t, cfg)
+	if got, err := s.Get("k"); err != nil || string(got) != "v2" {
+		t.Errorf("Get(k) = %q, %v, want v2", got, err)
+	}
+}
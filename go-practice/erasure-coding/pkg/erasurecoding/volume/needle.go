@@ -0,0 +1,107 @@
+package volume
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+)
+
+// Needle layout (little endian), as in Haystack every object is a
+// self-describing record so an index can always be rebuilt from volumes:
+//
+//	magic   uint32  "NEDL"
+//	seq     uint64  store-wide write sequence, newest record wins
+//	flags   uint8   flagTombstone for deletes
+//	keyLen  uint16
+//	dataLen uint32
+//	crc     uint32  CRC-32 (IEEE) of the data
+//	key     [keyLen]byte
+//	data    [dataLen]byte
+const (
+	needleMagic   = 0x4c44454e // "NEDL"
+	headerSize    = 23
+	flagTombstone = 1
+)
+
+// needle is one decoded record header
+type needle struct {
+	seq     uint64
+	flags   uint8
+	key     string
+	dataLen uint32
+	crc     uint32
+}
+
+// size returns the encoded length of the needle
+func (n *needle) size() int64 {
+	return headerSize + int64(len(n.key)) + int64(n.dataLen)
+}
+
+// dataOffset returns the offset of the data relative to the needle start
+func (n *needle) dataOffset() int64 {
+	return headerSize + int64(len(n.key))
+}
+
+// encodeNeedle serialises a record
+func encodeNeedle(seq uint64, flags uint8, key string, data []byte) []byte {
+	buf := make([]byte, headerSize+len(key)+len(data))
+	binary.LittleEndian.PutUint32(buf[0:], needleMagic)
+	binary.LittleEndian.PutUint64(buf[4:], seq)
+	buf[12] = flags
+	binary.LittleEndian.PutUint16(buf[13:], uint16(len(key)))
+	binary.LittleEndian.PutUint32(buf[15:], uint32(len(data)))
+	binary.LittleEndian.PutUint32(buf[19:], crc32.ChecksumIEEE(data))
+	copy(buf[headerSize:], key)
+	copy(buf[headerSize+len(key):], data)
+	return buf
+}
+
+// readNeedle decodes the header and key of the needle at off
+func readNeedle(r io.ReaderAt, off, limit int64) (*needle, error) {
+	if off+headerSize > limit {
+		return nil, ErrCorruptNeedle
+	}
+	var hdr [headerSize]byte
+	if _, err := r.ReadAt(hdr[:], off); err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(hdr[0:]) != needleMagic {
+		return nil, ErrCorruptNeedle
+	}
+	n := &needle{
+		seq:     binary.LittleEndian.Uint64(hdr[4:]),
+		flags:   hdr[12],
+		dataLen: binary.LittleEndian.Uint32(hdr[15:]),
+		crc:     binary.LittleEndian.Uint32(hdr[19:]),
+	}
+	key := make([]byte, binary.LittleEndian.Uint16(hdr[13:]))
+	if off+headerSize+int64(len(key))+int64(n.dataLen) > limit {
+		return nil, ErrCorruptNeedle
+	}
+	if _, err := r.ReadAt(key, off+headerSize); err != nil {
+		return nil, err
+	}
+	n.key = string(key)
+	return n, nil
+}
+
+// scanNeedles calls fn for every needle in r up to limit and returns the
+// offset just past the last intact needle. A torn tail (e.g. a crash in the
+// middle of an append) ends the scan without an error.
+func scanNeedles(r io.ReaderAt, limit int64, fn func(n *needle, off int64) error) (int64, error) {
+	off := int64(0)
+	for off < limit {
+		n, err := readNeedle(r, off, limit)
+		if err != nil {
+			if err == ErrCorruptNeedle || err == io.EOF || err == io.ErrUnexpectedEOF {
+				return off, nil
+			}
+			return off, err
+		}
+		if err := fn(n, off); err != nil {
+			return off, err
+		}
+		off += n.size()
+	}
+	return off, nil
+}
@@ -0,0 +1,46 @@
+package volume
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNeedle_EncodeScan(t *testing.T) {
+	var buf []byte
+	buf = append(buf, encodeNeedle(1, 0, "a", []byte("first"))...)
+	buf = append(buf, encodeNeedle(2, flagTombstone, "b", nil)...)
+	buf = append(buf, encodeNeedle(3, 0, "long-key", bytes.Repeat([]byte{7}, 300))...)
+
+	var got []string
+	end, err := scanNeedles(bytes.NewReader(buf), int64(len(buf)), func(n *needle, off int64) error {
+		got = append(got, n.key)
+		if n.key == "b" && n.flags != flagTombstone {
+			t.Errorf("needle b flags = %d, want tombstone", n.flags)
+		}
+		if n.key == "long-key" && (n.seq != 3 || n.dataLen != 300) {
+			t.Errorf("needle long-key = %+v", n)
+		}
+		return nil
+	})
+	if err != nil || end != int64(len(buf)) {
+		t.Fatalf("scanNeedles() = %d, %v, want %d", end, err, len(buf))
+	}
+	if len(got) != 3 {
+		t.Errorf("scanned keys = %v", got)
+	}
+}
+
+func TestNeedle_TornTail(t *testing.T) {
+	first := encodeNeedle(1, 0, "a", []byte("kept"))
+	second := encodeNeedle(2, 0, "b", []byte("torn by a crash"))
+	buf := append(append([]byte(nil), first...), second[:len(second)-4]...)
+
+	count := 0
+	end, err := scanNeedles(bytes.NewReader(buf), int64(len(buf)), func(*needle, int64) error {
+		count++
+		return nil
+	})
+	if err != nil || count != 1 || end != int64(len(first)) {
+		t.Errorf("scanNeedles(torn) = %d needles, end %d, %v; want 1 needle ending at %d", count, end, err, len(first))
+	}
+}
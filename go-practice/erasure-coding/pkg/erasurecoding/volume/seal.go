@@ -0,0 +1,168 @@
+package volume
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/codec"
+)
+
+// sealVolume erasure-codes the plain file of volume id into shards
+//
+// Shards and metadata are written atomically before the plain file is
+// removed, so a crash at any point leaves either the plain file or a
+// complete sealed volume (or both, which Open resolves).
+func (s *Store) sealVolume(id uint32) error {
+	info := s.volumes[id]
+	data, err := os.ReadFile(s.dataPath(id))
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		delete(s.volumes, id)
+		return os.Remove(s.dataPath(id))
+	}
+
+	c := s.cfg.Codec
+	shards, err := codec.Split(c, data)
+	if err != nil {
+		return err
+	}
+	if err := c.Encode(shards); err != nil {
+		return err
+	}
+	placement := make([]int, len(shards))
+	for i, shard := range shards {
+		placement[i] = (int(id) + i) % len(s.cfg.ShardDirs)
+		if err := writeFileAtomic(s.shardPath(placement[i], id, i), shard); err != nil {
+			return fmt.Errorf("seal volume %d shard %d: %w", id, i, err)
+		}
+	}
+
+	// info only changes once the metadata is durable, so a failed seal
+	// leaves the volume unsealed and appendable
+	sealed := *info
+	sealed.Sealed = true
+	sealed.Size = int64(len(data))
+	sealed.Codec = c.Spec()
+	sealed.ChunkSize = len(shards[0])
+	sealed.Placement = placement
+	raw, err := json.Marshal(&sealed)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(s.metaPath(id), raw); err != nil {
+		return err
+	}
+	*info = sealed
+	return os.Remove(s.dataPath(id))
+}
+
+// sealedVolume is an opened sealed volume readable at any offset
+type sealedVolume struct {
+	files  []*os.File
+	reader *codec.RangeReader
+}
+
+// openSealed opens (or returns the cached) reader of a sealed volume
+func (s *Store) openSealed(info *VolumeInfo) (*sealedVolume, error) {
+	if sv, ok := s.sealed[info.ID]; ok {
+		return sv, nil
+	}
+	c, err := codec.Parse(info.Codec)
+	if err != nil {
+		return nil, fmt.Errorf("%w: volume %d: %v", ErrCorruptMetadata, info.ID, err)
+	}
+	if len(info.Placement) != codec.TotalShards(c) {
+		return nil, fmt.Errorf("%w: volume %d", ErrCorruptMetadata, info.ID)
+	}
+
+	sv := &sealedVolume{files: make([]*os.File, len(info.Placement))}
+	missing := 0
+	for i, d := range info.Placement {
+		if d < 0 || d >= len(s.cfg.ShardDirs) {
+			sv.Close()
+			return nil, fmt.Errorf("%w: volume %d", ErrCorruptMetadata, info.ID)
+		}
+		f, err := os.Open(s.shardPath(d, info.ID, i))
+		if err != nil {
+			missing++
+			continue
+		}
+		sv.files[i] = f
+	}
+	if missing > c.ParityShards() {
+		sv.Close()
+		return nil, fmt.Errorf("%w: volume %d", ErrVolumeDegraded, info.ID)
+	}
+	sv.reader, err = codec.NewRangeReader(c, sv, info.ChunkSize, info.Size)
+	if err != nil {
+		sv.Close()
+		return nil, fmt.Errorf("%w: volume %d: %v", ErrCorruptMetadata, info.ID, err)
+	}
+	s.sealed[info.ID] = sv
+	return sv, nil
+}
+
+// ReadShardAt implements codec.ShardSource
+func (sv *sealedVolume) ReadShardAt(shard int, p []byte, off int64) (int, error) {
+	if shard < 0 || shard >= len(sv.files) || sv.files[shard] == nil {
+		return 0, codec.ErrShardUnavailable
+	}
+	return sv.files[shard].ReadAt(p, off)
+}
+
+// Close closes the shard files
+func (sv *sealedVolume) Close() error {
+	var errs []error
+	for _, f := range sv.files {
+		if f != nil {
+			errs = append(errs, f.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// removeSealed deletes the metadata and shards of a sealed volume;
+// metadata goes first so a crash never leaves a volume without shards
+func (s *Store) removeSealed(info *VolumeInfo) error {
+	if sv, ok := s.sealed[info.ID]; ok {
+		sv.Close()
+		delete(s.sealed, info.ID)
+	}
+	if err := os.Remove(s.metaPath(info.ID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for i, d := range info.Placement {
+		os.Remove(s.shardPath(d, info.ID, i))
+	}
+	delete(s.volumes, info.ID)
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file, syncs it and renames it
+// into place so readers never observe a partial file
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
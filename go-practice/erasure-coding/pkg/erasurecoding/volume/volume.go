@@ -0,0 +1,554 @@
+// Package volume packs many small objects into large append-only volumes
+// and erasure-codes whole volumes once they are sealed, in the style of
+// Facebook's Haystack and f4.
+//
+// Encoding a 1 KiB object on its own yields tiny shards whose per-file and
+// per-request overhead dwarfs the data. Here objects are instead appended as
+// "needles" to the active volume, a plain file. When it reaches
+// MaxVolumeSize the volume is sealed: encoded with the configured codec as
+// one big object (phase1 contiguous layout), its shards written to the shard
+// directories and the plain file removed. An in-memory index maps every key
+// to (volume, offset, length), so a read is one range read, which works
+// through codec.RangeReader even while shard directories are missing.
+//
+// Deletes append a tombstone needle and drop the key from the index; the
+// space is reclaimed by Compact, which copies the live needles of sparse
+// sealed volumes into the active volume and removes the old shards.
+//
+// Needles carry a store-wide sequence number and the index is rebuilt on
+// Open by scanning all volumes, newest sequence per key winning, so no
+// separate index file has to be kept consistent.
+//
+// On-disk layout:
+//
+//	<Dir>/vol-<id>.dat            active volume (needles)
+//	<Dir>/vol-<id>.json           sealed volume metadata
+//	<ShardDirs[d]>/vol-<id>.<i>   shard i of a sealed volume
+package volume
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/codec"
+)
+
+// VolumeError represents errors returned by the volume store
+type VolumeError struct {
+	message string
+}
+
+func (e *VolumeError) Error() string {
+	return e.message
+}
+
+// Common errors
+var (
+	ErrNoCodec         = &VolumeError{"a codec is required"}
+	ErrNotEnoughDirs   = &VolumeError{"fewer shard directories than shards per volume"}
+	ErrNotFound        = &VolumeError{"object not found"}
+	ErrInvalidKey      = &VolumeError{"key must be 1 to 65535 bytes"}
+	ErrObjectTooLarge  = &VolumeError{"object larger than the volume size"}
+	ErrCorruptNeedle   = &VolumeError{"corrupt needle"}
+	ErrChecksum        = &VolumeError{"object checksum mismatch"}
+	ErrVolumeDegraded  = &VolumeError{"too many shards of the volume are unavailable"}
+	ErrCorruptMetadata = &VolumeError{"corrupt volume metadata"}
+	ErrClosed          = &VolumeError{"store is closed"}
+)
+
+// DefaultMaxVolumeSize is used when Config.MaxVolumeSize is zero
+const DefaultMaxVolumeSize = 64 << 20
+
+// Config configures a Store
+type Config struct {
+	// Dir holds the active volume and sealed volume metadata
+	Dir string
+	// ShardDirs receive the shards of sealed volumes, one shard per
+	// directory (rotated per volume)
+	ShardDirs []string
+	// Codec encodes sealed volumes
+	Codec codec.Codec
+	// MaxVolumeSize seals the active volume once it reaches this size
+	MaxVolumeSize int64
+}
+
+// Location says where the data of an object lives
+type Location struct {
+	Volume uint32 `json:"volume"`
+	// Offset and Length locate the object data within the volume
+	Offset int64  `json:"offset"`
+	Length uint32 `json:"length"`
+	// Needle is the offset of the whole needle
+	Needle int64  `json:"needle"`
+	Seq    uint64 `json:"seq"`
+	crc    uint32
+}
+
+// VolumeInfo describes one volume
+type VolumeInfo struct {
+	ID     uint32 `json:"id"`
+	Sealed bool   `json:"sealed"`
+	// Size is the number of needle bytes in the volume
+	Size int64 `json:"size"`
+	// Live is the number of bytes of needles still referenced by the index
+	Live int64 `json:"live"`
+	// Codec, ChunkSize and Placement describe the shards of a sealed volume
+	Codec     string `json:"codec,omitempty"`
+	ChunkSize int    `json:"chunkSize,omitempty"`
+	Placement []int  `json:"placement,omitempty"`
+}
+
+// Store is a Haystack-style object store over erasure-coded volumes
+type Store struct {
+	cfg Config
+
+	mu      sync.Mutex
+	closed  bool
+	index   map[string]Location
+	puts    map[string]int // put needles physically present per key
+	volumes map[uint32]*VolumeInfo
+	sealed  map[uint32]*sealedVolume // opened lazily
+	active  *os.File
+	actID   uint32
+	seq     uint64
+}
+
+// Open opens or creates a store and rebuilds its index from the volumes
+//
+// Errors:
+//   - ErrNoCodec if cfg.Codec is nil
+//   - ErrNotEnoughDirs if there are fewer shard dirs than codec shards
+//   - ErrCorruptMetadata for unreadable sealed volume metadata
+func Open(cfg Config) (*Store, error) {
+	if cfg.Codec == nil {
+		return nil, ErrNoCodec
+	}
+	if len(cfg.ShardDirs) < codec.TotalShards(cfg.Codec) {
+		return nil, ErrNotEnoughDirs
+	}
+	if cfg.MaxVolumeSize <= 0 {
+		cfg.MaxVolumeSize = DefaultMaxVolumeSize
+	}
+	for _, dir := range append([]string{cfg.Dir}, cfg.ShardDirs...) {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &Store{
+		cfg:     cfg,
+		index:   map[string]Location{},
+		puts:    map[string]int{},
+		volumes: map[uint32]*VolumeInfo{},
+		sealed:  map[uint32]*sealedVolume{},
+	}
+	if err := s.load(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// load discovers volumes, replays their needles and opens the active volume
+func (s *Store) load() error {
+	entries, err := os.ReadDir(s.cfg.Dir)
+	if err != nil {
+		return err
+	}
+	var ids []uint32
+	seen := map[uint32]bool{}
+	for _, e := range entries {
+		var id uint32
+		var ext string
+		if n, _ := fmt.Sscanf(e.Name(), "vol-%08x.%s", &id, &ext); n != 2 || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	// best holds the newest record per key, tombstones included
+	type record struct {
+		loc       Location
+		tombstone bool
+	}
+	best := map[string]record{}
+	var activeIDs []uint32
+
+	for _, id := range ids {
+		info, r, limit, err := s.openForScan(id)
+		if err != nil {
+			return err
+		}
+		s.volumes[id] = info
+		end, err := scanNeedles(r, limit, func(n *needle, off int64) error {
+			if !info.Sealed && !s.checkCRC(r, n, off) {
+				return errTornTail
+			}
+			if n.seq > s.seq {
+				s.seq = n.seq
+			}
+			if n.flags&flagTombstone == 0 {
+				s.puts[n.key]++
+			}
+			loc := Location{Volume: id, Needle: off, Offset: off + n.dataOffset(), Length: n.dataLen, Seq: n.seq, crc: n.crc}
+			if cur, ok := best[n.key]; !ok || n.seq >= cur.loc.Seq {
+				best[n.key] = record{loc: loc, tombstone: n.flags&flagTombstone != 0}
+			}
+			return nil
+		})
+		if err != nil && err != errTornTail {
+			return err
+		}
+		if !info.Sealed {
+			info.Size = end
+			if f, ok := r.(*os.File); ok {
+				// Drop a torn tail so new appends start on a needle boundary
+				if err := f.Truncate(end); err != nil {
+					return err
+				}
+				f.Close()
+			}
+			activeIDs = append(activeIDs, id)
+		}
+	}
+
+	for key, rec := range best {
+		if rec.tombstone {
+			continue
+		}
+		s.index[key] = rec.loc
+		s.volumes[rec.loc.Volume].Live += int64(headerSize+len(key)) + int64(rec.loc.Length)
+	}
+
+	// Only the newest unsealed volume stays active; older ones (left by a
+	// crash during sealing) are sealed now
+	for i, id := range activeIDs {
+		if i < len(activeIDs)-1 {
+			if err := s.sealVolume(id); err != nil {
+				return err
+			}
+		}
+	}
+	if len(activeIDs) > 0 {
+		return s.openActive(activeIDs[len(activeIDs)-1])
+	}
+	next := uint32(1)
+	if len(ids) > 0 {
+		next = ids[len(ids)-1] + 1
+	}
+	return s.openActive(next)
+}
+
+// errTornTail stops a scan at the first needle whose data does not match
+// its checksum
+var errTornTail = errors.New("torn tail")
+
+// openForScan returns the volume info and a reader over its needles
+func (s *Store) openForScan(id uint32) (*VolumeInfo, io.ReaderAt, int64, error) {
+	raw, err := os.ReadFile(s.metaPath(id))
+	if err == nil {
+		info := &VolumeInfo{}
+		if err := json.Unmarshal(raw, info); err != nil || info.ID != id {
+			return nil, nil, 0, fmt.Errorf("%w: volume %d", ErrCorruptMetadata, id)
+		}
+		info.Sealed, info.Live = true, 0
+		// A crash after sealing may leave the plain file behind
+		os.Remove(s.dataPath(id))
+		sv, err := s.openSealed(info)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		return info, sv.reader, info.Size, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, nil, 0, err
+	}
+	f, err := os.OpenFile(s.dataPath(id), os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, 0, err
+	}
+	return &VolumeInfo{ID: id}, f, fi.Size(), nil
+}
+
+// checkCRC verifies the data of the needle at off
+func (s *Store) checkCRC(r io.ReaderAt, n *needle, off int64) bool {
+	data := make([]byte, n.dataLen)
+	if _, err := r.ReadAt(data, off+n.dataOffset()); err != nil {
+		return false
+	}
+	return crc32.ChecksumIEEE(data) == n.crc
+}
+
+// openActive makes volume id the append target, creating it if needed
+func (s *Store) openActive(id uint32) error {
+	f, err := os.OpenFile(s.dataPath(id), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if s.volumes[id] == nil {
+		s.volumes[id] = &VolumeInfo{ID: id}
+	}
+	s.volumes[id].Size = fi.Size()
+	s.active, s.actID = f, id
+	return nil
+}
+
+// Put stores data under key, replacing any previous object
+//
+// The needle is fsynced before Put returns. The active volume is sealed
+// first if data would not fit.
+//
+// Errors:
+//   - ErrInvalidKey for empty or over-long keys
+//   - ErrObjectTooLarge if the needle alone exceeds MaxVolumeSize
+func (s *Store) Put(key string, data []byte) error {
+	if len(key) == 0 || len(key) > 0xffff {
+		return ErrInvalidKey
+	}
+	if int64(headerSize+len(key)+len(data)) > s.cfg.MaxVolumeSize {
+		return ErrObjectTooLarge
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	loc, err := s.appendLocked(key, data, 0, 0)
+	if err != nil {
+		return err
+	}
+	s.setLive(key, loc)
+	s.puts[key]++
+	return nil
+}
+
+// appendLocked appends a needle to the active volume, sealing it first if
+// the needle does not fit. seq 0 allocates a new sequence number.
+func (s *Store) appendLocked(key string, data []byte, flags uint8, seq uint64) (Location, error) {
+	buf := int64(headerSize + len(key) + len(data))
+	if s.active == nil {
+		// A failed roll could not reopen the active volume; try again
+		if err := s.openActive(s.actID); err != nil {
+			return Location{}, err
+		}
+	}
+	info := s.volumes[s.actID]
+	if info.Size > 0 && info.Size+buf > s.cfg.MaxVolumeSize {
+		if err := s.rollLocked(); err != nil {
+			return Location{}, err
+		}
+		info = s.volumes[s.actID]
+	}
+	if seq == 0 {
+		s.seq++
+		seq = s.seq
+	}
+
+	rec := encodeNeedle(seq, flags, key, data)
+	off := info.Size
+	if _, err := s.active.WriteAt(rec, off); err != nil {
+		return Location{}, err
+	}
+	if err := s.active.Sync(); err != nil {
+		return Location{}, err
+	}
+	info.Size += int64(len(rec))
+	return Location{
+		Volume: s.actID,
+		Needle: off,
+		Offset: off + headerSize + int64(len(key)),
+		Length: uint32(len(data)),
+		Seq:    seq,
+		crc:    crc32.ChecksumIEEE(data),
+	}, nil
+}
+
+// setLive points key at loc and moves its live bytes between volumes
+func (s *Store) setLive(key string, loc Location) {
+	if old, ok := s.index[key]; ok {
+		s.volumes[old.Volume].Live -= int64(headerSize+len(key)) + int64(old.Length)
+	}
+	s.index[key] = loc
+	s.volumes[loc.Volume].Live += int64(headerSize+len(key)) + int64(loc.Length)
+}
+
+// Get returns the data stored under key
+//
+// Errors:
+//   - ErrNotFound if the key does not exist
+//   - ErrVolumeDegraded if too many shards of its volume are missing
+//   - ErrChecksum if the data does not match the needle's CRC
+func (s *Store) Get(key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, ErrClosed
+	}
+	loc, ok := s.index[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return s.readLocked(loc)
+}
+
+// readLocked reads the data at loc and verifies its checksum
+func (s *Store) readLocked(loc Location) ([]byte, error) {
+	var r io.ReaderAt = s.active
+	if loc.Volume != s.actID {
+		sv, err := s.openSealed(s.volumes[loc.Volume])
+		if err != nil {
+			return nil, err
+		}
+		r = sv.reader
+	}
+	data := make([]byte, loc.Length)
+	if _, err := r.ReadAt(data, loc.Offset); err != nil && !(err == io.EOF && len(data) == 0) {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(data) != loc.crc {
+		return nil, ErrChecksum
+	}
+	return data, nil
+}
+
+// Delete removes key by appending a tombstone
+//
+// Deleting a missing key is not an error. The space is reclaimed by
+// Compact.
+func (s *Store) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	old, ok := s.index[key]
+	if !ok {
+		return nil
+	}
+	if _, err := s.appendLocked(key, nil, flagTombstone, 0); err != nil {
+		return err
+	}
+	s.volumes[old.Volume].Live -= int64(headerSize+len(key)) + int64(old.Length)
+	delete(s.index, key)
+	return nil
+}
+
+// Locate returns where key is stored
+func (s *Store) Locate(key string) (Location, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	loc, ok := s.index[key]
+	return loc, ok
+}
+
+// Keys returns all stored keys in sorted order
+func (s *Store) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.index))
+	for k := range s.index {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Volumes returns a snapshot of every volume, oldest first
+func (s *Store) Volumes() []VolumeInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	infos := make([]VolumeInfo, 0, len(s.volumes))
+	for _, v := range s.volumes {
+		infos = append(infos, *v)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+// Seal seals the active volume now (if it holds any needle) and starts a
+// new one
+func (s *Store) Seal() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	if s.volumes[s.actID].Size == 0 {
+		return nil
+	}
+	return s.rollLocked()
+}
+
+// rollLocked seals the active volume and opens the next one
+//
+// The next volume is opened before the old one is sealed. If sealing fails
+// the next volume is discarded and the old one reopened, so the store stays
+// writable and the following append retries the roll.
+func (s *Store) rollLocked() error {
+	id, old := s.actID, s.active
+	if err := s.openActive(id + 1); err != nil {
+		return err
+	}
+	err := old.Close()
+	if err == nil {
+		err = s.sealVolume(id)
+	}
+	if err == nil || s.volumes[id] == nil || s.volumes[id].Sealed {
+		// Sealed (or dropped as empty) even if removing the plain file failed
+		return err
+	}
+
+	s.active.Close()
+	os.Remove(s.dataPath(id + 1))
+	delete(s.volumes, id+1)
+	s.active, s.actID = nil, id
+	return errors.Join(err, s.openActive(id))
+}
+
+// Close closes every open file
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	var errs []error
+	if s.active != nil {
+		errs = append(errs, s.active.Close())
+		s.active = nil
+	}
+	for id, sv := range s.sealed {
+		errs = append(errs, sv.Close())
+		delete(s.sealed, id)
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Store) dataPath(id uint32) string {
+	return filepath.Join(s.cfg.Dir, fmt.Sprintf("vol-%08x.dat", id))
+}
+
+func (s *Store) metaPath(id uint32) string {
+	return filepath.Join(s.cfg.Dir, fmt.Sprintf("vol-%08x.json", id))
+}
+
+func (s *Store) shardPath(d int, id uint32, shard int) string {
+	return filepath.Join(s.cfg.ShardDirs[d], fmt.Sprintf("vol-%08x.%d", id, shard))
+}
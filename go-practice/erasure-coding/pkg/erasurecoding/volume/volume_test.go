@@ -0,0 +1,275 @@
+package volume
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mokshesh/go-practice/erasure-coding/pkg/erasurecoding/codec"
+)
+
+// testConfig returns a config over fresh directories; shards spread over
+// numDirs directories
+func testConfig(t *testing.T, spec string, numDirs int, maxSize int64) Config {
+	t.Helper()
+	c, err := codec.Parse(spec)
+	if err != nil {
+		t.Fatalf("Parse(%q) error = %v", spec, err)
+	}
+	root := t.TempDir()
+	cfg := Config{Dir: filepath.Join(root, "volumes"), Codec: c, MaxVolumeSize: maxSize}
+	for i := 0; i < numDirs; i++ {
+		cfg.ShardDirs = append(cfg.ShardDirs, filepath.Join(root, fmt.Sprintf("disk%d", i)))
+	}
+	return cfg
+}
+
+func mustOpen(t *testing.T, cfg Config) *Store {
+	t.Helper()
+	s, err := Open(cfg)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func object(i int) []byte {
+	return bytes.Repeat([]byte(fmt.Sprintf("object %d;", i)), 20+i%7)
+}
+
+func checkAll(t *testing.T, s *Store, keys map[string][]byte) {
+	t.Helper()
+	for key, want := range keys {
+		got, err := s.Get(key)
+		if err != nil || !bytes.Equal(got, want) {
+			t.Fatalf("Get(%s) = %d bytes, %v", key, len(got), err)
+		}
+	}
+}
+
+func TestPutGet_AcrossSealedVolumes(t *testing.T) {
+	s := mustOpen(t, testConfig(t, "rs:4+2", 6, 4096))
+	keys := map[string][]byte{}
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("photos/%03d.jpg", i)
+		keys[key] = object(i)
+		if err := s.Put(key, keys[key]); err != nil {
+			t.Fatalf("Put(%s) error = %v", key, err)
+		}
+	}
+
+	sealed := 0
+	for _, v := range s.Volumes() {
+		if v.Sealed {
+			sealed++
+			if v.Codec != "rs:4+2" || len(v.Placement) != 6 || v.Size > 4096 {
+				t.Errorf("sealed volume = %+v", v)
+			}
+		}
+	}
+	if sealed < 3 {
+		t.Fatalf("%d sealed volumes, want several", sealed)
+	}
+	checkAll(t, s, keys)
+
+	loc, ok := s.Locate("photos/000.jpg")
+	if !ok || loc.Volume != 1 || loc.Length != uint32(len(keys["photos/000.jpg"])) {
+		t.Errorf("Locate() = %+v, %v", loc, ok)
+	}
+	if _, err := s.Get("nope"); err != ErrNotFound {
+		t.Errorf("Get(missing) error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestOpen_RebuildsIndex(t *testing.T) {
+	cfg := testConfig(t, "xor:3+1", 4, 2048)
+	s := mustOpen(t, cfg)
+	keys := map[string][]byte{}
+	for i := 0; i < 40; i++ {
+		key := fmt.Sprintf("k%d", i)
+		keys[key] = object(i)
+		s.Put(key, keys[key])
+	}
+	s.Put("k1", []byte("overwritten"))
+	keys["k1"] = []byte("overwritten")
+	s.Delete("k2")
+	delete(keys, "k2")
+	s.Close()
+
+	s = mustOpen(t, cfg)
+	checkAll(t, s, keys)
+	if _, err := s.Get("k2"); err != ErrNotFound {
+		t.Errorf("Get(deleted) after reopen error = %v, want %v", err, ErrNotFound)
+	}
+	if len(s.Keys()) != len(keys) {
+		t.Errorf("Keys() = %d keys, want %d", len(s.Keys()), len(keys))
+	}
+
+	// New writes continue after the highest sequence number
+	s.Put("k3", []byte("newest"))
+	s.Close()
+	s = mustOpen(t, cfg)
+	if got, _ := s.Get("k3"); string(got) != "newest" {
+		t.Errorf("Get(k3) = %q, want newest", got)
+	}
+}
+
+func TestGet_DegradedVolume(t *testing.T) {
+	cfg := testConfig(t, "rs:3+2", 5, 1024)
+	s := mustOpen(t, cfg)
+	keys := map[string][]byte{}
+	for i := 0; i < 30; i++ {
+		key := fmt.Sprintf("k%d", i)
+		keys[key] = object(i)
+		s.Put(key, keys[key])
+	}
+	s.Seal()
+	s.Close()
+
+	// Two disks fail: every sealed volume loses two shards
+	os.RemoveAll(cfg.ShardDirs[0])
+	os.RemoveAll(cfg.ShardDirs[3])
+	s = mustOpen(t, cfg)
+	checkAll(t, s, keys)
+
+	s.Close()
+	os.RemoveAll(cfg.ShardDirs[1])
+	if _, err := Open(cfg); !errors.Is(err, ErrVolumeDegraded) {
+		t.Errorf("Open() with 3 of 5 shards gone error = %v, want %v", err, ErrVolumeDegraded)
+	}
+}
+
+func TestOpen_TruncatesTornTail(t *testing.T) {
+	cfg := testConfig(t, "xor:2+1", 3, 1<<20)
+	s := mustOpen(t, cfg)
+	s.Put("a", []byte("intact"))
+	s.Put("b", []byte("will be torn"))
+	s.Close()
+
+	path := s.dataPath(1)
+	fi, _ := os.Stat(path)
+	os.Truncate(path, fi.Size()-3)
+
+	s = mustOpen(t, cfg)
+	if got, err := s.Get("a"); err != nil || string(got) != "intact" {
+		t.Errorf("Get(a) = %q, %v", got, err)
+	}
+	if _, err := s.Get("b"); err != ErrNotFound {
+		t.Errorf("Get(torn) error = %v, want %v", err, ErrNotFound)
+	}
+	if err := s.Put("c", []byte("after recovery")); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	s = mustOpen(t, cfg)
+	if got, _ := s.Get("c"); string(got) != "after recovery" {
+		t.Errorf("Get(c) = %q after torn-tail recovery", got)
+	}
+}
+
+func TestOpen_FinishesInterruptedSeal(t *testing.T) {
+	cfg := testConfig(t, "xor:2+1", 3, 1<<20)
+	s := mustOpen(t, cfg)
+	s.Put("a", []byte("sealed twice"))
+	s.Seal()
+	s.Close()
+
+	// Crash before the plain file was removed: both forms exist
+	os.WriteFile(s.dataPath(1), encodeNeedle(1, 0, "a", []byte("sealed twice")), 0o644)
+	s = mustOpen(t, cfg)
+	if got, err := s.Get("a"); err != nil || string(got) != "sealed twice" {
+		t.Errorf("Get(a) = %q, %v", got, err)
+	}
+	if _, err := os.Stat(s.dataPath(1)); !os.IsNotExist(err) {
+		t.Errorf("plain file of sealed volume still exists: %v", err)
+	}
+}
+
+func TestSeal_FailureKeepsStoreWritable(t *testing.T) {
+	cfg := testConfig(t, "xor:2+1", 3, 1<<20)
+	s := mustOpen(t, cfg)
+	keys := map[string][]byte{}
+	put := func(key string) {
+		t.Helper()
+		keys[key] = []byte("value of " + key)
+		if err := s.Put(key, keys[key]); err != nil {
+			t.Fatalf("Put(%s) error = %v", key, err)
+		}
+	}
+	put("a")
+
+	// A shard directory replaced by a file makes the shard writes fail
+	os.RemoveAll(cfg.ShardDirs[0])
+	os.WriteFile(cfg.ShardDirs[0], nil, 0o644)
+	if err := s.Seal(); err == nil {
+		t.Fatal("Seal() with a broken shard dir expected error")
+	}
+	if vols := s.Volumes(); len(vols) != 1 || vols[0].Sealed {
+		t.Errorf("Volumes() after failed seal = %+v", vols)
+	}
+	put("b")
+	checkAll(t, s, keys)
+
+	// A directory in the way of the next volume makes opening it fail
+	os.Remove(cfg.ShardDirs[0])
+	os.Mkdir(cfg.ShardDirs[0], 0o755)
+	os.Mkdir(s.dataPath(2), 0o755)
+	if err := s.Seal(); err == nil {
+		t.Fatal("Seal() with the next volume unopenable expected error")
+	}
+	put("c")
+	checkAll(t, s, keys)
+
+	os.Remove(s.dataPath(2))
+	if err := s.Seal(); err != nil {
+		t.Fatalf("Seal() after repair error = %v", err)
+	}
+	put("d")
+	s.Close()
+
+	s = mustOpen(t, cfg)
+	checkAll(t, s, keys)
+	if vols := s.Volumes(); len(vols) != 2 || !vols[0].Sealed || vols[1].ID != 2 {
+		t.Errorf("Volumes() after reopen = %+v", vols)
+	}
+}
+
+func TestPut_Validation(t *testing.T) {
+	s := mustOpen(t, testConfig(t, "xor:2+1", 3, 256))
+	if err := s.Put("", []byte("x")); err != ErrInvalidKey {
+		t.Errorf("Put(empty key) error = %v, want %v", err, ErrInvalidKey)
+	}
+	if err := s.Put("big", make([]byte, 300)); err != ErrObjectTooLarge {
+		t.Errorf("Put(300 B into 256 B volume) error = %v, want %v", err, ErrObjectTooLarge)
+	}
+	if _, err := Open(Config{Dir: t.TempDir(), Codec: s.cfg.Codec, ShardDirs: []string{"a"}}); err != ErrNotEnoughDirs {
+		t.Errorf("Open(1 dir) error = %v, want %v", err, ErrNotEnoughDirs)
+	}
+	s.Close()
+	if err := s.Put("k", nil); err != ErrClosed {
+		t.Errorf("Put() after Close error = %v, want %v", err, ErrClosed)
+	}
+}
+
+// Benchmark appending 1 KiB objects
+func BenchmarkPut1K(b *testing.B) {
+	c, _ := codec.NewReedSolomon(4, 2)
+	root := b.TempDir()
+	cfg := Config{Dir: root, Codec: c, MaxVolumeSize: 8 << 20}
+	for i := 0; i < 6; i++ {
+		cfg.ShardDirs = append(cfg.ShardDirs, filepath.Join(root, fmt.Sprint(i)))
+	}
+	s, _ := Open(cfg)
+	defer s.Close()
+	data := make([]byte, 1024)
+	b.SetBytes(1024)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_ = s.Put(fmt.Sprint(i), data)
+	}
+}